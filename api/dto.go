@@ -3,6 +3,8 @@ package api
 import (
 	"fmt"
 	"math"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -19,31 +21,499 @@ type (
 	}
 
 	Project struct {
+		// ID identifies the project regardless of renames. Wherever a project name is
+		// expected, the ID or a former name can be given instead.
+		ID          string `json:"id,omitempty"`
 		Name        string `json:"name"`
 		Description string `json:"description"`
 		Billable    bool   `json:"billable"`
+		// Client is the name of the client the project is billed to, or empty
+		Client string  `json:"client,omitempty"`
+		Rates  []*Rate `json:"rates,omitempty"`
+		// Budget limits the time or money spent on the project
+		Budget *Budget `json:"budget,omitempty"`
+		// Aliases are the former names of the project. They are ignored in updates.
+		Aliases []string `json:"aliases,omitempty"`
+		// Archived projects are only listed on request and take no new entries. The
+		// archive and unarchive endpoints change it; it is ignored in updates.
+		Archived bool `json:"archived"`
+	}
+
+	// Budget limits the worked time, the money or both that can be spent on a project.
+	// Period is "total" (the default), "week" or "month". Thresholds are percentages of
+	// the budget that trigger warnings, 80 and 100 if none are given.
+	Budget struct {
+		Hours      time.Duration `json:"hours,omitempty"`
+		Amount     float64       `json:"amount,omitempty"`
+		Currency   string        `json:"currency,omitempty"`
+		Period     string        `json:"period"`
+		Thresholds []int         `json:"thresholds,omitempty"`
+	}
+
+	// BudgetStatus is how much of a project's budget is used in the current period. From
+	// and To are not set for total budgets. Used is a percentage, and Reached lists the
+	// thresholds it has reached.
+	BudgetStatus struct {
+		Project         string        `json:"project"`
+		Budget          *Budget       `json:"budget"`
+		From            *time.Time    `json:"from,omitempty"`
+		To              *time.Time    `json:"to,omitempty"`
+		Hours           time.Duration `json:"hours"`
+		RemainingHours  time.Duration `json:"remaining_hours"`
+		Amount          float64       `json:"amount"`
+		RemainingAmount float64       `json:"remaining_amount"`
+		Unpriced        time.Duration `json:"unpriced"`
+		Used            float64       `json:"used"`
+		Reached         []int         `json:"reached,omitempty"`
+	}
+
+	// BudgetNotification is sent to the budget webhook of the server when recorded time
+	// makes a project's budget reach one of its thresholds
+	BudgetNotification struct {
+		UserID    string        `json:"user_id"`
+		Threshold int           `json:"threshold"`
+		Status    *BudgetStatus `json:"status"`
+	}
+
+	// Client is a customer that projects are billed to. Its rates apply to all its
+	// projects, unless a project has a rate of its own.
+	Client struct {
+		Name        string  `json:"name"`
+		Description string  `json:"description"`
+		Rates       []*Rate `json:"rates,omitempty"`
+	}
+
+	// Task is a part of a project that time can be recorded on
+	Task struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	// Rate is an hourly rate that applies from its From date, given as YYYY-MM-DD, until
+	// a later rate takes over. A rate with an entry type only applies to entries of that
+	// type, and wins over rates for all types.
+	Rate struct {
+		EntryType string  `json:"entry_type,omitempty"`
+		Amount    float64 `json:"amount"`
+		Currency  string  `json:"currency"`
+		From      string  `json:"from,omitempty"`
+	}
+
+	// BillingLine is the time in one project and of one entry type that is billed at
+	// the same rate
+	BillingLine struct {
+		Client    string        `json:"client"`
+		Project   string        `json:"project"`
+		EntryType string        `json:"entry_type"`
+		Rate      float64       `json:"rate"`
+		Currency  string        `json:"currency"`
+		Hours     time.Duration `json:"hours"`
+		Amount    float64       `json:"amount"`
+	}
+
+	// Billing is what the time in a date range amounts to when billed. Totals holds the
+	// amount per currency, and Unpriced the billable time that no rate applies to.
+	Billing struct {
+		From     *time.Time         `json:"from"`
+		To       *time.Time         `json:"to"`
+		Lines    []*BillingLine     `json:"lines"`
+		Totals   map[string]float64 `json:"totals"`
+		Unpriced time.Duration      `json:"unpriced"`
 	}
 
 	TimeEntry struct {
-		ID          string        `json:"id"`
-		ProjectName string        `json:"project_name"`
-		Type        string        `json:"type"`
-		Start       *time.Time    `json:"start"`
-		End         *time.Time    `json:"end"`
-		Breaks      time.Duration `json:"breaks"`
-		Comment     string        `json:"comment"`
+		ID          string `json:"id"`
+		ProjectName string `json:"project_name"`
+		// Task is the name of one of the project's tasks, or empty
+		Task    string        `json:"task,omitempty"`
+		Type    string        `json:"type"`
+		Start   *time.Time    `json:"start"`
+		End     *time.Time    `json:"end"`
+		Breaks  time.Duration `json:"breaks"`
+		Comment string        `json:"comment"`
+		// Tags are merged with the #hashtags in the comment by the server
+		Tags []string `json:"tags,omitempty"`
+		// InvoiceNumber is set by the server once the entry has been invoiced. Invoiced
+		// entries cannot be changed or deleted.
+		InvoiceNumber int `json:"invoice_number,omitempty"`
+	}
+
+	// InvoiceLine is a line item of an invoice
+	InvoiceLine struct {
+		Project     string        `json:"project"`
+		Description string        `json:"description"`
+		Hours       time.Duration `json:"hours"`
+		Rate        float64       `json:"rate"`
+		Currency    string        `json:"currency"`
+		Amount      float64       `json:"amount"`
+	}
+
+	// Invoice is an immutable record of invoiced time, numbered in sequence. Totals holds
+	// the amount per currency.
+	Invoice struct {
+		Number int                `json:"number"`
+		Issued time.Time          `json:"issued"`
+		Client string             `json:"client,omitempty"`
+		From   *time.Time         `json:"from"`
+		To     *time.Time         `json:"to"`
+		Lines  []*InvoiceLine     `json:"lines"`
+		Totals map[string]float64 `json:"totals"`
+	}
+
+	// InvoiceRequest creates an invoice for the uninvoiced time in the named billable
+	// projects that starts in the period. GroupBy is "project" (the default) or "comment".
+	InvoiceRequest struct {
+		Projects []string   `json:"projects"`
+		From     *time.Time `json:"from"`
+		To       *time.Time `json:"to"`
+		GroupBy  string     `json:"group_by"`
+	}
+
+	SummaryGroup struct {
+		Key         string                   `json:"key"`
+		Worked      time.Duration            `json:"worked"`
+		Billable    time.Duration            `json:"billable"`
+		NonBillable time.Duration            `json:"non_billable"`
+		Hours       map[string]time.Duration `json:"hours"`
+		Days        map[string]int           `json:"days"`
+	}
+
+	// EntryType describes a kind of time entry. Entries of time-based types are recorded
+	// from start to end; other entries cover whole days.
+	EntryType struct {
+		Name             string `json:"name"`
+		TimeBased        bool   `json:"time_based"`
+		CountsAsWork     bool   `json:"counts_as_work"`
+		FulfillsSchedule bool   `json:"fulfills_schedule"`
+		Color            string `json:"color"`
+	}
+
+	// Feed locates the read-only calendar feed. The path contains the secret feed token
+	// and is relative to the server's base URL.
+	Feed struct {
+		Path string `json:"path"`
+	}
+
+	// FieldError is a validation rule that a field of a request violates
+	FieldError struct {
+		Field   string `json:"field"`
+		Rule    string `json:"rule"`
+		Message string `json:"message"`
+	}
+
+	// ValidationError is the body of 422 responses. It lists every rule that was violated.
+	ValidationError struct {
+		Message string        `json:"message"`
+		Errors  []*FieldError `json:"errors"`
+	}
+
+	// TimerStart is the result of starting a timer. Stopped holds the timers that were
+	// stopped because the user's timer policy is auto-stop.
+	TimerStart struct {
+		Started *TimeEntry   `json:"started"`
+		Stopped []*TimeEntry `json:"stopped"`
+	}
+
+	// TimerStop closes the running timer. If ProjectName is given, only a timer for that
+	// project is stopped. End defaults to the current time.
+	TimerStop struct {
+		ProjectName string         `json:"project_name"`
+		End         *time.Time     `json:"end"`
+		Breaks      *time.Duration `json:"breaks"`
+	}
+
+	UserSettings struct {
+		TimerPolicy string `json:"timer_policy"`
+	}
+
+	Summary struct {
+		GroupBy string          `json:"group_by"`
+		From    *time.Time      `json:"from"`
+		To      *time.Time      `json:"to"`
+		Groups  []*SummaryGroup `json:"groups"`
+		Total   *SummaryGroup   `json:"total"`
+	}
+
+	// Schedule is the time a user is expected to work on each weekday, starting with
+	// Monday. It applies from the day in From, formatted as YYYY-MM-DD, until a later
+	// schedule takes over.
+	Schedule struct {
+		From  string          `json:"from"`
+		Hours []time.Duration `json:"hours"`
+	}
+
+	// BalanceAdjustment adds Amount, which may be negative, to the flex balance on the
+	// day in Date, formatted as YYYY-MM-DD
+	BalanceAdjustment struct {
+		ID      string        `json:"id,omitempty"`
+		Date    string        `json:"date"`
+		Amount  time.Duration `json:"amount"`
+		Comment string        `json:"comment"`
+	}
+
+	// BalancePeriod compares the expected and recorded time of a day, week or month.
+	// Running is the flex balance at the end of the period.
+	BalancePeriod struct {
+		Key         string        `json:"key"`
+		Expected    time.Duration `json:"expected"`
+		Worked      time.Duration `json:"worked"`
+		Absence     time.Duration `json:"absence"`
+		Adjustments time.Duration `json:"adjustments"`
+		Balance     time.Duration `json:"balance"`
+		Running     time.Duration `json:"running"`
+	}
+
+	// Balance is the flex balance from the day in From to the day in To, inclusive.
+	// Opening is the balance before From; the running balance of Total is the closing
+	// balance.
+	Balance struct {
+		GroupBy string           `json:"group_by"`
+		From    string           `json:"from"`
+		To      string           `json:"to"`
+		Opening time.Duration    `json:"opening"`
+		Periods []*BalancePeriod `json:"periods"`
+		Total   *BalancePeriod   `json:"total"`
+	}
+
+	// Allowance is the number of days of a whole-day entry type that may be taken each
+	// year, from the year in From until a later allowance for the type takes over. Up to
+	// CarryOver unused days are carried over into the next year.
+	Allowance struct {
+		Type      string `json:"type"`
+		From      int    `json:"from"`
+		Days      int    `json:"days"`
+		CarryOver int    `json:"carry_over"`
+	}
+
+	// AllowanceStatus is how many days of an allowance are left in a year. Weekends and
+	// holidays do not count as used.
+	AllowanceStatus struct {
+		Type        string `json:"type"`
+		Year        int    `json:"year"`
+		Allowance   int    `json:"allowance"`
+		CarriedOver int    `json:"carried_over"`
+		Used        int    `json:"used"`
+		Remaining   int    `json:"remaining"`
+	}
+
+	// Holiday is a day off, formatted as YYYY-MM-DD, that does not use up allowances
+	Holiday struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
 	}
 )
 
 func (p *Project) String() string {
-	return fmt.Sprintf("Project: %s (%s) (billable: %t)", p.Name, p.Description, p.Billable)
+	result := fmt.Sprintf("Project: %s (%s) (billable: %t)", p.Name, p.Description, p.Billable)
+	if p.Client != "" {
+		result += fmt.Sprintf(" (client: %s)", p.Client)
+	}
+	if p.Budget != nil {
+		result += fmt.Sprintf(" (budget: %s)", p.Budget)
+	}
+	if len(p.Aliases) > 0 {
+		result += fmt.Sprintf(" (formerly: %s)", strings.Join(p.Aliases, ", "))
+	}
+	if p.Archived {
+		result += " (archived)"
+	}
+	return result + formatRates(p.Rates)
+}
+
+func (b *Budget) String() string {
+	var limits []string
+	if b.Hours > 0 {
+		limits = append(limits, formatBudgetHours(b.Hours)+"h")
+	}
+	if b.Amount > 0 {
+		limits = append(limits, fmt.Sprintf("%.2f %s", b.Amount, b.Currency))
+	}
+	result := strings.Join(limits, " and ")
+	if b.Period != "" && b.Period != "total" {
+		result += " per " + b.Period
+	}
+	return result
+}
+
+func formatBudgetHours(d time.Duration) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", d.Hours()), "0"), ".")
+}
+
+func (s *BudgetStatus) String() string {
+	result := fmt.Sprintf("Budget of %s: %.0f%% used", s.Project, s.Used)
+	if s.Budget.Hours > 0 {
+		result += fmt.Sprintf(", %sh of %sh worked", formatBudgetHours(s.Hours), formatBudgetHours(s.Budget.Hours))
+	}
+	if s.Budget.Amount > 0 {
+		result += fmt.Sprintf(", %.2f of %.2f %s spent", s.Amount, s.Budget.Amount, s.Budget.Currency)
+		if s.Unpriced > 0 {
+			result += fmt.Sprintf(" (%sh unpriced)", formatBudgetHours(s.Unpriced))
+		}
+	}
+	if s.From != nil && s.To != nil {
+		result += fmt.Sprintf(" (%s - %s)", s.From.Format("2006-01-02"), s.To.Add(-time.Nanosecond).Format("2006-01-02"))
+	}
+	return result
+}
+
+// Warning describes the highest threshold that the budget has reached, or is empty if
+// it has reached none
+func (s *BudgetStatus) Warning() string {
+	if len(s.Reached) == 0 {
+		return ""
+	}
+	threshold := s.Reached[len(s.Reached)-1]
+	if threshold >= 100 {
+		return fmt.Sprintf("Warning: the budget of %s is used up - %s", s.Project, s)
+	}
+	return fmt.Sprintf("Warning: %d%% of the budget of %s is used - %s", threshold, s.Project, s)
+}
+
+func (s *Schedule) String() string {
+	days := []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+	var hours []string
+	for i, d := range s.Hours {
+		if i < len(days) {
+			hours = append(hours, fmt.Sprintf("%s %sh", days[i], formatBudgetHours(d)))
+		}
+	}
+	return fmt.Sprintf("From %s: %s", s.From, strings.Join(hours, ", "))
+}
+
+func (a *BalanceAdjustment) String() string {
+	result := fmt.Sprintf("%s %-10s %sh", a.ID, a.Date, formatBudgetHours(a.Amount))
+	if a.Comment != "" {
+		result += " " + a.Comment
+	}
+	return result
+}
+
+func (a *Allowance) String() string {
+	return fmt.Sprintf("%-16s from %d: %d days, up to %d carried over", a.Type, a.From, a.Days, a.CarryOver)
+}
+
+func (s *AllowanceStatus) String() string {
+	return fmt.Sprintf("%s %d: %d of %d days left (%d days allowed, %d carried over, %d used)",
+		s.Type, s.Year, s.Remaining, s.Allowance+s.CarriedOver, s.Allowance, s.CarriedOver, s.Used)
+}
+
+func (h *Holiday) String() string {
+	return fmt.Sprintf("%s %s", h.Date, h.Name)
+}
+
+func (c *Client) String() string {
+	return fmt.Sprintf("Client: %s (%s)", c.Name, c.Description) + formatRates(c.Rates)
+}
+
+func (t *Task) String() string {
+	return fmt.Sprintf("Task: %s (%s)", t.Name, t.Description)
+}
+
+func (r *Rate) String() string {
+	result := fmt.Sprintf("%.2f %s/h", r.Amount, r.Currency)
+	if r.EntryType != "" {
+		result += " for " + r.EntryType
+	}
+	if r.From != "" {
+		result += " from " + r.From
+	}
+	return result
+}
+
+// formatRates lists rates on lines of their own
+func formatRates(rates []*Rate) string {
+	var result string
+	for _, rate := range rates {
+		result += "\n  Rate: " + rate.String()
+	}
+	return result
+}
+
+func (l *BillingLine) String() string {
+	return fmt.Sprintf("%-16s %-16s %-10s %8.2fh x %8.2f = %10.2f %s",
+		l.Client, l.Project, l.EntryType, l.Hours.Hours(), l.Rate, l.Amount, l.Currency)
+}
+
+func (l *InvoiceLine) String() string {
+	return fmt.Sprintf("%-16s %-30s %8.2fh x %8.2f = %10.2f %s",
+		l.Project, l.Description, l.Hours.Hours(), l.Rate, l.Amount, l.Currency)
+}
+
+func (inv *Invoice) String() string {
+	result := fmt.Sprintf("Invoice %d issued %s", inv.Number, inv.Issued.In(time.Local).Format("2006-01-02"))
+	if inv.Client != "" {
+		result += fmt.Sprintf(" (client: %s)", inv.Client)
+	}
+	for _, currency := range sortedCurrencies(inv.Totals) {
+		result += fmt.Sprintf(" %.2f %s", inv.Totals[currency], currency)
+	}
+	return result
+}
+
+// sortedCurrencies returns the currencies of totals in alphabetical order
+func sortedCurrencies(totals map[string]float64) []string {
+	var result []string
+	for currency := range totals {
+		result = append(result, currency)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (s *UserSettings) String() string {
+	return fmt.Sprintf("Timer policy: %s", s.TimerPolicy)
+}
+
+// entryTypes decides how entries are formatted, see SetEntryTypes
+var entryTypes map[string]*EntryType
+
+// SetEntryTypes sets the entry types that decide how entries are formatted
+func SetEntryTypes(types []*EntryType) {
+	entryTypes = make(map[string]*EntryType)
+	for _, t := range types {
+		entryTypes[t.Name] = t
+	}
+}
+
+// IsTimeBased tells whether entries of the named type are recorded as hours. Types that
+// were not given to SetEntryTypes are time-based only if they are work.
+func IsTimeBased(entryType string) bool {
+	if t, ok := entryTypes[entryType]; ok {
+		return t.TimeBased
+	}
+	return entryType == "work"
+}
+
+// IsWork tells whether entries of the named type are time-based and count as worked time.
+// Types that were not given to SetEntryTypes count as work only if they are work.
+func IsWork(entryType string) bool {
+	if t, ok := entryTypes[entryType]; ok {
+		return t.TimeBased && t.CountsAsWork
+	}
+	return entryType == "work"
+}
+
+func (t *EntryType) String() string {
+	kind := "whole days"
+	if t.TimeBased {
+		kind = "time-based"
+	}
+	work := ""
+	if t.CountsAsWork {
+		work = "counts as work"
+	}
+	schedule := ""
+	if t.FulfillsSchedule {
+		schedule = "fulfills schedule"
+	}
+	return fmt.Sprintf("%-16s %-10s %-14s %-17s %s", t.Name, kind, work, schedule, t.Color)
 }
 
 func formatEntryTime(entryType string, entryTime *time.Time) string {
 	if entryTime == nil {
 		return "                "
 	}
-	if entryType == "work" {
+	if IsTimeBased(entryType) {
 		return entryTime.In(time.Local).Format("2006-01-02 15:04")
 	} else {
 		return entryTime.In(time.Local).Format("2006-01-02      ")
@@ -51,7 +521,7 @@ func formatEntryTime(entryType string, entryTime *time.Time) string {
 }
 
 func formatEntryBreaks(entryType string, breaks time.Duration) string {
-	if entryType == "work" && breaks > 0 {
+	if IsTimeBased(entryType) && breaks > 0 {
 		seconds := int64(breaks.Seconds())
 		return fmt.Sprintf("(-%2dh%2dm)", seconds/3600, int(math.Round(float64(seconds%3600)/60.0)))
 	}
@@ -61,7 +531,7 @@ func formatEntryBreaks(entryType string, breaks time.Duration) string {
 func formatEntryDuration(e *TimeEntry) string {
 	duration := e.Duration()
 
-	if e.Type == "work" {
+	if IsTimeBased(e.Type) {
 		seconds := int64(duration.Seconds())
 		if seconds > 86400 {
 			return fmt.Sprintf("%2dd%2dh  ", seconds/86400, int(math.Round(float64(seconds%86400)/60.0)))
@@ -69,8 +539,7 @@ func formatEntryDuration(e *TimeEntry) string {
 			return fmt.Sprintf("  %2dh%2dm", seconds/3600, int(math.Round(float64(seconds%3600)/60.0)))
 		}
 	} else {
-		days := int(math.Round(duration.Round(24*time.Hour).Hours()/24 + 1))
-		return fmt.Sprintf("%2dd     ", days)
+		return fmt.Sprintf("%2dd     ", e.Days())
 	}
 }
 
@@ -88,6 +557,36 @@ func (e *TimeEntry) Duration() time.Duration {
 	return duration
 }
 
+// Days is the number of calendar days covered by an entry that is not time-based
+func (e *TimeEntry) Days() int {
+	return int(math.Round(e.Duration().Round(24*time.Hour).Hours()/24 + 1))
+}
+
+// formatEntryComment is the comment followed by the tags that are not already hashtags in it
+func formatEntryComment(e *TimeEntry) string {
+	result := e.Comment
+	lowerComment := strings.ToLower(e.Comment)
+	for _, tag := range e.Tags {
+		if !strings.Contains(lowerComment, "#"+tag) {
+			result = strings.TrimSpace(result + " #" + tag)
+		}
+	}
+	return result
+}
+
+// ProjectPath is the project name, followed by a slash and the task name if the entry
+// has a task. A project name that contains a slash gets a trailing slash if there is no
+// task, so that the path is not read back as a project and a task.
+func (e *TimeEntry) ProjectPath() string {
+	if e.Task == "" && strings.Contains(e.ProjectName, "/") {
+		return e.ProjectName + "/"
+	}
+	if e.Task == "" {
+		return e.ProjectName
+	}
+	return e.ProjectName + "/" + e.Task
+}
+
 func (e *TimeEntry) String() string {
 	return fmt.Sprintf("%s %s - %s %s (%s): %s %s %s",
 		e.ID,
@@ -95,7 +594,7 @@ func (e *TimeEntry) String() string {
 		formatEntryTime(e.Type, e.End),
 		formatEntryBreaks(e.Type, e.Breaks),
 		formatEntryDuration(e),
-		e.ProjectName,
+		e.ProjectPath(),
 		e.Type,
-		e.Comment)
+		formatEntryComment(e))
 }
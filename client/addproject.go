@@ -1,8 +1,6 @@
 package client
 
 import (
-	"fmt"
-
 	"github.com/spf13/cobra"
 )
 
@@ -10,6 +8,8 @@ func init() {
 	rootCmd.AddCommand(addProjectCmd)
 	addProjectCmd.Flags().BoolVarP(&commandLineProject.Billable, "billable", "b", false, "Mark a project as billable")
 	addProjectCmd.Flags().StringVarP(&commandLineProject.Description, "description", "d", "", "Project description")
+	addProjectCmd.Flags().StringVar(&commandLineProject.Client, "client", "", "Client the project is billed to")
+	addBudgetFlags(addProjectCmd)
 }
 
 var addProjectCmd = &cobra.Command{
@@ -18,23 +18,22 @@ var addProjectCmd = &cobra.Command{
 	Long:  `Add the specified project`,
 	Args:  cobra.ExactArgs(1),
 	Run: runCommand(func(cmd *cobra.Command, args []string) error {
-		return addProject(args[0])
+		return addProject(cmd, args[0])
 	}),
 }
 
-func addProject(projectName string) error {
+func addProject(cmd *cobra.Command, projectName string) error {
 	apiClient, err := NewApiClient()
 	if err != nil {
 		return err
 	}
 
 	commandLineProject.Name = projectName
+	commandLineProject.Budget = budgetFromFlags(cmd, nil)
 
 	project, err := apiClient.CreateProject(&commandLineProject)
 	if err != nil {
 		return err
 	}
-	fmt.Println(project)
-
-	return nil
+	return printRecord(project)
 }
@@ -2,7 +2,7 @@ package client
 
 import (
 	"errors"
-	"fmt"
+	"strings"
 	"time"
 
 	"github.com/kennep/timelapse/api"
@@ -14,20 +14,24 @@ var endTime string
 var breaks string
 var entryType string
 var entryComment string
+var entryTags []string
+var allowOverlap bool
 
 func init() {
 	rootCmd.AddCommand(addTimeEntryCmd)
 	addTimeEntryCmd.Flags().StringVarP(&startTime, "start", "s", "", "Entry start time/date")
 	addTimeEntryCmd.Flags().StringVarP(&endTime, "end", "e", "", "Entry end time/date")
 	addTimeEntryCmd.Flags().StringVarP(&breaks, "breaks", "b", "", "Break duration")
-	addTimeEntryCmd.Flags().StringVarP(&entryType, "type", "t", "work", "Entry type (work|sick|sick-child|vacation)")
+	addTimeEntryCmd.Flags().StringVarP(&entryType, "type", "t", "work", "Entry type, see list-entry-types")
 	addTimeEntryCmd.Flags().StringVarP(&entryComment, "comment", "c", "", "Entry comment")
+	addTimeEntryCmd.Flags().StringSliceVar(&entryTags, "tag", nil, "Tag the entry; can be repeated or comma separated. #hashtags in the comment are tags too")
+	addTimeEntryCmd.Flags().BoolVar(&allowOverlap, "allow-overlap", false, "Add the entry even if it overlaps other work")
 }
 
 var addTimeEntryCmd = &cobra.Command{
-	Use:   "add-entry PROJECTNAME [-s START] [-e END]",
+	Use:   "add-entry PROJECTNAME[/TASKNAME] [-s START] [-e END]",
 	Short: "Add time entry",
-	Long:  `Add a time entry for a given project`,
+	Long:  "Add a time entry for a given project, optionally on one of its tasks.\n" + projectTaskHelp,
 	Args:  cobra.ExactArgs(1),
 	Run: runCommand(func(cmd *cobra.Command, args []string) error {
 		return addTimeEntry(args[0])
@@ -44,15 +48,18 @@ func addTimeEntry(projectName string) error {
 		return err
 	}
 
+	projectName, taskName := splitProjectTask(projectName)
 	start := time.Now()
 	end := time.Now()
 
 	entry := api.TimeEntry{
+		Task:    taskName,
 		Start:   &start,
 		End:     &end,
 		Breaks:  time.Duration(0),
 		Type:    entryType,
 		Comment: entryComment,
+		Tags:    commandLineTags(),
 	}
 
 	if startTime != "" {
@@ -76,21 +83,33 @@ func addTimeEntry(projectName string) error {
 		}
 	}
 
-	if entryType != "work" {
+	if !api.IsTimeBased(entryType) {
 		*entry.Start = normalizeTime(*entry.Start)
 		*entry.End = normalizeTime(*entry.End)
 	}
 
-	result, err := apiClient.AddTimeEntry(projectName, &entry)
+	result, err := addOrRecordTimeEntry(apiClient, projectName, &entry)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(result)
-
-	return nil
+	return printRecord(result)
 }
 
 func normalizeTime(t time.Time) time.Time {
 	return time.Date(t.Year(), t.Month(), t.Day(), 12, 0, 0, 0, t.Location())
 }
+
+// commandLineTags returns the tags given with --tag
+func commandLineTags() []string {
+	return splitTags(strings.Join(entryTags, " "))
+}
+
+// splitTags reads tags separated by spaces or commas. A leading # is removed.
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' }) {
+		tags = append(tags, strings.TrimPrefix(tag, "#"))
+	}
+	return tags
+}
@@ -0,0 +1,282 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+var (
+	allowanceYear      int
+	allowanceFrom      int
+	allowanceCarryOver int
+)
+
+func init() {
+	rootCmd.AddCommand(allowanceCmd)
+	allowanceCmd.Flags().IntVar(&allowanceYear, "year", 0, "Year to show (default this year)")
+	rootCmd.AddCommand(setAllowanceCmd)
+	setAllowanceCmd.Flags().IntVar(&allowanceFrom, "from", 0, "First year the allowance applies (default this year)")
+	setAllowanceCmd.Flags().IntVar(&allowanceCarryOver, "carry-over", 0, "Most unused days carried over into the next year")
+	rootCmd.AddCommand(listAllowancesCmd)
+	rootCmd.AddCommand(deleteAllowanceCmd)
+	deleteAllowanceCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+
+	rootCmd.AddCommand(addHolidayCmd)
+	rootCmd.AddCommand(listHolidaysCmd)
+	rootCmd.AddCommand(deleteHolidayCmd)
+	deleteHolidayCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+}
+
+var allowanceCmd = &cobra.Command{
+	Use:   "allowance [--year YEAR]",
+	Short: "Show how many days off are left",
+	Long: `Show how many days of each allowance, like vacation, are left in a year. Days
+are counted like whole-day entries are, but weekends and holidays are not used up.
+Unused days are carried over from the year before, up to the carry-over limit of
+the allowance.`,
+	Args: cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return showAllowances()
+	}),
+}
+
+var setAllowanceCmd = &cobra.Command{
+	Use:   "set-allowance TYPENAME DAYS [--from YEAR] [--carry-over DAYS]",
+	Short: "Set the yearly allowance of an entry type",
+	Long: `Set the number of days of a whole-day entry type, like vacation or sick-child,
+that may be taken each year. The allowance applies from the --from year until a
+later allowance for the type takes over. Adding entries beyond the allowance
+gives a warning.`,
+	Args: cobra.ExactArgs(2),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return setAllowance(args[0], args[1])
+	}),
+}
+
+var listAllowancesCmd = &cobra.Command{
+	Use:   "list-allowances",
+	Short: "List yearly allowances",
+	Args:  cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return listAllowances()
+	}),
+}
+
+var deleteAllowanceCmd = &cobra.Command{
+	Use:   "delete-allowance TYPENAME YEAR",
+	Short: "Delete the allowance of an entry type that applies from a year",
+	Args:  cobra.ExactArgs(2),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return deleteAllowance(args[0], args[1])
+	}),
+}
+
+var addHolidayCmd = &cobra.Command{
+	Use:   "add-holiday DATE [NAME]",
+	Short: "Add a holiday",
+	Long: `Add a holiday, which does not use up allowances. Adding a holiday on a day that
+already has one renames it.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return addHoliday(args[0], strings.Join(args[1:], " "))
+	}),
+}
+
+var listHolidaysCmd = &cobra.Command{
+	Use:   "list-holidays",
+	Short: "List holidays",
+	Args:  cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return listHolidays()
+	}),
+}
+
+var deleteHolidayCmd = &cobra.Command{
+	Use:   "delete-holiday DATE",
+	Short: "Delete a holiday",
+	Args:  cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return deleteHoliday(args[0])
+	}),
+}
+
+func showAllowances() error {
+	year := allowanceYear
+	if year == 0 {
+		year = time.Now().Year()
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+	statuses, err := apiClient.GetRemainingAllowances(year)
+	if err != nil {
+		return err
+	}
+
+	if outputFormat == outputTable {
+		printAllowanceTable(statuses)
+		return nil
+	}
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if err := p.Print(status); err != nil {
+			return err
+		}
+	}
+	return p.Flush()
+}
+
+func printAllowanceTable(statuses []*api.AllowanceStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "TYPE\tYEAR\tALLOWANCE\tCARRIED OVER\tUSED\tREMAINING\t\n")
+	for _, s := range statuses {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t\n", s.Type, s.Year, s.Allowance, s.CarriedOver, s.Used, s.Remaining)
+	}
+	w.Flush()
+}
+
+func setAllowance(typeName string, days string) error {
+	allowance := api.Allowance{Type: typeName, From: allowanceFrom, CarryOver: allowanceCarryOver}
+	var err error
+	if allowance.Days, err = strconv.Atoi(days); err != nil {
+		return fmt.Errorf("invalid number of days: %s", days)
+	}
+	if allowance.From == 0 {
+		allowance.From = time.Now().Year()
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+	result, err := apiClient.SaveAllowance(&allowance)
+	if err != nil {
+		return err
+	}
+	return printRecord(result)
+}
+
+func listAllowances() error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	allowances, err := apiClient.GetAllowances()
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
+	}
+	for _, allowance := range allowances {
+		if err := p.Print(allowance); err != nil {
+			return err
+		}
+	}
+	return p.Flush()
+}
+
+func deleteAllowance(typeName string, year string) error {
+	from, err := strconv.Atoi(year)
+	if err != nil {
+		return fmt.Errorf("invalid year: %s", year)
+	}
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	ok, err := confirm(fmt.Sprintf("Delete the %s allowance from %d?", typeName, from))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Not deleted.")
+		return nil
+	}
+
+	if err := apiClient.DeleteAllowance(typeName, from); err != nil {
+		return err
+	}
+	fmt.Println("Allowance deleted.")
+	return nil
+}
+
+func addHoliday(date string, name string) error {
+	day, err := parseDateFlag(date, true)
+	if err != nil {
+		return err
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+	result, err := apiClient.SaveHoliday(&api.Holiday{Date: day, Name: name})
+	if err != nil {
+		return err
+	}
+	return printRecord(result)
+}
+
+func listHolidays() error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	holidays, err := apiClient.GetHolidays()
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
+	}
+	for _, holiday := range holidays {
+		if err := p.Print(holiday); err != nil {
+			return err
+		}
+	}
+	return p.Flush()
+}
+
+func deleteHoliday(date string) error {
+	day, err := parseDateFlag(date, true)
+	if err != nil {
+		return err
+	}
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	ok, err := confirm(fmt.Sprintf("Delete the holiday on %s?", day))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Not deleted.")
+		return nil
+	}
+
+	if err := apiClient.DeleteHoliday(day); err != nil {
+		return err
+	}
+	fmt.Println("Holiday deleted.")
+	return nil
+}
@@ -5,9 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/kennep/timelapse/api"
 	"github.com/sirupsen/logrus"
@@ -19,6 +23,22 @@ type ApiClient struct {
 	credentials   *Credentials
 	configuration *Configuration
 	client        *http.Client
+	// AllowOverlap lets added and updated time entries overlap other work entries
+	AllowOverlap bool
+}
+
+// TimeEntryFilter selects which time entries the server returns.
+// The zero value returns all entries.
+type TimeEntryFilter struct {
+	From *time.Time
+	To   *time.Time
+	Type string
+	// Task only matches entries of the named task; it needs a project
+	Task string
+	Open bool
+	// Tags only matches entries with all of the tags, or any of them if AnyTag is set
+	Tags   []string
+	AnyTag bool
 }
 
 type HTTPResponseError struct {
@@ -27,7 +47,9 @@ type HTTPResponseError struct {
 }
 
 type RemoteError struct {
-	Message string `json:"message"`
+	Message    string            `json:"message"`
+	Errors     []*api.FieldError `json:"errors"`
+	StatusCode int               `json:"-"`
 }
 
 func (err *HTTPResponseError) Error() string {
@@ -35,7 +57,22 @@ func (err *HTTPResponseError) Error() string {
 }
 
 func (err *RemoteError) Error() string {
-	return err.Message
+	message := err.Message
+	for _, fieldError := range err.Errors {
+		message += fmt.Sprintf("\n  %s: %s", fieldError.Field, fieldError.Message)
+	}
+	return message
+}
+
+// isNotFound tells whether a request failed because the resource does not exist
+func isNotFound(err error) bool {
+	switch e := err.(type) {
+	case *RemoteError:
+		return e.StatusCode == 404
+	case *HTTPResponseError:
+		return e.response.StatusCode == 404
+	}
+	return false
 }
 
 func NewApiClient() (*ApiClient, error) {
@@ -54,6 +91,12 @@ func NewApiClient() (*ApiClient, error) {
 	apiClient.credentials = credentials
 	apiClient.configuration = configuration
 	apiClient.client = &http.Client{}
+	apiClient.AllowOverlap = allowOverlap
+
+	// Without the user's entry types, entries are formatted as if only work was time-based
+	if err := useEntryTypes(&apiClient); err != nil {
+		logrus.WithError(err).Debug("Could not load entry types")
+	}
 
 	return &apiClient, nil
 }
@@ -140,14 +183,34 @@ func (c *ApiClient) jsonRequest(method string, path string, request interface{},
 }
 
 func (c *ApiClient) doRequest(method string, path string, body []byte) ([]byte, error) {
+	responseBody, _, err := c.doRequestWithHeaders(method, path, body)
+	return responseBody, err
+}
+
+// warningText returns the text of a Warning header, which the server uses for hints such
+// as a project having been renamed
+func warningText(header string) string {
+	// The header is a code, an agent and a quoted text
+	parts := strings.SplitN(header, " ", 3)
+	if len(parts) < 3 {
+		return header
+	}
+	text, err := strconv.Unquote(parts[2])
+	if err != nil {
+		return parts[2]
+	}
+	return text
+}
+
+func (c *ApiClient) doRequestWithHeaders(method string, path string, body []byte) ([]byte, http.Header, error) {
 	req, err := c.requestFor(method, path, body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 
@@ -155,22 +218,22 @@ func (c *ApiClient) doRequest(method string, path string, body []byte) ([]byte,
 		refreshToken, err := c.getRefreshToken()
 		err = refreshTokens(refreshToken)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		c.credentials, err = GetCredentials()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		req, err := c.requestFor(method, path, body)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		resp, err = c.client.Do(req)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		defer resp.Body.Close()
 
@@ -191,17 +254,22 @@ func (c *ApiClient) doRequest(method string, path string, body []byte) ([]byte,
 		}).Trace("HTTP Response body")
 	}
 
+	for _, warning := range resp.Header["Warning"] {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warningText(warning))
+	}
+
 	if resp.StatusCode >= 300 {
 		var remoteError RemoteError
 		err = json.Unmarshal(responseBody, &remoteError)
 		if err == nil && remoteError.Message != "" {
-			return nil, &remoteError
+			remoteError.StatusCode = resp.StatusCode
+			return nil, nil, &remoteError
 		}
 
-		return nil, &HTTPResponseError{resp, responseBody}
+		return nil, nil, &HTTPResponseError{resp, responseBody}
 	}
 
-	return responseBody, err
+	return responseBody, resp.Header, err
 }
 
 func (c *ApiClient) CreateProject(project *api.Project) (*api.Project, error) {
@@ -232,32 +300,103 @@ func (c *ApiClient) UpdateProject(projectName string, project *api.Project) (*ap
 	return &updatedProject, nil
 }
 
-func (c *ApiClient) ListProjects() ([]*api.Project, error) {
-	var projects []*api.Project
-	err := c.jsonRequest("GET", "/projects", nil, &projects)
+// ArchiveProject hides the project from project listings and stops it from taking new entries
+func (c *ApiClient) ArchiveProject(projectName string) (*api.Project, error) {
+	var project api.Project
+	err := c.jsonRequest("POST", fmt.Sprintf("/projects/%s/archive", url.QueryEscape(projectName)), nil, &project)
 	if err != nil {
 		return nil, err
 	}
+	return &project, nil
+}
+
+// UnarchiveProject makes an archived project active again
+func (c *ApiClient) UnarchiveProject(projectName string) (*api.Project, error) {
+	var project api.Project
+	err := c.jsonRequest("POST", fmt.Sprintf("/projects/%s/unarchive", url.QueryEscape(projectName)), nil, &project)
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// ListProjects returns the active projects if archived is empty or "false", the archived
+// ones if it is "true", and all projects if it is "all"
+func (c *ApiClient) ListProjects(archived string) ([]*api.Project, error) {
+	var projects []*api.Project
+	it := c.IterateProjects(archived)
+	for it.Next() {
+		projects = append(projects, it.Project())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
 	return projects, nil
 }
 
-func (c *ApiClient) GetTimeEntries(projectName string) ([]*api.TimeEntry, error) {
+func (f *TimeEntryFilter) values() url.Values {
+	params := make(url.Values)
+	if f == nil {
+		return params
+	}
+	if f.From != nil {
+		params.Set("from", f.From.Format(time.RFC3339))
+	}
+	if f.To != nil {
+		params.Set("to", f.To.Format(time.RFC3339))
+	}
+	if f.Type != "" {
+		params.Set("type", f.Type)
+	}
+	if f.Task != "" {
+		params.Set("task", f.Task)
+	}
+	if f.Open {
+		params.Set("open", "true")
+	}
+	for _, tag := range f.Tags {
+		params.Add("tag", tag)
+	}
+	if f.AnyTag {
+		params.Set("tag_match", "any")
+	}
+	return params
+}
+
+func (c *ApiClient) GetTimeEntries(projectName string, filter *TimeEntryFilter) ([]*api.TimeEntry, error) {
 	var timeentries []*api.TimeEntry
-	var path string
-	if projectName == "" {
-		path = "/entries"
-	} else {
-		path = fmt.Sprintf("/projects/%s/entries", url.QueryEscape(projectName))
+	it := c.IterateTimeEntries(projectName, filter)
+	for it.Next() {
+		timeentries = append(timeentries, it.Entry())
 	}
-	err := c.jsonRequest("GET", path, nil, &timeentries)
-	if err != nil {
+	if err := it.Err(); err != nil {
 		return nil, err
 	}
 	return timeentries, nil
 }
 
+// entryPath adds the parameters of a request that adds or changes time entries to its
+// path: allow_overlap, if overlaps are allowed, and the time zone that budget periods are
+// computed in
+func (c *ApiClient) entryPath(path string) string {
+	return c.timerPath(path, url.Values{})
+}
+
+// timerPath adds the parameters, allow_overlap and the time zone to the path of a request
+// that starts or stops timers
+func (c *ApiClient) timerPath(path string, params url.Values) string {
+	if c.AllowOverlap {
+		params.Set("allow_overlap", "true")
+	}
+	setTimeZone(params)
+	if len(params) == 0 {
+		return path
+	}
+	return path + "?" + params.Encode()
+}
+
 func (c *ApiClient) AddTimeEntry(projectName string, entry *api.TimeEntry) (*api.TimeEntry, error) {
-	path := fmt.Sprintf("/projects/%s/entries", url.QueryEscape(projectName))
+	path := c.entryPath(fmt.Sprintf("/projects/%s/entries", url.QueryEscape(projectName)))
 
 	var entryResult api.TimeEntry
 	err := c.jsonRequest("POST", path, entry, &entryResult)
@@ -269,7 +408,7 @@ func (c *ApiClient) AddTimeEntry(projectName string, entry *api.TimeEntry) (*api
 }
 
 func (c *ApiClient) GetTimeEntry(projectName string, entryID string) (*api.TimeEntry, error) {
-	path := fmt.Sprintf("/projects/%s/entries/%s", url.QueryEscape(projectName), url.QueryEscape(entryID))
+	path := fmt.Sprintf("/projects/%s/entries/%s", url.QueryEscape(projectName), url.PathEscape(entryID))
 
 	var entryResult api.TimeEntry
 	err := c.jsonRequest("GET", path, nil, &entryResult)
@@ -280,8 +419,19 @@ func (c *ApiClient) GetTimeEntry(projectName string, entryID string) (*api.TimeE
 	return &entryResult, nil
 }
 
+// GetEntry returns the user's time entry with the given ID, in whatever project it is
+func (c *ApiClient) GetEntry(entryID string) (*api.TimeEntry, error) {
+	var entryResult api.TimeEntry
+	err := c.jsonRequest("GET", fmt.Sprintf("/entries/%s", url.PathEscape(entryID)), nil, &entryResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entryResult, nil
+}
+
 func (c *ApiClient) UpdateTimeEntry(projectName string, entry *api.TimeEntry) (*api.TimeEntry, error) {
-	path := fmt.Sprintf("/projects/%s/entries/%s", url.QueryEscape(projectName), url.QueryEscape(entry.ID))
+	path := c.entryPath(fmt.Sprintf("/projects/%s/entries/%s", url.QueryEscape(projectName), url.PathEscape(entry.ID)))
 
 	var entryResult api.TimeEntry
 	err := c.jsonRequest("PUT", path, entry, &entryResult)
@@ -291,3 +441,524 @@ func (c *ApiClient) UpdateTimeEntry(projectName string, entry *api.TimeEntry) (*
 
 	return &entryResult, nil
 }
+
+func (c *ApiClient) DeleteTimeEntry(projectName string, entryID string) error {
+	path := fmt.Sprintf("/projects/%s/entries/%s", url.QueryEscape(projectName), url.PathEscape(entryID))
+
+	_, err := c.doRequest("DELETE", path, nil)
+	return err
+}
+
+func (c *ApiClient) DeleteProject(projectName string, cascade bool) error {
+	path := fmt.Sprintf("/projects/%s", url.QueryEscape(projectName))
+	if cascade {
+		path += "?cascade=true"
+	}
+
+	_, err := c.doRequest("DELETE", path, nil)
+	return err
+}
+
+func (c *ApiClient) CreateClient(client *api.Client) (*api.Client, error) {
+	var result api.Client
+	err := c.jsonRequest("POST", "/clients", client, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) GetClient(clientName string) (*api.Client, error) {
+	var result api.Client
+	err := c.jsonRequest("GET", fmt.Sprintf("/clients/%s", url.QueryEscape(clientName)), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) UpdateClient(clientName string, client *api.Client) (*api.Client, error) {
+	var result api.Client
+	err := c.jsonRequest("PUT", fmt.Sprintf("/clients/%s", url.QueryEscape(clientName)), client, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) ListClients() ([]*api.Client, error) {
+	var result []*api.Client
+	err := c.jsonRequest("GET", "/clients", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *ApiClient) DeleteClient(clientName string) error {
+	_, err := c.doRequest("DELETE", fmt.Sprintf("/clients/%s", url.QueryEscape(clientName)), nil)
+	return err
+}
+
+func (c *ApiClient) CreateTask(projectName string, task *api.Task) (*api.Task, error) {
+	var result api.Task
+	err := c.jsonRequest("POST", fmt.Sprintf("/projects/%s/tasks", url.QueryEscape(projectName)), task, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) GetTask(projectName string, taskName string) (*api.Task, error) {
+	var result api.Task
+	err := c.jsonRequest("GET", fmt.Sprintf("/projects/%s/tasks/%s", url.QueryEscape(projectName), url.QueryEscape(taskName)), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) UpdateTask(projectName string, taskName string, task *api.Task) (*api.Task, error) {
+	var result api.Task
+	err := c.jsonRequest("PUT", fmt.Sprintf("/projects/%s/tasks/%s", url.QueryEscape(projectName), url.QueryEscape(taskName)), task, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) ListTasks(projectName string) ([]*api.Task, error) {
+	var result []*api.Task
+	err := c.jsonRequest("GET", fmt.Sprintf("/projects/%s/tasks", url.QueryEscape(projectName)), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *ApiClient) DeleteTask(projectName string, taskName string) error {
+	_, err := c.doRequest("DELETE", fmt.Sprintf("/projects/%s/tasks/%s", url.QueryEscape(projectName), url.QueryEscape(taskName)), nil)
+	return err
+}
+
+// GetBudgetStatus returns how much of the project's budget is used in the current period.
+// If the project has no budget, a not found error is returned.
+func (c *ApiClient) GetBudgetStatus(projectName string) (*api.BudgetStatus, error) {
+	params := make(url.Values)
+	setTimeZone(params)
+
+	var result api.BudgetStatus
+	err := c.jsonRequest("GET", fmt.Sprintf("/projects/%s/budget?%s", url.QueryEscape(projectName), params.Encode()), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) GetSchedules() ([]*api.Schedule, error) {
+	var result []*api.Schedule
+	err := c.jsonRequest("GET", "/schedules", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SaveSchedule stores a schedule, replacing the one that takes effect on the same day
+func (c *ApiClient) SaveSchedule(schedule *api.Schedule) (*api.Schedule, error) {
+	var result api.Schedule
+	err := c.jsonRequest("PUT", fmt.Sprintf("/schedules/%s", url.QueryEscape(schedule.From)), schedule, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) DeleteSchedule(from string) error {
+	_, err := c.doRequest("DELETE", fmt.Sprintf("/schedules/%s", url.QueryEscape(from)), nil)
+	return err
+}
+
+// GetBalance returns the flex balance for the days from and to, formatted as YYYY-MM-DD.
+// Empty dates are left to the server, which defaults to the current month.
+func (c *ApiClient) GetBalance(from string, to string, groupBy string) (*api.Balance, error) {
+	params := make(url.Values)
+	if from != "" {
+		params.Set("from", from)
+	}
+	if to != "" {
+		params.Set("to", to)
+	}
+	params.Set("group_by", groupBy)
+	setTimeZone(params)
+
+	var result api.Balance
+	err := c.jsonRequest("GET", "/balance?"+params.Encode(), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) GetBalanceAdjustments() ([]*api.BalanceAdjustment, error) {
+	var result []*api.BalanceAdjustment
+	err := c.jsonRequest("GET", "/balance/adjustments", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *ApiClient) AddBalanceAdjustment(adjustment *api.BalanceAdjustment) (*api.BalanceAdjustment, error) {
+	var result api.BalanceAdjustment
+	err := c.jsonRequest("POST", "/balance/adjustments", adjustment, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) DeleteBalanceAdjustment(adjustmentID string) error {
+	_, err := c.doRequest("DELETE", fmt.Sprintf("/balance/adjustments/%s", url.PathEscape(adjustmentID)), nil)
+	return err
+}
+
+func (c *ApiClient) GetAllowances() ([]*api.Allowance, error) {
+	var result []*api.Allowance
+	err := c.jsonRequest("GET", "/allowances", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SaveAllowance stores an allowance, replacing the one for the same entry type and year
+func (c *ApiClient) SaveAllowance(allowance *api.Allowance) (*api.Allowance, error) {
+	var result api.Allowance
+	err := c.jsonRequest("PUT", fmt.Sprintf("/allowances/%s/%d", url.QueryEscape(allowance.Type), allowance.From), allowance, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) DeleteAllowance(entryType string, from int) error {
+	_, err := c.doRequest("DELETE", fmt.Sprintf("/allowances/%s/%d", url.QueryEscape(entryType), from), nil)
+	return err
+}
+
+// GetRemainingAllowances returns how many days of each allowance are left in the year
+func (c *ApiClient) GetRemainingAllowances(year int) ([]*api.AllowanceStatus, error) {
+	params := make(url.Values)
+	params.Set("year", strconv.Itoa(year))
+	setTimeZone(params)
+
+	var result []*api.AllowanceStatus
+	err := c.jsonRequest("GET", "/allowances/remaining?"+params.Encode(), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *ApiClient) GetHolidays() ([]*api.Holiday, error) {
+	var result []*api.Holiday
+	err := c.jsonRequest("GET", "/holidays", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SaveHoliday stores a holiday, replacing any on the same day
+func (c *ApiClient) SaveHoliday(holiday *api.Holiday) (*api.Holiday, error) {
+	var result api.Holiday
+	err := c.jsonRequest("PUT", fmt.Sprintf("/holidays/%s", url.QueryEscape(holiday.Date)), holiday, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) DeleteHoliday(date string) error {
+	_, err := c.doRequest("DELETE", fmt.Sprintf("/holidays/%s", url.QueryEscape(date)), nil)
+	return err
+}
+
+// setTimeZone passes the local time zone to the server, which uses it for dates
+func setTimeZone(params url.Values) {
+	params.Set("tz", localTimeZone())
+}
+
+// localTimeZone returns the IANA name of the local time zone, like Europe/Oslo, from $TZ
+// or the system configuration. If it cannot be found, or $TZ is not a zone name, like the
+// POSIX CET-1CEST, the current UTC offset, like +01:00, is returned instead, which is only
+// right until the next daylight saving time change.
+func localTimeZone() string {
+	if tz, ok := os.LookupEnv("TZ"); ok {
+		tz = strings.TrimPrefix(tz, ":")
+		if tz == "" {
+			return "UTC"
+		}
+		if name := zoneInfoName(tz); name != "" {
+			return name
+		}
+		if !strings.HasPrefix(tz, "/") {
+			if isZoneName(tz) {
+				return tz
+			}
+			return time.Now().Format("-07:00")
+		}
+	}
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		if name := zoneInfoName(target); name != "" {
+			return name
+		}
+	}
+	if content, err := ioutil.ReadFile("/etc/timezone"); err == nil {
+		if name := strings.TrimSpace(string(content)); isZoneName(name) {
+			return name
+		}
+	}
+	return time.Now().Format("-07:00")
+}
+
+// isZoneName returns true if name is in the time zone database, which the server loads
+// zones from
+func isZoneName(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// zoneInfoName returns the zone name in a path in the time zone database, like Europe/Oslo
+// in /usr/share/zoneinfo/Europe/Oslo, or an empty string if the path is not in it
+func zoneInfoName(path string) string {
+	idx := strings.Index(path, "zoneinfo/")
+	if idx < 0 {
+		return ""
+	}
+	return path[idx+len("zoneinfo/"):]
+}
+
+func (c *ApiClient) GetSummary(projectName string, groupBy string, filter *TimeEntryFilter) (*api.Summary, error) {
+	params := filter.values()
+	params.Set("group_by", groupBy)
+	if projectName != "" {
+		params.Set("project", projectName)
+	}
+	setTimeZone(params)
+
+	var summary api.Summary
+	err := c.jsonRequest("GET", "/reports/summary?"+params.Encode(), nil, &summary)
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// GetBilling prices the billable time matching the filter, for one project or one client
+// if given
+func (c *ApiClient) GetBilling(projectName string, clientName string, filter *TimeEntryFilter) (*api.Billing, error) {
+	params := filter.values()
+	if projectName != "" {
+		params.Set("project", projectName)
+	}
+	if clientName != "" {
+		params.Set("client", clientName)
+	}
+	setTimeZone(params)
+
+	var billing api.Billing
+	err := c.jsonRequest("GET", "/reports/billing?"+params.Encode(), nil, &billing)
+	if err != nil {
+		return nil, err
+	}
+	return &billing, nil
+}
+
+// CreateInvoice invoices the uninvoiced billable time selected by the request
+func (c *ApiClient) CreateInvoice(req *api.InvoiceRequest) (*api.Invoice, error) {
+	params := url.Values{}
+	setTimeZone(params)
+
+	var result api.Invoice
+	err := c.jsonRequest("POST", "/invoices?"+params.Encode(), req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) GetInvoice(number int) (*api.Invoice, error) {
+	var result api.Invoice
+	err := c.jsonRequest("GET", fmt.Sprintf("/invoices/%d", number), nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) ListInvoices() ([]*api.Invoice, error) {
+	var result []*api.Invoice
+	err := c.jsonRequest("GET", "/invoices", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ExportTimeEntries returns the time entries as a csv or ics file. For csv, the columns
+// can be chosen; if none are given the server default is used.
+func (c *ApiClient) ExportTimeEntries(projectName string, format string, columns []string, filter *TimeEntryFilter) ([]byte, error) {
+	params := filter.values()
+	params.Set("format", format)
+	if len(columns) > 0 {
+		params.Set("columns", strings.Join(columns, ","))
+	}
+	if projectName != "" {
+		params.Set("project", projectName)
+	}
+	setTimeZone(params)
+
+	return c.doRequest("GET", "/entries/export?"+params.Encode(), nil)
+}
+
+func (c *ApiClient) GetFeed() (*api.Feed, error) {
+	var feed api.Feed
+	err := c.jsonRequest("GET", "/self/feed", nil, &feed)
+	if err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+func (c *ApiClient) ResetFeed() (*api.Feed, error) {
+	var feed api.Feed
+	err := c.jsonRequest("PUT", "/self/feed", nil, &feed)
+	if err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+func (c *ApiClient) RevokeFeed() error {
+	_, err := c.doRequest("DELETE", "/self/feed", nil)
+	return err
+}
+
+// FeedURL is the full URL of the calendar feed, for subscribing from calendar apps
+func (c *ApiClient) FeedURL(feed *api.Feed) string {
+	return strings.TrimSuffix(c.configuration.BaseURL, "/") + feed.Path
+}
+
+func (c *ApiClient) GetSettings() (*api.UserSettings, error) {
+	var settings api.UserSettings
+	err := c.jsonRequest("GET", "/self/settings", nil, &settings)
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (c *ApiClient) UpdateSettings(settings *api.UserSettings) (*api.UserSettings, error) {
+	var result api.UserSettings
+	err := c.jsonRequest("PUT", "/self/settings", settings, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) GetEntryTypes() ([]*api.EntryType, error) {
+	var result []*api.EntryType
+	err := c.jsonRequest("GET", "/entry-types", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SaveEntryType adds an entry type, or changes the one with the same name
+func (c *ApiClient) SaveEntryType(entryType *api.EntryType) (*api.EntryType, error) {
+	var result api.EntryType
+	err := c.jsonRequest("PUT", fmt.Sprintf("/entry-types/%s", url.QueryEscape(entryType.Name)), entryType, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ApiClient) DeleteEntryType(typeName string) error {
+	_, err := c.doRequest("DELETE", fmt.Sprintf("/entry-types/%s", url.QueryEscape(typeName)), nil)
+	return err
+}
+
+// GetTimer returns the running timer, or nil if no timer is running
+func (c *ApiClient) GetTimer() (*api.TimeEntry, error) {
+	var entryResult api.TimeEntry
+	err := c.jsonRequest("GET", "/timer", nil, &entryResult)
+	if isNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &entryResult, nil
+}
+
+// StartTimer starts a timer for the project named in the entry
+func (c *ApiClient) StartTimer(entry *api.TimeEntry) (*api.TimerStart, error) {
+	var result api.TimerStart
+	err := c.jsonRequest("POST", c.timerPath("/timer", url.Values{}), entry, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SwitchTimer starts a timer for the project named in the entry and stops the running
+// timers at its start, whatever the timer policy is. If the new timer cannot be started,
+// the running ones are left alone.
+func (c *ApiClient) SwitchTimer(entry *api.TimeEntry) (*api.TimerStart, error) {
+	var result api.TimerStart
+	err := c.jsonRequest("POST", c.timerPath("/timer", url.Values{"stop_running": {"true"}}), entry, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// StopTimer stops the running timer. If no timer is running, a not found error is returned.
+func (c *ApiClient) StopTimer(stop *api.TimerStop) (*api.TimeEntry, error) {
+	var entryResult api.TimeEntry
+	err := c.jsonRequest("POST", c.timerPath("/timer/stop", url.Values{}), stop, &entryResult)
+	if err != nil {
+		return nil, err
+	}
+	return &entryResult, nil
+}
+
+// batchSize is the number of time entries sent per request when adding entries in bulk
+const batchSize = 500
+
+// AddTimeEntries adds entries to the projects named in them. The entries are sent in
+// batches; if a batch fails, the entries of earlier batches have been added.
+func (c *ApiClient) AddTimeEntries(entries []*api.TimeEntry) ([]*api.TimeEntry, error) {
+	var added []*api.TimeEntry
+	for start := 0; start < len(entries); start += batchSize {
+		end := start + batchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		var result []*api.TimeEntry
+		err := c.jsonRequest("POST", c.entryPath("/entries:batch"), entries[start:end], &result)
+		if err != nil {
+			return added, err
+		}
+		added = append(added, result...)
+	}
+	return added, nil
+}
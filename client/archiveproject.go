@@ -0,0 +1,57 @@
+package client
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(archiveProjectCmd)
+	rootCmd.AddCommand(unarchiveProjectCmd)
+}
+
+var archiveProjectCmd = &cobra.Command{
+	Use:   "archive-project PROJECTNAME",
+	Short: "Archive a project",
+	Long: `Archive the specified project. Archived projects are hidden from list-projects
+and take no new time entries, but their entries stay in reports and exports.`,
+	Args: cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return archiveProject(args[0])
+	}),
+}
+
+var unarchiveProjectCmd = &cobra.Command{
+	Use:   "unarchive-project PROJECTNAME",
+	Short: "Unarchive a project",
+	Long:  `Make an archived project active again`,
+	Args:  cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return unarchiveProject(args[0])
+	}),
+}
+
+func archiveProject(projectName string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	project, err := apiClient.ArchiveProject(projectName)
+	if err != nil {
+		return err
+	}
+	return printRecord(project)
+}
+
+func unarchiveProject(projectName string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	project, err := apiClient.UnarchiveProject(projectName)
+	if err != nil {
+		return err
+	}
+	return printRecord(project)
+}
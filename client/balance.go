@@ -0,0 +1,348 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scheduleFrom      string
+	balanceFrom       string
+	balanceTo         string
+	balanceGroupBy    string
+	adjustmentDate    string
+	adjustmentComment string
+)
+
+func init() {
+	rootCmd.AddCommand(setScheduleCmd)
+	setScheduleCmd.Flags().StringVar(&scheduleFrom, "from", "", "First day the schedule applies (default today)")
+	rootCmd.AddCommand(listSchedulesCmd)
+	rootCmd.AddCommand(deleteScheduleCmd)
+	deleteScheduleCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+
+	rootCmd.AddCommand(balanceCmd)
+	balanceCmd.Flags().StringVar(&balanceFrom, "from", "", "First day of the balance (default the first day of this month)")
+	balanceCmd.Flags().StringVar(&balanceTo, "to", "", "Last day of the balance (default today)")
+	balanceCmd.Flags().StringVarP(&balanceGroupBy, "group-by", "g", "day", "Group by (day|week|month)")
+
+	rootCmd.AddCommand(adjustBalanceCmd)
+	adjustBalanceCmd.Flags().StringVar(&adjustmentDate, "date", "", "Day the adjustment applies (default today)")
+	adjustBalanceCmd.Flags().StringVarP(&adjustmentComment, "comment", "c", "", "Reason for the adjustment")
+	rootCmd.AddCommand(listAdjustmentsCmd)
+	rootCmd.AddCommand(deleteAdjustmentCmd)
+	deleteAdjustmentCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+}
+
+var setScheduleCmd = &cobra.Command{
+	Use:   "set-schedule HOURS... [--from DATE]",
+	Short: "Set the hours you are expected to work",
+	Long: `Set the hours you are expected to work on each weekday, starting with Monday,
+like "set-schedule 8 8 8 8 7.5". Hours are numbers or durations like 7h30m, and
+weekdays that are left out are days off. The schedule applies from the --from
+date until a later schedule takes over; setting a schedule for the same date
+replaces it.`,
+	Args: cobra.RangeArgs(1, 7),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return setSchedule(args)
+	}),
+}
+
+var listSchedulesCmd = &cobra.Command{
+	Use:   "list-schedules",
+	Short: "List work schedules",
+	Args:  cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return listSchedules()
+	}),
+}
+
+var deleteScheduleCmd = &cobra.Command{
+	Use:   "delete-schedule DATE",
+	Short: "Delete the work schedule that applies from a date",
+	Args:  cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return deleteSchedule(args[0])
+	}),
+}
+
+var balanceCmd = &cobra.Command{
+	Use:   "balance [--from DATE] [--to DATE] [-g GROUPING]",
+	Short: "Show the flex balance",
+	Long: `Compare the recorded time with the work schedule, and show the flex balance.
+Worked time counts on the day it started. Absences whose entry type fulfills the
+schedule, like sick days and vacation, count towards the days they cover, and no
+time is expected on holidays. The balance accumulates from the first schedule, so
+the opening balance covers the days before --from.`,
+	Args: cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return showBalance()
+	}),
+}
+
+var adjustBalanceCmd = &cobra.Command{
+	Use:   "adjust-balance AMOUNT [--date DATE] [-c COMMENT]",
+	Short: "Adjust the flex balance",
+	Long: `Add hours to the flex balance, or subtract them, for example when overtime is
+paid out. The amount is a number of hours or a duration like 1h30m. Put negative
+amounts after --, like "adjust-balance -- -10".`,
+	Args: cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return adjustBalance(args[0])
+	}),
+}
+
+var listAdjustmentsCmd = &cobra.Command{
+	Use:   "list-adjustments",
+	Short: "List flex balance adjustments",
+	Args:  cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return listAdjustments()
+	}),
+}
+
+var deleteAdjustmentCmd = &cobra.Command{
+	Use:   "delete-adjustment ID",
+	Short: "Delete a flex balance adjustment",
+	Args:  cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return deleteAdjustment(args[0])
+	}),
+}
+
+// parseHours parses a number of hours, like 7.5, or a duration, like 7h30m
+func parseHours(s string) (time.Duration, error) {
+	if hours, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(hours * float64(time.Hour)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hours: %s", s)
+	}
+	return d, nil
+}
+
+// parseDateFlag returns the date in a flag as YYYY-MM-DD, or today if the flag is empty
+// and required is set. Otherwise an empty flag gives an empty string.
+func parseDateFlag(value string, required bool) (string, error) {
+	if value == "" && !required {
+		return "", nil
+	}
+	now := time.Now()
+	date := now
+	if value != "" {
+		var err error
+		if date, err = ParseTimeRef(value, now); err != nil {
+			return "", err
+		}
+	}
+	return date.Format("2006-01-02"), nil
+}
+
+func setSchedule(args []string) error {
+	schedule := api.Schedule{Hours: make([]time.Duration, 7)}
+	for i, arg := range args {
+		hours, err := parseHours(arg)
+		if err != nil {
+			return err
+		}
+		schedule.Hours[i] = hours
+	}
+	var err error
+	if schedule.From, err = parseDateFlag(scheduleFrom, true); err != nil {
+		return err
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+	result, err := apiClient.SaveSchedule(&schedule)
+	if err != nil {
+		return err
+	}
+	return printRecord(result)
+}
+
+func listSchedules() error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	schedules, err := apiClient.GetSchedules()
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
+	}
+	for _, schedule := range schedules {
+		if err := p.Print(schedule); err != nil {
+			return err
+		}
+	}
+	return p.Flush()
+}
+
+func deleteSchedule(date string) error {
+	from, err := parseDateFlag(date, true)
+	if err != nil {
+		return err
+	}
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	ok, err := confirm(fmt.Sprintf("Delete the schedule from %s?", from))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Not deleted.")
+		return nil
+	}
+
+	if err := apiClient.DeleteSchedule(from); err != nil {
+		return err
+	}
+	fmt.Println("Schedule deleted.")
+	return nil
+}
+
+func showBalance() error {
+	from, err := parseDateFlag(balanceFrom, false)
+	if err != nil {
+		return err
+	}
+	to, err := parseDateFlag(balanceTo, false)
+	if err != nil {
+		return err
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+	balance, err := apiClient.GetBalance(from, to, balanceGroupBy)
+	if err != nil {
+		return err
+	}
+
+	switch outputFormat {
+	case outputTable:
+		printBalanceTable(balance)
+		return nil
+	case outputJSON:
+		return printRecord(balance)
+	}
+
+	// The remaining formats print one record per period
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
+	}
+	for _, period := range balance.Periods {
+		if err := p.Print(period); err != nil {
+			return err
+		}
+	}
+	return p.Flush()
+}
+
+func printBalanceTable(balance *api.Balance) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%s\tEXPECTED\tWORKED\tABSENCE\tADJUSTED\tBALANCE\tRUNNING\t\n", strings.ToUpper(balance.GroupBy))
+	fmt.Fprintf(w, "OPENING\t\t\t\t\t\t%s\t\n", formatHours(balance.Opening))
+	for _, period := range balance.Periods {
+		printBalancePeriod(w, period.Key, period)
+	}
+	if balance.Total != nil {
+		printBalancePeriod(w, "TOTAL", balance.Total)
+	}
+	w.Flush()
+}
+
+func printBalancePeriod(w *tabwriter.Writer, key string, period *api.BalancePeriod) {
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", key, formatHours(period.Expected), formatHours(period.Worked),
+		formatHours(period.Absence), formatHours(period.Adjustments), formatHours(period.Balance), formatHours(period.Running))
+}
+
+func adjustBalance(amount string) error {
+	var adjustment api.BalanceAdjustment
+	var err error
+	if adjustment.Amount, err = parseHours(amount); err != nil {
+		return err
+	}
+	if adjustment.Amount == 0 {
+		return errors.New("Amount must not be zero")
+	}
+	if adjustment.Date, err = parseDateFlag(adjustmentDate, true); err != nil {
+		return err
+	}
+	adjustment.Comment = adjustmentComment
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+	result, err := apiClient.AddBalanceAdjustment(&adjustment)
+	if err != nil {
+		return err
+	}
+	return printRecord(result)
+}
+
+func listAdjustments() error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	adjustments, err := apiClient.GetBalanceAdjustments()
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
+	}
+	for _, adjustment := range adjustments {
+		if err := p.Print(adjustment); err != nil {
+			return err
+		}
+	}
+	return p.Flush()
+}
+
+func deleteAdjustment(adjustmentID string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	ok, err := confirm(fmt.Sprintf("Delete balance adjustment %s?", adjustmentID))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Not deleted.")
+		return nil
+	}
+
+	if err := apiClient.DeleteBalanceAdjustment(adjustmentID); err != nil {
+		return err
+	}
+	fmt.Println("Adjustment deleted.")
+	return nil
+}
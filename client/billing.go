@@ -0,0 +1,101 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+var billingClient string
+
+func init() {
+	rootCmd.AddCommand(billCmd)
+	billCmd.Flags().StringVarP(&startTime, "start", "s", "", "Only entries that start at or later than this time/date")
+	billCmd.Flags().StringVarP(&endTime, "end", "e", "", "Only entries that start earlier than this time/date")
+	billCmd.Flags().StringVar(&billingClient, "client", "", "Only projects billed to this client")
+	addTagFilterFlags(billCmd)
+}
+
+var billCmd = &cobra.Command{
+	Use:   "bill [PROJECTNAME] [-s START] [-e END] [--client CLIENT]",
+	Short: "Show billed amounts",
+	Long: `Show what the time in billable projects amounts to, at the rates that applied
+when the time was worked. Time is listed per client, project, entry type and rate,
+followed by the total per currency. Billable time that no rate applies to is shown
+as unpriced.
+With --output json the whole billing is printed; the ndjson, csv and template
+formats print one record per line.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return bill(args[0])
+		}
+		return bill("")
+	}),
+}
+
+func bill(projectName string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	filter, err := entryFilterFromFlags()
+	if err != nil {
+		return err
+	}
+
+	billing, err := apiClient.GetBilling(projectName, billingClient, filter)
+	if err != nil {
+		return err
+	}
+
+	switch outputFormat {
+	case outputTable:
+		printBillingTable(billing)
+		return nil
+	case outputJSON:
+		return printRecord(billing)
+	}
+
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
+	}
+	for _, line := range billing.Lines {
+		if err := p.Print(line); err != nil {
+			return err
+		}
+	}
+	return p.Flush()
+}
+
+func formatAmount(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+func printBillingTable(billing *api.Billing) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "CLIENT\tPROJECT\tTYPE\tHOURS\tRATE\tAMOUNT\t\n")
+	for _, line := range billing.Lines {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s %s\t\n", line.Client, line.Project, line.EntryType,
+			formatHours(line.Hours), formatAmount(line.Rate), formatAmount(line.Amount), line.Currency)
+	}
+
+	var currencies []string
+	for currency := range billing.Totals {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		fmt.Fprintf(w, "TOTAL\t\t\t\t\t%s %s\t\n", formatAmount(billing.Totals[currency]), currency)
+	}
+	if billing.Unpriced > 0 {
+		fmt.Fprintf(w, "UNPRICED\t\t\t%s\t\t\t\n", formatHours(billing.Unpriced))
+	}
+	w.Flush()
+}
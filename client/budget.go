@@ -0,0 +1,127 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+var (
+	budgetHours      float64
+	budgetAmount     float64
+	budgetCurrency   string
+	budgetPeriod     string
+	budgetThresholds []int
+	noBudget         bool
+)
+
+var budgetFlags = []string{"budget-hours", "budget-amount", "budget-currency", "budget-period", "budget-thresholds"}
+
+func init() {
+	rootCmd.AddCommand(budgetCmd)
+}
+
+// addBudgetFlags adds the flags that set the budget of a project
+func addBudgetFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64Var(&budgetHours, "budget-hours", 0, "Limit the hours worked on the project")
+	cmd.Flags().Float64Var(&budgetAmount, "budget-amount", 0, "Limit the money spent on the project, priced at its hourly rates")
+	cmd.Flags().StringVar(&budgetCurrency, "budget-currency", "", "Currency of the budget amount")
+	cmd.Flags().StringVar(&budgetPeriod, "budget-period", "", "Renew the budget every week or month, instead of it being a total")
+	cmd.Flags().IntSliceVar(&budgetThresholds, "budget-thresholds", nil, "Warn when these percentages of the budget are used (default 80,100)")
+}
+
+// budgetFromFlags applies the budget flags given on the command line to the budget of a
+// project, which may be nil. If no budget flags are given, the budget is returned as is.
+func budgetFromFlags(cmd *cobra.Command, budget *api.Budget) *api.Budget {
+	changed := false
+	for _, name := range budgetFlags {
+		changed = changed || cmd.Flags().Changed(name)
+	}
+	if !changed {
+		return budget
+	}
+
+	var result api.Budget
+	if budget != nil {
+		result = *budget
+	}
+	if cmd.Flags().Changed("budget-hours") {
+		result.Hours = time.Duration(budgetHours * float64(time.Hour))
+	}
+	if cmd.Flags().Changed("budget-amount") {
+		result.Amount = budgetAmount
+	}
+	if cmd.Flags().Changed("budget-currency") {
+		result.Currency = budgetCurrency
+	}
+	if cmd.Flags().Changed("budget-period") {
+		result.Period = budgetPeriod
+	}
+	if cmd.Flags().Changed("budget-thresholds") {
+		result.Thresholds = budgetThresholds
+	}
+	return &result
+}
+
+var budgetCmd = &cobra.Command{
+	Use:   "budget PROJECTNAME",
+	Short: "Show the budget of a project",
+	Long: `Show how much of the budget of a project is used and how much remains. For
+budgets that are renewed every week or month, the current period is shown.
+Budgets are set with --budget-hours and --budget-amount to add-project and
+update-project.`,
+	Args: cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return showBudget(args[0])
+	}),
+}
+
+func showBudget(projectName string) error {
+	if projectName == "" {
+		return errors.New("Project name must be given")
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	status, err := apiClient.GetBudgetStatus(projectName)
+	if err != nil {
+		return err
+	}
+	if isTableOutput() {
+		fmt.Println(status)
+		if status.Budget.Hours > 0 {
+			fmt.Printf("Remaining: %sh\n", formatHours(status.RemainingHours))
+		}
+		if status.Budget.Amount > 0 {
+			fmt.Printf("Remaining: %s %s\n", formatAmount(status.RemainingAmount), status.Budget.Currency)
+		}
+		if warning := status.Warning(); warning != "" {
+			fmt.Fprintln(os.Stderr, warning)
+		}
+		return nil
+	}
+	return printRecord(status)
+}
+
+// warnAboutBudget prints a warning if the budget of the project has reached one of its
+// thresholds. The warning is only a courtesy, so errors, such as the project having no
+// budget, are ignored.
+func warnAboutBudget(apiClient *ApiClient, projectName string) {
+	if !isTableOutput() || projectName == "" {
+		return
+	}
+	status, err := apiClient.GetBudgetStatus(projectName)
+	if err != nil {
+		return
+	}
+	if warning := status.Warning(); warning != "" {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+var commandLineClient api.Client
+
+func init() {
+	rootCmd.AddCommand(addClientCmd)
+	addClientCmd.Flags().StringVarP(&commandLineClient.Description, "description", "d", "", "Client description")
+	rootCmd.AddCommand(listClientsCmd)
+	rootCmd.AddCommand(getClientCmd)
+	rootCmd.AddCommand(updateClientCmd)
+	updateClientCmd.Flags().StringVarP(&commandLineClient.Name, "rename-to", "r", "", "Rename client")
+	updateClientCmd.Flags().StringVarP(&commandLineClient.Description, "description", "d", "", "Set description")
+	rootCmd.AddCommand(deleteClientCmd)
+	deleteClientCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+}
+
+var addClientCmd = &cobra.Command{
+	Use:   "add-client CLIENTNAME",
+	Short: "Add a client",
+	Long: `Add a client that projects can be billed to. Use add-project or update-project
+with --client to bill a project to the client, and set-rate to give the client rates.`,
+	Args: cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return addClient(args[0])
+	}),
+}
+
+var listClientsCmd = &cobra.Command{
+	Use:   "list-clients",
+	Short: "List clients",
+	Long:  `List the clients that projects can be billed to, with their rates`,
+	Args:  cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return listClients()
+	}),
+}
+
+var getClientCmd = &cobra.Command{
+	Use:   "get-client CLIENTNAME",
+	Short: "Show a client",
+	Long:  `Show a client and its rates`,
+	Args:  cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return getClient(args[0])
+	}),
+}
+
+var updateClientCmd = &cobra.Command{
+	Use:   "update-client CLIENTNAME",
+	Short: "Update a client",
+	Long:  `Rename a client or change its description`,
+	Args:  cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return updateClient(cmd, args[0])
+	}),
+}
+
+var deleteClientCmd = &cobra.Command{
+	Use:   "delete-client CLIENTNAME",
+	Short: "Delete a client",
+	Long: `Delete the specified client. A client can only be deleted when no projects
+are billed to it.`,
+	Args: cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return deleteClient(args[0])
+	}),
+}
+
+func addClient(clientName string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	commandLineClient.Name = clientName
+
+	client, err := apiClient.CreateClient(&commandLineClient)
+	if err != nil {
+		return err
+	}
+	return printRecord(client)
+}
+
+func listClients() error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	clients, err := apiClient.ListClients()
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
+	}
+	for _, client := range clients {
+		if err := p.Print(client); err != nil {
+			return err
+		}
+	}
+
+	return p.Flush()
+}
+
+func getClient(clientName string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	client, err := apiClient.GetClient(clientName)
+	if err != nil {
+		return err
+	}
+	return printRecord(client)
+}
+
+func updateClient(cmd *cobra.Command, clientName string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	client, err := apiClient.GetClient(clientName)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Flags().Changed("rename-to") {
+		client.Name = commandLineClient.Name
+	}
+	if cmd.Flags().Changed("description") {
+		client.Description = commandLineClient.Description
+	}
+
+	client, err = apiClient.UpdateClient(clientName, client)
+	if err != nil {
+		return err
+	}
+	return printRecord(client)
+}
+
+func deleteClient(clientName string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	client, err := apiClient.GetClient(clientName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(client)
+	ok, err := confirm("Delete this client?")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Not deleted.")
+		return nil
+	}
+
+	err = apiClient.DeleteClient(clientName)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Client deleted.")
+
+	return nil
+}
@@ -1,8 +1,11 @@
 package client
 
 import (
+	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/kennep/timelapse/api"
 	"github.com/sirupsen/logrus"
@@ -18,6 +21,11 @@ var rootCmd = &cobra.Command{
 			logrus.Warn("Activating trace logging - this can cause confidential information to be logged!")
 			logrus.SetLevel(logrus.TraceLevel)
 		}
+		// Reject an invalid output format before any changes are made
+		if _, err := newPrinter(ioutil.Discard, false); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	},
 
 	Run: func(cmd *cobra.Command, args []string) {
@@ -29,6 +37,7 @@ var commandLineProject api.Project
 var commandLineEntry api.TimeEntry
 
 var logLevelTrace bool
+var assumeYes bool
 
 func init() {
 	rootCmd.PersistentFlags().BoolVar(&logLevelTrace, "trace", false, "Activate trace logging")
@@ -56,3 +65,18 @@ func runCommand(commandFunc func(*cobra.Command, []string) error) func(*cobra.Co
 		_runCommand(cmd, args, commandFunc)
 	}
 }
+
+// confirm asks the user a yes/no question on the terminal. The default answer is no.
+func confirm(question string) (bool, error) {
+	if assumeYes {
+		return true, nil
+	}
+
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+// continueLookback is how far back continue looks for the most recently closed entry
+const continueLookback = 31 * 24 * time.Hour
+
+func init() {
+	rootCmd.AddCommand(continueCmd)
+}
+
+var continueCmd = &cobra.Command{
+	Use:   "continue [TIME]",
+	Short: "Restart the most recently closed entry",
+	Long: `Start a new time entry with the same project, task, type and comment as the entry that
+was closed most recently. A running entry is stopped at the same instant. The time
+defaults to now.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		at, err := optionalTimeArg(args, 0)
+		if err != nil {
+			return err
+		}
+		return continueTimeEntry(at)
+	}),
+}
+
+// lastClosedEntry returns the entry that ended most recently, looking back from the given time
+func lastClosedEntry(apiClient *ApiClient, at time.Time) (*api.TimeEntry, error) {
+	from := at.Add(-continueLookback)
+	entries, err := apiClient.GetTimeEntries("", &TimeEntryFilter{From: &from})
+	if err != nil {
+		return nil, err
+	}
+
+	var result *api.TimeEntry
+	for _, entry := range entries {
+		if entry.End != nil && !entry.End.After(at) && (result == nil || entry.End.After(*result.End)) {
+			result = entry
+		}
+	}
+	return result, nil
+}
+
+func continueTimeEntry(at time.Time) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	last, err := lastClosedEntry(apiClient, at)
+	if err != nil {
+		return err
+	}
+	if last == nil {
+		return errors.New("Did not find any closed time entry to continue")
+	}
+
+	return switchTimeEntry(apiClient, last.ProjectName, &api.TimeEntry{Task: last.Task, Type: last.Type, Comment: last.Comment}, at)
+}
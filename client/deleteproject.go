@@ -0,0 +1,60 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var cascadeDelete bool
+
+func init() {
+	rootCmd.AddCommand(deleteProjectCmd)
+	deleteProjectCmd.Flags().BoolVar(&cascadeDelete, "cascade", false, "Also delete all time entries in the project")
+	deleteProjectCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+}
+
+var deleteProjectCmd = &cobra.Command{
+	Use:   "delete-project PROJECTNAME",
+	Short: "Delete a project",
+	Long: `Delete the specified project. A project that still has time entries
+is only deleted if --cascade is given, in which case the entries are deleted too.`,
+	Args: cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return deleteProject(args[0])
+	}),
+}
+
+func deleteProject(projectName string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	project, err := apiClient.GetProject(projectName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(project)
+	question := "Delete this project?"
+	if cascadeDelete {
+		question = "Delete this project and all its time entries?"
+	}
+	ok, err := confirm(question)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Not deleted.")
+		return nil
+	}
+
+	err = apiClient.DeleteProject(projectName, cascadeDelete)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Project deleted.")
+
+	return nil
+}
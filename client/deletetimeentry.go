@@ -0,0 +1,52 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(deleteTimeEntryCmd)
+	deleteTimeEntryCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+}
+
+var deleteTimeEntryCmd = &cobra.Command{
+	Use:   "delete-entry PROJECTNAME ENTRYID",
+	Short: "Delete time entry",
+	Long:  `Delete a time entry from a given project`,
+	Args:  cobra.ExactArgs(2),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return deleteTimeEntry(args[0], args[1])
+	}),
+}
+
+func deleteTimeEntry(projectName string, entryID string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	entry, err := apiClient.GetTimeEntry(projectName, entryID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(entry)
+	ok, err := confirm("Delete this time entry?")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Not deleted.")
+		return nil
+	}
+
+	err = apiClient.DeleteTimeEntry(projectName, entryID)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Time entry deleted.")
+
+	return nil
+}
@@ -0,0 +1,568 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+// editTimeFormat is how times are written in the edit document. ParseTimeRef reads it back.
+const editTimeFormat = "2006-01-02 15:04"
+
+// editNewID marks entries that are added in the edit document
+const editNewID = "new"
+
+// editErrorPrefix starts the comment lines that point out problems in the edit document.
+// They are removed before the document is read again.
+const editErrorPrefix = "# ERROR"
+
+// editFields are the fields of an entry in the edit document, in the order they are
+// written and applied
+var editFields = []string{"id", "project", "type", "start", "end", "breaks", "comment", "tags"}
+
+var editCommentEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var editCommentUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+
+const editHelp = `# Edit the time entries and save to apply the changes. Lines starting with # are ignored.
+#
+# - Change a field to update the entry.
+# - Remove an entry to delete it.
+# - Add an entry with "id: new" to create one.
+# - Remove all entries to abort without changes.
+#
+# Times are read like on the command line, for instance "2019-01-07 08:00". The end can
+# be just "16:00" on the day the entry starts. Leave the end empty for a running entry.
+# The type is work unless given. The project can be given as project/task to record the
+# entry on one of the project's tasks; a project whose name contains a slash ends with a
+# slash when there is no task. Tags are separated by spaces; #hashtags in the comment are
+# added as tags as well.
+`
+
+var editDay string
+var editWeek string
+
+func init() {
+	rootCmd.AddCommand(editCmd)
+	editCmd.Flags().StringVarP(&editDay, "day", "d", "", "Edit the entries that start on this day")
+	editCmd.Flags().StringVarP(&editWeek, "week", "w", "", "Edit the entries that start in the week (Monday to Sunday) of this day")
+	editCmd.Flags().BoolVar(&allowOverlap, "allow-overlap", false, "Save entries even if they overlap other work")
+}
+
+var editCmd = &cobra.Command{
+	Use:   "edit [PROJECTNAME [ENTRYID]] [-d DAY | -w DAY]",
+	Short: "Edit time entries in a text editor",
+	Long: `Open time entries in $VISUAL or $EDITOR as a text document. When the editor is closed,
+the changes are applied: changed entries are updated, removed entries are deleted and
+entries with "id: new" are added.
+Either a single entry, given by project and ID, or the entries of a day or week are
+edited, optionally for one project only. Without --day or --week, today's entries are
+edited. If the document has errors, the editor is opened again with the problems
+pointed out.`,
+	Args: cobra.MaximumNArgs(2),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return edit(args)
+	}),
+}
+
+type (
+	// editEntry is an entry of the edit document, with the fields as they were written
+	editEntry struct {
+		line   int
+		fields map[string]string
+		lines  map[string]int
+	}
+
+	// editError is a problem in the edit document
+	editError struct {
+		line    int
+		message string
+	}
+
+	// editPlan holds the requests that apply an edit document. Entries that are moved to
+	// another project are updated in the new project.
+	editPlan struct {
+		deletes []*api.TimeEntry
+		updates []*api.TimeEntry
+		creates []*api.TimeEntry
+	}
+)
+
+func (e editError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.message)
+}
+
+func formatEditTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(time.Local).Format(editTimeFormat)
+}
+
+// formatEditBreaks formats a duration without trailing zero units, like 1h30m
+func formatEditBreaks(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// editEntryFields returns the fields of an entry as they are written in the edit document
+func editEntryFields(e *api.TimeEntry) map[string]string {
+	return map[string]string{
+		"id":      e.ID,
+		"project": e.ProjectPath(),
+		"type":    e.Type,
+		"start":   formatEditTime(e.Start),
+		"end":     formatEditTime(e.End),
+		"breaks":  formatEditBreaks(e.Breaks),
+		"comment": editCommentEscaper.Replace(e.Comment),
+		"tags":    strings.Join(e.Tags, " "),
+	}
+}
+
+func formatEditDocument(entries []*api.TimeEntry) string {
+	var b strings.Builder
+	b.WriteString(editHelp)
+	for _, entry := range entries {
+		fields := editEntryFields(entry)
+		b.WriteString("\n")
+		for idx, field := range editFields {
+			prefix := "  "
+			if idx == 0 {
+				prefix = "- "
+			}
+			fmt.Fprintf(&b, "%s%s: %s\n", prefix, field, fields[field])
+		}
+	}
+	return b.String()
+}
+
+func isEditField(name string) bool {
+	for _, field := range editFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEditDocument reads the entries of an edit document. Line numbers start at 1.
+func parseEditDocument(text string) ([]*editEntry, []editError) {
+	var entries []*editEntry
+	var errs []editError
+	var current *editEntry
+
+	for idx, line := range strings.Split(text, "\n") {
+		lineNo := idx + 1
+		line = strings.TrimRight(line, " \t\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "- ") {
+			current = &editEntry{line: lineNo, fields: make(map[string]string), lines: make(map[string]int)}
+			entries = append(entries, current)
+			line = strings.TrimPrefix(line, "- ")
+		} else if current == nil || (line[0] != ' ' && line[0] != '\t') {
+			errs = append(errs, editError{lineNo, `expected "- id: ..." to start an entry, or an indented "field: value"`})
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if len(parts) != 2 || !isEditField(name) {
+			errs = append(errs, editError{lineNo, fmt.Sprintf("expected one of %s, followed by a colon", strings.Join(editFields, ", "))})
+			continue
+		}
+		if _, ok := current.fields[name]; ok {
+			errs = append(errs, editError{lineNo, fmt.Sprintf("%s is given more than once", name)})
+			continue
+		}
+		current.fields[name] = strings.TrimSpace(parts[1])
+		current.lines[name] = lineNo
+	}
+
+	return entries, errs
+}
+
+// fieldLine returns the line a field was given on, or the first line of the entry if the
+// field is missing
+func (e *editEntry) fieldLine(name string) int {
+	if line, ok := e.lines[name]; ok {
+		return line
+	}
+	return e.line
+}
+
+// apply sets a field of the entry from the edit document. Times are read relative
+// to ref.
+func (e *editEntry) apply(entry *api.TimeEntry, name string, ref time.Time) error {
+	value := e.fields[name]
+	switch name {
+	case "project":
+		if value == "" {
+			return errors.New("project must be given")
+		}
+		entry.ProjectName, entry.Task = splitProjectTask(value)
+	case "type":
+		entry.Type = value
+		if value == "" {
+			entry.Type = "work"
+		}
+	case "start":
+		if value == "" {
+			return errors.New("start must be given")
+		}
+		start, err := ParseTimeRef(value, ref)
+		if err != nil {
+			return err
+		}
+		entry.Start = &start
+	case "end":
+		if value == "" {
+			entry.End = nil
+			return nil
+		}
+		if entry.Start != nil {
+			ref = *entry.Start
+		}
+		end, err := ParseTimeRef(value, ref)
+		if err != nil {
+			return err
+		}
+		entry.End = &end
+	case "breaks":
+		entry.Breaks = 0
+		if value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid duration: %s", value)
+			}
+			entry.Breaks = d
+		}
+	case "comment":
+		entry.Comment = editCommentUnescaper.Replace(value)
+	case "tags":
+		entry.Tags = splitTags(value)
+	}
+	return nil
+}
+
+// planEdits compares the entries of the edit document to the original entries. Only the
+// fields that were changed are applied, so that times keep their full precision. New
+// entries are read relative to ref.
+func planEdits(original []*api.TimeEntry, entries []*editEntry, ref time.Time) (*editPlan, []editError) {
+	var plan editPlan
+	var errs []editError
+
+	byID := make(map[string]*api.TimeEntry)
+	for _, entry := range original {
+		byID[entry.ID] = entry
+	}
+	seen := make(map[string]bool)
+
+	for _, edited := range entries {
+		id := edited.fields["id"]
+		isNew := id == "" || id == editNewID
+
+		entry := &api.TimeEntry{Type: "work"}
+		entryRef := ref
+		written := map[string]string{}
+		var orig *api.TimeEntry
+		if !isNew {
+			orig = byID[id]
+			if orig == nil {
+				errs = append(errs, editError{edited.fieldLine("id"), fmt.Sprintf("unknown entry id %s - use \"id: %s\" to add an entry", id, editNewID)})
+				continue
+			}
+			if seen[id] {
+				errs = append(errs, editError{edited.fieldLine("id"), fmt.Sprintf("entry %s is given more than once", id)})
+				continue
+			}
+			seen[id] = true
+			copied := *orig
+			entry = &copied
+			written = editEntryFields(orig)
+			if orig.Start != nil {
+				entryRef = *orig.Start
+			}
+		}
+
+		changed := false
+		valid := true
+		for _, name := range editFields[1:] {
+			if !isNew && edited.fields[name] == written[name] {
+				continue
+			}
+			changed = true
+			if err := edited.apply(entry, name, entryRef); err != nil {
+				errs = append(errs, editError{edited.fieldLine(name), err.Error()})
+				valid = false
+			}
+		}
+		if !valid || !changed {
+			continue
+		}
+
+		if !api.IsTimeBased(entry.Type) {
+			start := normalizeTime(*entry.Start)
+			entry.Start = &start
+			if entry.End != nil {
+				end := normalizeTime(*entry.End)
+				entry.End = &end
+			}
+		}
+		if entry.End != nil && entry.End.Before(*entry.Start) {
+			errs = append(errs, editError{edited.fieldLine("end"), "end is before start"})
+			continue
+		}
+
+		// Entries moved to another project are updated in the new project, which moves
+		// them in one step
+		if isNew {
+			entry.ID = ""
+			plan.creates = append(plan.creates, entry)
+		} else {
+			plan.updates = append(plan.updates, entry)
+		}
+	}
+
+	for _, entry := range original {
+		if !seen[entry.ID] {
+			plan.deletes = append(plan.deletes, entry)
+		}
+	}
+
+	return &plan, errs
+}
+
+func (p *editPlan) empty() bool {
+	return len(p.deletes) == 0 && len(p.updates) == 0 && len(p.creates) == 0
+}
+
+// apply sends the changes to the server. Deletes go first, so that changed and new entries
+// can take the place of removed ones.
+func (p *editPlan) apply(apiClient *ApiClient) error {
+	for _, entry := range p.deletes {
+		if err := apiClient.DeleteTimeEntry(entry.ProjectName, entry.ID); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted: %s\n", entry)
+	}
+	for _, entry := range p.updates {
+		result, err := apiClient.UpdateTimeEntry(entry.ProjectName, entry)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Updated: %s\n", result)
+	}
+	for _, entry := range p.creates {
+		result, err := apiClient.AddTimeEntry(entry.ProjectName, entry)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Added:   %s\n", result)
+	}
+	return nil
+}
+
+// stripEditErrors removes the error annotations of an earlier attempt
+func stripEditErrors(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if !strings.HasPrefix(line, editErrorPrefix) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// annotateEditErrors adds a comment line after each line with a problem
+func annotateEditErrors(text string, errs []editError) string {
+	messages := make(map[int][]string)
+	for _, err := range errs {
+		messages[err.line] = append(messages[err.line], err.message)
+	}
+
+	lines := []string{editErrorPrefix + ": fix the problems below and save again, or save without changes to give up."}
+	for idx, line := range strings.Split(text, "\n") {
+		lines = append(lines, line)
+		for _, message := range messages[idx+1] {
+			lines = append(lines, fmt.Sprintf("%s: %s", editErrorPrefix, message))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func editorCommand() []string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.Fields(os.Getenv(name)); len(editor) > 0 {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// editText lets the user edit a text in the editor and returns the result
+func editText(text string) (string, error) {
+	f, err := ioutil.TempFile("", "timelapse-edit-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(text)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	editor := editorCommand()
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Editor %s failed: %v", editor[0], err)
+	}
+
+	edited, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(edited), nil
+}
+
+// editRange returns the time range given with --day or --week, or today
+func editRange() (time.Time, time.Time, error) {
+	now := time.Now()
+	switch {
+	case editDay != "" && editWeek != "":
+		return time.Time{}, time.Time{}, errors.New("Only one of --day and --week can be given")
+	case editWeek != "":
+		day, err := ParseTimeRef(editWeek, now)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from := startOfWeek(day)
+		return from, from.AddDate(0, 0, 7), nil
+	case editDay != "":
+		day, err := ParseTimeRef(editDay, now)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		now = day
+	}
+	from := startOfDay(now)
+	return from, from.AddDate(0, 0, 1), nil
+}
+
+func edit(args []string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	var original []*api.TimeEntry
+	ref := time.Now()
+	if len(args) == 2 {
+		entry, err := apiClient.GetTimeEntry(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		original = append(original, entry)
+	} else {
+		from, to, err := editRange()
+		if err != nil {
+			return err
+		}
+		projectName := ""
+		if len(args) == 1 {
+			projectName = args[0]
+		}
+		original, err = apiClient.GetTimeEntries(projectName, &TimeEntryFilter{From: &from, To: &to})
+		if err != nil {
+			return err
+		}
+		ref = from
+	}
+
+	plan, err := editUntilValid(formatEditDocument(original), original, ref)
+	if err != nil || plan == nil {
+		return err
+	}
+	if plan.empty() {
+		fmt.Println("No changes.")
+		return nil
+	}
+
+	if len(plan.deletes) > 0 {
+		for _, entry := range plan.deletes {
+			fmt.Printf("  %s\n", entry)
+		}
+		ok, err := confirm(fmt.Sprintf("Delete %d time entries?", len(plan.deletes)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Edit aborted, nothing was changed.")
+			return nil
+		}
+	}
+
+	defer invalidateStatusCache()
+	return plan.apply(apiClient)
+}
+
+// editUntilValid opens the document in the editor until it can be read, and returns the
+// changes it makes. If the user gives up, nil is returned.
+func editUntilValid(text string, original []*api.TimeEntry, ref time.Time) (*editPlan, error) {
+	hasErrors := false
+	for {
+		edited, err := editText(text)
+		if err != nil {
+			return nil, err
+		}
+		edited = stripEditErrors(edited)
+		if hasErrors && edited == stripEditErrors(text) {
+			fmt.Println("Edit aborted, nothing was changed.")
+			return nil, nil
+		}
+
+		entries, errs := parseEditDocument(edited)
+		if len(errs) == 0 && len(entries) == 0 {
+			fmt.Println("No entries left in the document - edit aborted, nothing was changed.")
+			return nil, nil
+		}
+		var plan *editPlan
+		if len(errs) == 0 {
+			plan, errs = planEdits(original, entries, ref)
+		}
+		if len(errs) == 0 {
+			return plan, nil
+		}
+
+		text = annotateEditErrors(edited, errs)
+		hasErrors = true
+	}
+}
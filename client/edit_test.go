@@ -0,0 +1,102 @@
+package client
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+)
+
+func editTestEntries() []*api.TimeEntry {
+	start := time.Date(2019, 1, 7, 8, 0, 30, 0, time.Local)
+	end := time.Date(2019, 1, 7, 16, 0, 30, 0, time.Local)
+	vacation := time.Date(2019, 1, 8, 12, 0, 0, 0, time.Local)
+	return []*api.TimeEntry{
+		{ID: "1", ProjectName: "acme", Type: "work", Start: &start, End: &end, Breaks: 30 * time.Minute, Comment: "planning\nreview"},
+		{ID: "2", ProjectName: "acme", Type: "vacation", Start: &vacation, End: &vacation},
+		// Project names can contain slashes
+		{ID: "3", ProjectName: "clients/internal", Type: "work", Start: &end},
+	}
+}
+
+func TestEditDocumentRoundTrip(t *testing.T) {
+	original := editTestEntries()
+	doc := formatEditDocument(original)
+	if !strings.Contains(doc, "- id: 1\n  project: acme\n  type: work\n  start: 2019-01-07 08:00\n  end: 2019-01-07 16:00\n  breaks: 30m\n  comment: planning\\nreview\n") {
+		t.Errorf("Unexpected edit document:\n%s", doc)
+	}
+	if !strings.Contains(doc, "  project: clients/internal/\n") {
+		t.Errorf("Expected a trailing slash after a project name with a slash:\n%s", doc)
+	}
+
+	entries, errs := parseEditDocument(doc)
+	if len(errs) != 0 || len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d (%v)", len(entries), errs)
+	}
+	plan, errs := planEdits(original, entries, time.Now())
+	if len(errs) != 0 || !plan.empty() {
+		t.Errorf("Expected no changes for an unchanged document, got %+v (%v)", plan, errs)
+	}
+}
+
+func TestPlanEdits(t *testing.T) {
+	original := editTestEntries()
+	doc := formatEditDocument(original)
+	// Change the end of the first entry, move the second entry and remove the third
+	doc = strings.Replace(doc, "end: 2019-01-07 16:00", "end: 17:15", 1)
+	doc = strings.Replace(doc, "- id: 2\n  project: acme", "- id: 2\n  project: internal", 1)
+	doc = doc[:strings.Index(doc, "- id: 3")]
+	doc += "- id: new\n  project: acme\n  start: 09:00\n  end: 10:00\n  comment: standup\n"
+
+	entries, errs := parseEditDocument(doc)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	ref := time.Date(2019, 1, 9, 0, 0, 0, 0, time.Local)
+	plan, errs := planEdits(original, entries, ref)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	if len(plan.updates) != 2 || plan.updates[0].ID != "1" || plan.updates[0].End.Format("2006-01-02 15:04") != "2019-01-07 17:15" {
+		t.Errorf("Unexpected updates: %v", plan.updates)
+	}
+	if len(plan.updates) == 2 && (plan.updates[1].ID != "2" || plan.updates[1].ProjectName != "internal") {
+		t.Errorf("Expected the moved entry to be updated in its new project, got %v", plan.updates[1])
+	}
+	if !plan.updates[0].Start.Equal(*original[0].Start) || plan.updates[0].Comment != "planning\nreview" {
+		t.Errorf("Expected the unchanged fields to be kept, got %v", plan.updates[0])
+	}
+	if len(plan.deletes) != 1 || plan.deletes[0].ID != "3" {
+		t.Errorf("Unexpected deletes: %v", plan.deletes)
+	}
+	if len(plan.creates) != 1 || plan.creates[0].ID != "" ||
+		plan.creates[0].Comment != "standup" || plan.creates[0].Start.Format("2006-01-02 15:04") != "2019-01-09 09:00" {
+		t.Errorf("Unexpected creates: %v", plan.creates)
+	}
+}
+
+func TestEditErrors(t *testing.T) {
+	original := editTestEntries()
+	doc := "- id: 1\n  project: acme\n  type: work\n  start: someday\n  end: 16:00\n" +
+		"- id: 9\n  project: acme\n" +
+		"garbage\n"
+
+	entries, errs := parseEditDocument(doc)
+	if len(errs) != 1 || errs[0].line != 8 {
+		t.Errorf("Expected an error on line 8, got %v", errs)
+	}
+	_, errs = planEdits(original, entries, time.Now())
+	if len(errs) != 2 || errs[0].line != 4 || errs[1].line != 6 {
+		t.Errorf("Expected errors on lines 4 and 6, got %v", errs)
+	}
+
+	annotated := annotateEditErrors(doc, errs)
+	if !strings.Contains(annotated, "  start: someday\n# ERROR: ") {
+		t.Errorf("Expected the error to be shown below the line, got:\n%s", annotated)
+	}
+	if stripEditErrors(annotated) != doc {
+		t.Errorf("Expected the annotations to be removed, got:\n%s", stripEditErrors(annotated))
+	}
+}
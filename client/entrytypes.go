@@ -0,0 +1,197 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+const entryTypesCacheFile = "entry_types.json"
+
+// entryTypesCacheAge is how long the cached entry types are used before they are fetched
+// again. Entry types rarely change, and every command needs them to format entries.
+const entryTypesCacheAge = time.Hour
+
+var commandLineEntryType api.EntryType
+
+func init() {
+	rootCmd.AddCommand(listEntryTypesCmd)
+	rootCmd.AddCommand(setEntryTypeCmd)
+	setEntryTypeCmd.Flags().BoolVar(&commandLineEntryType.TimeBased, "time-based", false, "Record entries of this type as hours rather than whole days")
+	setEntryTypeCmd.Flags().BoolVar(&commandLineEntryType.CountsAsWork, "counts-as-work", false, "Count entries of this type as worked time")
+	setEntryTypeCmd.Flags().BoolVar(&commandLineEntryType.FulfillsSchedule, "fulfills-schedule", false, "Count entries of this absence type towards the work schedule")
+	setEntryTypeCmd.Flags().StringVar(&commandLineEntryType.Color, "color", "", "Color used when displaying entries, like #4caf50")
+	rootCmd.AddCommand(deleteEntryTypeCmd)
+	deleteEntryTypeCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+}
+
+var listEntryTypesCmd = &cobra.Command{
+	Use:   "list-entry-types",
+	Short: "List entry types",
+	Long: `List the entry types that time entries can have. Entries of time-based types
+are recorded as hours, the others as whole days.`,
+	Args: cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return listEntryTypes()
+	}),
+}
+
+var setEntryTypeCmd = &cobra.Command{
+	Use:   "set-entry-type TYPENAME [--time-based] [--counts-as-work] [--fulfills-schedule] [--color COLOR]",
+	Short: "Add or change an entry type",
+	Long: `Add an entry type, or change an existing one. When changing a type, only the
+given flags are changed. New types cover whole days and do not count as work unless
+the flags say otherwise. Absences like parental leave or public holidays should
+fulfill the schedule, so that they do not reduce the flex balance.`,
+	Args: cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return setEntryType(cmd, args[0])
+	}),
+}
+
+var deleteEntryTypeCmd = &cobra.Command{
+	Use:   "delete-entry-type TYPENAME",
+	Short: "Delete an entry type",
+	Long: `Delete an entry type that has no time entries. Deleting one of the default
+types (work, sick, sick-child and vacation) reverts the changes made to it.`,
+	Args: cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return deleteEntryType(args[0])
+	}),
+}
+
+// entryTypesCache holds the entry types as of Fetched
+type entryTypesCache struct {
+	Types   []*api.EntryType `json:"types"`
+	Fetched time.Time        `json:"fetched"`
+}
+
+// loadEntryTypes returns the user's entry types from the cache, or from the server if the
+// cache is too old. If the server cannot be reached, a stale cache is used.
+func loadEntryTypes(apiClient *ApiClient, now time.Time) ([]*api.EntryType, error) {
+	var cached entryTypesCache
+	if err := internalLoadConfig(entryTypesCacheFile, &cached); err != nil {
+		return nil, err
+	}
+	fresh := now.Sub(cached.Fetched) < entryTypesCacheAge && !now.Before(cached.Fetched)
+	if !cached.Fetched.IsZero() && fresh {
+		return cached.Types, nil
+	}
+
+	types, err := apiClient.GetEntryTypes()
+	if isOffline(err) && !cached.Fetched.IsZero() {
+		return cached.Types, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := internalStoreConfig(entryTypesCacheFile, &entryTypesCache{Types: types, Fetched: now}, 0600); err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+// useEntryTypes makes entries be formatted and normalized according to the user's entry
+// types. Until it has been called, only work is treated as time-based.
+func useEntryTypes(apiClient *ApiClient) error {
+	types, err := loadEntryTypes(apiClient, time.Now())
+	if err != nil {
+		return err
+	}
+	api.SetEntryTypes(types)
+	return nil
+}
+
+// invalidateEntryTypesCache removes the cached entry types, for commands that change them
+func invalidateEntryTypesCache() {
+	os.Remove(filepath.Join(configDir(), entryTypesCacheFile))
+}
+
+func listEntryTypes() error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	types, err := apiClient.GetEntryTypes()
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
+	}
+	for _, entryType := range types {
+		if err := p.Print(entryType); err != nil {
+			return err
+		}
+	}
+
+	return p.Flush()
+}
+
+func setEntryType(cmd *cobra.Command, typeName string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	types, err := apiClient.GetEntryTypes()
+	if err != nil {
+		return err
+	}
+	entryType := &api.EntryType{Name: typeName}
+	for _, existing := range types {
+		if existing.Name == typeName {
+			entryType = existing
+		}
+	}
+
+	if cmd.Flags().Changed("time-based") {
+		entryType.TimeBased = commandLineEntryType.TimeBased
+	}
+	if cmd.Flags().Changed("counts-as-work") {
+		entryType.CountsAsWork = commandLineEntryType.CountsAsWork
+	}
+	if cmd.Flags().Changed("fulfills-schedule") {
+		entryType.FulfillsSchedule = commandLineEntryType.FulfillsSchedule
+	}
+	if cmd.Flags().Changed("color") {
+		entryType.Color = commandLineEntryType.Color
+	}
+
+	entryType, err = apiClient.SaveEntryType(entryType)
+	if err != nil {
+		return err
+	}
+	invalidateEntryTypesCache()
+	return printRecord(entryType)
+}
+
+func deleteEntryType(typeName string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	ok, err := confirm(fmt.Sprintf("Delete entry type %s?", typeName))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Not deleted.")
+		return nil
+	}
+
+	err = apiClient.DeleteEntryType(typeName)
+	if err != nil {
+		return err
+	}
+	invalidateEntryTypesCache()
+	fmt.Println("Entry type deleted.")
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+)
+
+func TestEntryTypesCache(t *testing.T) {
+	defer useTempConfigDir(t)()
+	defer api.SetEntryTypes(nil)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewEncoder(rw).Encode([]*api.EntryType{
+			{Name: "work", TimeBased: true, CountsAsWork: true},
+			{Name: "on-call", TimeBased: true},
+			{Name: "training", TimeBased: true, CountsAsWork: true},
+		})
+	}))
+	defer server.Close()
+	apiClient := newTestApiClient(server.URL)
+
+	now := time.Now()
+	if _, err := loadEntryTypes(apiClient, now); err != nil {
+		t.Fatal(err)
+	}
+	types, err := loadEntryTypes(apiClient, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 || len(types) != 3 {
+		t.Errorf("Expected the cached types to be used, got %d requests and %v", requests, types)
+	}
+
+	server.Close()
+	types, err = loadEntryTypes(apiClient, now.Add(entryTypesCacheAge))
+	if err != nil || len(types) != 3 {
+		t.Errorf("Expected the stale cache to be used while offline, got %v (%v)", types, err)
+	}
+
+	api.SetEntryTypes(types)
+	if !api.IsTimeBased("on-call") || api.IsWork("on-call") || !api.IsWork("training") || api.IsTimeBased("vacation") {
+		t.Errorf("Expected entries to be treated according to their type")
+	}
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.Local)
+	end := start.Add(90 * time.Minute)
+	entry := &api.TimeEntry{ID: "1", ProjectName: "acme", Type: "on-call", Start: &start, End: &end}
+	if s := entry.String(); s != "1 2019-01-07 08:00 - 2019-01-07 09:30           (   1h30m): acme on-call " {
+		t.Errorf("Expected on-call to be formatted with times, got %q", s)
+	}
+}
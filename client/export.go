@@ -0,0 +1,64 @@
+package client
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var exportFormat string
+var exportColumns []string
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVarP(&startTime, "start", "s", "", "Only entries that start at or later than this time/date")
+	exportCmd.Flags().StringVarP(&endTime, "end", "e", "", "Only entries that start earlier than this time/date")
+	exportCmd.Flags().StringVarP(&filterType, "type", "t", "", "Only entries of this type, see list-entry-types")
+	exportCmd.Flags().BoolVar(&filterOpen, "open", false, "Only entries that have not been stopped")
+	addTagFilterFlags(exportCmd)
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "csv", "File format (csv|ics)")
+	exportCmd.Flags().StringSliceVarP(&exportColumns, "columns", "c", nil,
+		"Columns in the csv file (id,date,project,task,billable,type,start,end,breaks,hours,days,comment,tags)")
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export [PROJECTNAME[/TASKNAME]] [-f csv|ics] [-c COLUMNS]",
+	Short: "Export time entries",
+	Long: `Export time entries for a project or one of its tasks, or for all projects, as CSV or iCalendar.
+The file is written to standard output. In iCalendar files, work is exported as timed
+events and other entry types as all-day events.
+` + projectTaskHelp,
+	Args: cobra.MaximumNArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return export(args[0])
+		}
+		return export("")
+	}),
+}
+
+func export(projectName string) error {
+	if exportFormat != "csv" && exportFormat != "ics" {
+		return errors.New("Export format must be csv or ics")
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	filter, err := entryFilterFromFlags()
+	if err != nil {
+		return err
+	}
+	projectName, filter.Task = splitProjectTask(projectName)
+
+	body, err := apiClient.ExportTimeEntries(projectName, exportFormat, exportColumns, filter)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(body)
+	return err
+}
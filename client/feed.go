@@ -0,0 +1,61 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+var resetFeed bool
+var revokeFeed bool
+
+func init() {
+	rootCmd.AddCommand(feedCmd)
+	feedCmd.Flags().BoolVar(&resetFeed, "reset", false, "Create a new feed URL. The old URL stops working.")
+	feedCmd.Flags().BoolVar(&revokeFeed, "revoke", false, "Disable the calendar feed")
+}
+
+var feedCmd = &cobra.Command{
+	Use:   "feed [--reset|--revoke]",
+	Short: "Show the calendar feed URL",
+	Long: `Show the URL of a read-only iCalendar feed of your time entries, which calendar
+apps can subscribe to. The feed is enabled the first time this command is run.
+Anyone who knows the URL can read the feed - use --reset if it has been shared by mistake.`,
+	Args: cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return feed()
+	}),
+}
+
+func feed() error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	if revokeFeed {
+		if err := apiClient.RevokeFeed(); err != nil {
+			return err
+		}
+		fmt.Println("Calendar feed disabled.")
+		return nil
+	}
+
+	var feed *api.Feed
+	if !resetFeed {
+		feed, err = apiClient.GetFeed()
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	if feed == nil {
+		feed, err = apiClient.ResetFeed()
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(apiClient.FeedURL(feed))
+	return nil
+}
@@ -1,8 +1,6 @@
 package client
 
 import (
-	"fmt"
-
 	"github.com/spf13/cobra"
 )
 
@@ -30,7 +28,5 @@ func getProject(projectName string) error {
 	if err != nil {
 		return err
 	}
-	fmt.Println(project)
-
-	return nil
+	return printRecord(project)
 }
@@ -2,21 +2,31 @@ package client
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var filterType string
+var filterOpen bool
+var filterTags []string
+var filterAnyTag bool
+
 func init() {
 	rootCmd.AddCommand(getTimeEntriesCmd)
-	getTimeEntriesCmd.Flags().StringVarP(&startTime, "start", "s", "", "Only entries that start later than this start time/date")
-	getTimeEntriesCmd.Flags().StringVarP(&endTime, "end", "e", "", "Only entries that end earlier than this time/date")
+	getTimeEntriesCmd.Flags().StringVarP(&startTime, "start", "s", "", "Only entries that start at or later than this time/date")
+	getTimeEntriesCmd.Flags().StringVarP(&endTime, "end", "e", "", "Only entries that start earlier than this time/date")
+	getTimeEntriesCmd.Flags().StringVarP(&filterType, "type", "t", "", "Only entries of this type, see list-entry-types")
+	getTimeEntriesCmd.Flags().BoolVar(&filterOpen, "open", false, "Only entries that have not been stopped")
+	addTagFilterFlags(getTimeEntriesCmd)
 }
 
 var getTimeEntriesCmd = &cobra.Command{
-	Use:   "get-entries [PROJECTNAME]",
+	Use:   "get-entries [PROJECTNAME[/TASKNAME]]",
 	Short: "Show time entries",
-	Long:  `List time entries for a project, or for all projects`,
+	Long:  "List time entries for a project or one of its tasks, or for all projects.\n" + projectTaskHelp,
 	Args:  cobra.MaximumNArgs(1),
 	Run: runCommand(func(cmd *cobra.Command, args []string) error {
 		if len(args) > 0 {
@@ -27,46 +37,80 @@ var getTimeEntriesCmd = &cobra.Command{
 	}),
 }
 
-func getTimeEntries(projectName string) error {
-	apiClient, err := NewApiClient()
-	if err != nil {
-		return err
-	}
+// addTagFilterFlags adds the --tag and --any-tag flags that entryFilterFromFlags reads
+func addTagFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&filterTags, "tag", nil, "Only entries with this tag; can be repeated or comma separated")
+	cmd.Flags().BoolVar(&filterAnyTag, "any-tag", false, "Match entries with any of the tags rather than all of them")
+}
 
-	timeentries, err := apiClient.GetTimeEntries(projectName)
-	if err != nil {
-		return err
+// entryFilterFromFlags builds a filter from the --start, --end, --type, --open, --tag and
+// --any-tag flags
+func entryFilterFromFlags() (*TimeEntryFilter, error) {
+	filter := TimeEntryFilter{
+		Type:   filterType,
+		Open:   filterOpen,
+		Tags:   splitTags(strings.Join(filterTags, " ")),
+		AnyTag: filterAnyTag,
 	}
 
-	var start time.Time
-	var end time.Time
 	now := time.Now()
 	if startTime != "" {
-		start, err = ParseTimeRef(startTime, now)
+		start, err := ParseTimeRef(startTime, now)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		filter.From = &start
 	}
 
 	if endTime != "" {
-		end, err = ParseTimeRef(endTime, now)
+		end, err := ParseTimeRef(endTime, now)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		filter.To = &end
+	}
+
+	return &filter, nil
+}
+
+func getTimeEntries(projectName string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	filter, err := entryFilterFromFlags()
+	if err != nil {
+		return err
+	}
+	projectName, filter.Task = splitProjectTask(projectName)
+
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
 	}
 
 	var totalDuration time.Duration
-	for _, timeentry := range timeentries {
-		if !start.IsZero() && (timeentry.Start == nil || timeentry.Start.Before(start)) {
-			continue
-		}
-		if !end.IsZero() && (timeentry.End == nil || timeentry.End.After(end)) {
-			continue
+	it := apiClient.IterateTimeEntries(projectName, filter)
+	for it.Next() {
+		timeentry := it.Entry()
+		if err := p.Print(timeentry); err != nil {
+			return err
 		}
-		fmt.Println(timeentry)
 		totalDuration += timeentry.Duration()
 	}
-	fmt.Println("Total duration: " + totalDuration.String())
+	if err := it.Err(); err != nil {
+		if isOffline(err) {
+			printPendingOperations()
+		}
+		return err
+	}
+	if err := p.Flush(); err != nil {
+		return err
+	}
+	if isTableOutput() {
+		fmt.Println("Total duration: " + totalDuration.String())
+	}
 
-	return nil
+	return printPendingOperations()
 }
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+var importFormat string
+var importColumns map[string]string
+var importDryRun bool
+var importDayStart string
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVarP(&importFormat, "format", "f", "csv", "File format (toggl|clockify|harvest|csv|json)")
+	importCmd.Flags().StringToStringVarP(&importColumns, "map", "m", nil,
+		"Map an entry field to a column, e.g. --map project=Client (fields: project,type,comment,start,end,date,starttime,enddate,endtime,duration,breaks)")
+	importCmd.Flags().BoolVarP(&importDryRun, "dry-run", "n", false, "Only report what would be imported")
+	importCmd.Flags().StringVar(&importDayStart, "day-start", "08:00", "Start time for entries that only have a date and a duration")
+	importCmd.Flags().BoolVar(&allowOverlap, "allow-overlap", false, "Import entries even if they overlap other work")
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import FILE [-f FORMAT] [-m FIELD=COLUMN]... [--dry-run]",
+	Short: "Import time entries",
+	Long: `Import time entries from a file exported from another time tracker, or from
+a generic CSV or JSON file. Use - to read from standard input.
+
+Missing projects are created. Entries that are already tracked are skipped, and
+entries that overlap other work are reported. The server refuses overlapping work
+entries unless --allow-overlap is given. Use --dry-run to see this before anything
+is written.`,
+	Args: cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return importEntries(args[0])
+	}),
+}
+
+func readImportEntries(fileName string) ([]*api.TimeEntry, error) {
+	dayStart, err := parseImportTimeOfDay(importDayStart)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = os.Stdin
+	if fileName != "-" {
+		f, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	return readImportFile(r, importFormat, importColumns, time.Local, dayStart)
+}
+
+// missingProjects returns the names of the projects in entries that do not exist
+func missingProjects(apiClient *ApiClient, entries []*api.TimeEntry) ([]string, error) {
+	projects, err := apiClient.ListProjects("all")
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool)
+	for _, project := range projects {
+		exists[project.Name] = true
+	}
+
+	var missing []string
+	for _, entry := range entries {
+		if !exists[entry.ProjectName] {
+			exists[entry.ProjectName] = true
+			missing = append(missing, entry.ProjectName)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// existingEntries returns the tracked entries that could conflict with the imported ones
+func existingEntries(apiClient *ApiClient, entries []*api.TimeEntry) ([]*api.TimeEntry, error) {
+	from := *entries[0].Start
+	to := entryEnd(entries[0], time.Now())
+	for _, entry := range entries {
+		if entry.Start.Before(from) {
+			from = *entry.Start
+		}
+		if end := entryEnd(entry, time.Now()); end.After(to) {
+			to = end
+		}
+	}
+	// Entries that start a day earlier may still run into the imported range
+	from = from.Add(-24 * time.Hour)
+
+	return apiClient.GetTimeEntries("", &TimeEntryFilter{From: &from, To: &to})
+}
+
+func importEntries(fileName string) error {
+	entries, err := readImportEntries(fileName)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No time entries found.")
+		return nil
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	missing, err := missingProjects(apiClient, entries)
+	if err != nil {
+		return err
+	}
+	existing, err := existingEntries(apiClient, entries)
+	if err != nil {
+		return err
+	}
+	toAdd, conflicts := findImportConflicts(entries, existing)
+
+	// The report goes to standard error so that other output formats stay machine-readable
+	report := os.Stderr
+	for _, projectName := range missing {
+		fmt.Fprintf(report, "New project: %s\n", projectName)
+	}
+	for _, entry := range conflicts.Duplicates {
+		fmt.Fprintf(report, "Already tracked, skipping: %s\n", entry)
+	}
+	for _, pair := range conflicts.Overlaps {
+		fmt.Fprintf(report, "Overlap: %s\n   with: %s\n", pair[0], pair[1])
+	}
+	fmt.Fprintf(report, "%d of %d time entries to import, %d duplicates, %d overlaps\n",
+		len(toAdd), len(entries), len(conflicts.Duplicates), len(conflicts.Overlaps))
+
+	if importDryRun || len(toAdd) == 0 {
+		return nil
+	}
+
+	for _, projectName := range missing {
+		if _, err := apiClient.CreateProject(&api.Project{Name: projectName}); err != nil {
+			return err
+		}
+	}
+
+	added, err := apiClient.AddTimeEntries(toAdd)
+	if err != nil {
+		if len(added) > 0 {
+			fmt.Fprintf(report, "Imported %d time entries before the error\n", len(added))
+		}
+		return err
+	}
+
+	if isTableOutput() {
+		fmt.Printf("Imported %d time entries.\n", len(added))
+		return nil
+	}
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
+	}
+	for _, entry := range added {
+		if err := p.Print(entry); err != nil {
+			return err
+		}
+	}
+	return p.Flush()
+}
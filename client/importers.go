@@ -0,0 +1,486 @@
+package client
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+)
+
+// Fields of a time entry that columns in an imported file can be mapped to. A time entry
+// needs either start, or date and starttime. The end is taken from end, from enddate and
+// endtime, or from the start and duration. Entries with a date and a duration but no start
+// time are laid out one after another from dayStart.
+const (
+	importProject   = "project"
+	importType      = "type"
+	importComment   = "comment"
+	importStart     = "start"
+	importEnd       = "end"
+	importDate      = "date"
+	importStartTime = "starttime"
+	importEndDate   = "enddate"
+	importEndTime   = "endtime"
+	importDuration  = "duration"
+	importBreaks    = "breaks"
+)
+
+var importFields = []string{importProject, importType, importComment, importStart, importEnd, importDate,
+	importStartTime, importEndDate, importEndTime, importDuration, importBreaks}
+
+// importMappings are the column mappings for the CSV exports of other time trackers
+var importMappings = map[string]map[string]string{
+	"toggl": {
+		importProject:   "Project",
+		importComment:   "Description",
+		importDate:      "Start date",
+		importStartTime: "Start time",
+		importEndDate:   "End date",
+		importEndTime:   "End time",
+	},
+	"clockify": {
+		importProject:   "Project",
+		importComment:   "Description",
+		importDate:      "Start Date",
+		importStartTime: "Start Time",
+		importEndDate:   "End Date",
+		importEndTime:   "End Time",
+	},
+	"harvest": {
+		importProject:  "Project",
+		importComment:  "Notes",
+		importDate:     "Date",
+		importDuration: "Hours",
+	},
+	"csv": {
+		importProject: "project",
+		importType:    "type",
+		importComment: "comment",
+		importStart:   "start",
+		importEnd:     "end",
+		importBreaks:  "breaks",
+	},
+}
+
+// importFormats lists the formats the import command can read
+var importFormats = []string{"toggl", "clockify", "harvest", "csv", "json"}
+
+var importDateFormats = [...]string{
+	"2006-01-02",
+	"01/02/2006",
+	"02.01.2006",
+}
+
+var importTimeFormats = [...]string{
+	"15:04:05",
+	"15:04",
+	"03:04:05 PM",
+	"3:04:05 PM",
+	"03:04 PM",
+	"3:04 PM",
+}
+
+var importDateTimeFormats = [...]string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// entryImporter turns records read from a file into time entries
+type entryImporter struct {
+	mapping  map[string]string
+	loc      *time.Location
+	dayStart time.Duration
+	// nextStart is where the next entry on a day starts, for entries without a start time
+	nextStart map[string]time.Time
+}
+
+func newEntryImporter(mapping map[string]string, loc *time.Location, dayStart time.Duration) (*entryImporter, error) {
+	for field := range mapping {
+		known := false
+		for _, f := range importFields {
+			known = known || f == field
+		}
+		if !known {
+			return nil, fmt.Errorf("Unknown field in column mapping: %s (valid fields are %s)", field, strings.Join(importFields, ", "))
+		}
+	}
+	return &entryImporter{
+		mapping:   mapping,
+		loc:       loc,
+		dayStart:  dayStart,
+		nextStart: make(map[string]time.Time),
+	}, nil
+}
+
+// importMapping returns the column mapping for a format, with the fields in overrides
+// replacing those of the format
+func importMapping(format string, overrides map[string]string) map[string]string {
+	mapping := make(map[string]string)
+	for field, column := range importMappings[format] {
+		mapping[field] = column
+	}
+	for field, column := range overrides {
+		mapping[field] = column
+	}
+	return mapping
+}
+
+func parseImportDate(value string, loc *time.Location) (time.Time, error) {
+	for _, f := range importDateFormats {
+		result, err := time.ParseInLocation(f, value, loc)
+		if err == nil {
+			return result, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s", value)
+}
+
+func parseImportTimeOfDay(value string) (time.Duration, error) {
+	for _, f := range importTimeFormats {
+		result, err := time.Parse(f, strings.ToUpper(value))
+		if err == nil {
+			return result.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time: %s", value)
+}
+
+func parseImportDateTime(value string, loc *time.Location) (time.Time, error) {
+	if result, err := time.Parse(time.RFC3339, value); err == nil {
+		return result, nil
+	}
+	for _, f := range importDateTimeFormats {
+		result, err := time.ParseInLocation(f, value, loc)
+		if err == nil {
+			return result, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s", value)
+}
+
+// parseImportDuration accepts Go durations (1h30m), clock durations (1:30 or 1:30:00)
+// and decimal hours (1.5)
+func parseImportDuration(value string) (time.Duration, error) {
+	if result, err := time.ParseDuration(value); err == nil {
+		return result, nil
+	}
+	if parts := strings.Split(value, ":"); len(parts) == 2 || len(parts) == 3 {
+		var result time.Duration
+		units := []time.Duration{time.Hour, time.Minute, time.Second}
+		for idx, part := range parts {
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration: %s", value)
+			}
+			result += time.Duration(n) * units[idx]
+		}
+		return result, nil
+	}
+	hours, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+	return time.Duration(hours * float64(time.Hour)).Round(time.Second), nil
+}
+
+// atTimeOfDay returns the given time of day on the date, in the importer's time zone
+func (im *entryImporter) atTimeOfDay(date time.Time, timeOfDay time.Duration) time.Time {
+	y, m, d := date.Date()
+	return time.Date(y, m, d, int(timeOfDay/time.Hour), int(timeOfDay%time.Hour/time.Minute), int(timeOfDay%time.Minute/time.Second), 0, im.loc)
+}
+
+func (im *entryImporter) field(record map[string]string, field string) string {
+	column := im.mapping[field]
+	if column == "" {
+		return ""
+	}
+	return strings.TrimSpace(record[column])
+}
+
+func (im *entryImporter) dateAndTime(record map[string]string, dateField string, timeField string) (*time.Time, error) {
+	dateValue := im.field(record, dateField)
+	timeValue := im.field(record, timeField)
+	if dateValue == "" || timeValue == "" {
+		return nil, nil
+	}
+	date, err := parseImportDate(dateValue, im.loc)
+	if err != nil {
+		return nil, err
+	}
+	timeOfDay, err := parseImportTimeOfDay(timeValue)
+	if err != nil {
+		return nil, err
+	}
+	result := im.atTimeOfDay(date, timeOfDay)
+	return &result, nil
+}
+
+// entry converts one record to a time entry
+func (im *entryImporter) entry(record map[string]string) (*api.TimeEntry, error) {
+	entry := api.TimeEntry{
+		ProjectName: im.field(record, importProject),
+		Type:        im.field(record, importType),
+		Comment:     im.field(record, importComment),
+	}
+	if entry.ProjectName == "" {
+		return nil, fmt.Errorf("project is missing")
+	}
+	if entry.Type == "" {
+		entry.Type = "work"
+	}
+
+	var err error
+	if value := im.field(record, importStart); value != "" {
+		start, err := parseImportDateTime(value, im.loc)
+		if err != nil {
+			return nil, err
+		}
+		entry.Start = &start
+	} else if entry.Start, err = im.dateAndTime(record, importDate, importStartTime); err != nil {
+		return nil, err
+	}
+
+	if value := im.field(record, importEnd); value != "" {
+		end, err := parseImportDateTime(value, im.loc)
+		if err != nil {
+			return nil, err
+		}
+		entry.End = &end
+	} else if entry.End, err = im.dateAndTime(record, importEndDate, importEndTime); err != nil {
+		return nil, err
+	}
+	if entry.End == nil && entry.Start != nil && im.field(record, importEndTime) != "" {
+		// The end is on the same day as the start
+		endTime, err := parseImportTimeOfDay(im.field(record, importEndTime))
+		if err != nil {
+			return nil, err
+		}
+		end := im.atTimeOfDay(entry.Start.In(im.loc), endTime)
+		entry.End = &end
+	}
+
+	if value := im.field(record, importBreaks); value != "" {
+		if entry.Breaks, err = parseImportDuration(value); err != nil {
+			return nil, err
+		}
+	}
+
+	if value := im.field(record, importDuration); value != "" && entry.End == nil {
+		duration, err := parseImportDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Start == nil {
+			dateValue := im.field(record, importDate)
+			if dateValue == "" {
+				return nil, fmt.Errorf("start or date is missing")
+			}
+			date, err := parseImportDate(dateValue, im.loc)
+			if err != nil {
+				return nil, err
+			}
+			start, ok := im.nextStart[dateValue]
+			if !ok {
+				start = im.atTimeOfDay(date, im.dayStart)
+			}
+			entry.Start = &start
+			im.nextStart[dateValue] = start.Add(duration + entry.Breaks)
+		}
+		end := entry.Start.Add(duration + entry.Breaks)
+		entry.End = &end
+	}
+
+	if entry.Start == nil {
+		return nil, fmt.Errorf("start is missing")
+	}
+	return &entry, nil
+}
+
+// readCSVRecords reads a CSV file with a header row into one map per row, keyed by column name
+func readCSVRecords(r io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	var records []map[string]string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		record := make(map[string]string)
+		for idx, value := range row {
+			if idx < len(header) {
+				record[header[idx]] = value
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// readJSONRecords reads a JSON array of objects. Values that are not strings are kept in
+// their JSON form.
+func readJSONRecords(r io.Reader) ([]map[string]string, error) {
+	var objects []map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&objects); err != nil {
+		return nil, err
+	}
+
+	var records []map[string]string
+	for _, object := range objects {
+		record := make(map[string]string)
+		for key, value := range object {
+			switch v := value.(type) {
+			case string:
+				record[key] = v
+			case nil:
+			default:
+				encoded, _ := json.Marshal(v)
+				record[key] = string(encoded)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// readImportFile parses a file in one of the importFormats. Without a column mapping, JSON
+// files are read in the format written by get-entries --output json.
+func readImportFile(r io.Reader, format string, overrides map[string]string, loc *time.Location, dayStart time.Duration) ([]*api.TimeEntry, error) {
+	var records []map[string]string
+	var err error
+	switch format {
+	case "json":
+		if len(overrides) == 0 {
+			var entries []*api.TimeEntry
+			if err := json.NewDecoder(r).Decode(&entries); err != nil {
+				return nil, err
+			}
+			return entries, nil
+		}
+		records, err = readJSONRecords(r)
+	case "toggl", "clockify", "harvest", "csv":
+		records, err = readCSVRecords(r)
+	default:
+		return nil, fmt.Errorf("Unknown import format: %s (valid formats are %s)", format, strings.Join(importFormats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	importer, err := newEntryImporter(importMapping(format, overrides), loc, dayStart)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*api.TimeEntry
+	for idx, record := range records {
+		entry, err := importer.entry(record)
+		if err != nil {
+			// Row numbers count the header row, as in a spreadsheet
+			return nil, fmt.Errorf("Row %d: %v", idx+2, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// importConflicts describes imported entries that are already tracked or that overlap
+// other entries
+type importConflicts struct {
+	Duplicates []*api.TimeEntry
+	Overlaps   [][2]*api.TimeEntry
+}
+
+func sameTime(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func isDuplicate(a *api.TimeEntry, b *api.TimeEntry) bool {
+	return a.ProjectName == b.ProjectName && a.Type == b.Type && sameTime(a.Start, b.Start) && sameTime(a.End, b.End)
+}
+
+func entryEnd(e *api.TimeEntry, now time.Time) time.Time {
+	if e.End != nil {
+		return *e.End
+	}
+	return now
+}
+
+func overlaps(a *api.TimeEntry, b *api.TimeEntry, now time.Time) bool {
+	if !api.IsWork(a.Type) || !api.IsWork(b.Type) {
+		return false
+	}
+	return a.Start.Before(entryEnd(b, now)) && b.Start.Before(entryEnd(a, now))
+}
+
+// findImportConflicts checks the imported entries against the existing ones and each other.
+// Duplicates are returned separately from the entries to add, and are not checked for overlaps.
+func findImportConflicts(imported []*api.TimeEntry, existing []*api.TimeEntry) ([]*api.TimeEntry, *importConflicts) {
+	var conflicts importConflicts
+	var toAdd []*api.TimeEntry
+	now := time.Now()
+
+	for _, entry := range imported {
+		duplicate := false
+		for _, other := range existing {
+			duplicate = duplicate || isDuplicate(entry, other)
+		}
+		for _, other := range toAdd {
+			duplicate = duplicate || isDuplicate(entry, other)
+		}
+		if duplicate {
+			conflicts.Duplicates = append(conflicts.Duplicates, entry)
+		} else {
+			toAdd = append(toAdd, entry)
+		}
+	}
+
+	var all []*api.TimeEntry
+	for _, entry := range existing {
+		if entry.Start != nil {
+			all = append(all, entry)
+		}
+	}
+	all = append(all, toAdd...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Start.Before(*all[j].Start) })
+	isNew := make(map[*api.TimeEntry]bool)
+	for _, entry := range toAdd {
+		isNew[entry] = true
+	}
+	for i, entry := range all {
+		for _, other := range all[i+1:] {
+			if !other.Start.Before(entryEnd(entry, now)) {
+				break
+			}
+			if (isNew[entry] || isNew[other]) && overlaps(entry, other, now) {
+				conflicts.Overlaps = append(conflicts.Overlaps, [2]*api.TimeEntry{entry, other})
+			}
+		}
+	}
+
+	return toAdd, &conflicts
+}
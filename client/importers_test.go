@@ -0,0 +1,98 @@
+package client
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+)
+
+func TestReadImportFile(t *testing.T) {
+	oslo := time.FixedZone("CET", 3600)
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, oslo)
+
+	for _, test := range []struct {
+		format   string
+		mapping  map[string]string
+		input    string
+		expected []api.TimeEntry
+	}{
+		{"toggl", nil,
+			"\ufeffUser,Email,Client,Project,Task,Description,Billable,Start date,Start time,End date,End time,Duration,Tags,Amount ()\n" +
+				"Alice,alice@example.com,Acme Inc.,acme,,planning,Yes,2019-01-07,08:00:00,2019-01-07,10:30:00,02:30:00,,\n",
+			[]api.TimeEntry{{ProjectName: "acme", Type: "work", Comment: "planning", Start: &start, End: timeRef(start.Add(150 * time.Minute))}}},
+		{"clockify", nil,
+			"Project,Client,Description,Task,User,Group,Email,Tags,Billable,Start Date,Start Time,End Date,End Time,Duration (h),Duration (decimal)\n" +
+				"acme,Acme Inc.,planning,,Alice,,alice@example.com,,Yes,01/07/2019,08:00:00 AM,01/07/2019,01:15:00 PM,05:15:00,5.25\n",
+			[]api.TimeEntry{{ProjectName: "acme", Type: "work", Comment: "planning", Start: &start, End: timeRef(start.Add(315 * time.Minute))}}},
+		{"harvest", nil,
+			"Date,Client,Project,Project Code,Task,Notes,Hours,Billable?\n" +
+				"2019-01-07,Acme Inc.,acme,,Design,planning,1.5,Yes\n" +
+				"2019-01-07,Acme Inc.,acme,,Design,review,2,Yes\n",
+			[]api.TimeEntry{
+				{ProjectName: "acme", Type: "work", Comment: "planning", Start: &start, End: timeRef(start.Add(90 * time.Minute))},
+				{ProjectName: "acme", Type: "work", Comment: "review", Start: timeRef(start.Add(90 * time.Minute)), End: timeRef(start.Add(210 * time.Minute))}}},
+		{"csv", map[string]string{"project": "Job", "duration": "Length"},
+			"Job,start,Length,breaks\nacme,2019-01-07 08:00,8:00,0:30\n",
+			[]api.TimeEntry{{ProjectName: "acme", Type: "work", Start: &start, End: timeRef(start.Add(510 * time.Minute)), Breaks: 30 * time.Minute}}},
+		{"json", nil,
+			`[{"project_name":"acme","type":"vacation","start":"2019-01-07T08:00:00+01:00","end":"2019-01-07T08:00:00+01:00","breaks":0}]`,
+			[]api.TimeEntry{{ProjectName: "acme", Type: "vacation", Start: &start, End: &start}}},
+		{"json", map[string]string{"project": "client", "start": "from", "end": "to"},
+			`[{"client":"acme","from":"2019-01-07T08:00:00+01:00","to":"2019-01-07T09:00:00+01:00","id":17}]`,
+			[]api.TimeEntry{{ProjectName: "acme", Type: "work", Start: &start, End: timeRef(start.Add(time.Hour))}}},
+	} {
+		entries, err := readImportFile(strings.NewReader(test.input), test.format, test.mapping, oslo, 8*time.Hour)
+		if err != nil {
+			t.Errorf("Could not read %s file: %v", test.format, err)
+			continue
+		}
+		if len(entries) != len(test.expected) {
+			t.Errorf("Expected %d entries from %s file, got %d", len(test.expected), test.format, len(entries))
+			continue
+		}
+		for idx, entry := range entries {
+			expected := test.expected[idx]
+			if entry.ProjectName != expected.ProjectName || entry.Type != expected.Type || entry.Comment != expected.Comment ||
+				!sameTime(entry.Start, expected.Start) || !sameTime(entry.End, expected.End) || entry.Breaks != expected.Breaks {
+				t.Errorf("Unexpected entry from %s file: %v, expected %v", test.format, entry, &expected)
+			}
+		}
+	}
+
+	_, err := readImportFile(strings.NewReader("project,start\nacme,tomorrow\n"), "csv", nil, oslo, 0)
+	if err == nil || !strings.HasPrefix(err.Error(), "Row 2:") {
+		t.Errorf("Expected error for row 2, got %v", err)
+	}
+	_, err = readImportFile(strings.NewReader(""), "csv", map[string]string{"client": "Client"}, oslo, 0)
+	if err == nil {
+		t.Errorf("Expected error for unknown field in mapping")
+	}
+}
+
+func timeRef(t time.Time) *time.Time {
+	return &t
+}
+
+func TestFindImportConflicts(t *testing.T) {
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	existing := []*api.TimeEntry{
+		{ProjectName: "acme", Type: "work", Start: &start, End: timeRef(start.Add(2 * time.Hour))},
+	}
+	duplicate := &api.TimeEntry{ProjectName: "acme", Type: "work", Start: &start, End: timeRef(start.Add(2 * time.Hour))}
+	overlapping := &api.TimeEntry{ProjectName: "internal", Type: "work", Start: timeRef(start.Add(time.Hour)), End: timeRef(start.Add(3 * time.Hour))}
+	vacation := &api.TimeEntry{ProjectName: "acme", Type: "vacation", Start: &start, End: &start}
+	later := &api.TimeEntry{ProjectName: "acme", Type: "work", Start: timeRef(start.Add(3 * time.Hour)), End: timeRef(start.Add(4 * time.Hour))}
+
+	toAdd, conflicts := findImportConflicts([]*api.TimeEntry{duplicate, overlapping, vacation, later, later}, existing)
+	if len(toAdd) != 3 || toAdd[0] != overlapping || toAdd[1] != vacation || toAdd[2] != later {
+		t.Errorf("Unexpected entries to add: %v", toAdd)
+	}
+	if len(conflicts.Duplicates) != 2 {
+		t.Errorf("Expected 2 duplicates, got %v", conflicts.Duplicates)
+	}
+	if len(conflicts.Overlaps) != 1 || conflicts.Overlaps[0][0] != existing[0] || conflicts.Overlaps[0][1] != overlapping {
+		t.Errorf("Unexpected overlaps: %v", conflicts.Overlaps)
+	}
+}
@@ -0,0 +1,309 @@
+package client
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+// Formats that invoices can be rendered in, see renderInvoice
+const (
+	invoiceMarkdown = "markdown"
+	invoiceHTML     = "html"
+	invoiceText     = "text"
+)
+
+// invoiceTemplateDir is the directory below the config directory that holds the
+// invoice templates. Missing templates are created from the built-in defaults, so
+// they can be customized in place.
+const invoiceTemplateDir = "templates"
+
+var invoiceTemplateFiles = map[string]string{
+	invoiceMarkdown: "invoice.md.tmpl",
+	invoiceHTML:     "invoice.html.tmpl",
+	invoiceText:     "invoice.txt.tmpl",
+}
+
+var invoiceGroupBy string
+var invoiceFormat string
+
+func init() {
+	rootCmd.AddCommand(createInvoiceCmd)
+	createInvoiceCmd.Flags().StringVarP(&startTime, "start", "s", "", "Only entries that start at or later than this time/date")
+	createInvoiceCmd.Flags().StringVarP(&endTime, "end", "e", "", "Only entries that start earlier than this time/date")
+	createInvoiceCmd.Flags().StringVar(&invoiceGroupBy, "group-by", "project", "Line items per project or per entry comment (project|comment)")
+	createInvoiceCmd.Flags().StringVar(&invoiceFormat, "format", invoiceText, "Render the invoice as markdown, html or text")
+	rootCmd.AddCommand(listInvoicesCmd)
+	rootCmd.AddCommand(showInvoiceCmd)
+	showInvoiceCmd.Flags().StringVar(&invoiceFormat, "format", invoiceText, "Render the invoice as markdown, html or text")
+}
+
+var createInvoiceCmd = &cobra.Command{
+	Use:   "create-invoice PROJECTNAME... [-s START] [-e END] [--group-by project|comment]",
+	Short: "Invoice billable time",
+	Long: `Create an invoice for the time in the given billable projects that has not been
+invoiced yet. The invoice gets the next number in sequence, and the invoiced time
+entries can no longer be changed or deleted. Every entry must have a rate, see
+set-rate.
+The invoice is rendered from a template in the templates folder of the config
+directory, which is created with a default template the first time it is used.
+Use --output json for the invoice record instead.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return createInvoice(args)
+	}),
+}
+
+var listInvoicesCmd = &cobra.Command{
+	Use:   "list-invoices",
+	Short: "List invoices",
+	Long:  `List invoices in order of their number, with the total amounts`,
+	Args:  cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return listInvoices()
+	}),
+}
+
+var showInvoiceCmd = &cobra.Command{
+	Use:   "show-invoice NUMBER [--format markdown|html|text]",
+	Short: "Show an invoice",
+	Long: `Render an invoice from a template in the templates folder of the config
+directory. Use --output json for the invoice record instead.`,
+	Args: cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		number, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("Invalid invoice number: %s", args[0])
+		}
+		return showInvoice(number)
+	}),
+}
+
+func createInvoice(projectNames []string) error {
+	if _, ok := invoiceTemplateFiles[invoiceFormat]; !ok {
+		return fmt.Errorf("Unknown invoice format: %s", invoiceFormat)
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	filter, err := entryFilterFromFlags()
+	if err != nil {
+		return err
+	}
+
+	invoice, err := apiClient.CreateInvoice(&api.InvoiceRequest{
+		Projects: projectNames,
+		From:     filter.From,
+		To:       filter.To,
+		GroupBy:  invoiceGroupBy,
+	})
+	if err != nil {
+		return err
+	}
+	return printInvoice(invoice)
+}
+
+func listInvoices() error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	invoices, err := apiClient.ListInvoices()
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
+	}
+	for _, invoice := range invoices {
+		if err := p.Print(invoice); err != nil {
+			return err
+		}
+	}
+
+	return p.Flush()
+}
+
+func showInvoice(number int) error {
+	if _, ok := invoiceTemplateFiles[invoiceFormat]; !ok {
+		return fmt.Errorf("Unknown invoice format: %s", invoiceFormat)
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	invoice, err := apiClient.GetInvoice(number)
+	if err != nil {
+		return err
+	}
+	return printInvoice(invoice)
+}
+
+// printInvoice renders the invoice in the format selected with --format, or prints
+// the record if another output format than table is selected
+func printInvoice(invoice *api.Invoice) error {
+	if !isTableOutput() {
+		return printRecord(invoice)
+	}
+	return renderInvoice(os.Stdout, invoice, invoiceFormat)
+}
+
+// invoiceFuncs are the functions available to invoice templates
+var invoiceFuncs = map[string]interface{}{
+	"hours":  formatHours,
+	"amount": formatAmount,
+	"date":   formatInvoiceDate,
+	"currencies": func(totals map[string]float64) []string {
+		var result []string
+		for currency := range totals {
+			result = append(result, currency)
+		}
+		sort.Strings(result)
+		return result
+	},
+}
+
+// formatInvoiceDate formats a time or time pointer as a local date, or as an empty
+// string if the pointer is nil
+func formatInvoiceDate(t interface{}) string {
+	switch v := t.(type) {
+	case time.Time:
+		return v.In(time.Local).Format("2006-01-02")
+	case *time.Time:
+		if v != nil {
+			return v.In(time.Local).Format("2006-01-02")
+		}
+	}
+	return ""
+}
+
+// invoiceTemplate returns the text of the template for the format, creating the
+// template file from the default if it does not exist
+func invoiceTemplate(format string) (string, error) {
+	filePath := filepath.Join(configDir(), invoiceTemplateDir, invoiceTemplateFiles[format])
+	data, err := ioutil.ReadFile(filePath)
+	if err == nil {
+		return string(data), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	text := defaultInvoiceTemplates[format]
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filePath, []byte(text), 0644); err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// renderInvoice writes the invoice in one of the invoice formats. HTML is rendered with
+// html/template so that names and descriptions are escaped.
+func renderInvoice(w io.Writer, invoice *api.Invoice, format string) error {
+	if _, ok := invoiceTemplateFiles[format]; !ok {
+		return fmt.Errorf("Unknown invoice format: %s", format)
+	}
+	text, err := invoiceTemplate(format)
+	if err != nil {
+		return err
+	}
+
+	if format == invoiceHTML {
+		tmpl, err := htmltemplate.New("invoice").Funcs(invoiceFuncs).Parse(text)
+		if err != nil {
+			return fmt.Errorf("Invalid invoice template: %v", err)
+		}
+		return tmpl.Execute(w, invoice)
+	}
+	tmpl, err := template.New("invoice").Funcs(invoiceFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("Invalid invoice template: %v", err)
+	}
+	return tmpl.Execute(w, invoice)
+}
+
+var defaultInvoiceTemplates = map[string]string{
+	invoiceText: `INVOICE {{.Number}}
+Issued: {{date .Issued}}
+{{- if .Client}}
+Client: {{.Client}}
+{{- end}}
+{{- if or .From .To}}
+Period: {{date .From}} - {{date .To}}
+{{- end}}
+
+{{range .Lines -}}
+{{printf "%-40s %8sh x %10s = %12s %s" .Description (hours .Hours) (amount .Rate) (amount .Amount) .Currency}}
+{{end}}
+{{- range currencies .Totals -}}
+{{printf "%-40s %37s %s" "TOTAL" (amount (index $.Totals .)) .}}
+{{end -}}
+`,
+	invoiceMarkdown: `# Invoice {{.Number}}
+
+- Issued: {{date .Issued}}
+{{- if .Client}}
+- Client: {{.Client}}
+{{- end}}
+{{- if or .From .To}}
+- Period: {{date .From}} - {{date .To}}
+{{- end}}
+
+| Description | Hours | Rate | Amount |
+|---|--:|--:|--:|
+{{range .Lines -}}
+| {{.Description}} | {{hours .Hours}} | {{amount .Rate}} | {{amount .Amount}} {{.Currency}} |
+{{end -}}
+{{range currencies .Totals -}}
+| **Total** | | | **{{amount (index $.Totals .)}} {{.}}** |
+{{end -}}
+`,
+	invoiceHTML: `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+</head>
+<body>
+<h1>Invoice {{.Number}}</h1>
+<p>
+Issued: {{date .Issued}}
+{{- if .Client}}<br>
+Client: {{.Client}}
+{{- end}}
+{{- if or .From .To}}<br>
+Period: {{date .From}} - {{date .To}}
+{{- end}}
+</p>
+<table>
+<tr><th>Description</th><th>Hours</th><th>Rate</th><th>Amount</th></tr>
+{{range .Lines -}}
+<tr><td>{{.Description}}</td><td>{{hours .Hours}}</td><td>{{amount .Rate}}</td><td>{{amount .Amount}} {{.Currency}}</td></tr>
+{{end -}}
+{{range currencies .Totals -}}
+<tr><th colspan="3">Total</th><th>{{amount (index $.Totals .)}} {{.}}</th></tr>
+{{end -}}
+</table>
+</body>
+</html>
+`,
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+)
+
+func TestRenderInvoice(t *testing.T) {
+	defer useTempConfigDir(t)()
+
+	issued := time.Date(2019, 2, 1, 12, 0, 0, 0, time.UTC)
+	invoice := &api.Invoice{
+		Number: 7,
+		Issued: issued,
+		Client: "acme",
+		Lines: []*api.InvoiceLine{
+			{Project: "web", Description: "Design <b>review</b>", Hours: 90 * time.Minute, Rate: 100, Currency: "EUR", Amount: 150},
+		},
+		Totals: map[string]float64{"EUR": 150},
+	}
+
+	for _, test := range []struct {
+		format   string
+		expected []string
+	}{
+		{invoiceText, []string{"INVOICE 7", "Client: acme", "Design <b>review</b>", "1.50h", "150.00 EUR"}},
+		{invoiceMarkdown, []string{"# Invoice 7", "| Design <b>review</b> | 1.50 | 100.00 | 150.00 EUR |", "**150.00 EUR**"}},
+		{invoiceHTML, []string{"<h1>Invoice 7</h1>", "Design &lt;b&gt;review&lt;/b&gt;", "<th>150.00 EUR</th>"}},
+	} {
+		var buf bytes.Buffer
+		if err := renderInvoice(&buf, invoice, test.format); err != nil {
+			t.Fatalf("Rendering %s failed: %v", test.format, err)
+		}
+		for _, expected := range test.expected {
+			if !strings.Contains(buf.String(), expected) {
+				t.Errorf("Expected %s invoice to contain %q, got:\n%s", test.format, expected, buf.String())
+			}
+		}
+	}
+
+	// Changed templates are used as they are
+	templatePath := filepath.Join(configDir(), invoiceTemplateDir, invoiceTemplateFiles[invoiceText])
+	if err := ioutil.WriteFile(templatePath, []byte("Invoice {{.Number}} for {{.Client}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := renderInvoice(&buf, invoice, invoiceText); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "Invoice 7 for acme" {
+		t.Errorf("Expected the customized template to be used, got %q", buf.String())
+	}
+
+	if err := renderInvoice(&buf, invoice, "pdf"); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
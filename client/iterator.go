@@ -0,0 +1,154 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+
+	"github.com/kennep/timelapse/api"
+)
+
+// pageSize is the number of items requested per page when iterating
+const pageSize = 200
+
+var nextLinkPattern = regexp.MustCompile(`<([^>]*)>\s*;\s*rel="?next"?`)
+
+// nextLink returns the path of the next page from a Link response header, if there is one
+func nextLink(header http.Header) string {
+	for _, link := range header["Link"] {
+		matches := nextLinkPattern.FindStringSubmatch(link)
+		if matches != nil {
+			return matches[1]
+		}
+	}
+	return ""
+}
+
+// pager fetches consecutive pages from a list endpoint by following the next links
+type pager struct {
+	client *ApiClient
+	next   string
+	err    error
+}
+
+func newPager(client *ApiClient, path string, params url.Values) pager {
+	params.Set("limit", strconv.Itoa(pageSize))
+	return pager{
+		client: client,
+		next:   path + "?" + params.Encode(),
+	}
+}
+
+// fetch reads the next page into target. It returns false when there are no more pages
+// or an error occurred.
+func (p *pager) fetch(target interface{}) bool {
+	if p.next == "" || p.err != nil {
+		return false
+	}
+
+	body, header, err := p.client.doRequestWithHeaders("GET", p.next, nil)
+	if err != nil {
+		p.err = err
+		return false
+	}
+	if err = json.Unmarshal(body, target); err != nil {
+		p.err = err
+		return false
+	}
+
+	p.next = nextLink(header)
+	return true
+}
+
+// TimeEntryIterator iterates over time entries, fetching more pages from the server as needed.
+//
+//	it := apiClient.IterateTimeEntries("", nil)
+//	for it.Next() {
+//		fmt.Println(it.Entry())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type TimeEntryIterator struct {
+	pager
+	page    []*api.TimeEntry
+	current *api.TimeEntry
+}
+
+// IterateTimeEntries returns an iterator over the time entries of a project, or of all
+// projects if projectName is empty. Entries are ordered by start time.
+func (c *ApiClient) IterateTimeEntries(projectName string, filter *TimeEntryFilter) *TimeEntryIterator {
+	path := "/entries"
+	if projectName != "" {
+		path = fmt.Sprintf("/projects/%s/entries", url.QueryEscape(projectName))
+	}
+	return &TimeEntryIterator{pager: newPager(c, path, filter.values())}
+}
+
+// Next advances to the next entry. It returns false when there are no more entries.
+func (it *TimeEntryIterator) Next() bool {
+	for len(it.page) == 0 {
+		it.page = nil
+		if !it.fetch(&it.page) {
+			it.current = nil
+			return false
+		}
+	}
+	it.current = it.page[0]
+	it.page = it.page[1:]
+	return true
+}
+
+// Entry returns the current entry
+func (it *TimeEntryIterator) Entry() *api.TimeEntry {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *TimeEntryIterator) Err() error {
+	return it.err
+}
+
+// ProjectIterator iterates over projects, fetching more pages from the server as needed
+type ProjectIterator struct {
+	pager
+	page    []*api.Project
+	current *api.Project
+}
+
+// IterateProjects returns an iterator over projects, ordered by name. See ListProjects for
+// which projects archived selects.
+func (c *ApiClient) IterateProjects(archived string) *ProjectIterator {
+	params := make(url.Values)
+	if archived != "" {
+		params.Set("archived", archived)
+	}
+	return &ProjectIterator{pager: newPager(c, "/projects", params)}
+}
+
+// Next advances to the next project. It returns false when there are no more projects.
+func (it *ProjectIterator) Next() bool {
+	for len(it.page) == 0 {
+		it.page = nil
+		if !it.fetch(&it.page) {
+			it.current = nil
+			return false
+		}
+	}
+	it.current = it.page[0]
+	it.page = it.page[1:]
+	return true
+}
+
+// Project returns the current project
+func (it *ProjectIterator) Project() *api.Project {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *ProjectIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kennep/timelapse/api"
+)
+
+func newTestApiClient(serverURL string) *ApiClient {
+	return &ApiClient{
+		credentials: &Credentials{
+			Credentials:     map[string]*ProviderCredentials{"test": {IDToken: "token"}},
+			DefaultProvider: "test",
+		},
+		configuration: &Configuration{BaseURL: serverURL},
+		client:        &http.Client{},
+	}
+}
+
+func TestIteratorFollowsNextLinks(t *testing.T) {
+	pages := map[string][]*api.Project{
+		"":  {{Name: "alpha"}, {Name: "bravo"}},
+		"2": {{Name: "charlie"}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("limit") != fmt.Sprint(pageSize) {
+			t.Errorf("Expected page size to be requested, got %s", r.URL.RawQuery)
+		}
+		cursor := r.URL.Query().Get("cursor")
+		if cursor == "" {
+			rw.Header().Set("Link", "</projects?cursor=2&limit=200>; rel=\"next\"")
+		}
+		json.NewEncoder(rw).Encode(pages[cursor])
+	}))
+	defer server.Close()
+
+	projects, err := newTestApiClient(server.URL).ListProjects("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 3 || projects[2].Name != "charlie" {
+		t.Errorf("Unexpected projects: %v", projects)
+	}
+}
@@ -0,0 +1,373 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+)
+
+const journalFile = "journal.json"
+
+// Entries added while offline get a local ID until they have been synced
+const localIDPrefix = "local:"
+
+// Journal actions
+const (
+	journalAdd    = "add"
+	journalUpdate = "update"
+	// journalStop closes the open entry of a project, which is not known while offline
+	journalStop = "stop"
+)
+
+type (
+	// entryChanges are the fields an update sets. Fields that are nil are left as they are.
+	entryChanges struct {
+		Start   *time.Time     `json:"start,omitempty"`
+		End     *time.Time     `json:"end,omitempty"`
+		Breaks  *time.Duration `json:"breaks,omitempty"`
+		Type    *string        `json:"type,omitempty"`
+		Comment *string        `json:"comment,omitempty"`
+		Tags    *[]string      `json:"tags,omitempty"`
+	}
+
+	// journalOperation is a change made while the server could not be reached
+	journalOperation struct {
+		Action      string         `json:"action"`
+		Recorded    time.Time      `json:"recorded"`
+		ProjectName string         `json:"project_name"`
+		EntryID     string         `json:"entry_id,omitempty"`
+		Entry       *api.TimeEntry `json:"entry,omitempty"`
+		Changes     *entryChanges  `json:"changes,omitempty"`
+		// AllowOverlap is set if the change was made with --allow-overlap
+		AllowOverlap bool `json:"allow_overlap,omitempty"`
+		// Conflict is set when the operation could not be replayed. Such operations are kept
+		// for reference but not replayed again.
+		Conflict string `json:"conflict,omitempty"`
+	}
+
+	// journal holds the operations that are waiting to be sent to the server, in order
+	journal struct {
+		Operations []*journalOperation `json:"operations"`
+		NextID     int                 `json:"next_id"`
+	}
+)
+
+// isOffline tells whether a request failed because the server could not be reached, as
+// opposed to the server rejecting it
+func isOffline(err error) bool {
+	_, ok := err.(*url.Error)
+	return ok
+}
+
+func isLocalID(entryID string) bool {
+	return strings.HasPrefix(entryID, localIDPrefix)
+}
+
+func loadJournal() (*journal, error) {
+	var j journal
+	if err := internalLoadConfig(journalFile, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (j *journal) store() error {
+	return internalStoreConfig(journalFile, j, 0600)
+}
+
+// pending returns the operations that have not been replayed yet
+func (j *journal) pending() []*journalOperation {
+	var result []*journalOperation
+	for _, op := range j.Operations {
+		if op.Conflict == "" {
+			result = append(result, op)
+		}
+	}
+	return result
+}
+
+func (j *journal) conflicts() []*journalOperation {
+	var result []*journalOperation
+	for _, op := range j.Operations {
+		if op.Conflict != "" {
+			result = append(result, op)
+		}
+	}
+	return result
+}
+
+func (j *journal) record(op *journalOperation) {
+	op.Recorded = time.Now()
+	op.AllowOverlap = allowOverlap
+	j.Operations = append(j.Operations, op)
+}
+
+// add records a new entry and gives it a local ID
+func (j *journal) add(projectName string, entry *api.TimeEntry) *api.TimeEntry {
+	j.NextID++
+	entry.ID = fmt.Sprintf("%s%d", localIDPrefix, j.NextID)
+	entry.ProjectName = projectName
+	j.record(&journalOperation{Action: journalAdd, ProjectName: projectName, Entry: entry})
+	return entry
+}
+
+// pendingEntry returns the entry of a pending add with the given local ID
+func (j *journal) pendingEntry(entryID string) *api.TimeEntry {
+	for _, op := range j.pending() {
+		if op.Action == journalAdd && op.Entry.ID == entryID {
+			return op.Entry
+		}
+	}
+	return nil
+}
+
+// openEntry returns the last pending entry that has not been stopped, if any. If
+// projectName is given, only entries for that project are considered.
+func (j *journal) openEntry(projectName string) *api.TimeEntry {
+	var result *api.TimeEntry
+	for _, op := range j.pending() {
+		if op.Action == journalAdd && op.Entry.End == nil && (projectName == "" || op.ProjectName == projectName) {
+			result = op.Entry
+		}
+	}
+	return result
+}
+
+func (c *entryChanges) apply(entry *api.TimeEntry) {
+	if c.Start != nil {
+		entry.Start = c.Start
+	}
+	if c.End != nil {
+		entry.End = c.End
+	}
+	if c.Breaks != nil {
+		entry.Breaks = *c.Breaks
+	}
+	if c.Type != nil {
+		entry.Type = *c.Type
+	}
+	if c.Comment != nil {
+		entry.Comment = *c.Comment
+	}
+	if c.Tags != nil {
+		entry.Tags = *c.Tags
+	}
+	if !api.IsTimeBased(entry.Type) {
+		if entry.Start != nil {
+			*entry.Start = normalizeTime(*entry.Start)
+		}
+		if entry.End != nil {
+			*entry.End = normalizeTime(*entry.End)
+		}
+	}
+}
+
+// timerStop makes a request to stop the running timer with the end time and breaks
+// of the changes
+func (c *entryChanges) timerStop(projectName string) *api.TimerStop {
+	return &api.TimerStop{
+		ProjectName: projectName,
+		End:         c.End,
+		Breaks:      c.Breaks,
+	}
+}
+
+func (op *journalOperation) String() string {
+	switch op.Action {
+	case journalAdd:
+		return fmt.Sprintf("add: %s", op.Entry)
+	case journalStop:
+		project := op.ProjectName
+		if project == "" {
+			project = "any project"
+		}
+		return fmt.Sprintf("stop: %s at %s", project, op.Changes.End.In(time.Local).Format("2006-01-02 15:04"))
+	}
+	return fmt.Sprintf("update: %s %s", op.ProjectName, op.EntryID)
+}
+
+// recordOffline stores an operation made while the server was unreachable and tells the user
+func recordOffline(op func(j *journal)) error {
+	j, err := loadJournal()
+	if err != nil {
+		return err
+	}
+	op(j)
+	if err := j.store(); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Server unreachable - the change is kept in the offline journal. Run sync when back online.")
+	return nil
+}
+
+// recordAdd stores a new entry in the offline journal and returns it with its local ID
+func recordAdd(projectName string, entry *api.TimeEntry) (*api.TimeEntry, error) {
+	var result *api.TimeEntry
+	err := recordOffline(func(j *journal) {
+		result = j.add(projectName, entry)
+	})
+	return result, err
+}
+
+// addOrRecordTimeEntry adds an entry on the server or, if the server cannot be reached,
+// to the offline journal
+func addOrRecordTimeEntry(apiClient *ApiClient, projectName string, entry *api.TimeEntry) (*api.TimeEntry, error) {
+	result, err := apiClient.AddTimeEntry(projectName, entry)
+	if isOffline(err) {
+		return recordAdd(projectName, entry)
+	}
+	return result, err
+}
+
+// updateOrRecordTimeEntry saves an entry that has been changed on the server or, if the
+// server cannot be reached, records the changes in the offline journal
+func updateOrRecordTimeEntry(apiClient *ApiClient, entry *api.TimeEntry, changes *entryChanges) (*api.TimeEntry, error) {
+	result, err := apiClient.UpdateTimeEntry(entry.ProjectName, entry)
+	if isOffline(err) {
+		return entry, recordUpdate(entry.ProjectName, entry.ID, changes)
+	}
+	return result, err
+}
+
+// recordUpdate stores changes to an entry in the offline journal. Changes to entries that
+// are themselves waiting in the journal are made to the pending entry directly.
+func recordUpdate(projectName string, entryID string, changes *entryChanges) error {
+	if isLocalID(entryID) {
+		j, err := loadJournal()
+		if err != nil {
+			return err
+		}
+		entry := j.pendingEntry(entryID)
+		if entry == nil {
+			return fmt.Errorf("No pending time entry with id %s", entryID)
+		}
+		changes.apply(entry)
+		return j.store()
+	}
+
+	return recordOffline(func(j *journal) {
+		j.record(&journalOperation{Action: journalUpdate, ProjectName: projectName, EntryID: entryID, Changes: changes})
+	})
+}
+
+// printPendingOperations lists the operations in the journal, if any, in table output
+func printPendingOperations() error {
+	if !isTableOutput() {
+		return nil
+	}
+	j, err := loadJournal()
+	if err != nil {
+		return err
+	}
+	if pending := j.pending(); len(pending) > 0 {
+		fmt.Println("Pending offline changes (run sync to send them):")
+		for _, op := range pending {
+			fmt.Printf("  %s\n", op)
+		}
+	}
+	return nil
+}
+
+// replay sends an operation to the server. ids maps the local IDs of entries added earlier in
+// the same sync to the IDs the server gave them.
+func (op *journalOperation) replay(apiClient *ApiClient, ids map[string]string) error {
+	defer func(allowOverlap bool) { apiClient.AllowOverlap = allowOverlap }(apiClient.AllowOverlap)
+	apiClient.AllowOverlap = op.AllowOverlap
+
+	switch op.Action {
+	case journalAdd:
+		entry := *op.Entry
+		localID := entry.ID
+		entry.ID = ""
+		result, err := apiClient.AddTimeEntry(op.ProjectName, &entry)
+		if err != nil {
+			return err
+		}
+		ids[localID] = result.ID
+		return nil
+
+	case journalUpdate:
+		entryID := op.EntryID
+		if isLocalID(entryID) {
+			entryID = ids[entryID]
+			if entryID == "" {
+				return errors.New("the entry was never added to the server")
+			}
+		}
+		entry, err := apiClient.GetTimeEntry(op.ProjectName, entryID)
+		if isNotFound(err) {
+			return errors.New("the entry no longer exists on the server")
+		} else if err != nil {
+			return err
+		}
+		return op.update(apiClient, entry)
+
+	case journalStop:
+		_, err := apiClient.StopTimer(op.Changes.timerStop(op.ProjectName))
+		if isNotFound(err) {
+			return errors.New("there is no timer running on the server - it may have been stopped already")
+		}
+		return err
+	}
+	return fmt.Errorf("unknown journal action: %s", op.Action)
+}
+
+// update applies the changes to the current server version of the entry, unless the
+// entry has been closed on the server in the meantime
+func (op *journalOperation) update(apiClient *ApiClient, entry *api.TimeEntry) error {
+	if op.Changes.End != nil && entry.End != nil && !entry.End.Equal(*op.Changes.End) {
+		return fmt.Errorf("the entry was already closed on the server at %s",
+			entry.End.In(time.Local).Format("2006-01-02 15:04"))
+	}
+	op.Changes.apply(entry)
+	_, err := apiClient.UpdateTimeEntry(entry.ProjectName, entry)
+	return err
+}
+
+// sync replays the pending operations in order. Operations that conflict with the server
+// are marked and skipped. Syncing stops if the server cannot be reached; the operations
+// that remain are kept for the next sync. The number of operations sent is returned.
+func (j *journal) sync(apiClient *ApiClient) (int, error) {
+	ids := make(map[string]string)
+	var remaining []*journalOperation
+	sent := 0
+	var syncErr error
+
+	for idx, op := range j.Operations {
+		if op.Conflict != "" {
+			remaining = append(remaining, op)
+			continue
+		}
+		err := op.replay(apiClient, ids)
+		if err == nil {
+			sent++
+			continue
+		}
+		if isOffline(err) {
+			remaining = append(remaining, j.Operations[idx:]...)
+			syncErr = err
+			break
+		}
+		// The server rejected the operation, so replaying it again would not help
+		op.Conflict = err.Error()
+		remaining = append(remaining, op)
+	}
+
+	// Later operations on entries added in this sync must refer to their server IDs
+	for _, op := range remaining {
+		if serverID, ok := ids[op.EntryID]; ok {
+			op.EntryID = serverID
+		}
+	}
+
+	j.Operations = remaining
+	if err := j.store(); err != nil {
+		return sent, err
+	}
+	return sent, syncErr
+}
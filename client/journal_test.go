@@ -0,0 +1,117 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+)
+
+func useTempConfigDir(t *testing.T) func() {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "timelapse-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgDir = dir
+	return func() {
+		cfgDir = ""
+		os.RemoveAll(dir)
+	}
+}
+
+func TestJournalSync(t *testing.T) {
+	defer useTempConfigDir(t)()
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+	var updated []*api.TimeEntry
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var entry api.TimeEntry
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/timer/stop":
+			// The timer has been stopped from another machine
+			rw.WriteHeader(404)
+			json.NewEncoder(rw).Encode(&RemoteError{Message: "No timer is running"})
+		case r.Method == "POST" && r.URL.Path == "/projects/internal/entries":
+			json.NewDecoder(r.Body).Decode(&entry)
+			entry.ID = "server-1"
+			entry.ProjectName = "internal"
+			json.NewEncoder(rw).Encode(&entry)
+		case r.Method == "GET" && r.URL.Path == "/projects/internal/entries/server-1":
+			json.NewEncoder(rw).Encode(&api.TimeEntry{ID: "server-1", ProjectName: "internal", Type: "work", Start: &start})
+		case r.Method == "PUT" && r.URL.Path == "/projects/internal/entries/server-1":
+			json.NewDecoder(r.Body).Decode(&entry)
+			updated = append(updated, &entry)
+			json.NewEncoder(rw).Encode(&entry)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+			rw.WriteHeader(500)
+		}
+	}))
+	defer server.Close()
+
+	j, err := loadJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.record(&journalOperation{Action: journalStop, ProjectName: "acme", Changes: &entryChanges{End: &end}})
+	added := j.add("internal", &api.TimeEntry{Type: "work", Start: &start})
+	comment := "offline"
+	j.record(&journalOperation{Action: journalUpdate, ProjectName: "internal", EntryID: added.ID, Changes: &entryChanges{End: &end, Comment: &comment}})
+	if err := j.store(); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err = loadJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, err := j.sync(newTestApiClient(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 2 {
+		t.Errorf("Expected 2 changes to be sent, got %d", sent)
+	}
+	if len(updated) != 1 || updated[0].Comment != "offline" || updated[0].End == nil || !updated[0].End.Equal(end) {
+		t.Errorf("Unexpected updates: %v", updated)
+	}
+
+	j, err = loadJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(j.pending()) != 0 || len(j.conflicts()) != 1 || j.conflicts()[0].Action != journalStop {
+		t.Errorf("Expected only the stop to remain as a conflict, got %v", j.Operations)
+	}
+}
+
+func TestJournalSyncOffline(t *testing.T) {
+	defer useTempConfigDir(t)()
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	serverURL := server.URL
+	server.Close()
+
+	start := time.Now()
+	j, err := loadJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.add("acme", &api.TimeEntry{Type: "work", Start: &start})
+
+	sent, err := j.sync(newTestApiClient(serverURL))
+	if !isOffline(err) {
+		t.Errorf("Expected sync to fail as offline, got %v", err)
+	}
+	if sent != 0 || len(j.pending()) != 1 || len(j.conflicts()) != 0 {
+		t.Errorf("Expected the operation to stay pending, got %v", j.Operations)
+	}
+}
@@ -1,13 +1,20 @@
 package client
 
 import (
-	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	listArchived    bool
+	listAllProjects bool
+)
+
 func init() {
 	rootCmd.AddCommand(listProjectCmd)
+	listProjectCmd.Flags().BoolVar(&listArchived, "archived", false, "List the archived projects instead of the active ones")
+	listProjectCmd.Flags().BoolVarP(&listAllProjects, "all", "a", false, "List both active and archived projects")
 }
 
 var listProjectCmd = &cobra.Command{
@@ -26,13 +33,25 @@ func listProjects() error {
 		return err
 	}
 
-	projects, err := apiClient.ListProjects()
+	archived := ""
+	if listAllProjects {
+		archived = "all"
+	} else if listArchived {
+		archived = "true"
+	}
+	projects, err := apiClient.ListProjects(archived)
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(os.Stdout, true)
 	if err != nil {
 		return err
 	}
 	for _, project := range projects {
-		fmt.Println(project)
+		if err := p.Print(project); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return p.Flush()
 }
@@ -0,0 +1,265 @@
+package client
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+)
+
+// Output formats selectable with --output. Any other value containing "{{", or
+// prefixed with "template=", is used as a Go text/template for each record.
+const (
+	outputTable  = "table"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+	outputCSV    = "csv"
+
+	templatePrefix = "template="
+)
+
+var outputFormat string
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable,
+		"Output format (table|json|ndjson|csv|template=GOTEMPLATE)")
+}
+
+// printer writes command results in the format selected with --output. Records are
+// printed with Print; Flush must be called once all records have been printed.
+type printer interface {
+	Print(record interface{}) error
+	Flush() error
+}
+
+// newPrinter creates a printer for the selected output format. If list is true, the
+// records are a list, which matters for the json format where they are written as an
+// array rather than as a single object.
+func newPrinter(w io.Writer, list bool) (printer, error) {
+	switch outputFormat {
+	case outputTable:
+		return &tablePrinter{w: w}, nil
+	case outputJSON:
+		return &jsonPrinter{w: w, list: list}, nil
+	case outputNDJSON:
+		return &ndjsonPrinter{encoder: json.NewEncoder(w)}, nil
+	case outputCSV:
+		return &csvPrinter{w: csv.NewWriter(w)}, nil
+	}
+
+	text := outputFormat
+	if strings.HasPrefix(text, templatePrefix) {
+		text = strings.TrimPrefix(text, templatePrefix)
+	} else if !strings.Contains(text, "{{") {
+		return nil, fmt.Errorf("Unknown output format: %s", outputFormat)
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid output template: %v", err)
+	}
+	return &templatePrinter{w: w, template: tmpl}, nil
+}
+
+// isTableOutput tells whether the human readable format is selected, for commands
+// that print extra information such as totals
+func isTableOutput() bool {
+	return outputFormat == outputTable
+}
+
+// printRecord prints a single result in the selected output format
+func printRecord(record interface{}) error {
+	p, err := newPrinter(os.Stdout, false)
+	if err != nil {
+		return err
+	}
+	if err := p.Print(record); err != nil {
+		return err
+	}
+	return p.Flush()
+}
+
+type tablePrinter struct {
+	w io.Writer
+}
+
+func (p *tablePrinter) Print(record interface{}) error {
+	_, err := fmt.Fprintln(p.w, record)
+	return err
+}
+
+func (p *tablePrinter) Flush() error {
+	return nil
+}
+
+type jsonPrinter struct {
+	w       io.Writer
+	list    bool
+	records []interface{}
+}
+
+func (p *jsonPrinter) Print(record interface{}) error {
+	p.records = append(p.records, record)
+	return nil
+}
+
+func (p *jsonPrinter) Flush() error {
+	encoder := json.NewEncoder(p.w)
+	encoder.SetIndent("", "  ")
+	if !p.list {
+		if len(p.records) == 0 {
+			return nil
+		}
+		return encoder.Encode(p.records[0])
+	}
+	if p.records == nil {
+		p.records = []interface{}{}
+	}
+	return encoder.Encode(p.records)
+}
+
+type ndjsonPrinter struct {
+	encoder *json.Encoder
+}
+
+func (p *ndjsonPrinter) Print(record interface{}) error {
+	return p.encoder.Encode(record)
+}
+
+func (p *ndjsonPrinter) Flush() error {
+	return nil
+}
+
+type csvPrinter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (p *csvPrinter) Print(record interface{}) error {
+	header, row, err := csvRecord(record)
+	if err != nil {
+		return err
+	}
+	if !p.headerWritten {
+		if err := p.w.Write(header); err != nil {
+			return err
+		}
+		p.headerWritten = true
+	}
+	return p.w.Write(row)
+}
+
+func (p *csvPrinter) Flush() error {
+	p.w.Flush()
+	return p.w.Error()
+}
+
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// csvRecord returns the column names and values for a record in csv output
+func csvRecord(record interface{}) ([]string, []string, error) {
+	switch r := record.(type) {
+	case *api.TimeEntry:
+		return []string{"id", "project", "task", "type", "start", "end", "breaks", "duration", "comment", "tags"},
+			[]string{r.ID, r.ProjectName, r.Task, r.Type, csvTime(r.Start), csvTime(r.End), r.Breaks.String(), r.Duration().String(), r.Comment, strings.Join(r.Tags, ",")},
+			nil
+	case *api.Project:
+		return []string{"id", "name", "description", "billable", "client", "aliases", "archived"},
+			[]string{r.ID, r.Name, r.Description, strconv.FormatBool(r.Billable), r.Client, strings.Join(r.Aliases, ","), strconv.FormatBool(r.Archived)},
+			nil
+	case *api.BudgetStatus:
+		var remainingAmount string
+		if r.Budget.Amount > 0 {
+			remainingAmount = formatAmount(r.RemainingAmount)
+		}
+		return []string{"project", "from", "to", "hours", "remaining_hours", "amount", "remaining_amount", "currency", "used"},
+			[]string{r.Project, csvTime(r.From), csvTime(r.To), formatHours(r.Hours), formatHours(r.RemainingHours), formatAmount(r.Amount), remainingAmount, r.Budget.Currency, formatAmount(r.Used)},
+			nil
+	case *api.Client:
+		return []string{"name", "description"}, []string{r.Name, r.Description}, nil
+	case *api.BillingLine:
+		return []string{"client", "project", "type", "hours", "rate", "currency", "amount"},
+			[]string{r.Client, r.Project, r.EntryType, formatHours(r.Hours), formatAmount(r.Rate), r.Currency, formatAmount(r.Amount)},
+			nil
+	case *api.Invoice:
+		var totals []string
+		for currency, amount := range r.Totals {
+			totals = append(totals, formatAmount(amount)+" "+currency)
+		}
+		sort.Strings(totals)
+		return []string{"number", "issued", "client", "from", "to", "totals"},
+			[]string{strconv.Itoa(r.Number), csvTime(&r.Issued), r.Client, csvTime(r.From), csvTime(r.To), strings.Join(totals, ";")},
+			nil
+	case *api.SummaryGroup:
+		return []string{"key", "worked", "billable", "non_billable", "hours", "days"},
+			[]string{r.Key, r.Worked.String(), r.Billable.String(), r.NonBillable.String(), formatTypeHours(r.Hours), formatDays(r.Days)},
+			nil
+	case *timerStatus:
+		var project, comment, elapsed string
+		if r.Timer != nil {
+			project, comment, elapsed = r.Timer.ProjectName, r.Timer.Comment, r.Timer.Duration().String()
+		}
+		return []string{"project", "comment", "elapsed", "today", "week"},
+			[]string{project, comment, elapsed, r.Today.String(), r.Week.String()},
+			nil
+	case *api.UserSettings:
+		return []string{"timer_policy"}, []string{r.TimerPolicy}, nil
+	case *api.Schedule:
+		hours := make([]string, len(r.Hours))
+		for i, d := range r.Hours {
+			hours[i] = formatHours(d)
+		}
+		return []string{"from", "hours"}, []string{r.From, strings.Join(hours, ",")}, nil
+	case *api.BalanceAdjustment:
+		return []string{"id", "date", "amount", "comment"},
+			[]string{r.ID, r.Date, formatHours(r.Amount), r.Comment},
+			nil
+	case *api.BalancePeriod:
+		return []string{"key", "expected", "worked", "absence", "adjustments", "balance", "running"},
+			[]string{r.Key, formatHours(r.Expected), formatHours(r.Worked), formatHours(r.Absence), formatHours(r.Adjustments), formatHours(r.Balance), formatHours(r.Running)},
+			nil
+	case *api.Allowance:
+		return []string{"type", "from", "days", "carry_over"},
+			[]string{r.Type, strconv.Itoa(r.From), strconv.Itoa(r.Days), strconv.Itoa(r.CarryOver)},
+			nil
+	case *api.AllowanceStatus:
+		return []string{"type", "year", "allowance", "carried_over", "used", "remaining"},
+			[]string{r.Type, strconv.Itoa(r.Year), strconv.Itoa(r.Allowance), strconv.Itoa(r.CarriedOver), strconv.Itoa(r.Used), strconv.Itoa(r.Remaining)},
+			nil
+	case *api.Holiday:
+		return []string{"date", "name"}, []string{r.Date, r.Name}, nil
+	case *api.EntryType:
+		return []string{"name", "time_based", "counts_as_work", "fulfills_schedule", "color"},
+			[]string{r.Name, strconv.FormatBool(r.TimeBased), strconv.FormatBool(r.CountsAsWork), strconv.FormatBool(r.FulfillsSchedule), r.Color},
+			nil
+	}
+	return nil, nil, fmt.Errorf("Records of type %T cannot be written as csv", record)
+}
+
+type templatePrinter struct {
+	w        io.Writer
+	template *template.Template
+}
+
+func (p *templatePrinter) Print(record interface{}) error {
+	return p.template.Execute(p.w, record)
+}
+
+func (p *templatePrinter) Flush() error {
+	return nil
+}
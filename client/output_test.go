@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+)
+
+func printEntries(t *testing.T, format string, list bool, entries ...*api.TimeEntry) string {
+	t.Helper()
+
+	outputFormat = format
+	defer func() { outputFormat = outputTable }()
+
+	var buf bytes.Buffer
+	p, err := newPrinter(&buf, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if err := p.Print(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestOutputFormats(t *testing.T) {
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+	first := &api.TimeEntry{ID: "a", ProjectName: "acme", Task: "design", Type: "work", Start: &start, End: &end, Breaks: 30 * time.Minute, Comment: "planning, review"}
+	second := &api.TimeEntry{ID: "b", ProjectName: "acme", Type: "vacation", Start: &start, End: &start}
+
+	for _, test := range []struct {
+		format   string
+		list     bool
+		expected string
+	}{
+		{"json", false, "{\n  \"id\": \"a\",\n  \"project_name\": \"acme\",\n  \"task\": \"design\",\n  \"type\": \"work\",\n  \"start\": \"2019-01-07T08:00:00Z\",\n  \"end\": \"2019-01-07T16:00:00Z\",\n  \"breaks\": 1800000000000,\n  \"comment\": \"planning, review\"\n}\n"},
+		{"ndjson", true, "{\"id\":\"a\",\"project_name\":\"acme\",\"task\":\"design\",\"type\":\"work\",\"start\":\"2019-01-07T08:00:00Z\",\"end\":\"2019-01-07T16:00:00Z\",\"breaks\":1800000000000,\"comment\":\"planning, review\"}\n" +
+			"{\"id\":\"b\",\"project_name\":\"acme\",\"type\":\"vacation\",\"start\":\"2019-01-07T08:00:00Z\",\"end\":\"2019-01-07T08:00:00Z\",\"breaks\":0,\"comment\":\"\"}\n"},
+		{"csv", true, "id,project,task,type,start,end,breaks,duration,comment,tags\n" +
+			"a,acme,design,work,2019-01-07T08:00:00Z,2019-01-07T16:00:00Z,30m0s,7h30m0s,\"planning, review\",\n" +
+			"b,acme,,vacation,2019-01-07T08:00:00Z,2019-01-07T08:00:00Z,0s,0s,,\n"},
+		{"{{.ID}} {{.Type}}", true, "a work\nb vacation\n"},
+		{"template={{.ProjectName}}", true, "acme\nacme\n"},
+	} {
+		entries := []*api.TimeEntry{first, second}
+		if !test.list {
+			entries = entries[:1]
+		}
+		if output := printEntries(t, test.format, test.list, entries...); output != test.expected {
+			t.Errorf("Unexpected %s output:\n%s", test.format, output)
+		}
+	}
+
+	if output := printEntries(t, "json", true); output != "[]\n" {
+		t.Errorf("Expected empty json list, got %s", output)
+	}
+
+	outputFormat = "yaml"
+	defer func() { outputFormat = outputTable }()
+	if _, err := newPrinter(&bytes.Buffer{}, false); err == nil {
+		t.Errorf("Expected error for unknown output format")
+	}
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rateClient  string
+	rateProject string
+	rateType    string
+	rateFrom    string
+)
+
+func init() {
+	rootCmd.AddCommand(setRateCmd)
+	addRateFlags(setRateCmd)
+	rootCmd.AddCommand(deleteRateCmd)
+	addRateFlags(deleteRateCmd)
+}
+
+// addRateFlags adds the flags that select a rate
+func addRateFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&rateClient, "client", "", "The rate belongs to this client")
+	cmd.Flags().StringVarP(&rateProject, "project", "p", "", "The rate belongs to this project")
+	cmd.Flags().StringVarP(&rateType, "type", "t", "", "The rate only applies to entries of this type")
+	cmd.Flags().StringVar(&rateFrom, "from", "", "The rate applies from this date")
+}
+
+var setRateCmd = &cobra.Command{
+	Use:   "set-rate AMOUNT CURRENCY (--client CLIENT | --project PROJECT) [--type TYPE] [--from DATE]",
+	Short: "Set an hourly rate",
+	Long: `Set the hourly rate of a client or a project. A client's rates apply to all
+its projects, unless the project has a rate of its own. With --type the rate only
+applies to entries of that type, and wins over rates for all types.
+A rate applies from the date given with --from until a later rate takes over, so
+that earlier time is still billed at the old rate. A rate with the same type and date
+is replaced.`,
+	Args: cobra.ExactArgs(2),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return setRate(args[0], args[1])
+	}),
+}
+
+var deleteRateCmd = &cobra.Command{
+	Use:   "delete-rate (--client CLIENT | --project PROJECT) [--type TYPE] [--from DATE]",
+	Short: "Delete an hourly rate",
+	Long:  `Delete the rate of a client or a project that has the given type and date`,
+	Args:  cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return deleteRate()
+	}),
+}
+
+// rateOwner is the client or project whose rates are changed
+type rateOwner struct {
+	client  *api.Client
+	project *api.Project
+}
+
+func loadRateOwner(apiClient *ApiClient) (*rateOwner, error) {
+	if (rateClient == "") == (rateProject == "") {
+		return nil, errors.New("Either --client or --project must be given")
+	}
+	if rateClient != "" {
+		client, err := apiClient.GetClient(rateClient)
+		if err != nil {
+			return nil, err
+		}
+		return &rateOwner{client: client}, nil
+	}
+	project, err := apiClient.GetProject(rateProject)
+	if err != nil {
+		return nil, err
+	}
+	return &rateOwner{project: project}, nil
+}
+
+func (o *rateOwner) rates() *[]*api.Rate {
+	if o.client != nil {
+		return &o.client.Rates
+	}
+	return &o.project.Rates
+}
+
+func (o *rateOwner) save(apiClient *ApiClient) (interface{}, error) {
+	if o.client != nil {
+		return apiClient.UpdateClient(rateClient, o.client)
+	}
+	return apiClient.UpdateProject(rateProject, o.project)
+}
+
+// rateFromDate returns the --from date as YYYY-MM-DD, or an empty string if not given
+func rateFromDate() (string, error) {
+	if rateFrom == "" {
+		return "", nil
+	}
+	from, err := ParseTimeRef(rateFrom, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return from.Format("2006-01-02"), nil
+}
+
+// withoutRate returns the rates except the one with the given type and date
+func withoutRate(rates []*api.Rate, entryType string, from string) ([]*api.Rate, bool) {
+	var result []*api.Rate
+	found := false
+	for _, rate := range rates {
+		if rate.EntryType == entryType && rate.From == from {
+			found = true
+		} else {
+			result = append(result, rate)
+		}
+	}
+	return result, found
+}
+
+func setRate(amount string, currency string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	rate := api.Rate{EntryType: rateType, Currency: strings.ToUpper(currency)}
+	if rate.Amount, err = strconv.ParseFloat(amount, 64); err != nil {
+		return fmt.Errorf("invalid amount: %s", amount)
+	}
+	if rate.From, err = rateFromDate(); err != nil {
+		return err
+	}
+
+	owner, err := loadRateOwner(apiClient)
+	if err != nil {
+		return err
+	}
+	rates := owner.rates()
+	*rates, _ = withoutRate(*rates, rate.EntryType, rate.From)
+	*rates = append(*rates, &rate)
+
+	result, err := owner.save(apiClient)
+	if err != nil {
+		return err
+	}
+	return printRecord(result)
+}
+
+func deleteRate() error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	from, err := rateFromDate()
+	if err != nil {
+		return err
+	}
+	owner, err := loadRateOwner(apiClient)
+	if err != nil {
+		return err
+	}
+	rates := owner.rates()
+	var found bool
+	if *rates, found = withoutRate(*rates, rateType, from); !found {
+		return errors.New("No rate with this type and date")
+	}
+
+	result, err := owner.save(apiClient)
+	if err != nil {
+		return err
+	}
+	return printRecord(result)
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+var reportGroupBy string
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.Flags().StringVarP(&startTime, "start", "s", "", "Only entries that start at or later than this time/date")
+	reportCmd.Flags().StringVarP(&endTime, "end", "e", "", "Only entries that start earlier than this time/date")
+	reportCmd.Flags().StringVarP(&reportGroupBy, "group-by", "g", "project", "Group by (project|type|day|week|month|tag|task)")
+	addTagFilterFlags(reportCmd)
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report [PROJECTNAME[/TASKNAME]] [-s START] [-e END] [-g GROUPING]",
+	Short: "Show a time summary",
+	Long: `Summarize worked hours and days off for a project or one of its tasks, or for all
+projects. Grouping by task gives a group per project and task, with the entries that
+have no task in a group per project.
+Worked hours are split into billable and non-billable hours. Hours of time-based
+entry types that do not count as work, and days of whole-day types, are listed
+per type.
+When grouping by tag, an entry counts once for each of its tags, so the groups can add
+up to more than the total.
+With --output json the whole summary is printed; the ndjson, csv and template
+formats print one record per group.
+` + projectTaskHelp,
+	Args: cobra.MaximumNArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return report(args[0])
+		}
+		return report("")
+	}),
+}
+
+func report(projectName string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	filter, err := entryFilterFromFlags()
+	if err != nil {
+		return err
+	}
+	projectName, filter.Task = splitProjectTask(projectName)
+
+	summary, err := apiClient.GetSummary(projectName, reportGroupBy, filter)
+	if err != nil {
+		return err
+	}
+
+	switch outputFormat {
+	case outputTable:
+		printSummaryTable(summary)
+		return nil
+	case outputJSON:
+		return printRecord(summary)
+	}
+
+	// The remaining formats print one record per group
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
+	}
+	for _, group := range summary.Groups {
+		if err := p.Print(group); err != nil {
+			return err
+		}
+	}
+	return p.Flush()
+}
+
+func formatHours(d time.Duration) string {
+	return fmt.Sprintf("%.2f", d.Hours())
+}
+
+func formatDays(days map[string]int) string {
+	var types []string
+	for entryType := range days {
+		types = append(types, entryType)
+	}
+	sort.Strings(types)
+
+	var parts []string
+	for _, entryType := range types {
+		parts = append(parts, fmt.Sprintf("%s %d", entryType, days[entryType]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatTypeHours lists the hours per entry type, like "on-call 12.00h"
+func formatTypeHours(hours map[string]time.Duration) string {
+	var types []string
+	for entryType := range hours {
+		types = append(types, entryType)
+	}
+	sort.Strings(types)
+
+	var parts []string
+	for _, entryType := range types {
+		parts = append(parts, fmt.Sprintf("%s %sh", entryType, formatHours(hours[entryType])))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatOtherTime lists the time that is not work: hours per type, then days per type
+func formatOtherTime(group *api.SummaryGroup) string {
+	var parts []string
+	for _, part := range []string{formatTypeHours(group.Hours), formatDays(group.Days)} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func printSummaryTable(summary *api.Summary) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%s\tWORKED\tBILLABLE\tNON-BILLABLE\t\n", strings.ToUpper(summary.GroupBy))
+	for _, group := range summary.Groups {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t  %s\n", group.Key, formatHours(group.Worked), formatHours(group.Billable), formatHours(group.NonBillable), formatOtherTime(group))
+	}
+	if summary.Total != nil {
+		total := summary.Total
+		fmt.Fprintf(w, "TOTAL\t%s\t%s\t%s\t  %s\n", formatHours(total.Worked), formatHours(total.Billable), formatHours(total.NonBillable), formatOtherTime(total))
+	}
+	w.Flush()
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume ENTRYID [TIME]",
+	Short: "Start a new entry like a past one",
+	Long: `Start a new time entry with the same project, task, type and comment as the given entry.
+A running entry is stopped at the same instant. The time defaults to now.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		at, err := optionalTimeArg(args, 1)
+		if err != nil {
+			return err
+		}
+		return resumeTimeEntry(args[0], at)
+	}),
+}
+
+func resumeTimeEntry(entryID string, at time.Time) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	var entry *api.TimeEntry
+	if isLocalID(entryID) {
+		j, err := loadJournal()
+		if err != nil {
+			return err
+		}
+		entry = j.pendingEntry(entryID)
+		if entry == nil {
+			return fmt.Errorf("No pending time entry with id %s", entryID)
+		}
+	} else {
+		entry, err = apiClient.GetEntry(entryID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return switchTimeEntry(apiClient, entry.ProjectName, &api.TimeEntry{Task: entry.Task, Type: entry.Type, Comment: entry.Comment}, at)
+}
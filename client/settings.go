@@ -0,0 +1,44 @@
+package client
+
+import (
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+var timerPolicy string
+
+func init() {
+	rootCmd.AddCommand(settingsCmd)
+	settingsCmd.Flags().StringVar(&timerPolicy, "timer-policy", "", "What starting a timer does while another one is running (forbid|auto-stop)")
+}
+
+var settingsCmd = &cobra.Command{
+	Use:   "settings [--timer-policy POLICY]",
+	Short: "Show or change user settings",
+	Long: `Show or change your settings. The timer policy decides what happens when a timer is
+started while another one is running: forbid refuses to start it, and auto-stop stops the
+running timer when the new one starts.`,
+	Args: cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return settings(cmd)
+	}),
+}
+
+func settings(cmd *cobra.Command) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	var result *api.UserSettings
+	if cmd.Flags().Changed("timer-policy") {
+		result, err = apiClient.UpdateSettings(&api.UserSettings{TimerPolicy: timerPolicy})
+	} else {
+		result, err = apiClient.GetSettings()
+	}
+	if err != nil {
+		return err
+	}
+
+	return printRecord(result)
+}
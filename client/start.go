@@ -3,6 +3,7 @@ package client
 import (
 	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/kennep/timelapse/api"
@@ -12,14 +13,16 @@ import (
 func init() {
 	rootCmd.AddCommand(startCmd)
 	startCmd.Flags().StringVarP(&breaks, "breaks", "b", "", "Break duration")
-	startCmd.Flags().StringVarP(&entryType, "type", "t", "work", "Entry type (work|sick|sick-child|vacation)")
+	startCmd.Flags().StringVarP(&entryType, "type", "t", "work", "Entry type, see list-entry-types")
 	startCmd.Flags().StringVarP(&entryComment, "comment", "c", "", "Entry comment")
+	startCmd.Flags().BoolVar(&allowOverlap, "allow-overlap", false, "Start the timer even if it overlaps other work")
+	startCmd.Flags().StringSliceVar(&entryTags, "tag", nil, "Tag the entry; can be repeated or comma separated. #hashtags in the comment are tags too")
 }
 
 var startCmd = &cobra.Command{
-	Use:   "start PROJECTNAME [START]",
+	Use:   "start PROJECTNAME[/TASKNAME] [START]",
 	Short: "Start time tracking",
-	Long:  `start time tracking for a given project`,
+	Long:  "Start time tracking for a given project, optionally on one of its tasks.\n" + projectTaskHelp,
 	Args:  cobra.RangeArgs(1, 2),
 	Run: runCommand(func(cmd *cobra.Command, args []string) error {
 		if len(args) > 1 {
@@ -35,19 +38,18 @@ func startTimeEntry(projectName string, startTime string) error {
 		return err
 	}
 
+	projectName, taskName := splitProjectTask(projectName)
 	if projectName == "" {
 		return errors.New("Project name must be given")
 	}
 
-	entries, err := apiClient.GetTimeEntries(projectName)
+	// An entry started while offline is not known to the server yet
+	j, err := loadJournal()
 	if err != nil {
 		return err
 	}
-
-	for _, entry := range entries {
-		if entry.End == nil {
-			return errors.New("Already started entry: \"" + entry.String() + "\" - close it first")
-		}
+	if pending := j.openEntry(""); pending != nil {
+		return errors.New("Already started entry: \"" + pending.String() + "\" - close it first")
 	}
 
 	now := time.Now()
@@ -56,8 +58,10 @@ func startTimeEntry(projectName string, startTime string) error {
 		Start:   &now,
 		End:     nil,
 		Breaks:  time.Duration(0),
+		Task:    taskName,
 		Type:    entryType,
 		Comment: entryComment,
+		Tags:    commandLineTags(),
 	}
 
 	if startTime != "" {
@@ -74,16 +78,29 @@ func startTimeEntry(projectName string, startTime string) error {
 		}
 	}
 
-	if entryType != "work" {
+	if !api.IsTimeBased(entryType) {
 		*entry.Start = normalizeTime(*entry.Start)
 	}
 
-	result, err := apiClient.AddTimeEntry(projectName, &entry)
-	if err != nil {
+	entry.ProjectName = projectName
+	defer invalidateStatusCache()
+	result, err := apiClient.StartTimer(&entry)
+	if isOffline(err) {
+		started, err := recordAdd(projectName, &entry)
+		if err != nil {
+			return err
+		}
+		return printRecord(started)
+	} else if err != nil {
 		return err
 	}
 
-	fmt.Println(result)
-
-	return nil
+	if isTableOutput() {
+		for _, stopped := range result.Stopped {
+			fmt.Fprintf(os.Stderr, "Stopped running timer: %s\n", stopped)
+			warnAboutBudget(apiClient, stopped.ProjectName)
+		}
+	}
+	warnAboutBudget(apiClient, result.Started.ProjectName)
+	return printRecord(result.Started)
 }
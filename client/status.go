@@ -0,0 +1,202 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+const statusCacheFile = "status.json"
+
+// statusCacheAge is how long a cached status is used by status --short before the server
+// is asked again. Shell prompts may run the command several times a second.
+const statusCacheAge = 30 * time.Second
+
+var statusShort bool
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().BoolVar(&statusShort, "short", false, "Print a single line for shell prompts and status bars, using a short-lived cache")
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status [--short]",
+	Short: "Show the running timer and today's totals",
+	Long: `Show the running timer, with its project, comment and elapsed time, and the time
+worked today and this week.
+With --short a single line is printed. The result is cached for a short while, so that
+the command can be used in shell prompts and status bars without querying the server
+every time. If the server cannot be reached, the last cached status is shown.`,
+	Args: cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return status()
+	}),
+}
+
+// timerStatus is the running timer, if any, and the time worked so far today and this week,
+// as of Fetched
+type timerStatus struct {
+	Timer   *api.TimeEntry `json:"timer"`
+	Today   time.Duration  `json:"today"`
+	Week    time.Duration  `json:"week"`
+	Fetched time.Time      `json:"fetched"`
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// startOfWeek returns midnight on the Monday of the week of t
+func startOfWeek(t time.Time) time.Time {
+	midnight := startOfDay(t)
+	return midnight.AddDate(0, 0, -((int(midnight.Weekday()) + 6) % 7))
+}
+
+func fetchStatus(apiClient *ApiClient, now time.Time) (*timerStatus, error) {
+	timer, err := apiClient.GetTimer()
+	if err != nil {
+		return nil, err
+	}
+
+	weekStart := startOfWeek(now)
+	summary, err := apiClient.GetSummary("", "day", &TimeEntryFilter{From: &weekStart})
+	if err != nil {
+		return nil, err
+	}
+
+	// A timer started while offline is only known to the journal
+	if timer == nil {
+		j, err := loadJournal()
+		if err != nil {
+			return nil, err
+		}
+		timer = j.openEntry("")
+	}
+
+	result := timerStatus{Timer: timer, Fetched: now}
+	today := now.Format("2006-01-02")
+	for _, group := range summary.Groups {
+		result.Week += group.Worked
+		if group.Key == today {
+			result.Today += group.Worked
+		}
+	}
+	return &result, nil
+}
+
+// at returns the status as it is at the given time. While a timer is running, the totals
+// grow; they are only fetched from the server now and then.
+func (s *timerStatus) at(now time.Time) *timerStatus {
+	result := *s
+	result.Fetched = now
+	if s.Timer == nil || !api.IsWork(s.Timer.Type) || !now.After(s.Fetched) {
+		return &result
+	}
+
+	elapsed := now.Sub(s.Fetched)
+	result.Today += elapsed
+	result.Week += elapsed
+	// The timer is all there is to a day or week that started after the status was fetched
+	if today := startOfDay(now); s.Fetched.Before(today) {
+		result.Today = now.Sub(today)
+	}
+	if week := startOfWeek(now); s.Fetched.Before(week) {
+		result.Week = now.Sub(week)
+	}
+	return &result
+}
+
+func loadStatusCache() (*timerStatus, error) {
+	var s timerStatus
+	if err := internalLoadConfig(statusCacheFile, &s); err != nil {
+		return nil, err
+	}
+	if s.Fetched.IsZero() {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+// invalidateStatusCache removes the cached status, for commands that start or stop timers
+func invalidateStatusCache() {
+	os.Remove(filepath.Join(configDir(), statusCacheFile))
+}
+
+// currentStatus returns the status from the server, or from the cache if it is recent
+// enough and useCache is set. If the server cannot be reached, a stale cached status
+// is returned if there is one.
+func currentStatus(apiClient *ApiClient, useCache bool, now time.Time) (*timerStatus, error) {
+	cached, err := loadStatusCache()
+	if err != nil {
+		return nil, err
+	}
+	if useCache && cached != nil && now.Sub(cached.Fetched) < statusCacheAge && !now.Before(cached.Fetched) {
+		return cached.at(now), nil
+	}
+
+	fetched, err := fetchStatus(apiClient, now)
+	if isOffline(err) && cached != nil {
+		return cached.at(now), nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := internalStoreConfig(statusCacheFile, fetched, 0600); err != nil {
+		return nil, err
+	}
+	return fetched, nil
+}
+
+// formatElapsed formats a duration as hours and minutes, like 7h05m
+func formatElapsed(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	minutes := int64(d.Round(time.Minute).Minutes())
+	return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+}
+
+// shortStatus is the one line form of the status, for shell prompts
+func (s *timerStatus) shortStatus() string {
+	timer := "idle"
+	if s.Timer != nil {
+		timer = fmt.Sprintf("%s %s", s.Timer.ProjectName, formatElapsed(s.Timer.Duration()))
+	}
+	return fmt.Sprintf("%s | today %s | week %s", timer, formatElapsed(s.Today), formatElapsed(s.Week))
+}
+
+func (s *timerStatus) String() string {
+	timer := "No timer running"
+	if s.Timer != nil {
+		timer = fmt.Sprintf("Running: %s for %s since %s", s.Timer.ProjectName, formatElapsed(s.Timer.Duration()),
+			s.Timer.Start.In(time.Local).Format("15:04"))
+		if s.Timer.Comment != "" {
+			timer += fmt.Sprintf("\n         %s", s.Timer.Comment)
+		}
+		if isLocalID(s.Timer.ID) {
+			timer += "\n         (started offline, not synced yet)"
+		}
+	}
+	return fmt.Sprintf("%s\nToday:   %s\nWeek:    %s", timer, formatElapsed(s.Today), formatElapsed(s.Week))
+}
+
+func status() error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	s, err := currentStatus(apiClient, statusShort, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if statusShort && isTableOutput() {
+		fmt.Println(s.shortStatus())
+		return nil
+	}
+	return printRecord(s)
+}
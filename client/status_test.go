@@ -0,0 +1,77 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+)
+
+func TestStatusCache(t *testing.T) {
+	defer useTempConfigDir(t)()
+
+	// Wednesday
+	now := time.Date(2019, 1, 9, 10, 0, 0, 0, time.Local)
+	start := now.Add(-90 * time.Minute)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/timer":
+			json.NewEncoder(rw).Encode(&api.TimeEntry{ID: "1", ProjectName: "acme", Type: "work", Start: &start})
+		case "/reports/summary":
+			if from := r.URL.Query().Get("from"); from == "" {
+				t.Errorf("Expected the summary to start at the beginning of the week")
+			}
+			json.NewEncoder(rw).Encode(&api.Summary{GroupBy: "day", Groups: []*api.SummaryGroup{
+				{Key: "2019-01-07", Worked: 8 * time.Hour},
+				{Key: "2019-01-09", Worked: 90 * time.Minute},
+			}})
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+			rw.WriteHeader(500)
+		}
+	}))
+	defer server.Close()
+	apiClient := newTestApiClient(server.URL)
+
+	s, err := currentStatus(apiClient, true, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 || s.Today != 90*time.Minute || s.Week != 9*time.Hour+30*time.Minute {
+		t.Errorf("Unexpected status after %d requests: %+v", requests, s)
+	}
+
+	s, err = currentStatus(apiClient, true, now.Add(10*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected the cached status to be used, got %d requests", requests)
+	}
+	if s.Today != 90*time.Minute+10*time.Second {
+		t.Errorf("Expected the cached totals to include the running timer, got %v", s.Today)
+	}
+
+	currentStatus(apiClient, true, now.Add(statusCacheAge))
+	if requests != 4 {
+		t.Errorf("Expected an expired cache to be refreshed, got %d requests", requests)
+	}
+
+	invalidateStatusCache()
+	server.Close()
+	if _, err := currentStatus(apiClient, true, now); !isOffline(err) {
+		t.Errorf("Expected an offline error without a cache, got %v", err)
+	}
+}
+
+func TestShortStatus(t *testing.T) {
+	s := &timerStatus{Today: 7*time.Hour + 5*time.Minute, Week: 30 * time.Hour}
+	if short := s.shortStatus(); short != "idle | today 7h05m | week 30h00m" {
+		t.Errorf("Unexpected short status: %s", short)
+	}
+}
@@ -2,7 +2,6 @@ package client
 
 import (
 	"errors"
-	"fmt"
 	"time"
 
 	"github.com/kennep/timelapse/api"
@@ -12,8 +11,9 @@ import (
 func init() {
 	rootCmd.AddCommand(stopCmd)
 	stopCmd.Flags().StringVarP(&breaks, "breaks", "b", "", "Break duration")
-	stopCmd.Flags().StringVarP(&entryType, "type", "t", "work", "Entry type (work|sick|sick-child|vacation)")
+	stopCmd.Flags().StringVarP(&entryType, "type", "t", "work", "Entry type, see list-entry-types")
 	stopCmd.Flags().StringVarP(&entryComment, "comment", "c", "", "Entry comment")
+	stopCmd.Flags().BoolVar(&allowOverlap, "allow-overlap", false, "Stop the timer even if the entry then overlaps other work")
 }
 
 var stopCmd = &cobra.Command{
@@ -38,51 +38,57 @@ func stopTimeEntry(projectName string, endTime string) error {
 		return err
 	}
 
-	entries, err := apiClient.GetTimeEntries(projectName)
-	if err != nil {
-		return err
-	}
-
-	var foundEntry *api.TimeEntry
-
-	for _, entry := range entries {
-		if entry.End == nil {
-			foundEntry = entry
-		}
-	}
-	if foundEntry == nil {
-		return errors.New("Did not find any time entry to close")
-	}
-
 	now := time.Now()
 
-	foundEntry.End = &now
+	var changes entryChanges
+	changes.End = &now
 
 	if endTime != "" {
 		tm, err := ParseTimeRef(endTime, now)
-		foundEntry.End = &tm
+		changes.End = &tm
 		if err != nil {
 			return err
 		}
 	}
 
 	if breaks != "" {
-		foundEntry.Breaks, err = time.ParseDuration(breaks)
+		breakDuration, err := time.ParseDuration(breaks)
 		if err != nil {
 			return err
 		}
+		changes.Breaks = &breakDuration
 	}
 
-	if entryType != "work" {
-		*foundEntry.End = normalizeTime(*foundEntry.End)
+	if !api.IsTimeBased(entryType) {
+		*changes.End = normalizeTime(*changes.End)
 	}
 
-	result, err := apiClient.UpdateTimeEntry(foundEntry.ProjectName, foundEntry)
+	defer invalidateStatusCache()
+
+	// An entry started while offline is stopped in the journal
+	j, err := loadJournal()
 	if err != nil {
 		return err
 	}
+	if pending := j.openEntry(projectName); pending != nil {
+		changes.apply(pending)
+		if err := j.store(); err != nil {
+			return err
+		}
+		return printRecord(pending)
+	}
 
-	fmt.Println(result)
+	result, err := apiClient.StopTimer(changes.timerStop(projectName))
+	if isOffline(err) {
+		return recordOffline(func(j *journal) {
+			j.record(&journalOperation{Action: journalStop, ProjectName: projectName, Changes: &changes})
+		})
+	} else if isNotFound(err) {
+		return errors.New("Did not find any running timer to stop")
+	} else if err != nil {
+		return err
+	}
 
-	return nil
+	warnAboutBudget(apiClient, result.ProjectName)
+	return printRecord(result)
 }
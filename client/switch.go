@@ -0,0 +1,159 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(switchCmd)
+	switchCmd.Flags().StringVarP(&entryType, "type", "t", "work", "Entry type, see list-entry-types")
+	switchCmd.Flags().StringVarP(&entryComment, "comment", "c", "", "Entry comment")
+	switchCmd.Flags().BoolVar(&allowOverlap, "allow-overlap", false, "Start the timer even if it overlaps other work")
+}
+
+var switchCmd = &cobra.Command{
+	Use:   "switch PROJECTNAME[/TASKNAME] [TIME]",
+	Short: "Stop the running entry and start another",
+	Long: `Stop the running time entry, if any, and start a new one for the given project,
+optionally on one of its tasks, at the same instant. The time defaults to now.
+` + projectTaskHelp,
+	Args: cobra.RangeArgs(1, 2),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		at, err := optionalTimeArg(args, 1)
+		if err != nil {
+			return err
+		}
+		apiClient, err := NewApiClient()
+		if err != nil {
+			return err
+		}
+		projectName, taskName := splitProjectTask(args[0])
+		return switchTimeEntry(apiClient, projectName, &api.TimeEntry{Task: taskName, Type: entryType, Comment: entryComment}, at)
+	}),
+}
+
+// optionalTimeArg parses the argument at idx with ParseTimeRef. If there is no such
+// argument, the current time is returned.
+func optionalTimeArg(args []string, idx int) (time.Time, error) {
+	now := time.Now()
+	if len(args) <= idx {
+		return now, nil
+	}
+	return ParseTimeRef(args[idx], now)
+}
+
+// stopRunningEntry closes the running entry, if there is one, at the given time. The
+// stopped entry is returned.
+func stopRunningEntry(apiClient *ApiClient, at time.Time) (*api.TimeEntry, error) {
+	changes := entryChanges{End: &at}
+
+	// An entry started while offline is stopped in the journal
+	j, err := loadJournal()
+	if err != nil {
+		return nil, err
+	}
+	if pending := j.openEntry(""); pending != nil {
+		if pending.Start != nil && pending.Start.After(at) {
+			return nil, fmt.Errorf("The running entry \"%s\" starts after %s", pending, at.In(time.Local).Format("2006-01-02 15:04"))
+		}
+		changes.apply(pending)
+		return pending, j.store()
+	}
+
+	running, err := apiClient.GetTimer()
+	if isOffline(err) {
+		return nil, recordOffline(func(j *journal) {
+			j.record(&journalOperation{Action: journalStop, Changes: &changes})
+		})
+	} else if err != nil || running == nil {
+		return nil, err
+	}
+
+	if running.Start != nil && running.Start.After(at) {
+		return nil, fmt.Errorf("The running entry \"%s\" starts after %s", running, at.In(time.Local).Format("2006-01-02 15:04"))
+	}
+	changes.apply(running)
+	return updateOrRecordTimeEntry(apiClient, running, &changes)
+}
+
+// switchTimeEntry stops the running entry, if any, and adds an open entry to the project
+// starting at the same time. The server does both in one step, so that the running entry
+// is only stopped if the new one can be started.
+func switchTimeEntry(apiClient *ApiClient, projectName string, entry *api.TimeEntry, at time.Time) error {
+	if projectName == "" {
+		return errors.New("Project name must be given")
+	}
+
+	if !api.IsTimeBased(entry.Type) {
+		at = normalizeTime(at)
+	}
+	entry.ID = ""
+	entry.Start = &at
+	entry.End = nil
+	entry.Breaks = 0
+
+	defer invalidateStatusCache()
+
+	// An entry started while offline is waiting in the journal, so the server cannot stop it
+	j, err := loadJournal()
+	if err != nil {
+		return err
+	}
+	if pending := j.openEntry(""); pending != nil {
+		return switchPendingEntry(apiClient, pending, projectName, entry, at)
+	}
+
+	entry.ProjectName = projectName
+	result, err := apiClient.SwitchTimer(entry)
+	if isOffline(err) {
+		return switchOffline(apiClient, projectName, entry, at)
+	} else if err != nil {
+		return err
+	}
+
+	if isTableOutput() {
+		for _, stopped := range result.Stopped {
+			fmt.Fprintf(os.Stderr, "Stopped running timer: %s\n", stopped)
+		}
+	}
+	return printRecord(result.Started)
+}
+
+// switchPendingEntry adds the new entry and then stops the running entry that is waiting
+// in the offline journal, so that the running entry is kept if the new one is refused
+func switchPendingEntry(apiClient *ApiClient, pending *api.TimeEntry, projectName string, entry *api.TimeEntry, at time.Time) error {
+	if pending.Start != nil && pending.Start.After(at) {
+		return fmt.Errorf("The running entry \"%s\" starts after %s", pending, at.In(time.Local).Format("2006-01-02 15:04"))
+	}
+	result, err := addOrRecordTimeEntry(apiClient, projectName, entry)
+	if err != nil {
+		return err
+	}
+	if err := recordUpdate(pending.ProjectName, pending.ID, &entryChanges{End: &at}); err != nil {
+		return err
+	}
+	if isTableOutput() {
+		pending.End = &at
+		fmt.Fprintf(os.Stderr, "Stopped running timer: %s\n", pending)
+	}
+	return printRecord(result)
+}
+
+// switchOffline records stopping the running entry and adding the new one in the offline
+// journal, to be sent to the server by sync
+func switchOffline(apiClient *ApiClient, projectName string, entry *api.TimeEntry, at time.Time) error {
+	if _, err := stopRunningEntry(apiClient, at); err != nil {
+		return err
+	}
+	result, err := recordAdd(projectName, entry)
+	if err != nil {
+		return err
+	}
+	return printRecord(result)
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+)
+
+func TestSwitchTimeEntry(t *testing.T) {
+	defer useTempConfigDir(t)()
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	at := start.Add(2 * time.Hour)
+	var started *api.TimeEntry
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/timer" && r.URL.Query().Get("stop_running") == "true":
+			json.NewDecoder(r.Body).Decode(&started)
+			if started.Start.Before(start) {
+				rw.WriteHeader(409)
+				return
+			}
+			end := *started.Start
+			rw.WriteHeader(201)
+			json.NewEncoder(rw).Encode(&api.TimerStart{
+				Started: &api.TimeEntry{ID: "2", ProjectName: started.ProjectName, Type: started.Type, Start: started.Start, Comment: started.Comment},
+				Stopped: []*api.TimeEntry{{ID: "1", ProjectName: "acme", Type: "work", Start: &start, End: &end}},
+			})
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+			rw.WriteHeader(500)
+		}
+	}))
+	defer server.Close()
+	apiClient := newTestApiClient(server.URL)
+
+	// The server stops the running entry and starts the new one in one request
+	err := switchTimeEntry(apiClient, "internal", &api.TimeEntry{Type: "work", Comment: "review"}, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started == nil || started.ProjectName != "internal" || !started.Start.Equal(at) || started.End != nil || started.Comment != "review" {
+		t.Errorf("Expected an open entry starting at %v, got %v", at, started)
+	}
+
+	err = switchTimeEntry(apiClient, "internal", &api.TimeEntry{Type: "work"}, start.Add(-time.Hour))
+	if err == nil {
+		t.Errorf("Expected switching to a time before the running entry started to fail")
+	}
+}
+
+func TestLastClosedEntry(t *testing.T) {
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	end := start.Add(4 * time.Hour)
+	later := start.Add(time.Hour)
+	laterEnd := later.Add(time.Hour)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(rw).Encode([]*api.TimeEntry{
+			{ID: "1", ProjectName: "acme", Start: &start, End: &end},
+			{ID: "2", ProjectName: "internal", Start: &later, End: &laterEnd},
+			{ID: "3", ProjectName: "acme", Start: &end},
+		})
+	}))
+	defer server.Close()
+
+	last, err := lastClosedEntry(newTestApiClient(server.URL), end.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last == nil || last.ID != "1" {
+		t.Errorf("Expected the entry that ended last, got %v", last)
+	}
+}
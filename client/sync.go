@@ -0,0 +1,78 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var syncList bool
+var syncDiscardConflicts bool
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().BoolVarP(&syncList, "list", "l", false, "List the pending changes without sending them")
+	syncCmd.Flags().BoolVar(&syncDiscardConflicts, "discard-conflicts", false, "Remove changes that could not be synced from the journal")
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [--list] [--discard-conflicts]",
+	Short: "Send offline changes to the server",
+	Long: `Send the changes recorded while the server could not be reached, in the order
+they were made. Changes that conflict with the server, such as stopping an entry
+that has already been stopped, are reported and kept in the journal instead of
+overwriting the server. Remove them with --discard-conflicts once they are dealt with.`,
+	Args: cobra.NoArgs,
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return syncJournal()
+	}),
+}
+
+func printConflicts(j *journal) {
+	for _, op := range j.conflicts() {
+		fmt.Printf("Conflict: %s\n  %s\n", op, op.Conflict)
+	}
+}
+
+func syncJournal() error {
+	j, err := loadJournal()
+	if err != nil {
+		return err
+	}
+
+	if syncDiscardConflicts {
+		j.Operations = j.pending()
+		if err := j.store(); err != nil {
+			return err
+		}
+	}
+
+	if syncList {
+		for _, op := range j.pending() {
+			fmt.Println(op)
+		}
+		printConflicts(j)
+		return nil
+	}
+
+	if len(j.pending()) == 0 {
+		fmt.Println("Nothing to sync.")
+		return nil
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	sent, err := j.sync(apiClient)
+	if sent > 0 {
+		invalidateStatusCache()
+	}
+	fmt.Printf("Sent %d changes.\n", sent)
+	printConflicts(j)
+	if err != nil {
+		return fmt.Errorf("Server unreachable, %d changes are still pending: %v", len(j.pending()), err)
+	}
+	return nil
+}
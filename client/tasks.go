@@ -0,0 +1,204 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/spf13/cobra"
+)
+
+var commandLineTask api.Task
+
+func init() {
+	rootCmd.AddCommand(addTaskCmd)
+	addTaskCmd.Flags().StringVarP(&commandLineTask.Description, "description", "d", "", "Task description")
+	rootCmd.AddCommand(listTasksCmd)
+	rootCmd.AddCommand(updateTaskCmd)
+	updateTaskCmd.Flags().StringVarP(&commandLineTask.Name, "rename-to", "r", "", "Rename task")
+	updateTaskCmd.Flags().StringVarP(&commandLineTask.Description, "description", "d", "", "Set description")
+	rootCmd.AddCommand(deleteTaskCmd)
+	deleteTaskCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation")
+}
+
+var addTaskCmd = &cobra.Command{
+	Use:   "add-task PROJECTNAME/TASKNAME",
+	Short: "Add a task to a project",
+	Long: `Add a task to a project. Time entries can be recorded on the task by giving
+PROJECTNAME/TASKNAME instead of the project name to start, switch and add-entry.`,
+	Args: cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return addTask(args[0])
+	}),
+}
+
+var listTasksCmd = &cobra.Command{
+	Use:   "list-tasks PROJECTNAME",
+	Short: "List the tasks of a project",
+	Long:  `List the tasks of a project in order of their name`,
+	Args:  cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return listTasks(args[0])
+	}),
+}
+
+var updateTaskCmd = &cobra.Command{
+	Use:   "update-task PROJECTNAME/TASKNAME",
+	Short: "Update a task",
+	Long:  `Rename a task or change its description. Entries on the task keep referring to it.`,
+	Args:  cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return updateTask(cmd, args[0])
+	}),
+}
+
+var deleteTaskCmd = &cobra.Command{
+	Use:   "delete-task PROJECTNAME/TASKNAME",
+	Short: "Delete a task",
+	Long: `Delete the specified task. A task can only be deleted when no time entries
+are recorded on it.`,
+	Args: cobra.ExactArgs(1),
+	Run: runCommand(func(cmd *cobra.Command, args []string) error {
+		return deleteTask(args[0])
+	}),
+}
+
+// projectTaskHelp explains the PROJECTNAME/TASKNAME argument in the help of the commands
+// that take one
+const projectTaskHelp = `A task is given after the project name and a slash, like acme/design. To give a
+project whose name contains a slash without a task, add a trailing slash, like
+"clients/acme/".`
+
+// splitProjectTask splits a PROJECTNAME/TASKNAME argument at the last slash. Task names
+// cannot contain slashes, so a project whose name contains a slash can be given without a
+// task by adding a trailing slash.
+func splitProjectTask(arg string) (string, string) {
+	idx := strings.LastIndex(arg, "/")
+	if idx < 0 {
+		return arg, ""
+	}
+	return arg[:idx], arg[idx+1:]
+}
+
+// projectTaskArg splits a PROJECTNAME/TASKNAME argument of a command that needs a task
+func projectTaskArg(arg string) (string, string, error) {
+	projectName, taskName := splitProjectTask(arg)
+	if projectName == "" || taskName == "" {
+		return "", "", fmt.Errorf("Expected PROJECTNAME/TASKNAME: %s", arg)
+	}
+	return projectName, taskName, nil
+}
+
+func addTask(arg string) error {
+	projectName, taskName, err := projectTaskArg(arg)
+	if err != nil {
+		return err
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	commandLineTask.Name = taskName
+
+	task, err := apiClient.CreateTask(projectName, &commandLineTask)
+	if err != nil {
+		return err
+	}
+	return printRecord(task)
+}
+
+func listTasks(projectName string) error {
+	if projectName == "" {
+		return errors.New("Project name must be given")
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	tasks, err := apiClient.ListTasks(projectName)
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(os.Stdout, true)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if err := p.Print(task); err != nil {
+			return err
+		}
+	}
+
+	return p.Flush()
+}
+
+func updateTask(cmd *cobra.Command, arg string) error {
+	projectName, taskName, err := projectTaskArg(arg)
+	if err != nil {
+		return err
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	task, err := apiClient.GetTask(projectName, taskName)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Flags().Changed("rename-to") {
+		task.Name = commandLineTask.Name
+	}
+	if cmd.Flags().Changed("description") {
+		task.Description = commandLineTask.Description
+	}
+
+	task, err = apiClient.UpdateTask(projectName, taskName, task)
+	if err != nil {
+		return err
+	}
+	return printRecord(task)
+}
+
+func deleteTask(arg string) error {
+	projectName, taskName, err := projectTaskArg(arg)
+	if err != nil {
+		return err
+	}
+
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
+	}
+
+	task, err := apiClient.GetTask(projectName, taskName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(task)
+	ok, err := confirm("Delete this task?")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Not deleted.")
+		return nil
+	}
+
+	err = apiClient.DeleteTask(projectName, taskName)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Task deleted.")
+
+	return nil
+}
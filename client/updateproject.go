@@ -1,8 +1,6 @@
 package client
 
 import (
-	"fmt"
-
 	"github.com/spf13/cobra"
 )
 
@@ -11,6 +9,9 @@ func init() {
 	updateProjectCmd.Flags().StringVarP(&commandLineProject.Name, "rename-to", "r", "", "Rename project")
 	updateProjectCmd.Flags().StringVarP(&commandLineProject.Description, "description", "d", "", "Set description")
 	updateProjectCmd.Flags().BoolVarP(&commandLineProject.Billable, "billable", "b", false, "Mark a project as billable")
+	updateProjectCmd.Flags().StringVar(&commandLineProject.Client, "client", "", "Set the client the project is billed to, or none if empty")
+	addBudgetFlags(updateProjectCmd)
+	updateProjectCmd.Flags().BoolVar(&noBudget, "no-budget", false, "Remove the budget of the project")
 }
 
 var updateProjectCmd = &cobra.Command{
@@ -43,12 +44,17 @@ func updateProject(cmd *cobra.Command, projectName string) error {
 	if cmd.Flags().Changed("billable") {
 		project.Billable = commandLineProject.Billable
 	}
+	if cmd.Flags().Changed("client") {
+		project.Client = commandLineProject.Client
+	}
+	if noBudget {
+		project.Budget = nil
+	}
+	project.Budget = budgetFromFlags(cmd, project.Budget)
 
 	project, err = apiClient.UpdateProject(projectName, project)
 	if err != nil {
 		return err
 	}
-	fmt.Println(project)
-
-	return nil
+	return printRecord(project)
 }
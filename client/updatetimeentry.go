@@ -1,7 +1,6 @@
 package client
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -12,8 +11,10 @@ func init() {
 	updateTimeEntryCmd.Flags().StringVarP(&startTime, "start", "s", "", "Entry start time/date")
 	updateTimeEntryCmd.Flags().StringVarP(&endTime, "end", "e", "", "Entry end time/date")
 	updateTimeEntryCmd.Flags().StringVarP(&breaks, "breaks", "b", "", "Break duration")
-	updateTimeEntryCmd.Flags().StringVarP(&entryType, "type", "t", "work", "Entry type (work|sick|sick-child|vacation)")
+	updateTimeEntryCmd.Flags().StringVarP(&entryType, "type", "t", "work", "Entry type, see list-entry-types")
 	updateTimeEntryCmd.Flags().StringVarP(&entryComment, "comment", "c", "", "Entry comment")
+	updateTimeEntryCmd.Flags().StringSliceVar(&entryTags, "tag", nil, "Replace the tags of the entry; can be repeated or comma separated")
+	updateTimeEntryCmd.Flags().BoolVar(&allowOverlap, "allow-overlap", false, "Save the entry even if it overlaps other work")
 }
 
 var updateTimeEntryCmd = &cobra.Command{
@@ -26,63 +27,79 @@ var updateTimeEntryCmd = &cobra.Command{
 	}),
 }
 
-func updateTimeEntry(cmd *cobra.Command, projectName string, entryID string) error {
-	apiClient, err := NewApiClient()
-	if err != nil {
-		return err
-	}
-
-	entry, err := apiClient.GetTimeEntry(projectName, entryID)
-	if err != nil {
-		return err
-	}
+// entryChangesFromFlags returns the changes given with the --start, --end, --breaks, --type,
+// --comment and --tag flags
+func entryChangesFromFlags(cmd *cobra.Command) (*entryChanges, error) {
+	var changes entryChanges
 
 	if startTime != "" {
 		start, err := ParseTimeRef(startTime, time.Now())
-		entry.Start = &start
 		if err != nil {
-			return err
+			return nil, err
 		}
+		changes.Start = &start
 	}
 
 	if endTime != "" {
 		end, err := ParseTimeRef(endTime, time.Now())
-		entry.End = &end
 		if err != nil {
-			return err
+			return nil, err
 		}
+		changes.End = &end
 	}
 
 	if cmd.Flags().Changed("breaks") {
-		entry.Breaks, err = time.ParseDuration(breaks)
+		breakDuration, err := time.ParseDuration(breaks)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		changes.Breaks = &breakDuration
 	}
 
 	if cmd.Flags().Changed("type") {
-		entry.Type = entryType
+		changes.Type = &entryType
 	}
 
 	if cmd.Flags().Changed("comment") {
-		entry.Comment = entryComment
+		changes.Comment = &entryComment
 	}
 
-	if entry.Type != "work" {
-		if entry.Start != nil {
-			*entry.Start = normalizeTime(*entry.Start)
-		}
-		if entry.End != nil {
-			*entry.End = normalizeTime(*entry.End)
-		}
+	if cmd.Flags().Changed("tag") {
+		tags := commandLineTags()
+		changes.Tags = &tags
+	}
+
+	return &changes, nil
+}
+
+func updateTimeEntry(cmd *cobra.Command, projectName string, entryID string) error {
+	apiClient, err := NewApiClient()
+	if err != nil {
+		return err
 	}
 
-	result, err := apiClient.UpdateTimeEntry(projectName, entry)
+	changes, err := entryChangesFromFlags(cmd)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(result)
+	if isLocalID(entryID) {
+		return recordUpdate(projectName, entryID, changes)
+	}
+
+	entry, err := apiClient.GetTimeEntry(projectName, entryID)
+	if isOffline(err) {
+		return recordUpdate(projectName, entryID, changes)
+	} else if err != nil {
+		return err
+	}
+
+	changes.apply(entry)
+
+	result, err := updateOrRecordTimeEntry(apiClient, entry, changes)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return printRecord(result)
 }
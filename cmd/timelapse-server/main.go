@@ -1,14 +1,43 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/kennep/timelapse/domain"
 	"github.com/kennep/timelapse/endpoints"
+	"github.com/kennep/timelapse/memory_repository"
 	"github.com/kennep/timelapse/mongo_repository"
+	"github.com/kennep/timelapse/sql_repository"
 	log "github.com/sirupsen/logrus"
+
+	// SQL drivers supported by sql_repository
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
+// newRepository sets up the repository selected by the TIMELAPSE_DB_DRIVER environment variable.
+// MongoDB is used if nothing else is specified.
+func newRepository() (domain.TimelapseRepository, error) {
+	switch driver := os.Getenv("TIMELAPSE_DB_DRIVER"); driver {
+	case "", "mongo":
+		return mongo_repository.NewMongoRepository()
+	case "memory":
+		log.Warn("Using in-memory repository - all data will be lost when the server exits")
+		return memory_repository.NewMemoryRepository(), nil
+	case "sqlite3", "postgres":
+		dataSourceName := os.Getenv("TIMELAPSE_DB_URL")
+		if dataSourceName == "" {
+			return nil, fmt.Errorf("Configuration error: data source name for %s must be specified in TIMELAPSE_DB_URL environment variable", driver)
+		}
+		return sql_repository.NewSqlRepository(driver, dataSourceName)
+	default:
+		return nil, fmt.Errorf("Configuration error: unknown database driver in TIMELAPSE_DB_DRIVER: %s", driver)
+	}
+}
+
 func main() {
-	repository, err := mongo_repository.NewMongoRepository()
+	repository, err := newRepository()
 	if err != nil {
 		log.Errorf("Error connecting to repository: %s", err)
 		panic(err)
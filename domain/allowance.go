@@ -0,0 +1,290 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+type (
+	// Allowance is the number of days of a whole-day entry type, like vacation, that a user
+	// may take each year. An allowance applies from the year in From until a later allowance
+	// for the same type takes over.
+	Allowance struct {
+		Type string
+		From int
+		Days int
+		// CarryOver is the most unused days that are carried over into the next year
+		CarryOver int
+	}
+
+	// Holiday is a day off, formatted as YYYY-MM-DD, that does not use up allowances
+	Holiday struct {
+		Date string
+		Name string
+	}
+
+	// AllowanceStatus is how many days of an allowance are left in a year. Used counts the
+	// days covered by entries of the type, except weekends and holidays. Remaining is
+	// negative if more days were taken than the allowance and the carried over days.
+	AllowanceStatus struct {
+		Type        string
+		Year        int
+		Allowance   int
+		CarriedOver int
+		Used        int
+		Remaining   int
+	}
+)
+
+// validateAllowance checks that the allowance is for a known whole-day entry type and has
+// a sensible year and number of days. If any rule is violated, a *ValidationError is returned.
+func validateAllowance(a *Allowance, types EntryTypeSet) error {
+	var result ValidationError
+	if t := types.Get(a.Type); t == nil {
+		result.add("type", RuleUnknownType, "unknown entry type: %s", a.Type)
+	} else if t.TimeBased {
+		result.add("type", RuleTimeBased, "allowances are counted in days, but %s entries are time-based", a.Type)
+	}
+	if a.From < 1900 || a.From > 9999 {
+		result.add("from", RuleFormat, "from must be a year like 2019")
+	}
+	if a.Days < 0 {
+		result.add("days", RuleNegative, "days must not be negative")
+	}
+	if a.CarryOver < 0 {
+		result.add("carry_over", RuleNegative, "carry_over must not be negative")
+	}
+	return result.result()
+}
+
+// validateHoliday checks that the holiday has a valid date. If not, a *ValidationError is
+// returned.
+func validateHoliday(h *Holiday) error {
+	var result ValidationError
+	if _, err := time.Parse(ScheduleDateFormat, h.Date); err != nil {
+		result.add("date", RuleFormat, "date must be a date like 2019-01-31")
+	}
+	return result.result()
+}
+
+// GetAllowances returns the user's allowances ordered by type and year
+func (u *User) GetAllowances() ([]*Allowance, error) {
+	return u.repo.GetAllowances(u)
+}
+
+// SaveAllowance stores an allowance, replacing the one for the same type and year. If the
+// allowance is not valid, a *ValidationError is returned.
+func (u *User) SaveAllowance(a *Allowance) error {
+	types, err := u.EntryTypeSet()
+	if err != nil {
+		return err
+	}
+	if err := validateAllowance(a, types); err != nil {
+		return err
+	}
+	return u.repo.SaveAllowance(u, *a)
+}
+
+// DeleteAllowance removes the allowance for the entry type that applies from the given year
+func (u *User) DeleteAllowance(entryType string, from int) error {
+	return u.repo.DeleteAllowance(u, entryType, from)
+}
+
+// GetHolidays returns the user's holidays ordered by date
+func (u *User) GetHolidays() ([]*Holiday, error) {
+	return u.repo.GetHolidays(u)
+}
+
+// SaveHoliday stores a holiday, replacing any on the same day. If the holiday is not valid,
+// a *ValidationError is returned.
+func (u *User) SaveHoliday(h *Holiday) error {
+	if err := validateHoliday(h); err != nil {
+		return err
+	}
+	return u.repo.SaveHoliday(u, *h)
+}
+
+// DeleteHoliday removes the holiday on the given day
+func (u *User) DeleteHoliday(date string) error {
+	return u.repo.DeleteHoliday(u, date)
+}
+
+// AllowanceStatuses returns the status in the given year of each entry type that has an
+// allowance by then, ordered by type. Days are taken in loc.
+func (u *User) AllowanceStatuses(year int, loc *time.Location) ([]*AllowanceStatus, error) {
+	allowances, err := u.GetAllowances()
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := u.holidaySet()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*AllowanceStatus
+	for _, entryType := range allowanceTypes(allowances) {
+		status, err := u.allowanceStatus(allowancesFor(allowances, entryType), holidays, year, loc)
+		if err != nil {
+			return nil, err
+		}
+		if status != nil {
+			result = append(result, status)
+		}
+	}
+	return result, nil
+}
+
+// CheckAllowance returns the status of the allowance for the entry's type in the first
+// year where the stored entry uses more days than are left, or nil if the allowance
+// covers it or the type has none
+func (u *User) CheckAllowance(entry *TimeEntry, loc *time.Location) (*AllowanceStatus, error) {
+	if entry == nil || entry.Start == nil {
+		return nil, nil
+	}
+	allowances, err := u.GetAllowances()
+	if err != nil {
+		return nil, err
+	}
+	allowances = allowancesFor(allowances, entry.Type)
+	if len(allowances) == 0 {
+		return nil, nil
+	}
+	holidays, err := u.holidaySet()
+	if err != nil {
+		return nil, err
+	}
+
+	days := countAllowanceDays([]*TimeEntry{entry}, holidays, loc)
+	var years []int
+	for year := range days {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+	for _, year := range years {
+		status, err := u.allowanceStatus(allowances, holidays, year, loc)
+		if err != nil {
+			return nil, err
+		}
+		if status != nil && status.Remaining < 0 {
+			return status, nil
+		}
+	}
+	return nil, nil
+}
+
+// allowanceStatus computes the status of an allowance in a year, carrying over the unused
+// days from each year since the type first had an allowance. The allowances must be for
+// one type and ordered by year. If none applies in the year, nil is returned.
+func (u *User) allowanceStatus(allowances []*Allowance, holidays map[string]bool, year int, loc *time.Location) (*AllowanceStatus, error) {
+	if len(allowances) == 0 || allowances[0].From > year {
+		return nil, nil
+	}
+	entryType := allowances[0].Type
+	first := allowances[0].From
+
+	// Entries that started in the previous year can cover the first days of a year
+	from := time.Date(first, 1, 1, 0, 0, 0, 0, loc).AddDate(0, 0, -maxAbsenceDays)
+	to := time.Date(year+1, 1, 1, 0, 0, 0, 0, loc)
+	entries, err := u.GetEntries(EntryQuery{From: &from, To: &to, Type: entryType})
+	if err != nil {
+		return nil, err
+	}
+	used := countAllowanceDays(entries, holidays, loc)
+
+	var status *AllowanceStatus
+	for y := first; y <= year; y++ {
+		allowance := effectiveAllowance(allowances, y)
+		next := &AllowanceStatus{Type: entryType, Year: y, Allowance: allowance.Days, Used: used[y]}
+		if status != nil && status.Remaining > 0 {
+			next.CarriedOver = status.Remaining
+			if carryOver := effectiveAllowance(allowances, y-1).CarryOver; next.CarriedOver > carryOver {
+				next.CarriedOver = carryOver
+			}
+		}
+		next.Remaining = next.Allowance + next.CarriedOver - next.Used
+		status = next
+	}
+	return status, nil
+}
+
+// countAllowanceDays counts the days covered by whole-day entries per year, the way
+// TimeEntry.Days counts them, but skipping weekends and holidays
+func countAllowanceDays(entries []*TimeEntry, holidays map[string]bool, loc *time.Location) map[int]int {
+	result := make(map[int]int)
+	for _, e := range entries {
+		if e.Start == nil {
+			continue
+		}
+		start := startOfDay(e.Start.In(loc))
+		for i := 0; i < e.Days(); i++ {
+			day := start.AddDate(0, 0, i)
+			if isWorkday(day) && !holidays[day.Format(ScheduleDateFormat)] {
+				result[day.Year()]++
+			}
+		}
+	}
+	return result
+}
+
+// isWorkday tells whether the day is a weekday from Monday to Friday
+func isWorkday(day time.Time) bool {
+	return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+}
+
+// holidaySet returns the dates of the user's holidays
+func (u *User) holidaySet() (map[string]bool, error) {
+	holidays, err := u.GetHolidays()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool)
+	for _, h := range holidays {
+		result[h.Date] = true
+	}
+	return result, nil
+}
+
+// effectiveAllowance returns the allowance that took effect last in or before the year.
+// The allowances must be ordered by year, and the first must apply.
+func effectiveAllowance(allowances []*Allowance, year int) *Allowance {
+	result := allowances[0]
+	for _, a := range allowances {
+		if a.From <= year {
+			result = a
+		}
+	}
+	return result
+}
+
+// allowancesFor returns the allowances for one entry type
+func allowancesFor(allowances []*Allowance, entryType string) []*Allowance {
+	var result []*Allowance
+	for _, a := range allowances {
+		if a.Type == entryType {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// allowanceTypes returns the entry types that have allowances, in order
+func allowanceTypes(allowances []*Allowance) []string {
+	var result []string
+	for _, a := range allowances {
+		if len(result) == 0 || result[len(result)-1] != a.Type {
+			result = append(result, a.Type)
+		}
+	}
+	return result
+}
+
+// SortAllowances orders allowances by type and year. It is meant for repositories that
+// cannot do this themselves.
+func SortAllowances(allowances []*Allowance) {
+	sort.Slice(allowances, func(i, j int) bool {
+		if allowances[i].Type != allowances[j].Type {
+			return allowances[i].Type < allowances[j].Type
+		}
+		return allowances[i].From < allowances[j].From
+	})
+}
@@ -0,0 +1,295 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ScheduleDateFormat is the format of Schedule.From and BalanceAdjustment.Date
+const ScheduleDateFormat = "2006-01-02"
+
+// BalanceGroupByOptions lists the valid groupings for balances
+var BalanceGroupByOptions = []string{GroupByDay, GroupByWeek, GroupByMonth}
+
+// maxAbsenceDays bounds how long before the start of a balance an absence can have started
+// and still cover days of the balance
+const maxAbsenceDays = 31
+
+type (
+	// Schedule is the time a user is expected to work on each weekday. A schedule takes
+	// effect on its From date and applies until a later schedule takes over.
+	Schedule struct {
+		// From is the first day the schedule applies, as YYYY-MM-DD
+		From string
+		// Hours holds the expected time for each weekday, starting with Monday
+		Hours []time.Duration
+	}
+
+	// BalanceAdjustment corrects the flex balance on a day, for example when overtime is
+	// paid out or a balance is carried over from before the first schedule
+	BalanceAdjustment struct {
+		ID string
+		// Date is the day the adjustment applies, as YYYY-MM-DD
+		Date string
+		// Amount is added to the balance. Negative amounts reduce it.
+		Amount  time.Duration
+		Comment string
+	}
+
+	// BalancePeriod is the expected and recorded time for one day, week or month of a balance
+	BalancePeriod struct {
+		Key      string
+		Expected time.Duration
+		Worked   time.Duration
+		// Absence is the part of the expected time that absences fulfilled
+		Absence     time.Duration
+		Adjustments time.Duration
+		// Balance is the difference between the recorded and expected time, adjustments
+		// included
+		Balance time.Duration
+		// Running is the flex balance at the end of the period
+		Running time.Duration
+	}
+
+	// Balance is the flex balance over a range of days. It accumulates from the first
+	// day of the user's first schedule.
+	Balance struct {
+		GroupBy string
+		// From and To are the first and last day of the balance, in the location it was
+		// computed for
+		From time.Time
+		To   time.Time
+		// Opening is the flex balance before the first day
+		Opening time.Duration
+		Periods []*BalancePeriod
+		// Total sums up the periods; its running balance is the closing balance
+		Total *BalancePeriod
+	}
+)
+
+// validateSchedule checks that the schedule has a valid date and an expected time between
+// zero and 24 hours for each weekday. If any rule is violated, a *ValidationError is returned.
+func validateSchedule(s *Schedule) error {
+	var result ValidationError
+	if _, err := time.Parse(ScheduleDateFormat, s.From); err != nil {
+		result.add("from", RuleFormat, "from must be a date like 2019-01-31")
+	}
+	if len(s.Hours) != 7 {
+		result.add("hours", RuleFormat, "hours must be given for each of the 7 weekdays")
+	}
+	for i, hours := range s.Hours {
+		if hours < 0 {
+			result.add(fmt.Sprintf("hours[%d]", i), RuleNegative, "hours must not be negative")
+		} else if hours > 24*time.Hour {
+			result.add(fmt.Sprintf("hours[%d]", i), RuleTooLong, "hours must not exceed a day")
+		}
+	}
+	return result.result()
+}
+
+// validateBalanceAdjustment checks that the adjustment has a valid date and an amount. If
+// any rule is violated, a *ValidationError is returned.
+func validateBalanceAdjustment(a *BalanceAdjustment) error {
+	var result ValidationError
+	if _, err := time.Parse(ScheduleDateFormat, a.Date); err != nil {
+		result.add("date", RuleFormat, "date must be a date like 2019-01-31")
+	}
+	if a.Amount == 0 {
+		result.add("amount", RuleRequired, "amount is required")
+	}
+	return result.result()
+}
+
+// GetSchedules returns the user's schedules ordered by the date they take effect
+func (u *User) GetSchedules() ([]*Schedule, error) {
+	return u.repo.GetSchedules(u)
+}
+
+// SaveSchedule stores a schedule, replacing any that takes effect on the same day. If the
+// schedule is not valid, a *ValidationError is returned.
+func (u *User) SaveSchedule(s *Schedule) error {
+	if err := validateSchedule(s); err != nil {
+		return err
+	}
+	return u.repo.SaveSchedule(u, *s)
+}
+
+// DeleteSchedule removes the schedule that takes effect on the given day
+func (u *User) DeleteSchedule(from string) error {
+	return u.repo.DeleteSchedule(u, from)
+}
+
+// GetBalanceAdjustments returns the user's balance adjustments ordered by date
+func (u *User) GetBalanceAdjustments() ([]*BalanceAdjustment, error) {
+	return u.repo.GetBalanceAdjustments(u)
+}
+
+// AddBalanceAdjustment stores a new balance adjustment. If the adjustment is not valid, a
+// *ValidationError is returned.
+func (u *User) AddBalanceAdjustment(a *BalanceAdjustment) (*BalanceAdjustment, error) {
+	if err := validateBalanceAdjustment(a); err != nil {
+		return nil, err
+	}
+	return u.repo.AddBalanceAdjustment(u, *a)
+}
+
+// DeleteBalanceAdjustment removes the balance adjustment with the given ID
+func (u *User) DeleteBalanceAdjustment(adjustmentID string) error {
+	return u.repo.DeleteBalanceAdjustment(u, adjustmentID)
+}
+
+// effectiveSchedule returns the schedule that took effect last on or before the given day,
+// formatted as YYYY-MM-DD, or nil if there is none. The schedules must be ordered by date.
+func effectiveSchedule(schedules []*Schedule, day string) *Schedule {
+	var result *Schedule
+	for _, s := range schedules {
+		if s.From <= day {
+			result = s
+		}
+	}
+	return result
+}
+
+// expectedOn returns the time the schedule expects on the weekday of the given day
+func (s *Schedule) expectedOn(day time.Time) time.Duration {
+	if s == nil {
+		return 0
+	}
+	return s.Hours[(int(day.Weekday())+6)%7]
+}
+
+// Balance computes the flex balance for the days from the day of from to the day of to,
+// inclusive, in loc, grouped by day, week or month. Worked time counts on the day the
+// entry started. Entries of types that fulfill the schedule count towards the expected
+// time of the days they cover, but no more. No time is expected on holidays. If the user
+// has no schedule, nil is returned.
+func (u *User) Balance(from, to time.Time, groupBy string, loc *time.Location) (*Balance, error) {
+	valid := false
+	for _, option := range BalanceGroupByOptions {
+		valid = valid || option == groupBy
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid grouping: %s", groupBy)
+	}
+
+	schedules, err := u.GetSchedules()
+	if err != nil || len(schedules) == 0 {
+		return nil, err
+	}
+	adjustments, err := u.GetBalanceAdjustments()
+	if err != nil {
+		return nil, err
+	}
+	types, err := u.EntryTypeSet()
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := u.holidaySet()
+	if err != nil {
+		return nil, err
+	}
+
+	first, err := time.ParseInLocation(ScheduleDateFormat, schedules[0].From, loc)
+	if err != nil {
+		return nil, err
+	}
+	from = startOfDay(from.In(loc))
+	to = startOfDay(to.In(loc))
+	start := first
+	if from.Before(start) {
+		start = from
+	}
+	end := to.AddDate(0, 0, 1)
+
+	lookback := start.AddDate(0, 0, -maxAbsenceDays)
+	entries, err := u.GetEntries(EntryQuery{From: &lookback, To: &end})
+	if err != nil {
+		return nil, err
+	}
+
+	worked := make(map[string]time.Duration)
+	absent := make(map[string]time.Duration)
+	wholeDays := make(map[string]bool)
+	for _, e := range entries {
+		if e.Start == nil {
+			continue
+		}
+		day := e.Start.In(loc).Format(ScheduleDateFormat)
+		switch {
+		case types.IsWork(e.Type):
+			worked[day] += e.Duration()
+		case types.FulfillsSchedule(e.Type) && types.IsTimeBased(e.Type):
+			absent[day] += e.Duration()
+		case types.FulfillsSchedule(e.Type):
+			startDay := startOfDay(e.Start.In(loc))
+			for i := 0; i < e.Days(); i++ {
+				wholeDays[startDay.AddDate(0, 0, i).Format(ScheduleDateFormat)] = true
+			}
+		}
+	}
+	adjusted := make(map[string]time.Duration)
+	for _, a := range adjustments {
+		adjusted[a.Date] += a.Amount
+	}
+
+	balance := Balance{GroupBy: groupBy, From: from, To: to, Total: &BalancePeriod{}}
+	var current *BalancePeriod
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		key := day.Format(ScheduleDateFormat)
+		var period BalancePeriod
+		if !day.Before(first) {
+			if !holidays[key] {
+				period.Expected = effectiveSchedule(schedules, key).expectedOn(day)
+			}
+			period.Worked = worked[key]
+			period.Absence = absent[key]
+			if wholeDays[key] || period.Absence > period.Expected {
+				period.Absence = period.Expected
+			}
+			period.Adjustments = adjusted[key]
+			period.Balance = period.Worked + period.Absence + period.Adjustments - period.Expected
+		}
+
+		if day.Before(from) {
+			balance.Opening += period.Balance
+			continue
+		}
+		periodKey := periodKey(day, groupBy)
+		if current == nil || current.Key != periodKey {
+			current = &BalancePeriod{Key: periodKey}
+			balance.Periods = append(balance.Periods, current)
+		}
+		current.add(&period)
+		balance.Total.add(&period)
+	}
+
+	running := balance.Opening
+	for _, period := range balance.Periods {
+		running += period.Balance
+		period.Running = running
+	}
+	balance.Total.Running = running
+	return &balance, nil
+}
+
+func (p *BalancePeriod) add(other *BalancePeriod) {
+	p.Expected += other.Expected
+	p.Worked += other.Worked
+	p.Absence += other.Absence
+	p.Adjustments += other.Adjustments
+	p.Balance += other.Balance
+}
+
+// startOfDay returns midnight at the start of the day of t, in the location of t
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// SortSchedules orders schedules by the date they take effect. It is meant for
+// repositories that cannot do this themselves.
+func SortSchedules(schedules []*Schedule) {
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].From < schedules[j].From
+	})
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+type (
+	// BillingLine is the time in one project and of one entry type that is billed at
+	// the same rate
+	BillingLine struct {
+		// Client is the name of the client, or empty if the project has no client
+		Client    string
+		Project   string
+		EntryType string
+		Rate      float64
+		Currency  string
+		Hours     time.Duration
+		Amount    float64
+	}
+
+	// Billing is what time entries amount to when billed at the rates of their projects
+	// and clients
+	Billing struct {
+		Lines []*BillingLine
+		// Totals holds the billed amount per currency
+		Totals map[string]float64
+		// Unpriced is billable time that no rate applies to
+		Unpriced time.Duration
+	}
+)
+
+// roundAmount rounds an amount to cents
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// Bill prices the finished time-based entries of billable projects at the rate that
+// applied on the day each entry started, in the given location. The clients are those
+// the projects may be billed to. Lines are ordered by client, project, entry type and rate,
+// and amounts are rounded to cents.
+func Bill(entries []*TimeEntry, clients []*Client, loc *time.Location, types EntryTypeSet) *Billing {
+	clientsByID := make(map[string]*Client)
+	for _, c := range clients {
+		clientsByID[c.ID] = c
+	}
+
+	type lineKey struct {
+		project   string
+		entryType string
+		rate      float64
+		currency  string
+	}
+	lines := make(map[lineKey]*BillingLine)
+	billing := Billing{Totals: make(map[string]float64)}
+	for _, e := range entries {
+		if e.Project == nil || !e.Project.Billable || e.Start == nil || e.End == nil || !types.IsTimeBased(e.Type) {
+			continue
+		}
+		client := clientsByID[e.Project.ClientID]
+		rate := RateFor(e.Project, client, e.Type, e.Start.In(loc).Format(RateDateFormat))
+		if rate == nil {
+			billing.Unpriced += e.Duration()
+			continue
+		}
+
+		key := lineKey{e.Project.ID, e.Type, rate.Amount, rate.Currency}
+		line, ok := lines[key]
+		if !ok {
+			line = &BillingLine{Project: e.Project.Name, EntryType: e.Type, Rate: rate.Amount, Currency: rate.Currency}
+			if client != nil {
+				line.Client = client.Name
+			}
+			lines[key] = line
+			billing.Lines = append(billing.Lines, line)
+		}
+		line.Hours += e.Duration()
+		line.Amount += rate.Amount * e.Duration().Hours()
+	}
+
+	for _, line := range billing.Lines {
+		line.Amount = roundAmount(line.Amount)
+		billing.Totals[line.Currency] = roundAmount(billing.Totals[line.Currency] + line.Amount)
+	}
+	sort.Slice(billing.Lines, func(i, j int) bool {
+		a, b := billing.Lines[i], billing.Lines[j]
+		if a.Client != b.Client {
+			return a.Client < b.Client
+		}
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		if a.EntryType != b.EntryType {
+			return a.EntryType < b.EntryType
+		}
+		return a.Rate < b.Rate
+	})
+	return &billing
+}
@@ -0,0 +1,250 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Periods that a budget covers
+const (
+	// BudgetTotal budgets cover all the time recorded on a project
+	BudgetTotal = "total"
+	// BudgetWeekly budgets are renewed every ISO week, starting on Monday
+	BudgetWeekly = "week"
+	// BudgetMonthly budgets are renewed every calendar month
+	BudgetMonthly = "month"
+)
+
+// BudgetPeriods lists the valid budget periods
+var BudgetPeriods = []string{BudgetTotal, BudgetWeekly, BudgetMonthly}
+
+// DefaultBudgetThresholds are the percentages of a budget that trigger warnings when
+// a budget has no thresholds of its own
+var DefaultBudgetThresholds = []int{80, 100}
+
+type (
+	// Budget limits the worked time or the money that can be spent on a project, in
+	// total or per period
+	Budget struct {
+		// Hours is the worked time sold, or zero if the budget does not limit time
+		Hours time.Duration
+		// Amount is the money sold, or zero if the budget does not limit money. Worked
+		// time is priced at the rates of the project and its client, see RateFor.
+		Amount   float64
+		Currency string
+		// Period is one of BudgetPeriods
+		Period string
+		// Thresholds are percentages of the budget that trigger warnings. If there are
+		// none, DefaultBudgetThresholds apply.
+		Thresholds []int
+	}
+
+	// BudgetStatus is how much of a budget is used in one of its periods
+	BudgetStatus struct {
+		Budget *Budget
+		// From and To bound the period. They are nil for total budgets.
+		From *time.Time
+		To   *time.Time
+		// Hours is the worked time recorded in the period
+		Hours time.Duration
+		// Amount is what the worked time amounts to in the currency of the budget
+		Amount float64
+		// Unpriced is worked time that no rate in the currency of the budget applies to.
+		// It only counts for budgets that limit money.
+		Unpriced time.Duration
+		// Used is the percentage of the budget that is used, rounded to two decimals. For
+		// budgets that limit both time and money, it is the higher of the two.
+		Used float64
+		// Reached lists the thresholds that Used has reached, in ascending order
+		Reached []int
+	}
+)
+
+// validateBudget checks that the budget limits time or money, that the amounts are not
+// negative and that the period and thresholds are valid. If any rule is violated, a
+// *ValidationError is returned.
+func validateBudget(b *Budget) error {
+	var result ValidationError
+	if b.Hours < 0 {
+		result.add("hours", RuleNegative, "hours must not be negative")
+	}
+	if b.Amount < 0 {
+		result.add("amount", RuleNegative, "amount must not be negative")
+	}
+	if b.Hours == 0 && b.Amount == 0 {
+		result.add("hours", RuleRequired, "a budget needs hours or an amount")
+	}
+	if b.Amount != 0 && !currencyPattern.MatchString(b.Currency) {
+		result.add("currency", RuleFormat, "currency must be a three-letter code like EUR")
+	}
+	valid := false
+	for _, period := range BudgetPeriods {
+		valid = valid || period == b.Period
+	}
+	if !valid {
+		result.add("period", RuleFormat, "period must be one of %v", BudgetPeriods)
+	}
+	for i, threshold := range b.Thresholds {
+		if threshold <= 0 {
+			result.add(fmt.Sprintf("thresholds[%d]", i), RuleNegative, "thresholds must be positive percentages")
+		} else if i > 0 && threshold <= b.Thresholds[i-1] {
+			result.add(fmt.Sprintf("thresholds[%d]", i), RuleFormat, "thresholds must be in ascending order")
+		}
+	}
+	return result.result()
+}
+
+// thresholds returns the thresholds of the budget, or the default ones
+func (b *Budget) thresholds() []int {
+	if len(b.Thresholds) == 0 {
+		return DefaultBudgetThresholds
+	}
+	return b.Thresholds
+}
+
+// PeriodAt returns the bounds of the period of the budget that contains the given time,
+// with weeks and months starting at midnight in loc. Total budgets have no bounds, so
+// nil is returned for both.
+func (b *Budget) PeriodAt(t time.Time, loc *time.Location) (*time.Time, *time.Time) {
+	t = t.In(loc)
+	var from, to time.Time
+	switch b.Period {
+	case BudgetWeekly:
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		from = time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, loc)
+		to = from.AddDate(0, 0, 7)
+	case BudgetMonthly:
+		from = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		to = from.AddDate(0, 1, 0)
+	default:
+		return nil, nil
+	}
+	return &from, &to
+}
+
+// budgetStatus sums up the worked time of the entries, which are those of the project in
+// one period of its budget. Time is priced at the rates of the project and the client,
+// which may be nil, on the day each entry started in loc.
+func budgetStatus(p *Project, client *Client, entries []*TimeEntry, types EntryTypeSet, loc *time.Location) *BudgetStatus {
+	b := p.Budget
+	status := BudgetStatus{Budget: b}
+	for _, e := range entries {
+		if e.Start == nil || !types.IsWork(e.Type) {
+			continue
+		}
+		duration := e.Duration()
+		status.Hours += duration
+		if b.Amount == 0 {
+			continue
+		}
+		rate := RateFor(p, client, e.Type, e.Start.In(loc).Format(RateDateFormat))
+		if rate == nil || rate.Currency != b.Currency {
+			status.Unpriced += duration
+			continue
+		}
+		status.Amount += rate.Amount * duration.Hours()
+	}
+	status.Amount = roundAmount(status.Amount)
+
+	if b.Hours > 0 {
+		status.Used = float64(status.Hours) / float64(b.Hours) * 100
+	}
+	if b.Amount > 0 && status.Amount/b.Amount*100 > status.Used {
+		status.Used = status.Amount / b.Amount * 100
+	}
+	status.Used = roundAmount(status.Used)
+	for _, threshold := range b.thresholds() {
+		if status.Used >= float64(threshold) {
+			status.Reached = append(status.Reached, threshold)
+		}
+	}
+	return &status
+}
+
+// budgetEntries returns the entries of the user's project in the period of its budget that
+// contains the given time, along with what is needed to price them
+func (u *User) budgetEntries(p *Project, at time.Time, loc *time.Location) (from, to *time.Time, entries []*TimeEntry, client *Client, types EntryTypeSet, err error) {
+	from, to = p.Budget.PeriodAt(at, loc)
+	entries, err = u.repo.GetProjectTimeEntries(p, EntryQuery{From: from, To: to})
+	if err != nil {
+		return
+	}
+	types, err = u.EntryTypeSet()
+	if err != nil {
+		return
+	}
+	if p.ClientID == "" {
+		return
+	}
+	clients, err := u.GetClients()
+	if err != nil {
+		return
+	}
+	for _, c := range clients {
+		if c.ID == p.ClientID {
+			client = c
+		}
+	}
+	return
+}
+
+// BudgetStatus returns how much of the project's budget is used in the period that
+// contains the given time, with dates in loc. If the project has no budget, nil is returned.
+func (p *Project) BudgetStatus(at time.Time, loc *time.Location) (*BudgetStatus, error) {
+	if p.Budget == nil {
+		return nil, nil
+	}
+	from, to, entries, client, types, err := p.User.budgetEntries(p, at, loc)
+	if err != nil {
+		return nil, err
+	}
+	status := budgetStatus(p, client, entries, types, loc)
+	status.From, status.To = from, to
+	return status, nil
+}
+
+// inPeriod tells whether an entry starts within the bounds of a budget period
+func inPeriod(e *TimeEntry, from, to *time.Time) bool {
+	if e.Start == nil {
+		return false
+	}
+	return (from == nil || !e.Start.Before(*from)) && (to == nil || e.Start.Before(*to))
+}
+
+// CheckBudget returns the budget status of the project of a stored entry, for the period
+// that the entry starts in, along with the thresholds that storing the entry made the
+// budget reach: those that are reached now, but were not with the previous version of
+// the entry, which is nil for new entries. If the project has no budget, nil is returned.
+func (u *User) CheckBudget(entry *TimeEntry, previous *TimeEntry, loc *time.Location) (*BudgetStatus, []int, error) {
+	p := entry.Project
+	if p == nil || p.Budget == nil {
+		return nil, nil, nil
+	}
+	at := time.Now()
+	if entry.Start != nil {
+		at = *entry.Start
+	}
+	from, to, entries, client, types, err := u.budgetEntries(p, at, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var others []*TimeEntry
+	for _, e := range entries {
+		if e.ID != entry.ID {
+			others = append(others, e)
+		}
+	}
+	if previous != nil && previous.Project != nil && previous.Project.ID == p.ID && inPeriod(previous, from, to) {
+		others = append(others, previous)
+	}
+	before := budgetStatus(p, client, others, types, loc)
+	status := budgetStatus(p, client, entries, types, loc)
+	status.From, status.To = from, to
+
+	var crossed []int
+	if len(status.Reached) > len(before.Reached) {
+		crossed = status.Reached[len(before.Reached):]
+	}
+	return status, crossed, nil
+}
@@ -0,0 +1,107 @@
+package domain
+
+type (
+	// Client is a customer that projects are billed to
+	Client struct {
+		aggregateRoot
+		ID          string
+		User        *User
+		Name        string
+		Description string
+		// Rates apply to all the client's projects, see RateFor
+		Rates []Rate
+	}
+)
+
+// Validate checks that the client has a name and valid rates. If any rule is violated,
+// a *ValidationError is returned.
+func (c *Client) Validate(types EntryTypeSet) error {
+	var result ValidationError
+	if c.Name == "" {
+		result.add("name", RuleRequired, "name is required")
+	}
+	result.merge("", validateRates(c.Rates, types))
+	return result.result()
+}
+
+func (c *Client) Save() error {
+	types, err := c.User.EntryTypeSet()
+	if err != nil {
+		return err
+	}
+	if err := c.Validate(types); err != nil {
+		return err
+	}
+	_, err = c.repo.UpdateClient(*c)
+	return err
+}
+
+// GetProjects returns the projects that are billed to the client
+func (c *Client) GetProjects() ([]*Project, error) {
+	projects, err := c.User.GetProjects(ProjectQuery{})
+	if err != nil {
+		return nil, err
+	}
+	var result []*Project
+	for _, p := range projects {
+		if p.ClientID == c.ID {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+// Delete removes the client. If projects are still billed to the client,
+// ErrClientHasProjects is returned.
+func (c *Client) Delete() error {
+	projects, err := c.GetProjects()
+	if err != nil {
+		return err
+	}
+	if len(projects) > 0 {
+		return ErrClientHasProjects
+	}
+	return c.repo.DeleteClient(c)
+}
+
+func (u *User) GetClient(clientName string) (*Client, error) {
+	client, err := u.repo.GetClient(u, clientName)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, nil
+	}
+	u.copyDeps(&client.aggregateRoot)
+	return client, nil
+}
+
+// GetClients returns the user's clients ordered by name
+func (u *User) GetClients() ([]*Client, error) {
+	clients, err := u.repo.GetClients(u)
+	if err != nil {
+		return nil, err
+	}
+	for _, client := range clients {
+		u.copyDeps(&client.aggregateRoot)
+	}
+	return clients, nil
+}
+
+// AddClient stores a new client. If the client is not valid, a *ValidationError is returned.
+func (u *User) AddClient(client *Client) (*Client, error) {
+	client.User = u
+	types, err := u.EntryTypeSet()
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Validate(types); err != nil {
+		return nil, err
+	}
+	client, err = u.repo.AddClient(*client)
+	if err != nil {
+		return nil, err
+	}
+	u.copyDeps(&client.aggregateRoot)
+	return client, nil
+}
@@ -23,8 +23,6 @@ type (
 	}
 )
 
-var EntryTypes = []string{"work", "sick", "sick-child", "vacation"}
-
 func (p *Project) String() string {
 	return fmt.Sprintf("Project: %s (%s) (billable: %t)", p.Name, p.Description, p.Billable)
 }
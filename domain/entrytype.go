@@ -0,0 +1,202 @@
+package domain
+
+import (
+	"regexp"
+	"sort"
+)
+
+type (
+	// EntryType describes a kind of time entry. Entries of time-based types are recorded
+	// from start to end; other entries cover whole days.
+	EntryType struct {
+		Name string
+		// TimeBased is set for types that are recorded as hours rather than whole days
+		TimeBased bool
+		// CountsAsWork is set for types that count towards the worked time
+		CountsAsWork bool
+		// FulfillsSchedule is set for absences, like vacation, whose entries count towards
+		// the time the user is expected to work. Entries that cover whole days fulfill the
+		// schedule of each day they cover; time-based entries count with their duration.
+		FulfillsSchedule bool
+		// Color is a hex color like #4caf50 used when displaying entries, or empty
+		Color string
+	}
+
+	// EntryTypeSet holds entry types by name
+	EntryTypeSet map[string]*EntryType
+)
+
+// DefaultEntryTypes are the entry types every user has. They can be changed, but not deleted.
+var DefaultEntryTypes = []EntryType{
+	{Name: "work", TimeBased: true, CountsAsWork: true, Color: "#4caf50"},
+	{Name: "sick", FulfillsSchedule: true, Color: "#f44336"},
+	{Name: "sick-child", FulfillsSchedule: true, Color: "#ff9800"},
+	{Name: "vacation", FulfillsSchedule: true, Color: "#2196f3"},
+}
+
+var (
+	entryTypeNamePattern  = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	entryTypeColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+// IsDefaultEntryType tells whether name is one of DefaultEntryTypes
+func IsDefaultEntryType(name string) bool {
+	return DefaultEntryType(name) != nil
+}
+
+// DefaultEntryType returns the default entry type with the given name, or nil if there is none
+func DefaultEntryType(name string) *EntryType {
+	for _, t := range DefaultEntryTypes {
+		if t.Name == name {
+			t := t
+			return &t
+		}
+	}
+	return nil
+}
+
+// Validate checks that the name is made of lowercase letters, digits and dashes, and
+// that the color is empty or a hex color. If any rule is violated, a *ValidationError
+// is returned.
+func (t *EntryType) Validate() error {
+	var result ValidationError
+	if t.Name == "" {
+		result.add("name", RuleRequired, "name is required")
+	} else if !entryTypeNamePattern.MatchString(t.Name) {
+		result.add("name", RuleFormat, "name must consist of lowercase letters, digits and dashes")
+	}
+	if t.Color != "" && !entryTypeColorPattern.MatchString(t.Color) {
+		result.add("color", RuleFormat, "color must be a hex color like #4caf50")
+	}
+	return result.result()
+}
+
+// Get returns the type with the given name, or nil if there is none
+func (s EntryTypeSet) Get(name string) *EntryType {
+	return s[name]
+}
+
+// IsTimeBased tells whether entries of the named type are recorded as hours. Unknown
+// types cover whole days.
+func (s EntryTypeSet) IsTimeBased(name string) bool {
+	t := s.Get(name)
+	return t != nil && t.TimeBased
+}
+
+// IsWork tells whether entries of the named type are time-based and count as worked time
+func (s EntryTypeSet) IsWork(name string) bool {
+	t := s.Get(name)
+	return t != nil && t.TimeBased && t.CountsAsWork
+}
+
+// FulfillsSchedule tells whether entries of the named type count towards the work schedule
+func (s EntryTypeSet) FulfillsSchedule(name string) bool {
+	t := s.Get(name)
+	return t != nil && t.FulfillsSchedule
+}
+
+// Names returns the names of the types in alphabetical order
+func (s EntryTypeSet) Names() []string {
+	var names []string
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetEntryTypes returns the user's entry types: the default types, as changed by the
+// user, followed by the user's own types in alphabetical order.
+func (u *User) GetEntryTypes() ([]*EntryType, error) {
+	stored, err := u.repo.GetEntryTypes(u)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*EntryType)
+	for _, t := range stored {
+		byName[t.Name] = t
+	}
+
+	var result []*EntryType
+	for _, t := range DefaultEntryTypes {
+		if custom, ok := byName[t.Name]; ok {
+			result = append(result, custom)
+			delete(byName, t.Name)
+		} else {
+			t := t
+			result = append(result, &t)
+		}
+	}
+	var custom []*EntryType
+	for _, t := range byName {
+		custom = append(custom, t)
+	}
+	sort.Slice(custom, func(i, j int) bool {
+		return custom[i].Name < custom[j].Name
+	})
+	return append(result, custom...), nil
+}
+
+// EntryTypeSet returns the user's entry types by name. The types are loaded once and
+// kept for the lifetime of the user value.
+func (u *User) EntryTypeSet() (EntryTypeSet, error) {
+	if u.entryTypes != nil {
+		return u.entryTypes, nil
+	}
+	types, err := u.GetEntryTypes()
+	if err != nil {
+		return nil, err
+	}
+	set := make(EntryTypeSet)
+	for _, t := range types {
+		set[t.Name] = t
+	}
+	u.entryTypes = set
+	return set, nil
+}
+
+// SaveEntryType adds an entry type, or changes the one with the same name. If the type
+// is not valid, a *ValidationError is returned.
+func (u *User) SaveEntryType(t *EntryType) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if err := u.repo.SaveEntryType(u, *t); err != nil {
+		return err
+	}
+	u.entryTypes = nil
+	return nil
+}
+
+// DeleteEntryType removes one of the user's own entry types. Deleting a default type
+// reverts any changes the user made to it; if there are none, ErrDefaultEntryType is
+// returned. ErrEntryTypeInUse is returned if the type has time entries.
+func (u *User) DeleteEntryType(name string) error {
+	if IsDefaultEntryType(name) {
+		stored, err := u.repo.GetEntryTypes(u)
+		if err != nil {
+			return err
+		}
+		changed := false
+		for _, t := range stored {
+			changed = changed || t.Name == name
+		}
+		if !changed {
+			return ErrDefaultEntryType
+		}
+	} else {
+		entries, err := u.GetEntries(EntryQuery{Type: name, Limit: 1})
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return ErrEntryTypeInUse
+		}
+	}
+
+	if err := u.repo.DeleteEntryType(u, name); err != nil {
+		return err
+	}
+	u.entryTypes = nil
+	return nil
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "errors"
+
+// ErrProjectHasEntries is returned when deleting a project that still has time entries
+// without asking for the entries to be deleted as well.
+var ErrProjectHasEntries = errors.New("project has time entries")
+
+// ErrTimerRunning is returned when starting a timer while another one is running, and the
+// user's timer policy forbids parallel timers
+var ErrTimerRunning = errors.New("a timer is already running")
+
+// ErrDefaultEntryType is returned when deleting one of the default entry types
+var ErrDefaultEntryType = errors.New("default entry types cannot be deleted")
+
+// ErrEntryTypeInUse is returned when deleting an entry type that still has time entries
+var ErrEntryTypeInUse = errors.New("entry type has time entries")
+
+// ErrEntryInvoiced is returned when changing or deleting a time entry that has been invoiced
+var ErrEntryInvoiced = errors.New("time entry has been invoiced")
+
+// ErrTaskHasEntries is returned when deleting a task that time entries still refer to
+var ErrTaskHasEntries = errors.New("task has time entries")
+
+// ErrProjectNameTaken is returned when renaming a project to the name of another project
+var ErrProjectNameTaken = errors.New("project name is taken")
+
+// ErrProjectArchived is returned when adding time entries to an archived project, or
+// starting a timer for it
+var ErrProjectArchived = errors.New("project is archived")
+
+// ErrClientHasProjects is returned when deleting a client that projects are still billed to
+var ErrClientHasProjects = errors.New("client has projects")
@@ -0,0 +1,257 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Ways of grouping invoiced time into line items
+const (
+	InvoiceByProject = "project"
+	InvoiceByComment = "comment"
+)
+
+type (
+	// InvoiceLine is a line item of an invoice: time in one project that is billed at the
+	// same rate, and that has the same comment if the invoice is grouped by comment
+	InvoiceLine struct {
+		Project     string
+		Description string
+		Hours       time.Duration
+		Rate        float64
+		Currency    string
+		Amount      float64
+	}
+
+	// Invoice is a record of billed time. Invoices are numbered in sequence per user and
+	// never change once created; the entries they bill carry the invoice number and can no
+	// longer be changed either.
+	Invoice struct {
+		aggregateRoot
+		ID     string
+		User   *User
+		Number int
+		Issued time.Time
+		// Client is the name of the client all the invoiced projects are billed to, or
+		// empty if they have different clients or none
+		Client string
+		// From and To is the period the invoice covers, as given when it was created
+		From  *time.Time
+		To    *time.Time
+		Lines []InvoiceLine
+	}
+
+	// InvoiceRequest selects the time to invoice: the uninvoiced, finished time-based
+	// entries of the named billable projects that start in the period. Dates are
+	// determined in Location.
+	InvoiceRequest struct {
+		Projects []string
+		From     *time.Time
+		To       *time.Time
+		// GroupBy is InvoiceByProject or InvoiceByComment
+		GroupBy  string
+		Location *time.Location
+	}
+)
+
+// Totals returns the invoiced amount per currency
+func (inv *Invoice) Totals() map[string]float64 {
+	totals := make(map[string]float64)
+	for _, line := range inv.Lines {
+		totals[line.Currency] = roundAmount(totals[line.Currency] + line.Amount)
+	}
+	return totals
+}
+
+// invoiceEntry is an entry that is about to be invoiced, with the rate it is billed at
+type invoiceEntry struct {
+	entry *TimeEntry
+	rate  *Rate
+}
+
+// collectInvoiceEntries returns the entries a request selects. Projects that do not
+// exist, are not billable or have entries that no rate applies to are reported in
+// the validation error.
+func (u *User) collectInvoiceEntries(req InvoiceRequest, result *ValidationError) ([]invoiceEntry, string, error) {
+	types, err := u.EntryTypeSet()
+	if err != nil {
+		return nil, "", err
+	}
+	clients, err := u.GetClients()
+	if err != nil {
+		return nil, "", err
+	}
+	clientsByID := make(map[string]*Client)
+	for _, c := range clients {
+		clientsByID[c.ID] = c
+	}
+
+	var selected []invoiceEntry
+	clientIDs := make(map[string]bool)
+	for i, projectName := range req.Projects {
+		field := fmt.Sprintf("projects[%d]", i)
+		project, err := u.GetProject(projectName)
+		if err != nil {
+			return nil, "", err
+		}
+		if project == nil {
+			result.add(field, RuleUnknownProject, "unknown project %s", projectName)
+			continue
+		}
+		if !project.Billable {
+			result.add(field, RuleNotBillable, "project %s is not billable", projectName)
+			continue
+		}
+		clientIDs[project.ClientID] = true
+
+		entries, err := project.GetEntries(EntryQuery{From: req.From, To: req.To})
+		if err != nil {
+			return nil, "", err
+		}
+		unpriced := 0
+		for _, e := range entries {
+			if e.InvoiceNumber != 0 || e.Start == nil || e.End == nil || !types.IsTimeBased(e.Type) {
+				continue
+			}
+			rate := RateFor(project, clientsByID[project.ClientID], e.Type, e.Start.In(req.Location).Format(RateDateFormat))
+			if rate == nil {
+				unpriced++
+				continue
+			}
+			selected = append(selected, invoiceEntry{e, rate})
+		}
+		if unpriced > 0 {
+			result.add(field, RuleNoRate, "no rate applies to %d entries in project %s", unpriced, projectName)
+		}
+	}
+
+	var clientName string
+	if len(clientIDs) == 1 {
+		for id := range clientIDs {
+			if c := clientsByID[id]; c != nil {
+				clientName = c.Name
+			}
+		}
+	}
+	return selected, clientName, nil
+}
+
+// invoiceLines groups entries into line items, ordered by project and description
+func invoiceLines(entries []invoiceEntry, groupBy string) []InvoiceLine {
+	type lineKey struct {
+		project  string
+		comment  string
+		rate     float64
+		currency string
+	}
+	lines := make(map[lineKey]*InvoiceLine)
+	var keys []lineKey
+	for _, ie := range entries {
+		e := ie.entry
+		key := lineKey{project: e.Project.Name, rate: ie.rate.Amount, currency: ie.rate.Currency}
+		description := e.Project.Description
+		if description == "" {
+			description = e.Project.Name
+		}
+		if groupBy == InvoiceByComment {
+			key.comment = e.Comment
+			if e.Comment != "" {
+				description = e.Comment
+			}
+		}
+		line, ok := lines[key]
+		if !ok {
+			line = &InvoiceLine{Project: e.Project.Name, Description: description, Rate: ie.rate.Amount, Currency: ie.rate.Currency}
+			lines[key] = line
+			keys = append(keys, key)
+		}
+		line.Hours += e.Duration()
+		line.Amount += ie.rate.Amount * e.Duration().Hours()
+	}
+
+	var result []InvoiceLine
+	for _, key := range keys {
+		line := lines[key]
+		line.Amount = roundAmount(line.Amount)
+		result = append(result, *line)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Project != result[j].Project {
+			return result[i].Project < result[j].Project
+		}
+		return result[i].Description < result[j].Description
+	})
+	return result
+}
+
+// CreateInvoice invoices the time selected by the request, and marks the invoiced entries
+// with the number of the new invoice. If the request is not valid or selects no time, a
+// *ValidationError is returned. ErrEntryInvoiced is returned if some of the entries were
+// invoiced by someone else in the meantime.
+func (u *User) CreateInvoice(req InvoiceRequest) (*Invoice, error) {
+	var result ValidationError
+	if len(req.Projects) == 0 {
+		result.add("projects", RuleRequired, "at least one project is required")
+	}
+	switch req.GroupBy {
+	case InvoiceByProject, InvoiceByComment:
+	default:
+		result.add("group_by", RuleFormat, "group_by must be %s or %s", InvoiceByProject, InvoiceByComment)
+	}
+	if req.Location == nil {
+		req.Location = time.UTC
+	}
+
+	entries, clientName, err := u.collectInvoiceEntries(req, &result)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Errors) == 0 && len(entries) == 0 {
+		result.add("projects", RuleNothingToInvoice, "there is no uninvoiced billable time in the period")
+	}
+	if err := result.result(); err != nil {
+		return nil, err
+	}
+
+	invoice := Invoice{
+		User:   u,
+		Issued: time.Now().UTC(),
+		Client: clientName,
+		From:   req.From,
+		To:     req.To,
+		Lines:  invoiceLines(entries, req.GroupBy),
+	}
+	var entryIDs []string
+	for _, ie := range entries {
+		entryIDs = append(entryIDs, ie.entry.ID)
+	}
+	created, err := u.repo.AddInvoice(invoice, entryIDs)
+	if err != nil {
+		return nil, err
+	}
+	u.copyDeps(&created.aggregateRoot)
+	return created, nil
+}
+
+// GetInvoice returns the invoice with the given number, or nil if there is none
+func (u *User) GetInvoice(number int) (*Invoice, error) {
+	invoice, err := u.repo.GetInvoice(u, number)
+	if err != nil || invoice == nil {
+		return nil, err
+	}
+	u.copyDeps(&invoice.aggregateRoot)
+	return invoice, nil
+}
+
+// GetInvoices returns the user's invoices ordered by number
+func (u *User) GetInvoices() ([]*Invoice, error) {
+	invoices, err := u.repo.GetInvoices(u)
+	if err != nil {
+		return nil, err
+	}
+	for _, invoice := range invoices {
+		u.copyDeps(&invoice.aggregateRoot)
+	}
+	return invoices, nil
+}
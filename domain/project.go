@@ -8,16 +8,139 @@ type (
 		Name        string
 		Description string
 		Billable    bool
+		// ClientID is the ID of the client the project is billed to, or empty
+		ClientID string
+		// Rates apply to the project's entries instead of the client's rates, see RateFor
+		Rates []Rate
+		// Budget limits the time or money spent on the project, or is nil
+		Budget *Budget
+		// Aliases are the names the project had before it was renamed. They still find the
+		// project, see FindProject.
+		Aliases []string
+		// Archived projects are hidden from project listings and take no new entries, but
+		// their entries still count in reports and exports
+		Archived bool
+
+		// releasedNames are the names that other projects lose as aliases when the project
+		// is saved after being renamed
+		releasedNames []string
 	}
 )
 
+// Validate checks that the project has a name, valid rates and a valid budget, if it has
+// one. If any rule is violated, a *ValidationError is returned.
+func (p *Project) Validate(types EntryTypeSet) error {
+	var result ValidationError
+	if p.Name == "" {
+		result.add("name", RuleRequired, "name is required")
+	}
+	result.merge("", validateRates(p.Rates, types))
+	if p.Budget != nil {
+		result.merge("budget.", validateBudget(p.Budget))
+	}
+	return result.result()
+}
+
 func (p *Project) Save() error {
-	_, err := p.repo.UpdateProject(*p)
-	return err
+	types, err := p.User.EntryTypeSet()
+	if err != nil {
+		return err
+	}
+	if err := p.Validate(types); err != nil {
+		return err
+	}
+	if len(p.releasedNames) == 0 {
+		_, err = p.repo.UpdateProject(*p)
+		return err
+	}
+	if _, err = p.repo.RenameProject(*p, p.releasedNames); err != nil {
+		return err
+	}
+	p.releasedNames = nil
+	return nil
 }
 
-func (p *Project) GetEntries() ([]*TimeEntry, error) {
-	timeentries, err := p.repo.GetProjectTimeEntries(p)
+// Delete removes the project. If the project still has time entries, ErrProjectHasEntries
+// is returned unless cascade is set, in which case the entries are deleted too. Projects
+// with invoiced entries cannot be deleted; ErrEntryInvoiced is returned.
+func (p *Project) Delete(cascade bool) error {
+	entries, err := p.repo.GetProjectTimeEntries(p, EntryQuery{})
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 && !cascade {
+		return ErrProjectHasEntries
+	}
+	for _, e := range entries {
+		if e.InvoiceNumber != 0 {
+			return ErrEntryInvoiced
+		}
+	}
+	return p.repo.DeleteProject(p)
+}
+
+// HasAlias tells whether the project had the name before it was renamed
+func (p *Project) HasAlias(name string) bool {
+	for _, alias := range p.Aliases {
+		if alias == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Rename changes the name of the project and keeps the old name as an alias; the project
+// still needs to be saved. If another project has the name, ErrProjectNameTaken is
+// returned. When the project is saved, other projects that had either name as an alias
+// lose it, so that each name finds this project only.
+func (p *Project) Rename(name string) error {
+	if name == p.Name {
+		return nil
+	}
+	existing, err := p.User.GetProject(name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return ErrProjectNameTaken
+	}
+	p.releasedNames = append(p.releasedNames, p.Name, name)
+
+	var aliases []string
+	for _, alias := range p.Aliases {
+		if alias != name {
+			aliases = append(aliases, alias)
+		}
+	}
+	p.Aliases = append(aliases, p.Name)
+	p.Name = name
+	return nil
+}
+
+// Archive hides the project from project listings and stops it from taking new entries
+func (p *Project) Archive() error {
+	return p.setArchived(true)
+}
+
+// Unarchive makes an archived project active again
+func (p *Project) Unarchive() error {
+	return p.setArchived(false)
+}
+
+func (p *Project) setArchived(archived bool) error {
+	if p.Archived == archived {
+		return nil
+	}
+	p.Archived = archived
+	if _, err := p.repo.UpdateProject(*p); err != nil {
+		p.Archived = !archived
+		return err
+	}
+	return nil
+}
+
+func (p *Project) GetEntries(q EntryQuery) ([]*TimeEntry, error) {
+	timeentries, err := p.repo.GetProjectTimeEntries(p, q)
 	if err != nil {
 		return nil, err
 	}
@@ -28,15 +151,42 @@ func (p *Project) GetEntries() ([]*TimeEntry, error) {
 	return timeentries, nil
 }
 
+// AddEntry stores a new entry in the project. If the project is archived,
+// ErrProjectArchived is returned.
 func (p *Project) AddEntry(entry *TimeEntry) (*TimeEntry, error) {
+	if p.Archived {
+		return nil, ErrProjectArchived
+	}
 	return p.repo.AddTimeEntry(p, *entry)
 }
 
+// UpdateEntry stores changes to an entry, which may move it to this project from
+// another. If the entry has been invoiced, ErrEntryInvoiced is returned.
 func (p *Project) UpdateEntry(entry *TimeEntry) (*TimeEntry, error) {
+	existing, err := p.repo.GetTimeEntry(p.User, entry.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.InvoiceNumber != 0 {
+		return nil, ErrEntryInvoiced
+	}
 	entry.Project = p
+	entry.InvoiceNumber = 0
 	return p.repo.UpdateTimeEntry(p, *entry)
 }
 
 func (p *Project) GetEntry(entryID string) (*TimeEntry, error) {
 	return p.repo.GetProjectTimeEntry(p, entryID)
 }
+
+// DeleteEntry removes an entry. If the entry has been invoiced, ErrEntryInvoiced is returned.
+func (p *Project) DeleteEntry(entryID string) error {
+	existing, err := p.repo.GetProjectTimeEntry(p, entryID)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.InvoiceNumber != 0 {
+		return ErrEntryInvoiced
+	}
+	return p.repo.DeleteTimeEntry(p, entryID)
+}
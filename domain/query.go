@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+type (
+	// EntryQuery restricts which time entries are returned from the repository.
+	// Entries are always returned ordered by start time and ID. The zero value
+	// matches all entries.
+	EntryQuery struct {
+		// From only matches entries that start at or after this time
+		From *time.Time
+		// To only matches entries that start before this time
+		To *time.Time
+		// Type only matches entries of the given type
+		Type string
+		// TaskID only matches entries of the task with this ID
+		TaskID string
+		// OpenOnly only matches entries that have not been closed yet
+		OpenOnly bool
+		// Tags only matches entries that have all of these tags, or any of them if AnyTag
+		// is set
+		Tags   []string
+		AnyTag bool
+		// After only matches entries that are ordered after this position
+		After *EntryCursor
+		// Limit is the maximum number of entries to return, 0 means no limit
+		Limit int
+	}
+
+	// EntryCursor is a position in the ordering of time entries
+	EntryCursor struct {
+		Start time.Time
+		ID    string
+	}
+
+	// ProjectQuery restricts which projects are returned from the repository.
+	// Projects are always returned ordered by name. The zero value matches all projects.
+	ProjectQuery struct {
+		// After only matches projects with a name that is ordered after this one
+		After string
+		// Limit is the maximum number of projects to return, 0 means no limit
+		Limit int
+		// Archived, if set, only matches projects that are archived or, if false, active
+		Archived *bool
+	}
+)
+
+// CursorFor returns the position of an entry in the entry ordering
+func CursorFor(e *TimeEntry) *EntryCursor {
+	cursor := EntryCursor{ID: e.ID}
+	if e.Start != nil {
+		cursor.Start = *e.Start
+	}
+	return &cursor
+}
+
+// Before returns true if the cursor is ordered before the other cursor
+func (c *EntryCursor) Before(other *EntryCursor) bool {
+	if !c.Start.Equal(other.Start) {
+		return c.Start.Before(other.Start)
+	}
+	return c.ID < other.ID
+}
+
+// SortEntries orders entries by start time and ID
+func SortEntries(entries []*TimeEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return CursorFor(entries[i]).Before(CursorFor(entries[j]))
+	})
+}
+
+// Matches returns true if the entry satisfies the query. The limit is not considered.
+func (q *EntryQuery) Matches(e *TimeEntry) bool {
+	if q.From != nil && (e.Start == nil || e.Start.Before(*q.From)) {
+		return false
+	}
+	if q.To != nil && (e.Start == nil || !e.Start.Before(*q.To)) {
+		return false
+	}
+	if q.Type != "" && e.Type != q.Type {
+		return false
+	}
+	if q.TaskID != "" && e.TaskID != q.TaskID {
+		return false
+	}
+	if q.OpenOnly && e.End != nil {
+		return false
+	}
+	if len(q.Tags) > 0 && !q.matchesTags(e) {
+		return false
+	}
+	if q.After != nil && !q.After.Before(CursorFor(e)) {
+		return false
+	}
+	return true
+}
+
+// Apply filters, orders and limits a list of entries according to the query. It is
+// meant for repositories that cannot do this themselves.
+func (q *EntryQuery) Apply(entries []*TimeEntry) []*TimeEntry {
+	var result []*TimeEntry
+	for _, e := range entries {
+		if q.Matches(e) {
+			result = append(result, e)
+		}
+	}
+	SortEntries(result)
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[:q.Limit]
+	}
+	return result
+}
+
+// Apply filters, orders and limits a list of projects according to the query
+func (q *ProjectQuery) Apply(projects []*Project) []*Project {
+	var result []*Project
+	for _, p := range projects {
+		if p.Name > q.After && (q.Archived == nil || p.Archived == *q.Archived) {
+			result = append(result, p)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[:q.Limit]
+	}
+	return result
+}
+
+func (q *EntryQuery) matchesTags(e *TimeEntry) bool {
+	for _, tag := range q.Tags {
+		if e.HasTag(tag) == q.AnyTag {
+			return q.AnyTag
+		}
+	}
+	return !q.AnyTag
+}
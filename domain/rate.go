@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// RateDateFormat is the format of Rate.From
+const RateDateFormat = "2006-01-02"
+
+type (
+	// Rate is an hourly rate. A rate takes effect on its From date and applies until a later
+	// rate takes over, so that changing a rate does not change what earlier time is billed at.
+	Rate struct {
+		// EntryType limits the rate to entries of one type. It is empty for rates that
+		// apply to entries of all types.
+		EntryType string
+		// Amount is the price of one hour
+		Amount float64
+		// Currency is a three-letter ISO 4217 code, like EUR
+		Currency string
+		// From is the first day the rate applies, as YYYY-MM-DD, or empty if the rate
+		// applies from the beginning
+		From string
+	}
+)
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// validateRates checks that each rate has a currency, a positive amount, a valid date and
+// one of the given types, and that no two rates for the same type take effect on the
+// same day. If any rule is violated, a *ValidationError is returned.
+func validateRates(rates []Rate, types EntryTypeSet) error {
+	var result ValidationError
+	seen := make(map[string]bool)
+	for i, rate := range rates {
+		field := fmt.Sprintf("rates[%d].", i)
+		if rate.Amount < 0 {
+			result.add(field+"amount", RuleNegative, "amount must not be negative")
+		}
+		if !currencyPattern.MatchString(rate.Currency) {
+			result.add(field+"currency", RuleFormat, "currency must be a three-letter code like EUR")
+		}
+		if rate.From != "" {
+			if _, err := time.Parse(RateDateFormat, rate.From); err != nil {
+				result.add(field+"from", RuleFormat, "from must be a date like 2019-01-31")
+			}
+		}
+		if rate.EntryType != "" && types.Get(rate.EntryType) == nil {
+			result.add(field+"entry_type", RuleUnknownType, "unknown entry type %s", rate.EntryType)
+		}
+		key := rate.EntryType + "/" + rate.From
+		if seen[key] {
+			result.add(field+"from", RuleDuplicate, "there is already a rate for this type from this date")
+		}
+		seen[key] = true
+	}
+	return result.result()
+}
+
+// effectiveRate returns the rate among rates for the given type, or for all types if
+// entryType is empty, that took effect last on or before the given day. If there is none,
+// nil is returned.
+func effectiveRate(rates []Rate, entryType string, day string) *Rate {
+	var result *Rate
+	for i := range rates {
+		rate := &rates[i]
+		if rate.EntryType != entryType || rate.From > day {
+			continue
+		}
+		if result == nil || rate.From > result.From {
+			result = rate
+		}
+	}
+	return result
+}
+
+// RateFor returns the rate that an entry of the given type in the project is billed at on
+// the given day, formatted as YYYY-MM-DD. Rates for the entry type win over rates for all
+// types, and project rates win over client rates at the same level. The client may be nil.
+// If no rate applies, nil is returned.
+func RateFor(project *Project, client *Client, entryType string, day string) *Rate {
+	var clientRates []Rate
+	if client != nil {
+		clientRates = client.Rates
+	}
+	for _, t := range []string{entryType, ""} {
+		if rate := effectiveRate(project.Rates, t, day); rate != nil {
+			return rate
+		}
+		if rate := effectiveRate(clientRates, t, day); rate != nil {
+			return rate
+		}
+	}
+	return nil
+}
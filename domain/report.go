@@ -0,0 +1,173 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Ways of grouping entries in a summary report
+const (
+	GroupByProject = "project"
+	GroupByType    = "type"
+	GroupByDay     = "day"
+	GroupByWeek    = "week"
+	GroupByMonth   = "month"
+	GroupByTag     = "tag"
+	GroupByTask    = "task"
+)
+
+// GroupByOptions lists the valid groupings for summary reports
+var GroupByOptions = []string{GroupByProject, GroupByType, GroupByDay, GroupByWeek, GroupByMonth, GroupByTag, GroupByTask}
+
+type (
+	// SummaryGroup holds the aggregated time for one group in a summary report
+	SummaryGroup struct {
+		Key         string
+		Worked      time.Duration
+		Billable    time.Duration
+		NonBillable time.Duration
+		// Hours holds the time per entry type for time-based entries that do not count
+		// as work
+		Hours map[string]time.Duration
+		// Days counts whole days per entry type for entries that are not time-based
+		Days map[string]int
+	}
+
+	// Summary is an aggregate of time entries
+	Summary struct {
+		GroupBy string
+		Groups  []*SummaryGroup
+		Total   *SummaryGroup
+	}
+)
+
+// Duration is the time spent on the entry, minus breaks. An entry that is still open
+// counts until now.
+func (e *TimeEntry) Duration() time.Duration {
+	start := time.Now()
+	if e.Start != nil {
+		start = *e.Start
+	}
+	end := time.Now()
+	if e.End != nil {
+		end = *e.End
+	}
+	duration := end.Sub(start)
+	duration -= e.Breaks
+	return duration
+}
+
+// Days is the number of calendar days covered by an entry that is not time-based
+func (e *TimeEntry) Days() int {
+	return int(math.Round(e.Duration().Round(24*time.Hour).Hours()/24 + 1))
+}
+
+func newSummaryGroup(key string) *SummaryGroup {
+	return &SummaryGroup{Key: key, Hours: make(map[string]time.Duration), Days: make(map[string]int)}
+}
+
+func (g *SummaryGroup) add(e *TimeEntry, types EntryTypeSet) {
+	if !types.IsTimeBased(e.Type) {
+		g.Days[e.Type] += e.Days()
+		return
+	}
+	duration := e.Duration()
+	if !types.IsWork(e.Type) {
+		g.Hours[e.Type] += duration
+		return
+	}
+
+	g.Worked += duration
+	if e.Project != nil && e.Project.Billable {
+		g.Billable += duration
+	} else {
+		g.NonBillable += duration
+	}
+}
+
+// summaryKeys returns the groups the entry belongs to. Entries belong to one group for
+// each of their tags when grouped by tag, and to a single group otherwise.
+func summaryKeys(e *TimeEntry, groupBy string, loc *time.Location, taskNames map[string]string) []string {
+	if groupBy != GroupByTag {
+		return []string{summaryKey(e, groupBy, loc, taskNames)}
+	}
+	if len(e.Tags) == 0 {
+		return []string{""}
+	}
+	return e.Tags
+}
+
+// summaryKey returns the group of an entry. When grouping by task, the key is the project
+// and task name separated by a slash, or just the project name for entries without a task.
+func summaryKey(e *TimeEntry, groupBy string, loc *time.Location, taskNames map[string]string) string {
+	switch groupBy {
+	case GroupByProject, GroupByTask:
+		if e.Project == nil {
+			return ""
+		}
+		if groupBy == GroupByTask && e.TaskID != "" {
+			return e.Project.Name + "/" + taskNames[e.TaskID]
+		}
+		return e.Project.Name
+	case GroupByType:
+		return e.Type
+	}
+
+	if e.Start == nil {
+		return ""
+	}
+	return periodKey(e.Start.In(loc), groupBy)
+}
+
+// periodKey returns the day, ISO week or month of t, depending on groupBy, which is one of
+// GroupByDay, GroupByWeek and GroupByMonth
+func periodKey(t time.Time, groupBy string) string {
+	switch groupBy {
+	case GroupByDay:
+		return t.Format("2006-01-02")
+	case GroupByWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	default:
+		return t.Format("2006-01")
+	}
+}
+
+// Summarize aggregates time entries by the given grouping, counting them according to
+// the given entry types. Dates are determined in the given location. Groups are ordered
+// by key. When grouping by tag, an entry with several tags counts in each of their groups,
+// but only once in the total. Grouping by task uses taskNames, which holds task names by ID.
+func Summarize(entries []*TimeEntry, groupBy string, loc *time.Location, types EntryTypeSet, taskNames map[string]string) (*Summary, error) {
+	valid := false
+	for _, option := range GroupByOptions {
+		valid = valid || option == groupBy
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid grouping: %s", groupBy)
+	}
+
+	groups := make(map[string]*SummaryGroup)
+	summary := Summary{
+		GroupBy: groupBy,
+		Total:   newSummaryGroup(""),
+	}
+	for _, e := range entries {
+		for _, key := range summaryKeys(e, groupBy, loc, taskNames) {
+			group, ok := groups[key]
+			if !ok {
+				group = newSummaryGroup(key)
+				groups[key] = group
+				summary.Groups = append(summary.Groups, group)
+			}
+			group.add(e, types)
+		}
+		summary.Total.add(e, types)
+	}
+
+	sort.Slice(summary.Groups, func(i, j int) bool {
+		return summary.Groups[i].Key < summary.Groups[j].Key
+	})
+	return &summary, nil
+}
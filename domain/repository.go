@@ -1,14 +1,92 @@
 package domain
 
+import "time"
+
 type TimelapseRepository interface {
 	CreateUserFromContext(appCtx *ApplicationContext) (*User, error)
+	SetFeedToken(u *User, token string) error
+	GetUserByFeedToken(token string) (*User, error)
+	SetTimerPolicy(u *User, policy string) error
+	// GetEntryTypes returns the entry types the user has stored, which does not include
+	// default types the user has not changed
+	GetEntryTypes(u *User) ([]*EntryType, error)
+	// SaveEntryType stores an entry type, replacing any with the same name
+	SaveEntryType(u *User, t EntryType) error
+	DeleteEntryType(u *User, name string) error
+	// GetSchedules returns the user's work schedules ordered by the date they take effect
+	GetSchedules(u *User) ([]*Schedule, error)
+	// SaveSchedule stores a work schedule, replacing any that takes effect on the same day
+	SaveSchedule(u *User, s Schedule) error
+	DeleteSchedule(u *User, from string) error
+	AddBalanceAdjustment(u *User, a BalanceAdjustment) (*BalanceAdjustment, error)
+	// GetBalanceAdjustments returns the user's balance adjustments ordered by date
+	GetBalanceAdjustments(u *User) ([]*BalanceAdjustment, error)
+	DeleteBalanceAdjustment(u *User, adjustmentID string) error
+	// GetAllowances returns the user's allowances ordered by entry type and year
+	GetAllowances(u *User) ([]*Allowance, error)
+	// SaveAllowance stores an allowance, replacing any for the same entry type and year
+	SaveAllowance(u *User, a Allowance) error
+	DeleteAllowance(u *User, entryType string, from int) error
+	// GetHolidays returns the user's holidays ordered by date
+	GetHolidays(u *User) ([]*Holiday, error)
+	// SaveHoliday stores a holiday, replacing any on the same day
+	SaveHoliday(u *User, h Holiday) error
+	DeleteHoliday(u *User, date string) error
+	AddClient(c Client) (*Client, error)
+	UpdateClient(c Client) (*Client, error)
+	DeleteClient(c *Client) error
+	GetClient(u *User, clientName string) (*Client, error)
+	// GetClients returns the user's clients ordered by name
+	GetClients(u *User) ([]*Client, error)
 	AddProject(p Project) (*Project, error)
 	UpdateProject(p Project) (*Project, error)
+	// RenameProject atomically updates the project and removes the released names from
+	// the aliases of the user's other projects
+	RenameProject(p Project, released []string) (*Project, error)
+	// DeleteProject removes the project with its tasks and entries. If any of its entries
+	// have been invoiced, ErrEntryInvoiced is returned and nothing is removed.
+	DeleteProject(p *Project) error
 	GetProject(u *User, projectName string) (*Project, error)
-	GetProjects(u *User) ([]*Project, error)
+	// GetProjectByID returns the user's project with the given ID, or nil if there is none
+	GetProjectByID(u *User, projectID string) (*Project, error)
+	GetProjects(u *User, q ProjectQuery) ([]*Project, error)
+	AddTask(t Task) (*Task, error)
+	UpdateTask(t Task) (*Task, error)
+	DeleteTask(t *Task) error
+	GetTask(p *Project, taskName string) (*Task, error)
+	// GetTasks returns the project's tasks ordered by name
+	GetTasks(p *Project) ([]*Task, error)
+	// GetUserTasks returns the tasks of all the user's projects, with their project set
+	GetUserTasks(u *User) ([]*Task, error)
 	AddTimeEntry(p *Project, e TimeEntry) (*TimeEntry, error)
+	AddTimeEntries(u *User, entries []TimeEntry) ([]*TimeEntry, error)
+	// UpdateTimeEntry stores changes to an entry. If the entry has been invoiced, it is left
+	// as is and ErrEntryInvoiced is returned.
 	UpdateTimeEntry(p *Project, e TimeEntry) (*TimeEntry, error)
-	GetProjectTimeEntries(p *Project) ([]*TimeEntry, error)
+	// DeleteTimeEntry removes an entry. If the entry has been invoiced, it is kept and
+	// ErrEntryInvoiced is returned.
+	DeleteTimeEntry(p *Project, entryID string) error
+	GetProjectTimeEntries(p *Project, q EntryQuery) ([]*TimeEntry, error)
 	GetProjectTimeEntry(p *Project, entryID string) (*TimeEntry, error)
-	GetUserTimeEntries(u *User) ([]*TimeEntry, error)
+	GetUserTimeEntries(u *User, q EntryQuery) ([]*TimeEntry, error)
+	// GetTimeEntry returns the user's time entry with the given ID in any project, or nil
+	// if there is none
+	GetTimeEntry(u *User, entryID string) (*TimeEntry, error)
+	// AddInvoice stores an invoice with the next number in the user's sequence, and marks
+	// the entries with that number. If any of the entries has been invoiced already,
+	// ErrEntryInvoiced is returned.
+	AddInvoice(inv Invoice, entryIDs []string) (*Invoice, error)
+	// GetInvoice returns the user's invoice with the given number, or nil if there is none
+	GetInvoice(u *User, number int) (*Invoice, error)
+	// GetInvoices returns the user's invoices ordered by number
+	GetInvoices(u *User) ([]*Invoice, error)
+	// StartTimer atomically adds an open entry, provided that the user has no other open
+	// entries. If stopRunning is set, open entries are closed at the start of the new entry
+	// instead; otherwise ErrTimerRunning is returned. The closed entries are returned.
+	StartTimer(p *Project, e TimeEntry, stopRunning bool) (*TimeEntry, []*TimeEntry, error)
+	// StopTimer atomically closes the open entry that started last, in the given project
+	// if one is given. If breaks is given, it is stored as well. The closed entry is passed
+	// to validate before it is stored; if validate fails, its error is returned and the
+	// entry stays open. If there is no open entry, nil is returned.
+	StopTimer(u *User, p *Project, end time.Time, breaks *time.Duration, validate func(*TimeEntry) error) (*TimeEntry, error)
 }
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	tagPattern     = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+	hashtagPattern = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_-]+)`)
+)
+
+// NormalizeTags lowercases the tags, strips any leading # and returns them sorted without
+// duplicates or empty tags
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// CommentTags returns the #hashtags in a comment, without the #
+func CommentTags(comment string) []string {
+	var result []string
+	for _, match := range hashtagPattern.FindAllStringSubmatch(comment, -1) {
+		result = append(result, match[1])
+	}
+	return result
+}
+
+// EntryTags returns the tags of an entry: the given tags together with the hashtags in
+// the comment, normalized
+func EntryTags(tags []string, comment string) []string {
+	all := append(append([]string{}, tags...), CommentTags(comment)...)
+	return NormalizeTags(all)
+}
+
+// HasTag tells whether the entry has the tag
+func (e *TimeEntry) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
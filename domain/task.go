@@ -0,0 +1,99 @@
+package domain
+
+import "strings"
+
+type (
+	// Task is a workstream within a project. Time entries can refer to one of the tasks
+	// of their project.
+	Task struct {
+		aggregateRoot
+		ID          string
+		Project     *Project
+		Name        string
+		Description string
+	}
+)
+
+// Validate checks that the task has a name. Names cannot contain slashes, which separate
+// project and task names on the command line. If any rule is violated, a *ValidationError
+// is returned.
+func (t *Task) Validate() error {
+	var result ValidationError
+	if t.Name == "" {
+		result.add("name", RuleRequired, "name is required")
+	} else if strings.Contains(t.Name, "/") {
+		result.add("name", RuleFormat, "name must not contain /")
+	}
+	return result.result()
+}
+
+func (t *Task) Save() error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	_, err := t.repo.UpdateTask(*t)
+	return err
+}
+
+// Delete removes the task. If time entries still refer to the task, ErrTaskHasEntries
+// is returned.
+func (t *Task) Delete() error {
+	entries, err := t.repo.GetProjectTimeEntries(t.Project, EntryQuery{TaskID: t.ID, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return ErrTaskHasEntries
+	}
+	return t.repo.DeleteTask(t)
+}
+
+func (p *Project) GetTask(taskName string) (*Task, error) {
+	task, err := p.repo.GetTask(p, taskName)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, nil
+	}
+	p.copyDeps(&task.aggregateRoot)
+	return task, nil
+}
+
+// GetTasks returns the project's tasks ordered by name
+func (p *Project) GetTasks() ([]*Task, error) {
+	tasks, err := p.repo.GetTasks(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		p.copyDeps(&task.aggregateRoot)
+	}
+	return tasks, nil
+}
+
+// AddTask stores a new task. If the task is not valid, a *ValidationError is returned.
+func (p *Project) AddTask(task *Task) (*Task, error) {
+	task.Project = p
+	if err := task.Validate(); err != nil {
+		return nil, err
+	}
+	task, err := p.repo.AddTask(*task)
+	if err != nil {
+		return nil, err
+	}
+	p.copyDeps(&task.aggregateRoot)
+	return task, nil
+}
+
+// GetTasks returns the tasks of all the user's projects
+func (u *User) GetTasks() ([]*Task, error) {
+	tasks, err := u.repo.GetUserTasks(u)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		u.copyDeps(&task.aggregateRoot)
+	}
+	return tasks, nil
+}
@@ -1,16 +1,25 @@
 package domain
 
-import "time"
+import (
+	"time"
+)
 
 type (
 	TimeEntry struct {
 		aggregateRoot
 		ID      string
 		Project *Project
+		// TaskID is the ID of one of the project's tasks, or empty
+		TaskID  string
 		Type    string
 		Start   *time.Time
 		End     *time.Time
 		Breaks  time.Duration
 		Comment string
+		// Tags are lowercase labels for categorizing time across projects, see NormalizeTags
+		Tags []string
+		// InvoiceNumber is the number of the invoice the entry is billed on, or 0 if it
+		// has not been invoiced. Invoiced entries cannot be changed.
+		InvoiceNumber int
 	}
 )
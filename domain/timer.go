@@ -0,0 +1,160 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// What happens when a timer is started while another one is running
+const (
+	// TimerPolicyForbid refuses to start the new timer
+	TimerPolicyForbid = "forbid"
+	// TimerPolicyAutoStop stops the running timer when the new one starts
+	TimerPolicyAutoStop = "auto-stop"
+)
+
+// TimerPolicies lists the valid timer policies
+var TimerPolicies = []string{TimerPolicyForbid, TimerPolicyAutoStop}
+
+// GetTimer returns the running timer, which is the open entry that started last, or nil if
+// no timer is running
+func (u *User) GetTimer() (*TimeEntry, error) {
+	entries, err := u.GetEntries(EntryQuery{OpenOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return entries[len(entries)-1], nil
+}
+
+// SetTimerPolicy changes what happens when a timer is started while another one is running
+func (u *User) SetTimerPolicy(policy string) error {
+	valid := false
+	for _, p := range TimerPolicies {
+		valid = valid || p == policy
+	}
+	if !valid {
+		return fmt.Errorf("unknown timer policy: %s", policy)
+	}
+	if err := u.repo.SetTimerPolicy(u, policy); err != nil {
+		return err
+	}
+	u.TimerPolicy = policy
+	return nil
+}
+
+// StartTimer adds an open entry to the project. If a timer is already running,
+// ErrTimerRunning is returned, unless the user's policy is to stop it, in which case
+// it is closed when the new entry starts. A running timer that started after the new
+// entry cannot be stopped that way, so ErrTimerRunning is returned regardless of the policy.
+// The stopped entries are returned as well. If the entry is not valid, or unless
+// allowOverlap is set, overlaps other work, a *ValidationError is returned. Timers cannot be
+// started for archived projects; ErrProjectArchived is returned.
+func (p *Project) StartTimer(entry *TimeEntry, allowOverlap bool) (*TimeEntry, []*TimeEntry, error) {
+	return p.startTimer(entry, p.User.TimerPolicy == TimerPolicyAutoStop, allowOverlap)
+}
+
+// SwitchTimer is like StartTimer, but stops running timers whatever the user's policy is.
+// Either the running timers are stopped and the new one started, or nothing changes.
+func (p *Project) SwitchTimer(entry *TimeEntry, allowOverlap bool) (*TimeEntry, []*TimeEntry, error) {
+	return p.startTimer(entry, true, allowOverlap)
+}
+
+func (p *Project) startTimer(entry *TimeEntry, stopRunning bool, allowOverlap bool) (*TimeEntry, []*TimeEntry, error) {
+	if p.Archived {
+		return nil, nil, ErrProjectArchived
+	}
+	entry.End = nil
+	if entry.Start == nil {
+		now := time.Now()
+		entry.Start = &now
+	}
+	types, err := p.User.EntryTypeSet()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := entry.Validate(types); err != nil {
+		return nil, nil, err
+	}
+	if !allowOverlap {
+		if err := p.User.checkTimerOverlaps(entry, types); err != nil {
+			return nil, nil, err
+		}
+	}
+	started, stopped, err := p.repo.StartTimer(p, *entry, stopRunning)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.copyDeps(&started.aggregateRoot)
+	for _, e := range stopped {
+		p.copyDeps(&e.aggregateRoot)
+	}
+	return started, stopped, nil
+}
+
+// checkTimerOverlaps checks that a new timer does not overlap closed work entries, like
+// ValidateEntry does. Running timers are left to the timer policy, which either stops
+// them when the new timer starts or refuses to start it. If the timer overlaps other work,
+// a *ValidationError is returned.
+func (u *User) checkTimerOverlaps(entry *TimeEntry, types EntryTypeSet) error {
+	others, err := u.findOverlaps(entry, types)
+	if err != nil {
+		return err
+	}
+	var result ValidationError
+	for _, other := range others {
+		if other.End != nil {
+			result.add("start", RuleOverlap, "overlaps %s entry %s", other.Type, describeEntry(other))
+		}
+	}
+	return result.result()
+}
+
+// StopTimer closes the running timer at the given time. If project is given, only a timer
+// for that project is stopped. If breaks is given, it replaces the breaks of the entry.
+// If the closed entry is not valid, or unless allowOverlap is set, overlaps other work, a
+// *ValidationError is returned and the timer keeps running. If no timer is running, nil
+// is returned.
+func (u *User) StopTimer(project *Project, end time.Time, breaks *time.Duration, allowOverlap bool) (*TimeEntry, error) {
+	types, err := u.EntryTypeSet()
+	if err != nil {
+		return nil, err
+	}
+
+	// The repository must not be called while it stops the timer, so the entries that the
+	// closed timer could overlap are fetched first. A valid timer started at most
+	// MaxWorkDuration before it ends, and closed entries that overlap it started at most
+	// MaxWorkDuration before that. Running timers are left to the timer policy.
+	var others []*TimeEntry
+	if !allowOverlap {
+		from := end.Add(-2 * MaxWorkDuration)
+		others, err = u.GetEntries(EntryQuery{From: &from, To: &end})
+		if err != nil {
+			return nil, err
+		}
+	}
+	validate := func(entry *TimeEntry) error {
+		if err := entry.Validate(types); err != nil {
+			return err
+		}
+		var result ValidationError
+		for _, other := range others {
+			if other.ID != entry.ID && other.End != nil && overlaps(entry, other, types) {
+				result.add("end", RuleOverlap, "overlaps %s entry %s", other.Type, describeEntry(other))
+			}
+		}
+		return result.result()
+	}
+
+	stopped, err := u.repo.StopTimer(u, project, end, breaks, validate)
+	if err != nil {
+		return nil, err
+	}
+	if stopped == nil {
+		return nil, nil
+	}
+	u.copyDeps(&stopped.aggregateRoot)
+	return stopped, nil
+}
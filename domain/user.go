@@ -1,5 +1,10 @@
 package domain
 
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
 type (
 	Identity struct {
 		Issuer    string
@@ -11,6 +16,14 @@ type (
 		aggregateRoot
 		ID         string
 		Identities []Identity
+		// FeedToken gives read-only access to the user's calendar feed. It is empty
+		// if the feed is not enabled.
+		FeedToken string
+		// TimerPolicy is one of TimerPolicies. It is empty if the user has not chosen one,
+		// which means TimerPolicyForbid.
+		TimerPolicy string
+
+		entryTypes EntryTypeSet
 	}
 )
 
@@ -26,9 +39,83 @@ func (u *User) GetProject(projectName string) (*Project, error) {
 	return project, nil
 }
 
+// GetProjectByID returns the user's project with the given ID, or nil if there is none
+func (u *User) GetProjectByID(projectID string) (*Project, error) {
+	project, err := u.repo.GetProjectByID(u, projectID)
+	if err != nil || project == nil {
+		return nil, err
+	}
+	u.copyDeps(&project.aggregateRoot)
+	return project, nil
+}
+
+// FindProject returns the project that a client refers to: the project with that name,
+// the project that had the name before it was renamed, or the project with that ID, in
+// that order. If there is no such project, nil is returned.
+func (u *User) FindProject(ref string) (*Project, error) {
+	project, err := u.GetProject(ref)
+	if err != nil || project != nil {
+		return project, err
+	}
+	projects, err := u.GetProjects(ProjectQuery{})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		if p.HasAlias(ref) {
+			return p, nil
+		}
+	}
+	return u.GetProjectByID(ref)
+}
+
+// releaseAliases removes the names from the aliases of the user's projects, except the
+// given one, which may be nil
+func (u *User) releaseAliases(except *Project, names ...string) error {
+	projects, err := u.GetProjects(ProjectQuery{})
+	if err != nil {
+		return err
+	}
+	for _, p := range projects {
+		if except != nil && p.ID == except.ID {
+			continue
+		}
+		var aliases []string
+		for _, alias := range p.Aliases {
+			released := false
+			for _, name := range names {
+				released = released || alias == name
+			}
+			if !released {
+				aliases = append(aliases, alias)
+			}
+		}
+		if len(aliases) == len(p.Aliases) {
+			continue
+		}
+		p.Aliases = aliases
+		if _, err := u.repo.UpdateProject(*p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddProject stores a new project. If the project is not valid, a *ValidationError is
+// returned. A project that had the name before it was renamed loses it as an alias.
 func (u *User) AddProject(project *Project) (*Project, error) {
 	project.User = u
-	project, err := u.repo.AddProject(*project)
+	types, err := u.EntryTypeSet()
+	if err != nil {
+		return nil, err
+	}
+	if err := project.Validate(types); err != nil {
+		return nil, err
+	}
+	if err := u.releaseAliases(nil, project.Name); err != nil {
+		return nil, err
+	}
+	project, err = u.repo.AddProject(*project)
 	if err != nil {
 		return nil, err
 	}
@@ -36,8 +123,8 @@ func (u *User) AddProject(project *Project) (*Project, error) {
 	return project, nil
 }
 
-func (u *User) GetProjects() ([]*Project, error) {
-	projects, err := u.repo.GetProjects(u)
+func (u *User) GetProjects(q ProjectQuery) ([]*Project, error) {
+	projects, err := u.repo.GetProjects(u, q)
 	if err != nil {
 		return nil, err
 	}
@@ -47,8 +134,40 @@ func (u *User) GetProjects() ([]*Project, error) {
 	return projects, nil
 }
 
-func (u *User) GetEntries() ([]*TimeEntry, error) {
-	timeentries, err := u.repo.GetUserTimeEntries(u)
+// AddEntries stores several time entries at once. Each entry must have its Project set to
+// one of the user's projects. If any of the projects is archived, ErrProjectArchived is
+// returned and no entries are stored.
+func (u *User) AddEntries(entries []*TimeEntry) ([]*TimeEntry, error) {
+	var values []TimeEntry
+	for _, entry := range entries {
+		if entry.Project.Archived {
+			return nil, ErrProjectArchived
+		}
+		values = append(values, *entry)
+	}
+	added, err := u.repo.AddTimeEntries(u, values)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range added {
+		u.copyDeps(&entry.aggregateRoot)
+	}
+	return added, nil
+}
+
+// GetEntry returns one of the user's entries, in any project, or nil if there is no
+// such entry
+func (u *User) GetEntry(entryID string) (*TimeEntry, error) {
+	entry, err := u.repo.GetTimeEntry(u, entryID)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	u.copyDeps(&entry.aggregateRoot)
+	return entry, nil
+}
+
+func (u *User) GetEntries(q EntryQuery) ([]*TimeEntry, error) {
+	timeentries, err := u.repo.GetUserTimeEntries(u, q)
 	if err != nil {
 		return nil, err
 	}
@@ -58,3 +177,27 @@ func (u *User) GetEntries() ([]*TimeEntry, error) {
 	return timeentries, nil
 
 }
+
+// ResetFeedToken enables the calendar feed with a new token. Any previous feed URL
+// stops working.
+func (u *User) ResetFeedToken() error {
+	tokenBytes := make([]byte, 24)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+	if err := u.repo.SetFeedToken(u, token); err != nil {
+		return err
+	}
+	u.FeedToken = token
+	return nil
+}
+
+// RevokeFeedToken disables the calendar feed
+func (u *User) RevokeFeedToken() error {
+	if err := u.repo.SetFeedToken(u, ""); err != nil {
+		return err
+	}
+	u.FeedToken = ""
+	return nil
+}
@@ -18,3 +18,20 @@ func (u *Users) GetOrCreateUserFromContext(ctx *ApplicationContext) (*User, erro
 	u.copyDeps(&user.aggregateRoot)
 	return user, nil
 }
+
+// GetUserByFeedToken returns the user the calendar feed token belongs to, or nil if
+// no user has the token
+func (u *Users) GetUserByFeedToken(token string) (*User, error) {
+	if token == "" {
+		return nil, nil
+	}
+	user, err := u.repo.GetUserByFeedToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+	u.copyDeps(&user.aggregateRoot)
+	return user, nil
+}
@@ -0,0 +1,210 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Validation rules, as reported in FieldError.Rule
+const (
+	RuleRequired       = "required"
+	RuleUnknownType    = "unknown_type"
+	RuleEndBeforeStart = "end_before_start"
+	RuleNegative       = "negative"
+	RuleBreaksTooLong  = "breaks_too_long"
+	RuleTooLong        = "too_long"
+	RuleOverlap        = "overlap"
+	RuleFormat         = "format"
+	RuleDuplicate      = "duplicate"
+	// Rules for creating invoices
+	RuleUnknownProject   = "unknown_project"
+	RuleNotBillable      = "not_billable"
+	RuleNoRate           = "no_rate"
+	RuleNothingToInvoice = "nothing_to_invoice"
+	// Rules for allowances
+	RuleTimeBased = "time_based"
+)
+
+// MaxWorkDuration is the longest an entry that counts as work can last. It also bounds
+// how far back entries are checked for overlaps.
+const MaxWorkDuration = 24 * time.Hour
+
+type (
+	// FieldError is a validation rule that a field violates
+	FieldError struct {
+		Field   string
+		Rule    string
+		Message string
+	}
+
+	// ValidationError lists every validation rule that was violated
+	ValidationError struct {
+		Errors []FieldError
+	}
+)
+
+func (e *ValidationError) Error() string {
+	var messages []string
+	for _, fieldError := range e.Errors {
+		messages = append(messages, fmt.Sprintf("%s: %s", fieldError.Field, fieldError.Message))
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) add(field string, rule string, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+// merge adds the errors of other, with their fields prefixed
+func (e *ValidationError) merge(prefix string, other error) {
+	if other == nil {
+		return
+	}
+	for _, fieldError := range other.(*ValidationError).Errors {
+		fieldError.Field = prefix + fieldError.Field
+		e.Errors = append(e.Errors, fieldError)
+	}
+}
+
+func (e *ValidationError) result() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Validate checks the entry on its own: it must have a start time and one of the given
+// types, must not end before it starts, breaks must fit within the entry, and tags must
+// be words. If any rule is violated, a *ValidationError is returned.
+func (e *TimeEntry) Validate(types EntryTypeSet) error {
+	var result ValidationError
+	if e.Start == nil {
+		result.add("start", RuleRequired, "start is required")
+	}
+	if e.Type == "" {
+		result.add("type", RuleRequired, "type is required")
+	} else if types.Get(e.Type) == nil {
+		result.add("type", RuleUnknownType, "unknown type %s, expected one of %s", e.Type, strings.Join(types.Names(), ", "))
+	}
+	if e.Breaks < 0 {
+		result.add("breaks", RuleNegative, "breaks must not be negative")
+	}
+	for _, tag := range e.Tags {
+		if !tagPattern.MatchString(tag) {
+			result.add("tags", RuleFormat, "tag %q must consist of letters, digits, dashes and underscores", tag)
+		}
+	}
+	if e.Start != nil && e.End != nil {
+		length := e.End.Sub(*e.Start)
+		switch {
+		case length < 0:
+			result.add("end", RuleEndBeforeStart, "end is before start")
+		case types.IsWork(e.Type) && length > MaxWorkDuration:
+			result.add("end", RuleTooLong, "%s entries cannot be longer than %s", e.Type, MaxWorkDuration)
+		case e.Breaks > length:
+			result.add("breaks", RuleBreaksTooLong, "breaks of %s are longer than the entry", e.Breaks)
+		}
+	}
+	return result.result()
+}
+
+// overlaps tells whether two entries that count as work share any time. Entries that are
+// still open last indefinitely.
+func overlaps(a *TimeEntry, b *TimeEntry, types EntryTypeSet) bool {
+	if !types.IsWork(a.Type) || !types.IsWork(b.Type) || a.Start == nil || b.Start == nil {
+		return false
+	}
+	return (b.End == nil || a.Start.Before(*b.End)) && (a.End == nil || b.Start.Before(*a.End))
+}
+
+func describeEntry(e *TimeEntry) string {
+	projectName := ""
+	if e.Project != nil {
+		projectName = e.Project.Name
+	}
+	end := "now"
+	if e.End != nil {
+		end = e.End.UTC().Format(time.RFC3339)
+	}
+	description := fmt.Sprintf("in %s from %s to %s", projectName, e.Start.UTC().Format(time.RFC3339), end)
+	if e.ID == "" {
+		return description
+	}
+	return e.ID + " " + description
+}
+
+// findOverlaps returns the user's entries that count as work and overlap the entry, other
+// than the entry itself
+func (u *User) findOverlaps(entry *TimeEntry, types EntryTypeSet) ([]*TimeEntry, error) {
+	if !types.IsWork(entry.Type) || entry.Start == nil {
+		return nil, nil
+	}
+
+	// Closed entries that overlap start at most MaxWorkDuration earlier. Open entries can
+	// have started any time.
+	from := entry.Start.Add(-MaxWorkDuration)
+	candidates, err := u.GetEntries(EntryQuery{From: &from, To: entry.End})
+	if err != nil {
+		return nil, err
+	}
+	open, err := u.GetEntries(EntryQuery{To: &from, OpenOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*TimeEntry
+	for _, other := range append(open, candidates...) {
+		if other.ID != entry.ID && overlaps(entry, other, types) {
+			result = append(result, other)
+		}
+	}
+	return result, nil
+}
+
+// ValidateEntry checks the entry with Validate and, unless allowOverlap is set, checks
+// that an entry that counts as work does not overlap the user's other such entries. If any
+// rule is violated, a *ValidationError is returned.
+func (u *User) ValidateEntry(entry *TimeEntry, allowOverlap bool) error {
+	return u.ValidateEntries([]*TimeEntry{entry}, allowOverlap)
+}
+
+// ValidateEntries checks several entries that are to be stored together, like
+// ValidateEntry. They are also checked for overlaps with each other. The fields of the
+// errors are prefixed with the index of the entry if there is more than one.
+func (u *User) ValidateEntries(entries []*TimeEntry, allowOverlap bool) error {
+	types, err := u.EntryTypeSet()
+	if err != nil {
+		return err
+	}
+
+	var result ValidationError
+	for idx, entry := range entries {
+		prefix := ""
+		if len(entries) > 1 {
+			prefix = fmt.Sprintf("%d.", idx)
+		}
+
+		if err := entry.Validate(types); err != nil {
+			result.merge(prefix, err)
+			continue
+		}
+		if allowOverlap {
+			continue
+		}
+
+		others, err := u.findOverlaps(entry, types)
+		if err != nil {
+			return err
+		}
+		for _, other := range entries[:idx] {
+			if overlaps(entry, other, types) {
+				others = append(others, other)
+			}
+		}
+		for _, other := range others {
+			result.add(prefix+"start", RuleOverlap, "overlaps %s entry %s", other.Type, describeEntry(other))
+		}
+	}
+	return result.result()
+}
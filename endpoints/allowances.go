@@ -0,0 +1,218 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/kennep/timelapse/domain"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkAllowances adds a warning to the response for each allowance that the new entries
+// use up more than the remaining days of. Dates are taken in loc. The entries are already
+// stored, so problems are logged rather than failing the request.
+func (s *apiServer) checkAllowances(rw http.ResponseWriter, r *http.Request, loc *time.Location, user *domain.User, entries ...*domain.TimeEntry) {
+	warned := make(map[string]bool)
+	for _, entry := range entries {
+		status, err := user.CheckAllowance(entry, loc)
+		if err != nil {
+			fields := RequestFields(r)
+			fields["error"] = err
+			log.WithFields(fields).Errorf("Could not check the %s allowance", entry.Type)
+			return
+		}
+		if status == nil {
+			continue
+		}
+		key := fmt.Sprintf("%s/%d", status.Type, status.Year)
+		if warned[key] {
+			continue
+		}
+		warned[key] = true
+		addWarning(rw, fmt.Sprintf("The %s allowance for %d is exceeded by %d days", status.Type, status.Year, -status.Remaining))
+	}
+}
+
+func (s *apiServer) listAllowances(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	allowances, err := user.GetAllowances()
+	if err != nil {
+		internalError(rw, r, err, "Could not get allowances")
+		return
+	}
+
+	jsonResponse(rw, r, 200, mapAllowancesToApi(allowances))
+}
+
+// getAllowanceYear returns the year in the URL. If it is not a number, an error response
+// is emitted and false is returned.
+func getAllowanceYear(rw http.ResponseWriter, r *http.Request) (int, bool) {
+	value := chi.URLParam(r, "year")
+	year, err := strconv.Atoi(value)
+	if err != nil {
+		validationError(rw, r, fmt.Sprintf("invalid year: %s", value))
+		return 0, false
+	}
+	return year, true
+}
+
+func (s *apiServer) saveAllowance(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	year, ok := getAllowanceYear(rw, r)
+	if !ok {
+		return
+	}
+	var apiAllowance api.Allowance
+	err := jsonRequest(rw, r, &apiAllowance)
+	if err != nil {
+		return
+	}
+	allowance := mapApiToAllowance(&apiAllowance)
+	allowance.Type = chi.URLParam(r, "typeName")
+	allowance.From = year
+
+	if err := user.SaveAllowance(allowance); err != nil {
+		entryError(rw, r, err, "Error while saving allowance")
+		return
+	}
+
+	jsonResponse(rw, r, 200, mapAllowanceToApi(allowance))
+}
+
+func (s *apiServer) deleteAllowance(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	typeName := chi.URLParam(r, "typeName")
+	year, ok := getAllowanceYear(rw, r)
+	if !ok {
+		return
+	}
+	allowances, err := user.GetAllowances()
+	if err != nil {
+		internalError(rw, r, err, "Could not get allowances")
+		return
+	}
+	found := false
+	for _, allowance := range allowances {
+		found = found || (allowance.Type == typeName && allowance.From == year)
+	}
+	if !found {
+		notFoundError(rw, r, fmt.Sprintf("Allowance not found: %s from %d", typeName, year))
+		return
+	}
+
+	if err := user.DeleteAllowance(typeName, year); err != nil {
+		internalError(rw, r, err, "Error while deleting allowance")
+		return
+	}
+	rw.WriteHeader(204)
+}
+
+// getRemainingAllowances returns the status of each allowance in the year in the year query
+// parameter, or the current year, with days taken in the time zone of the tz parameter
+func (s *apiServer) getRemainingAllowances(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	loc := getLocationFromURL(rw, r, domain.EntryQuery{})
+	if loc == nil {
+		return
+	}
+	year := time.Now().In(loc).Year()
+	if value := r.URL.Query().Get("year"); value != "" {
+		var err error
+		if year, err = strconv.Atoi(value); err != nil {
+			validationError(rw, r, fmt.Sprintf("invalid year parameter: %s", value))
+			return
+		}
+	}
+
+	statuses, err := user.AllowanceStatuses(year, loc)
+	if err != nil {
+		internalError(rw, r, err, "Could not compute the remaining allowances")
+		return
+	}
+	jsonResponse(rw, r, 200, mapAllowanceStatusesToApi(statuses))
+}
+
+func (s *apiServer) listHolidays(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	holidays, err := user.GetHolidays()
+	if err != nil {
+		internalError(rw, r, err, "Could not get holidays")
+		return
+	}
+
+	jsonResponse(rw, r, 200, mapHolidaysToApi(holidays))
+}
+
+func (s *apiServer) saveHoliday(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	var apiHoliday api.Holiday
+	err := jsonRequest(rw, r, &apiHoliday)
+	if err != nil {
+		return
+	}
+	holiday := &domain.Holiday{Date: chi.URLParam(r, "date"), Name: apiHoliday.Name}
+
+	if err := user.SaveHoliday(holiday); err != nil {
+		entryError(rw, r, err, "Error while saving holiday")
+		return
+	}
+
+	jsonResponse(rw, r, 200, mapHolidayToApi(holiday))
+}
+
+func (s *apiServer) deleteHoliday(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	date := chi.URLParam(r, "date")
+	holidays, err := user.GetHolidays()
+	if err != nil {
+		internalError(rw, r, err, "Could not get holidays")
+		return
+	}
+	found := false
+	for _, holiday := range holidays {
+		found = found || holiday.Date == date
+	}
+	if !found {
+		notFoundError(rw, r, fmt.Sprintf("Holiday not found: %s", date))
+		return
+	}
+
+	if err := user.DeleteHoliday(date); err != nil {
+		internalError(rw, r, err, "Error while deleting holiday")
+		return
+	}
+	rw.WriteHeader(204)
+}
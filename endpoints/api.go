@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,18 +26,18 @@ type errorResponse struct {
 	Message string `json:"message"`
 }
 
-func configureHandlerChain(users *domain.Users, r chi.Router) {
+func configureHandlerChain(r chi.Router) {
 	r.Use(ApplicationContext())
 	r.Use(chimiddleware.RealIP)
 	r.Use(Logging())
-	r.Use(Authentication(users))
 	r.Use(chimiddleware.Recoverer)
 
 	r.Use(chimiddleware.Timeout(60 * time.Second))
 }
 
 type apiServer struct {
-	users *domain.Users
+	users    *domain.Users
+	notifier budgetNotifier
 }
 
 func Serve(users *domain.Users) error {
@@ -45,23 +46,18 @@ func Serve(users *domain.Users) error {
 		listenAddr = ":8080"
 	}
 
-	server := apiServer{users}
+	server := apiServer{
+		users:    users,
+		notifier: newBudgetNotifier(os.Getenv("TIMELAPSE_BUDGET_WEBHOOK_URL")),
+	}
 
 	r := chi.NewRouter()
-	configureHandlerChain(users, r)
-
-	r.Get("/self", server.getCurrentUser)
-
-	r.Post("/projects", server.addProject)
-	r.Get("/projects", server.listProjects)
-	r.Get("/projects/{projectName}", server.getProject)
-	r.Put("/projects/{projectName}", server.updateProject)
-
-	r.Get("/entries", server.getUserTimeEntries)
-	r.Get("/projects/{projectName}/entries", server.getProjectTimeEntries)
-	r.Post("/projects/{projectName}/entries", server.addProjectTimeEntry)
-	r.Get("/projects/{projectName}/entries/{entryID}", server.getProjectTimeEntry)
-	r.Put("/projects/{projectName}/entries/{entryID}", server.updateProjectTimeEntry)
+	configureHandlerChain(r)
+	server.publicRoutes(r)
+	r.Group(func(r chi.Router) {
+		r.Use(Authentication(users))
+		server.routes(r)
+	})
 
 	log.WithFields(log.Fields{"address": listenAddr}).Info("Timelapse server listening")
 	if err := http.ListenAndServe(listenAddr, r); err != nil {
@@ -71,6 +67,80 @@ func Serve(users *domain.Users) error {
 	return nil
 }
 
+// publicRoutes are served without authentication
+func (s *apiServer) publicRoutes(r chi.Router) {
+	r.Get("/feeds/{feedFile}", s.getCalendarFeed)
+}
+
+func (s *apiServer) routes(r chi.Router) {
+	r.Get("/self", s.getCurrentUser)
+	r.Get("/self/feed", s.getFeed)
+	r.Put("/self/feed", s.resetFeed)
+	r.Delete("/self/feed", s.revokeFeed)
+	r.Get("/self/settings", s.getSettings)
+	r.Put("/self/settings", s.updateSettings)
+
+	r.Get("/entry-types", s.listEntryTypes)
+	r.Put("/entry-types/{typeName}", s.saveEntryType)
+	r.Delete("/entry-types/{typeName}", s.deleteEntryType)
+
+	r.Get("/timer", s.getTimer)
+	r.Post("/timer", s.startTimer)
+	r.Post("/timer/stop", s.stopTimer)
+
+	r.Get("/schedules", s.listSchedules)
+	r.Put("/schedules/{from}", s.saveSchedule)
+	r.Delete("/schedules/{from}", s.deleteSchedule)
+	r.Get("/balance", s.getBalance)
+	r.Get("/balance/adjustments", s.listBalanceAdjustments)
+	r.Post("/balance/adjustments", s.addBalanceAdjustment)
+	r.Delete("/balance/adjustments/{adjustmentID}", s.deleteBalanceAdjustment)
+	r.Get("/allowances", s.listAllowances)
+	r.Get("/allowances/remaining", s.getRemainingAllowances)
+	r.Put("/allowances/{typeName}/{year}", s.saveAllowance)
+	r.Delete("/allowances/{typeName}/{year}", s.deleteAllowance)
+	r.Get("/holidays", s.listHolidays)
+	r.Put("/holidays/{date}", s.saveHoliday)
+	r.Delete("/holidays/{date}", s.deleteHoliday)
+
+	r.Post("/clients", s.addClient)
+	r.Get("/clients", s.listClients)
+	r.Get("/clients/{clientName}", s.getClient)
+	r.Put("/clients/{clientName}", s.updateClient)
+	r.Delete("/clients/{clientName}", s.deleteClient)
+
+	r.Post("/projects", s.addProject)
+	r.Get("/projects", s.listProjects)
+	r.Get("/projects/{projectName}", s.getProject)
+	r.Put("/projects/{projectName}", s.updateProject)
+	r.Delete("/projects/{projectName}", s.deleteProject)
+	r.Post("/projects/{projectName}/archive", s.archiveProject)
+	r.Post("/projects/{projectName}/unarchive", s.unarchiveProject)
+	r.Get("/projects/{projectName}/budget", s.getBudgetStatus)
+	r.Post("/projects/{projectName}/tasks", s.addTask)
+	r.Get("/projects/{projectName}/tasks", s.listTasks)
+	r.Get("/projects/{projectName}/tasks/{taskName}", s.getTask)
+	r.Put("/projects/{projectName}/tasks/{taskName}", s.updateTask)
+	r.Delete("/projects/{projectName}/tasks/{taskName}", s.deleteTask)
+
+	r.Get("/entries", s.getUserTimeEntries)
+	r.Get("/entries/export", s.exportTimeEntries)
+	r.Get("/entries/{entryID}", s.getUserTimeEntry)
+	r.Post("/entries:batch", s.addTimeEntryBatch)
+	r.Get("/projects/{projectName}/entries", s.getProjectTimeEntries)
+	r.Post("/projects/{projectName}/entries", s.addProjectTimeEntry)
+	r.Get("/projects/{projectName}/entries/{entryID}", s.getProjectTimeEntry)
+	r.Put("/projects/{projectName}/entries/{entryID}", s.updateProjectTimeEntry)
+	r.Delete("/projects/{projectName}/entries/{entryID}", s.deleteProjectTimeEntry)
+
+	r.Get("/reports/summary", s.getSummaryReport)
+	r.Get("/reports/billing", s.getBillingReport)
+
+	r.Post("/invoices", s.createInvoice)
+	r.Get("/invoices", s.listInvoices)
+	r.Get("/invoices/{invoiceNumber}", s.getInvoice)
+}
+
 func emitErrorResponse(rw http.ResponseWriter, statusCode int, errorMessage string) {
 	errResponse := errorResponse{errorMessage}
 	body, err := json.Marshal(errResponse)
@@ -114,6 +184,25 @@ func notFoundError(rw http.ResponseWriter, r *http.Request, message string) {
 	emitErrorResponse(rw, 404, message)
 }
 
+func conflictError(rw http.ResponseWriter, r *http.Request, message string) {
+	fields := RequestFields(r)
+	log.WithFields(fields).Warnf("Conflict: %s", message)
+	emitErrorResponse(rw, 409, message)
+}
+
+// entryError emits a 422 response that lists the violated rules if err is a
+// *domain.ValidationError, and an internal error response otherwise
+func entryError(rw http.ResponseWriter, r *http.Request, err error, message string) {
+	validationErr, ok := err.(*domain.ValidationError)
+	if !ok {
+		internalError(rw, r, err, message)
+		return
+	}
+	fields := RequestFields(r)
+	log.WithFields(fields).Warnf("Validation failed: %v", validationErr)
+	jsonResponse(rw, r, 422, mapValidationErrorToApi(validationErr))
+}
+
 func jsonResponse(rw http.ResponseWriter, r *http.Request, statusCode int, doc interface{}) {
 	body, err := json.Marshal(doc)
 	if err != nil {
@@ -188,6 +277,10 @@ func (s *apiServer) addProject(rw http.ResponseWriter, r *http.Request) {
 		validationError(rw, r, "required attribute: name")
 		return
 	}
+	var ok bool
+	if project.ClientID, ok = getClientID(rw, r, user, apiProject.Client); !ok {
+		return
+	}
 
 	projectResult, err := user.GetProject(project.Name)
 	if err != nil {
@@ -201,60 +294,217 @@ func (s *apiServer) addProject(rw http.ResponseWriter, r *http.Request) {
 
 	projectResult, err = user.AddProject(project)
 	if err != nil {
-		internalError(rw, r, err, fmt.Sprintf("Could not create project %s", project.Name))
+		entryError(rw, r, err, fmt.Sprintf("Could not create project %s", project.Name))
 		return
 	}
-	jsonResponse(rw, r, 201, mapProjectToApi(projectResult))
+	s.projectResponse(rw, r, 201, user, projectResult)
 }
 
-func (s *apiServer) getProject(rw http.ResponseWriter, r *http.Request) {
-	user := s.getUser(rw, r)
-	if user == nil {
-		return
+// findProject looks up a project by name, former name or ID, see FindProject. If a former
+// name is used, the response carries a warning with the current name, so that clients can
+// update their references.
+func findProject(rw http.ResponseWriter, user *domain.User, ref string) (*domain.Project, error) {
+	project, err := user.FindProject(ref)
+	if err != nil || project == nil {
+		return nil, err
+	}
+	if project.HasAlias(ref) {
+		addWarning(rw, fmt.Sprintf("Project %s has been renamed to %s", ref, project.Name))
 	}
+	return project, nil
+}
+
+// addWarning adds a Warning header with the text to the response, for clients to show
+// to the user. It must be called before the response is written.
+func addWarning(rw http.ResponseWriter, text string) {
+	rw.Header().Add("Warning", fmt.Sprintf("299 - %q", text))
+}
 
+// getProjectFromURL looks up the project named in the URL, which may also be a former
+// name or the ID of the project. If the project cannot be
+// found, an error response is emitted and nil is returned.
+func (s *apiServer) getProjectFromURL(rw http.ResponseWriter, r *http.Request, user *domain.User) *domain.Project {
 	projectName := chi.URLParam(r, "projectName")
 	if projectName == "" {
 		validationError(rw, r, "project name in URL cannot be blank")
-		return
+		return nil
 	}
 	projectName, err := url.QueryUnescape(projectName)
 	if err != nil {
 		validationError(rw, r, err.Error())
-		return
+		return nil
 	}
 
-	projectResult, err := user.GetProject(projectName)
+	project, err := findProject(rw, user, projectName)
 	if err != nil {
 		internalError(rw, r, err, fmt.Sprintf("Could not get project by name %s", projectName))
-		return
+		return nil
 	}
-	if projectResult == nil {
+	if project == nil {
 		notFoundError(rw, r, fmt.Sprintf("Project not found: %s", projectName))
+		return nil
+	}
+	return project
+}
+
+// projectResponse emits a project, along with the name of its client
+func (s *apiServer) projectResponse(rw http.ResponseWriter, r *http.Request, statusCode int, user *domain.User, project *domain.Project) {
+	clientNames, ok := getClientNames(rw, r, user)
+	if !ok {
 		return
 	}
+	jsonResponse(rw, r, statusCode, mapProjectToApi(project, clientNames))
+}
+
+// getEntryIDFromURL returns the entry ID in the URL, or emits an error response and
+// returns an empty string if it is invalid.
+func getEntryIDFromURL(rw http.ResponseWriter, r *http.Request) string {
+	entryID := chi.URLParam(r, "entryID")
+	if entryID == "" {
+		validationError(rw, r, "entry ID in URL cannot be blank")
+		return ""
+	}
+	entryID, err := url.PathUnescape(entryID)
+	if err != nil {
+		validationError(rw, r, err.Error())
+		return ""
+	}
+	return entryID
+}
 
-	jsonResponse(rw, r, 200, mapProjectToApi(projectResult))
+// getAllowOverlapFromURL tells whether the allow_overlap query parameter is set, which
+// lets time entries overlap other work entries. If the parameter is invalid, an error
+// response is emitted and false is returned as the second value.
+func getAllowOverlapFromURL(rw http.ResponseWriter, r *http.Request) (bool, bool) {
+	param := r.URL.Query().Get("allow_overlap")
+	if param == "" {
+		return false, true
+	}
+	allowOverlap, err := strconv.ParseBool(param)
+	if err != nil {
+		validationError(rw, r, fmt.Sprintf("invalid allow_overlap: %s", param))
+		return false, false
+	}
+	return allowOverlap, true
 }
 
-func (s *apiServer) updateProject(rw http.ResponseWriter, r *http.Request) {
+// getEntryQueryFromURL builds an entry query from the from, to, type, tag, tag_match, open,
+// limit and cursor query parameters. The task parameter needs a project, see
+// getTaskFilterFromURL. If a parameter is invalid, an error response is
+// emitted and false is returned.
+func getEntryQueryFromURL(rw http.ResponseWriter, r *http.Request) (domain.EntryQuery, bool) {
+	var q domain.EntryQuery
+	params := r.URL.Query()
+
+	for _, param := range []struct {
+		name   string
+		target **time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		value := params.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			validationError(rw, r, fmt.Sprintf("invalid time in %s parameter: %s", param.name, value))
+			return q, false
+		}
+		*param.target = &t
+	}
+
+	q.Type = params.Get("type")
+
+	// Tags can be given as repeated tag parameters, or comma separated
+	var tags []string
+	for _, value := range params["tag"] {
+		tags = append(tags, strings.Split(value, ",")...)
+	}
+	q.Tags = domain.NormalizeTags(tags)
+	switch params.Get("tag_match") {
+	case "", "all":
+	case "any":
+		q.AnyTag = true
+	default:
+		validationError(rw, r, "tag_match parameter must be all or any")
+		return q, false
+	}
+
+	switch params.Get("open") {
+	case "", "false":
+	case "true":
+		q.OpenOnly = true
+	default:
+		validationError(rw, r, "open parameter must be true or false")
+		return q, false
+	}
+
+	var ok bool
+	q.Limit, ok = getLimitFromURL(rw, r)
+	if !ok {
+		return q, false
+	}
+
+	if cursor := params.Get("cursor"); cursor != "" {
+		after, err := decodeEntryCursor(cursor)
+		if err != nil {
+			validationError(rw, r, "invalid cursor")
+			return q, false
+		}
+		q.After = after
+	}
+
+	return q, true
+}
+
+// timeEntriesResponse emits a page of time entries, with a link to the next page if the page is full
+func timeEntriesResponse(rw http.ResponseWriter, r *http.Request, user *domain.User, q domain.EntryQuery, entries []*domain.TimeEntry) {
+	taskNames, ok := getTaskNames(rw, r, user)
+	if !ok {
+		return
+	}
+	if q.Limit > 0 && len(entries) == q.Limit {
+		setNextLink(rw, r, encodeEntryCursor(domain.CursorFor(entries[len(entries)-1])))
+	}
+	jsonResponse(rw, r, 200, mapTimeEntriesToApi(entries, taskNames))
+}
+
+// timeEntryResponse emits a time entry, along with the name of its task
+func timeEntryResponse(rw http.ResponseWriter, r *http.Request, statusCode int, user *domain.User, entry *domain.TimeEntry) {
+	taskNames, ok := getTaskNames(rw, r, user)
+	if !ok {
+		return
+	}
+	jsonResponse(rw, r, statusCode, mapTimeEntryToApi(entry, taskNames))
+}
+
+func (s *apiServer) getProject(rw http.ResponseWriter, r *http.Request) {
 	user := s.getUser(rw, r)
 	if user == nil {
 		return
 	}
 
-	projectName := chi.URLParam(r, "projectName")
-	if projectName == "" {
-		validationError(rw, r, "project name in URL cannot be blank")
+	projectResult := s.getProjectFromURL(rw, r, user)
+	if projectResult == nil {
 		return
 	}
-	projectName, err := url.QueryUnescape(projectName)
-	if err != nil {
-		validationError(rw, r, err.Error())
+
+	s.projectResponse(rw, r, 200, user, projectResult)
+}
+
+func (s *apiServer) updateProject(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	projectResult := s.getProjectFromURL(rw, r, user)
+	if projectResult == nil {
 		return
 	}
+	projectName := projectResult.Name
+
 	var apiProject api.Project
-	err = jsonRequest(rw, r, &apiProject)
+	err := jsonRequest(rw, r, &apiProject)
 	if err != nil {
 		return
 	}
@@ -263,74 +513,146 @@ func (s *apiServer) updateProject(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	projectResult, err := user.GetProject(projectName)
-	if err != nil {
-		internalError(rw, r, err, fmt.Sprintf("Could not get project by name %s", projectName))
+	clientID, ok := getClientID(rw, r, user, apiProject.Client)
+	if !ok {
 		return
 	}
-	if projectResult == nil {
-		notFoundError(rw, r, fmt.Sprintf("Project not found: %s", projectName))
+
+	err = projectResult.Rename(apiProject.Name)
+	if err == domain.ErrProjectNameTaken {
+		validationError(rw, r, fmt.Sprintf("A project with this name already exists: %s", apiProject.Name))
+		return
+	} else if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not rename project %s", projectName))
 		return
 	}
-
 	mapApiToProjectDest(&apiProject, projectResult)
+	projectResult.ClientID = clientID
 
 	err = projectResult.Save()
 	if err != nil {
-		internalError(rw, r, err, fmt.Sprintf("Could not update project %s", projectName))
+		entryError(rw, r, err, fmt.Sprintf("Could not update project %s", projectName))
 		return
 	}
-	jsonResponse(rw, r, 200, mapProjectToApi(projectResult))
+	s.projectResponse(rw, r, 200, user, projectResult)
 }
 
-func (s *apiServer) listProjects(rw http.ResponseWriter, r *http.Request) {
+func (s *apiServer) deleteProject(rw http.ResponseWriter, r *http.Request) {
 	user := s.getUser(rw, r)
 	if user == nil {
 		return
 	}
 
-	projects, err := user.GetProjects()
+	project := s.getProjectFromURL(rw, r, user)
+	if project == nil {
+		return
+	}
+
+	cascade := r.URL.Query().Get("cascade") == "true"
+
+	err := project.Delete(cascade)
+	if err == domain.ErrProjectHasEntries {
+		conflictError(rw, r, fmt.Sprintf("Project %s has time entries - delete them first or use cascade=true", project.Name))
+		return
+	}
+	if err == domain.ErrEntryInvoiced {
+		conflictError(rw, r, fmt.Sprintf("Project %s has invoiced time entries and cannot be deleted", project.Name))
+		return
+	}
 	if err != nil {
-		internalError(rw, r, err, "Could not list projects")
+		internalError(rw, r, err, fmt.Sprintf("Could not delete project %s", project.Name))
 		return
 	}
 
-	jsonResponse(rw, r, 200, mapProjectsToApi(projects))
+	rw.WriteHeader(204)
 }
 
-func (s *apiServer) getProjectTimeEntries(rw http.ResponseWriter, r *http.Request) {
+func (s *apiServer) archiveProject(rw http.ResponseWriter, r *http.Request) {
+	s.setProjectArchived(rw, r, true)
+}
+
+func (s *apiServer) unarchiveProject(rw http.ResponseWriter, r *http.Request) {
+	s.setProjectArchived(rw, r, false)
+}
+
+func (s *apiServer) setProjectArchived(rw http.ResponseWriter, r *http.Request, archived bool) {
 	user := s.getUser(rw, r)
 	if user == nil {
 		return
 	}
 
-	projectName := chi.URLParam(r, "projectName")
-	if projectName == "" {
-		validationError(rw, r, "project name in URL cannot be blank")
+	project := s.getProjectFromURL(rw, r, user)
+	if project == nil {
 		return
 	}
-	projectName, err := url.QueryUnescape(projectName)
+
+	var err error
+	if archived {
+		err = project.Archive()
+	} else {
+		err = project.Unarchive()
+	}
 	if err != nil {
-		validationError(rw, r, err.Error())
+		internalError(rw, r, err, fmt.Sprintf("Could not update project %s", project.Name))
 		return
 	}
+	s.projectResponse(rw, r, 200, user, project)
+}
 
-	project, err := user.GetProject(projectName)
+func (s *apiServer) listProjects(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	q, ok := getProjectQueryFromURL(rw, r)
+	if !ok {
+		return
+	}
+
+	projects, err := user.GetProjects(q)
 	if err != nil {
-		internalError(rw, r, err, fmt.Sprintf("Could not get project by name %s", projectName))
+		internalError(rw, r, err, "Could not list projects")
 		return
 	}
+
+	if q.Limit > 0 && len(projects) == q.Limit {
+		setNextLink(rw, r, encodeProjectCursor(projects[len(projects)-1].Name))
+	}
+	clientNames, ok := getClientNames(rw, r, user)
+	if !ok {
+		return
+	}
+	jsonResponse(rw, r, 200, mapProjectsToApi(projects, clientNames))
+}
+
+func (s *apiServer) getProjectTimeEntries(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	project := s.getProjectFromURL(rw, r, user)
 	if project == nil {
-		notFoundError(rw, r, fmt.Sprintf("Project not found: %s", projectName))
 		return
 	}
 
-	entries, err := project.GetEntries()
+	q, ok := getEntryQueryFromURL(rw, r)
+	if !ok {
+		return
+	}
+	if !getTaskFilterFromURL(rw, r, project, &q) {
+		return
+	}
+	q.Limit = pageLimit(q.Limit)
+
+	entries, err := project.GetEntries(q)
 	if err != nil {
-		internalError(rw, r, err, fmt.Sprintf("Could not get time entries for project %s", projectName))
+		internalError(rw, r, err, fmt.Sprintf("Could not get time entries for project %s", project.Name))
+		return
 	}
 
-	jsonResponse(rw, r, 200, mapTimeEntriesToApi(entries))
+	timeEntriesResponse(rw, r, user, q, entries)
 }
 
 func (s *apiServer) getUserTimeEntries(rw http.ResponseWriter, r *http.Request) {
@@ -339,12 +661,19 @@ func (s *apiServer) getUserTimeEntries(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	entries, err := user.GetEntries()
+	q, ok := getEntryQueryFromURL(rw, r)
+	if !ok {
+		return
+	}
+	q.Limit = pageLimit(q.Limit)
+
+	entries, err := user.GetEntries(q)
 	if err != nil {
 		internalError(rw, r, err, fmt.Sprintf("Could not get time entries"))
+		return
 	}
 
-	jsonResponse(rw, r, 200, mapTimeEntriesToApi(entries))
+	timeEntriesResponse(rw, r, user, q, entries)
 }
 
 func (s *apiServer) addProjectTimeEntry(rw http.ResponseWriter, r *http.Request) {
@@ -360,78 +689,142 @@ func (s *apiServer) addProjectTimeEntry(rw http.ResponseWriter, r *http.Request)
 	}
 	timeEntry := mapApiToTimeEntry(&apiTimeEntry)
 
-	projectName := chi.URLParam(r, "projectName")
-	if projectName == "" {
-		validationError(rw, r, "project name in URL cannot be blank")
+	project := s.getProjectFromURL(rw, r, user)
+	if project == nil {
 		return
 	}
-	projectName, err = url.QueryUnescape(projectName)
-	if err != nil {
-		validationError(rw, r, err.Error())
+
+	allowOverlap, ok := getAllowOverlapFromURL(rw, r)
+	if !ok {
 		return
 	}
-
-	project, err := user.GetProject(projectName)
-	if err != nil {
-		internalError(rw, r, err, fmt.Sprintf("Could not get project by name %s", projectName))
+	loc := getLocationFromURL(rw, r, domain.EntryQuery{})
+	if loc == nil {
 		return
 	}
-	if project == nil {
-		notFoundError(rw, r, fmt.Sprintf("Project not found: %s", projectName))
+	timeEntry.Project = project
+	if timeEntry.TaskID, ok = getTaskID(rw, r, project, apiTimeEntry.Task); !ok {
+		return
+	}
+	if err := user.ValidateEntry(timeEntry, allowOverlap); err != nil {
+		entryError(rw, r, err, "Error while validating time entry")
 		return
 	}
 
 	newEntry, err := project.AddEntry(timeEntry)
-	if err != nil {
+	if err == domain.ErrProjectArchived {
+		conflictError(rw, r, fmt.Sprintf("Project %s is archived - unarchive it to add entries", project.Name))
+		return
+	} else if err != nil {
 		internalError(rw, r, err, fmt.Sprintf("Error while adding time entry"))
 		return
 	}
+	s.checkBudgets(r, loc, user, newEntry)
+	s.checkAllowances(rw, r, loc, user, newEntry)
 
-	jsonResponse(rw, r, 200, mapTimeEntryToApi(newEntry))
+	timeEntryResponse(rw, r, 200, user, newEntry)
 }
 
-func (s *apiServer) updateProjectTimeEntry(rw http.ResponseWriter, r *http.Request) {
+// maxBatchSize is the largest number of time entries that can be added in one request
+const maxBatchSize = 1000
+
+// addTimeEntryBatch adds several time entries, possibly in different projects. The projects
+// must exist. Either all entries are added or, if any entry is invalid, none are.
+func (s *apiServer) addTimeEntryBatch(rw http.ResponseWriter, r *http.Request) {
 	user := s.getUser(rw, r)
 	if user == nil {
 		return
 	}
 
-	var apiTimeEntry api.TimeEntry
-	err := jsonRequest(rw, r, &apiTimeEntry)
+	var apiTimeEntries []*api.TimeEntry
+	err := jsonRequest(rw, r, &apiTimeEntries)
 	if err != nil {
 		return
 	}
-	timeEntry := mapApiToTimeEntry(&apiTimeEntry)
+	if len(apiTimeEntries) > maxBatchSize {
+		validationError(rw, r, fmt.Sprintf("at most %d entries can be added at once", maxBatchSize))
+		return
+	}
+	allowOverlap, ok := getAllowOverlapFromURL(rw, r)
+	if !ok {
+		return
+	}
+	loc := getLocationFromURL(rw, r, domain.EntryQuery{})
+	if loc == nil {
+		return
+	}
 
-	projectName := chi.URLParam(r, "projectName")
-	if projectName == "" {
-		validationError(rw, r, "project name in URL cannot be blank")
+	projects := make(map[string]*domain.Project)
+	var timeEntries []*domain.TimeEntry
+	for idx, apiTimeEntry := range apiTimeEntries {
+		project := projects[apiTimeEntry.ProjectName]
+		if project == nil {
+			project, err = findProject(rw, user, apiTimeEntry.ProjectName)
+			if err != nil {
+				internalError(rw, r, err, fmt.Sprintf("Could not get project by name %s", apiTimeEntry.ProjectName))
+				return
+			}
+			if project == nil {
+				validationError(rw, r, fmt.Sprintf("entry %d: project not found: %s", idx, apiTimeEntry.ProjectName))
+				return
+			}
+			projects[apiTimeEntry.ProjectName] = project
+		}
+
+		timeEntry := mapApiToTimeEntry(apiTimeEntry)
+		timeEntry.Project = project
+		if timeEntry.TaskID, ok = getTaskID(rw, r, project, apiTimeEntry.Task); !ok {
+			return
+		}
+		timeEntries = append(timeEntries, timeEntry)
+	}
+	if err := user.ValidateEntries(timeEntries, allowOverlap); err != nil {
+		entryError(rw, r, err, "Error while validating time entries")
 		return
 	}
-	projectName, err = url.QueryUnescape(projectName)
-	if err != nil {
-		validationError(rw, r, err.Error())
+
+	newEntries, err := user.AddEntries(timeEntries)
+	if err == domain.ErrProjectArchived {
+		conflictError(rw, r, "Entries cannot be added to archived projects - unarchive them first")
+		return
+	} else if err != nil {
+		internalError(rw, r, err, "Error while adding time entries")
 		return
 	}
+	s.checkBudgets(r, loc, user, newEntries...)
+	s.checkAllowances(rw, r, loc, user, newEntries...)
 
-	project, err := user.GetProject(projectName)
+	taskNames, ok := getTaskNames(rw, r, user)
+	if !ok {
+		return
+	}
+	result := mapTimeEntriesToApi(newEntries, taskNames)
+	if result == nil {
+		result = []*api.TimeEntry{}
+	}
+	jsonResponse(rw, r, 201, result)
+}
+
+func (s *apiServer) updateProjectTimeEntry(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	var apiTimeEntry api.TimeEntry
+	err := jsonRequest(rw, r, &apiTimeEntry)
 	if err != nil {
-		internalError(rw, r, err, fmt.Sprintf("Could not get project by name %s", projectName))
 		return
 	}
+	timeEntry := mapApiToTimeEntry(&apiTimeEntry)
+
+	project := s.getProjectFromURL(rw, r, user)
 	if project == nil {
-		notFoundError(rw, r, fmt.Sprintf("Project not found: %s", projectName))
 		return
 	}
 
-	entryID := chi.URLParam(r, "entryID")
+	entryID := getEntryIDFromURL(rw, r)
 	if entryID == "" {
-		validationError(rw, r, "entry ID in URL cannot be blank")
-		return
-	}
-	entryID, err = url.QueryUnescape(entryID)
-	if err != nil {
-		validationError(rw, r, err.Error())
 		return
 	}
 
@@ -444,50 +837,116 @@ func (s *apiServer) updateProjectTimeEntry(rw http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	allowOverlap, ok := getAllowOverlapFromURL(rw, r)
+	if !ok {
+		return
+	}
+	loc := getLocationFromURL(rw, r, domain.EntryQuery{})
+	if loc == nil {
+		return
+	}
+
+	previous, err := user.GetEntry(entryID)
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Error while getting time entry"))
+		return
+	}
+	if previous == nil {
+		notFoundError(rw, r, fmt.Sprintf("Time entry not found: %s", entryID))
+		return
+	}
+
+	timeEntry.Project = project
+	if timeEntry.TaskID, ok = getTaskID(rw, r, project, apiTimeEntry.Task); !ok {
+		return
+	}
+	if err := user.ValidateEntry(timeEntry, allowOverlap); err != nil {
+		entryError(rw, r, err, "Error while validating time entry")
+		return
+	}
+
 	updatedEntry, err := project.UpdateEntry(timeEntry)
+	if err == domain.ErrEntryInvoiced {
+		conflictError(rw, r, fmt.Sprintf("Time entry %s has been invoiced and cannot be changed", entryID))
+		return
+	}
 	if err != nil {
 		internalError(rw, r, err, fmt.Sprintf("Error while updating time entry"))
 		return
 	}
+	s.checkBudget(r, loc, user, updatedEntry, previous)
 
-	jsonResponse(rw, r, 200, mapTimeEntryToApi(updatedEntry))
+	timeEntryResponse(rw, r, 200, user, updatedEntry)
 }
 
-func (s *apiServer) getProjectTimeEntry(rw http.ResponseWriter, r *http.Request) {
+// getUserTimeEntry returns one of the user's entries by its ID alone, whatever project it
+// is in
+func (s *apiServer) getUserTimeEntry(rw http.ResponseWriter, r *http.Request) {
 	user := s.getUser(rw, r)
 	if user == nil {
 		return
 	}
 
-	projectName := chi.URLParam(r, "projectName")
-	if projectName == "" {
-		validationError(rw, r, "project name in URL cannot be blank")
+	entryID := getEntryIDFromURL(rw, r)
+	if entryID == "" {
 		return
 	}
-	projectName, err := url.QueryUnescape(projectName)
+
+	timeEntry, err := user.GetEntry(entryID)
 	if err != nil {
-		validationError(rw, r, err.Error())
+		internalError(rw, r, err, fmt.Sprintf("Error while getting time entry"))
+		return
+	}
+	if timeEntry == nil {
+		notFoundError(rw, r, fmt.Sprintf("Time entry not found: %s", entryID))
 		return
 	}
 
-	project, err := user.GetProject(projectName)
-	if err != nil {
-		internalError(rw, r, err, fmt.Sprintf("Could not get project by name %s", projectName))
+	timeEntryResponse(rw, r, 200, user, timeEntry)
+}
+
+func (s *apiServer) getProjectTimeEntry(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
 		return
 	}
+
+	project := s.getProjectFromURL(rw, r, user)
 	if project == nil {
-		notFoundError(rw, r, fmt.Sprintf("Project not found: %s", projectName))
 		return
 	}
 
-	entryID := chi.URLParam(r, "entryID")
+	entryID := getEntryIDFromURL(rw, r)
 	if entryID == "" {
-		validationError(rw, r, "entry ID in URL cannot be blank")
 		return
 	}
-	entryID, err = url.QueryUnescape(entryID)
+
+	timeEntry, err := project.GetEntry(entryID)
 	if err != nil {
-		validationError(rw, r, err.Error())
+		internalError(rw, r, err, fmt.Sprintf("Error while getting time entry"))
+		return
+	}
+	if timeEntry == nil {
+		notFoundError(rw, r, fmt.Sprintf("Time entry not found: %s/%s", project.Name, entryID))
+		return
+	}
+
+	timeEntryResponse(rw, r, 200, user, timeEntry)
+}
+
+func (s *apiServer) deleteProjectTimeEntry(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	project := s.getProjectFromURL(rw, r, user)
+	if project == nil {
+		return
+	}
+
+	entryID := getEntryIDFromURL(rw, r)
+	if entryID == "" {
 		return
 	}
 
@@ -497,8 +956,19 @@ func (s *apiServer) getProjectTimeEntry(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 	if timeEntry == nil {
-		notFoundError(rw, r, fmt.Sprintf("Time entry not found: %s/%s", projectName, entryID))
+		notFoundError(rw, r, fmt.Sprintf("Time entry not found: %s/%s", project.Name, entryID))
+		return
+	}
+
+	err = project.DeleteEntry(entryID)
+	if err == domain.ErrEntryInvoiced {
+		conflictError(rw, r, fmt.Sprintf("Time entry %s has been invoiced and cannot be deleted", entryID))
+		return
+	}
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Error while deleting time entry"))
+		return
 	}
 
-	jsonResponse(rw, r, 200, mapTimeEntryToApi(timeEntry))
+	rw.WriteHeader(204)
 }
@@ -0,0 +1,1584 @@
+package endpoints
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/kennep/timelapse/domain"
+	"github.com/kennep/timelapse/memory_repository"
+)
+
+const testSubjectHeader = "X-Test-Subject"
+
+// testAuthentication stands in for the OIDC authentication handler. The subject
+// is taken from a request header so that tests can act as several users.
+func testAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		appctx := ApplicationContextFromRequest(r)
+		appctx.User.SubjectID = r.Header.Get(testSubjectHeader)
+		appctx.User.Issuer = "https://issuer.example.com"
+		appctx.User.Email = appctx.User.SubjectID + "@example.com"
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func newTestRouter() http.Handler {
+	return newTestRouterWithNotifier(logNotifier{})
+}
+
+func newTestRouterWithNotifier(notifier budgetNotifier) http.Handler {
+	users := domain.InitUsersCollection(memory_repository.NewMemoryRepository())
+	server := apiServer{users: users, notifier: notifier}
+
+	r := chi.NewRouter()
+	r.Use(ApplicationContext())
+	server.publicRoutes(r)
+	r.Group(func(r chi.Router) {
+		r.Use(testAuthentication)
+		server.routes(r)
+	})
+	return r
+}
+
+func doRequest(t *testing.T, router http.Handler, subject string, method string, path string, body interface{}, response interface{}) int {
+	t.Helper()
+
+	var requestBody []byte
+	if body != nil {
+		var err error
+		requestBody, err = json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(requestBody))
+	req.Header.Set(testSubjectHeader, subject)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	// Validation failures are decoded too, so that tests can check the violated rules
+	if response != nil && (rec.Code < 300 || rec.Code == 422) {
+		if err := json.Unmarshal(rec.Body.Bytes(), response); err != nil {
+			t.Fatalf("Could not unmarshal response to %s %s: %v (%s)", method, path, err, rec.Body.String())
+		}
+	}
+	return rec.Code
+}
+
+func TestProjectLifecycle(t *testing.T) {
+	router := newTestRouter()
+
+	var project api.Project
+	status := doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme", Billable: true}, &project)
+	if status != 201 {
+		t.Fatalf("Expected 201 when creating project, got %d", status)
+	}
+
+	status = doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+	if status != 400 {
+		t.Errorf("Expected 400 when creating duplicate project, got %d", status)
+	}
+
+	status = doRequest(t, router, "alice", "PUT", "/projects/acme", &api.Project{Name: "acme", Description: "Acme Inc."}, &project)
+	if status != 200 || project.Description != "Acme Inc." {
+		t.Errorf("Unexpected update result: %d %v", status, project)
+	}
+
+	var projects []*api.Project
+	doRequest(t, router, "alice", "GET", "/projects", nil, &projects)
+	if len(projects) != 1 {
+		t.Errorf("Expected one project for alice, got %d", len(projects))
+	}
+
+	status = doRequest(t, router, "bob", "GET", "/projects/acme", nil, nil)
+	if status != 404 {
+		t.Errorf("Expected projects to be scoped to their user, got %d", status)
+	}
+}
+
+func TestTimeEntryLifecycle(t *testing.T) {
+	router := newTestRouter()
+
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	var entry api.TimeEntry
+	status := doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &start}, &entry)
+	if status != 200 || entry.ID == "" {
+		t.Fatalf("Unexpected result when adding entry: %d %v", status, entry)
+	}
+	if url.PathEscape(entry.ID) != entry.ID {
+		t.Errorf("Expected an entry ID that needs no escaping in URL paths, got %s", entry.ID)
+	}
+
+	end := start.Add(8 * time.Hour)
+	entry.End = &end
+	status = doRequest(t, router, "alice", "PUT", "/projects/acme/entries/"+url.QueryEscape(entry.ID), &entry, &entry)
+	if status != 200 || entry.End == nil || !entry.End.Equal(end) {
+		t.Errorf("Unexpected result when updating entry: %d %v", status, entry)
+	}
+
+	var entries []*api.TimeEntry
+	doRequest(t, router, "alice", "GET", "/entries", nil, &entries)
+	if len(entries) != 1 || entries[0].ProjectName != "acme" {
+		t.Errorf("Unexpected user entries: %v", entries)
+	}
+
+	entries = nil
+	doRequest(t, router, "bob", "GET", "/entries", nil, &entries)
+	if len(entries) != 0 {
+		t.Errorf("Expected entries to be scoped to their user, got %v", entries)
+	}
+
+	status = doRequest(t, router, "alice", "GET", "/projects/acme/entries/unknown", nil, nil)
+	if status != 404 {
+		t.Errorf("Expected 404 for unknown entry, got %d", status)
+	}
+	status = doRequest(t, router, "alice", "PUT", "/projects/acme/entries/unknown", &api.TimeEntry{Type: "work", Start: &start, End: &end}, nil)
+	if status != 404 {
+		t.Errorf("Expected 404 when updating an unknown entry, got %d", status)
+	}
+}
+
+func TestDeleteProjectAndEntries(t *testing.T) {
+	router := newTestRouter()
+
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	var first, second api.TimeEntry
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &start}, &first)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries?allow_overlap=true", &api.TimeEntry{Type: "work", Start: &start}, &second)
+
+	status := doRequest(t, router, "bob", "DELETE", "/projects/acme/entries/"+url.QueryEscape(first.ID), nil, nil)
+	if status != 404 {
+		t.Errorf("Expected 404 when deleting another user's entry, got %d", status)
+	}
+
+	status = doRequest(t, router, "alice", "DELETE", "/projects/acme/entries/"+url.QueryEscape(first.ID), nil, nil)
+	if status != 204 {
+		t.Errorf("Expected 204 when deleting entry, got %d", status)
+	}
+	status = doRequest(t, router, "alice", "GET", "/projects/acme/entries/"+url.QueryEscape(first.ID), nil, nil)
+	if status != 404 {
+		t.Errorf("Expected deleted entry to be gone, got %d", status)
+	}
+
+	status = doRequest(t, router, "alice", "DELETE", "/projects/acme", nil, nil)
+	if status != 409 {
+		t.Errorf("Expected 409 when deleting project with entries, got %d", status)
+	}
+
+	status = doRequest(t, router, "alice", "DELETE", "/projects/acme?cascade=true", nil, nil)
+	if status != 204 {
+		t.Errorf("Expected 204 when deleting project with cascade, got %d", status)
+	}
+
+	var entries []*api.TimeEntry
+	doRequest(t, router, "alice", "GET", "/entries", nil, &entries)
+	if len(entries) != 0 {
+		t.Errorf("Expected entries to be deleted with the project, got %v", entries)
+	}
+}
+
+func TestTimeEntryFilters(t *testing.T) {
+	router := newTestRouter()
+
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+	monday := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	tuesday := monday.Add(24 * time.Hour)
+	mondayEnd := monday.Add(8 * time.Hour)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &monday, End: &mondayEnd}, nil)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "vacation", Start: &tuesday, End: &tuesday}, nil)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &tuesday}, nil)
+
+	for _, test := range []struct {
+		query    string
+		expected int
+	}{
+		{"", 3},
+		{"?from=" + url.QueryEscape(tuesday.Format(time.RFC3339)), 2},
+		{"?to=" + url.QueryEscape(tuesday.Format(time.RFC3339)), 1},
+		{"?type=work", 2},
+		{"?type=work&open=true", 1},
+	} {
+		var entries []*api.TimeEntry
+		doRequest(t, router, "alice", "GET", "/entries"+test.query, nil, &entries)
+		if len(entries) != test.expected {
+			t.Errorf("Expected %d entries for /entries%s, got %d", test.expected, test.query, len(entries))
+		}
+		entries = nil
+		doRequest(t, router, "alice", "GET", "/projects/acme/entries"+test.query, nil, &entries)
+		if len(entries) != test.expected {
+			t.Errorf("Expected %d entries for /projects/acme/entries%s, got %d", test.expected, test.query, len(entries))
+		}
+	}
+
+	status := doRequest(t, router, "alice", "GET", "/entries?from=yesterday", nil, nil)
+	if status != 400 {
+		t.Errorf("Expected 400 for invalid from parameter, got %d", status)
+	}
+}
+
+func TestSummaryReport(t *testing.T) {
+	router := newTestRouter()
+
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme", Billable: true}, nil)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "internal"}, nil)
+	monday := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	mondayEnd := monday.Add(8 * time.Hour)
+	tuesday := monday.Add(24 * time.Hour)
+	tuesdayEnd := tuesday.Add(2 * time.Hour)
+	friday := monday.Add(4 * 24 * time.Hour)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &monday, End: &mondayEnd, Breaks: 30 * time.Minute}, nil)
+	doRequest(t, router, "alice", "POST", "/projects/internal/entries", &api.TimeEntry{Type: "work", Start: &tuesday, End: &tuesdayEnd}, nil)
+	doRequest(t, router, "alice", "POST", "/projects/internal/entries", &api.TimeEntry{Type: "vacation", Start: &friday, End: &friday}, nil)
+
+	var summary api.Summary
+	status := doRequest(t, router, "alice", "GET", "/reports/summary", nil, &summary)
+	if status != 200 {
+		t.Fatalf("Expected 200 for summary report, got %d", status)
+	}
+	if summary.GroupBy != "project" || len(summary.Groups) != 2 {
+		t.Fatalf("Unexpected summary: %+v", summary)
+	}
+	if summary.Groups[0].Key != "acme" || summary.Groups[0].Billable != 7*time.Hour+30*time.Minute {
+		t.Errorf("Unexpected acme group: %+v", summary.Groups[0])
+	}
+	if summary.Groups[1].Key != "internal" || summary.Groups[1].NonBillable != 2*time.Hour || summary.Groups[1].Days["vacation"] != 1 {
+		t.Errorf("Unexpected internal group: %+v", summary.Groups[1])
+	}
+	if summary.Total.Worked != 9*time.Hour+30*time.Minute {
+		t.Errorf("Expected 9h30m worked in total, got %v", summary.Total.Worked)
+	}
+
+	summary = api.Summary{}
+	doRequest(t, router, "alice", "GET", "/reports/summary?group_by=day&project=internal&from="+url.QueryEscape(tuesday.Format(time.RFC3339)), nil, &summary)
+	if len(summary.Groups) != 2 || summary.Groups[0].Key != "2019-01-08" || summary.Groups[1].Key != "2019-01-11" {
+		t.Errorf("Unexpected daily summary: %+v", summary.Groups)
+	}
+	// Clients that do not know their zone name send their UTC offset
+	summary = api.Summary{}
+	doRequest(t, router, "alice", "GET", "/reports/summary?group_by=day&project=internal&tz="+url.QueryEscape("-09:00"), nil, &summary)
+	if len(summary.Groups) != 2 || summary.Groups[0].Key != "2019-01-07" || summary.Groups[1].Key != "2019-01-10" {
+		t.Errorf("Unexpected daily summary with an offset: %+v", summary.Groups)
+	}
+
+	status = doRequest(t, router, "alice", "GET", "/reports/summary?group_by=year", nil, nil)
+	if status != 400 {
+		t.Errorf("Expected 400 for invalid grouping, got %d", status)
+	}
+	status = doRequest(t, router, "alice", "GET", "/reports/summary?project=unknown", nil, nil)
+	if status != 404 {
+		t.Errorf("Expected 404 for unknown project, got %d", status)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	router := newTestRouter()
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	for _, name := range []string{"delta", "alpha", "charlie", "bravo", "echo"} {
+		doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: name}, nil)
+		// Two entries with the same start time, to exercise the ID tie breaker
+		doRequest(t, router, "alice", "POST", "/projects/"+name+"/entries?allow_overlap=true", &api.TimeEntry{Type: "work", Start: &start}, nil)
+		doRequest(t, router, "alice", "POST", "/projects/"+name+"/entries?allow_overlap=true", &api.TimeEntry{Type: "work", Start: &start}, nil)
+		start = start.Add(-time.Hour)
+	}
+
+	var names []string
+	path := "/projects?limit=2"
+	for path != "" {
+		var projects []*api.Project
+		link := doPagedRequest(t, router, path, &projects)
+		for _, project := range projects {
+			names = append(names, project.Name)
+		}
+		path = link
+	}
+	if fmt.Sprint(names) != "[alpha bravo charlie delta echo]" {
+		t.Errorf("Unexpected project pages: %v", names)
+	}
+
+	seen := make(map[string]bool)
+	var previous *time.Time
+	path = "/entries?limit=3&type=work"
+	for path != "" {
+		var entries []*api.TimeEntry
+		link := doPagedRequest(t, router, path, &entries)
+		for _, entry := range entries {
+			if seen[entry.ID] {
+				t.Errorf("Entry %s returned twice", entry.ID)
+			}
+			seen[entry.ID] = true
+			if previous != nil && entry.Start.Before(*previous) {
+				t.Errorf("Entries not ordered by start time")
+			}
+			previous = entry.Start
+		}
+		path = link
+	}
+	if len(seen) != 10 {
+		t.Errorf("Expected 10 entries in total, got %d", len(seen))
+	}
+
+	status := doRequest(t, router, "alice", "GET", "/entries?limit=0", nil, nil)
+	if status != 400 {
+		t.Errorf("Expected 400 for invalid limit, got %d", status)
+	}
+
+	// Without a limit, lists are returned a default page at a time
+	var batch []*api.TimeEntry
+	for i := 0; i < defaultPageSize; i++ {
+		entryStart := time.Date(2019, 2, 1, 0, i, 0, 0, time.UTC)
+		batch = append(batch, &api.TimeEntry{ProjectName: "alpha", Type: "sick", Start: &entryStart, End: &entryStart})
+	}
+	if status := doRequest(t, router, "alice", "POST", "/entries:batch?allow_overlap=true", batch, nil); status != 201 {
+		t.Fatalf("Expected the entries to be added, got %d", status)
+	}
+	var page []*api.TimeEntry
+	link := doPagedRequest(t, router, "/entries", &page)
+	if len(page) != defaultPageSize || link == "" {
+		t.Errorf("Expected a default page of %d entries and a next link, got %d entries and %q", defaultPageSize, len(page), link)
+	}
+	var rest []*api.TimeEntry
+	if link := doPagedRequest(t, router, link, &rest); len(rest) != 10 || link != "" {
+		t.Errorf("Expected the last 10 entries on the next page, got %d entries and %q", len(rest), link)
+	}
+}
+
+// doPagedRequest gets a page and returns the path of the next page, if any
+func doPagedRequest(t *testing.T, router http.Handler, path string, response interface{}) string {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set(testSubjectHeader, "alice")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("Unexpected status %d for %s", rec.Code, path)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), response); err != nil {
+		t.Fatal(err)
+	}
+
+	link := rec.Header().Get("Link")
+	if link == "" {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(link, "<"), ">; rel=\"next\"")
+}
+
+// doExportRequest gets a file and returns its status code and body
+func doExportRequest(t *testing.T, router http.Handler, subject string, path string) (int, string) {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set(testSubjectHeader, subject)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestExport(t *testing.T) {
+	router := newTestRouter()
+
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme", Billable: true}, nil)
+	monday := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	mondayEnd := monday.Add(8 * time.Hour)
+	friday := monday.Add(4 * 24 * time.Hour)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &monday, End: &mondayEnd, Breaks: 30 * time.Minute, Comment: "planning, review"}, nil)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "vacation", Start: &friday, End: &friday}, nil)
+
+	status, body := doExportRequest(t, router, "alice", "/entries/export?format=csv")
+	expected := "date,project,type,start,end,breaks,hours,days,comment\n" +
+		"2019-01-07,acme,work,2019-01-07T08:00:00Z,2019-01-07T16:00:00Z,0.50,7.50,,\"planning, review\"\n" +
+		"2019-01-11,acme,vacation,2019-01-11T08:00:00Z,2019-01-11T08:00:00Z,0.00,,1,\n"
+	if status != 200 || body != expected {
+		t.Errorf("Unexpected csv export (%d):\n%s", status, body)
+	}
+
+	status, body = doExportRequest(t, router, "alice", "/entries/export?columns=project,hours&type=work&tz=Etc/GMT-1")
+	if status != 200 || body != "project,hours\nacme,7.50\n" {
+		t.Errorf("Unexpected filtered csv export (%d):\n%s", status, body)
+	}
+
+	status, _ = doExportRequest(t, router, "alice", "/entries/export?columns=project,salary")
+	if status != 400 {
+		t.Errorf("Expected 400 for unknown column, got %d", status)
+	}
+
+	status, body = doExportRequest(t, router, "alice", "/entries/export?format=ics&project=acme")
+	for _, line := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"DTSTART:20190107T080000Z\r\nDTEND:20190107T160000Z\r\nSUMMARY:acme: planning\\, review\r\n",
+		"DTSTART;VALUE=DATE:20190111\r\nDTEND;VALUE=DATE:20190112\r\n",
+	} {
+		if status != 200 || !strings.Contains(body, line) {
+			t.Errorf("Expected calendar export to contain %q, got (%d):\n%s", line, status, body)
+		}
+	}
+	if strings.Count(body, "BEGIN:VEVENT") != 2 {
+		t.Errorf("Expected 2 events, got:\n%s", body)
+	}
+
+	status = doRequest(t, router, "alice", "GET", "/self/feed", nil, nil)
+	if status != 404 {
+		t.Errorf("Expected 404 before the feed is enabled, got %d", status)
+	}
+	var feed api.Feed
+	doRequest(t, router, "alice", "PUT", "/self/feed", nil, &feed)
+	if !strings.HasPrefix(feed.Path, "/feeds/") || !strings.HasSuffix(feed.Path, ".ics") {
+		t.Fatalf("Unexpected feed path: %s", feed.Path)
+	}
+
+	status, body = doExportRequest(t, router, "", feed.Path+"?type=vacation")
+	if status != 200 || strings.Count(body, "BEGIN:VEVENT") != 1 {
+		t.Errorf("Unexpected calendar feed (%d):\n%s", status, body)
+	}
+
+	status = doRequest(t, router, "alice", "DELETE", "/self/feed", nil, nil)
+	if status != 204 {
+		t.Errorf("Expected 204 when revoking feed, got %d", status)
+	}
+	status, _ = doExportRequest(t, router, "", feed.Path)
+	if status != 404 {
+		t.Errorf("Expected 404 for revoked feed, got %d", status)
+	}
+}
+
+func TestICalendarLineFolding(t *testing.T) {
+	var w icalWriter
+	w.text("DESCRIPTION", strings.Repeat("æ", 100))
+	for _, line := range strings.Split(strings.TrimSuffix(w.buf.String(), "\r\n"), "\r\n") {
+		if len(line) > icalLineLength {
+			t.Errorf("Line longer than %d octets: %q", icalLineLength, line)
+		}
+	}
+	unfolded := strings.Replace(w.buf.String(), "\r\n ", "", -1)
+	if unfolded != "DESCRIPTION:"+strings.Repeat("æ", 100)+"\r\n" {
+		t.Errorf("Unexpected unfolded line: %q", unfolded)
+	}
+}
+
+func TestTimeEntryBatch(t *testing.T) {
+	router := newTestRouter()
+
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "internal"}, nil)
+	monday := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	mondayEnd := monday.Add(8 * time.Hour)
+
+	status := doRequest(t, router, "alice", "POST", "/entries:batch", []*api.TimeEntry{
+		{ProjectName: "acme", Type: "work", Start: &monday, End: &mondayEnd},
+		{ProjectName: "internal", Type: "work", Start: &mondayEnd, End: &monday},
+	}, nil)
+	if status != 422 {
+		t.Errorf("Expected 422 for entry that ends before it starts, got %d", status)
+	}
+	status = doRequest(t, router, "alice", "POST", "/entries:batch", []*api.TimeEntry{
+		{ProjectName: "unknown", Type: "work", Start: &monday},
+	}, nil)
+	if status != 400 {
+		t.Errorf("Expected 400 for unknown project, got %d", status)
+	}
+	var entries []*api.TimeEntry
+	doRequest(t, router, "alice", "GET", "/entries", nil, &entries)
+	if len(entries) != 0 {
+		t.Errorf("Expected no entries after failed batches, got %v", entries)
+	}
+
+	var added []*api.TimeEntry
+	status = doRequest(t, router, "alice", "POST", "/entries:batch", []*api.TimeEntry{
+		{ProjectName: "acme", Type: "work", Start: &monday, End: &mondayEnd},
+		{ProjectName: "internal", Type: "sick", Start: &mondayEnd, End: &mondayEnd},
+	}, &added)
+	if status != 201 || len(added) != 2 || added[0].ID == "" || added[1].ProjectName != "internal" {
+		t.Fatalf("Unexpected batch result (%d): %v", status, added)
+	}
+	entries = nil
+	doRequest(t, router, "alice", "GET", "/projects/internal/entries", nil, &entries)
+	if len(entries) != 1 || entries[0].ID != added[1].ID {
+		t.Errorf("Unexpected entries after batch: %v", entries)
+	}
+}
+
+func TestTimer(t *testing.T) {
+	router := newTestRouter()
+
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "internal"}, nil)
+
+	status := doRequest(t, router, "alice", "GET", "/timer", nil, nil)
+	if status != 404 {
+		t.Errorf("Expected 404 when no timer is running, got %d", status)
+	}
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	var started api.TimerStart
+	status = doRequest(t, router, "alice", "POST", "/timer", &api.TimeEntry{ProjectName: "acme", Start: &start}, &started)
+	if status != 201 || started.Started.ProjectName != "acme" || started.Started.Type != "work" || len(started.Stopped) != 0 {
+		t.Errorf("Unexpected timer start (%d): %v", status, started)
+	}
+
+	later := start.Add(2 * time.Hour)
+	status = doRequest(t, router, "alice", "POST", "/timer", &api.TimeEntry{ProjectName: "internal", Start: &later}, nil)
+	if status != 409 {
+		t.Errorf("Expected 409 when a timer is running, got %d", status)
+	}
+
+	// Switching stops the running timer regardless of the policy, but only if the new one starts
+	if status := doRequest(t, router, "alice", "POST", "/timer?stop_running=true", &api.TimeEntry{ProjectName: "typo", Start: &later}, nil); status != 400 {
+		t.Errorf("Expected 400 when switching to an unknown project, got %d", status)
+	}
+	var running api.TimeEntry
+	doRequest(t, router, "alice", "GET", "/timer", nil, &running)
+	if running.ID != started.Started.ID || running.End != nil {
+		t.Errorf("Expected the acme timer to keep running after a failed switch, got %v", running)
+	}
+	if status := doRequest(t, router, "alice", "POST", "/timer?stop_running=maybe", &api.TimeEntry{ProjectName: "internal", Start: &later}, nil); status != 400 {
+		t.Errorf("Expected 400 for an invalid stop_running parameter, got %d", status)
+	}
+
+	var settings api.UserSettings
+	doRequest(t, router, "alice", "GET", "/self/settings", nil, &settings)
+	if settings.TimerPolicy != "forbid" {
+		t.Errorf("Expected the forbid policy by default, got %q", settings.TimerPolicy)
+	}
+	status = doRequest(t, router, "alice", "PUT", "/self/settings", &api.UserSettings{TimerPolicy: "sometimes"}, nil)
+	if status != 400 {
+		t.Errorf("Expected 400 for unknown timer policy, got %d", status)
+	}
+	doRequest(t, router, "alice", "PUT", "/self/settings", &api.UserSettings{TimerPolicy: "auto-stop"}, nil)
+
+	status = doRequest(t, router, "alice", "POST", "/timer", &api.TimeEntry{ProjectName: "internal", Start: &later}, &started)
+	if status != 201 || len(started.Stopped) != 1 || started.Stopped[0].ProjectName != "acme" || !started.Stopped[0].End.Equal(later) {
+		t.Errorf("Expected the acme timer to be stopped (%d): %v", status, started)
+	}
+
+	var timer api.TimeEntry
+	doRequest(t, router, "alice", "GET", "/timer", nil, &timer)
+	if timer.ID != started.Started.ID {
+		t.Errorf("Expected the internal timer to be running, got %v", timer)
+	}
+
+	status = doRequest(t, router, "alice", "POST", "/timer/stop", &api.TimerStop{ProjectName: "acme"}, nil)
+	if status != 404 {
+		t.Errorf("Expected 404 when stopping a project without a running timer, got %d", status)
+	}
+	beforeStart := later.Add(-2 * time.Hour)
+	var validation api.ValidationError
+	status = doRequest(t, router, "alice", "POST", "/timer/stop", &api.TimerStop{End: &beforeStart}, &validation)
+	if status != 422 || len(validation.Errors) != 1 || validation.Errors[0].Rule != "end_before_start" {
+		t.Errorf("Expected 422 for a stop before the timer started (%d): %v", status, validation.Errors)
+	}
+	longBreaks := 10 * time.Hour
+	afterStart := later.Add(time.Hour)
+	if status := doRequest(t, router, "alice", "POST", "/timer/stop", &api.TimerStop{End: &afterStart, Breaks: &longBreaks}, nil); status != 422 {
+		t.Errorf("Expected 422 for breaks longer than the entry, got %d", status)
+	}
+	doRequest(t, router, "alice", "GET", "/timer", nil, &running)
+	if running.ID != timer.ID || running.End != nil {
+		t.Errorf("Expected the timer to keep running after a failed stop, got %v", running)
+	}
+	end := later.Add(time.Hour)
+	var stopped api.TimeEntry
+	status = doRequest(t, router, "alice", "POST", "/timer/stop", &api.TimerStop{End: &end}, &stopped)
+	if status != 200 || stopped.ID != timer.ID || !stopped.End.Equal(end) {
+		t.Errorf("Unexpected timer stop (%d): %v", status, stopped)
+	}
+
+	status = doRequest(t, router, "alice", "GET", "/timer", nil, nil)
+	if status != 404 {
+		t.Errorf("Expected 404 after stopping the timer, got %d", status)
+	}
+}
+
+func TestTimerOverlap(t *testing.T) {
+	router := newTestRouter()
+
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "internal"}, nil)
+	start := time.Date(2019, 1, 7, 9, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+	var entry api.TimeEntry
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &start, End: &end}, &entry)
+
+	timerStart := start.Add(time.Hour)
+	var validation api.ValidationError
+	status := doRequest(t, router, "alice", "POST", "/timer", &api.TimeEntry{ProjectName: "internal", Start: &timerStart}, &validation)
+	if status != 422 || len(validation.Errors) != 1 || validation.Errors[0].Rule != "overlap" || !strings.Contains(validation.Errors[0].Message, entry.ID) {
+		t.Errorf("Expected 422 for a timer that overlaps another entry (%d): %v", status, validation.Errors)
+	}
+	if status := doRequest(t, router, "alice", "GET", "/timer", nil, nil); status != 404 {
+		t.Errorf("Expected no timer after an overlapping start, got %d", status)
+	}
+
+	var started api.TimerStart
+	status = doRequest(t, router, "alice", "POST", "/timer?allow_overlap=true", &api.TimeEntry{ProjectName: "internal", Start: &timerStart}, &started)
+	if status != 201 || started.Started.ProjectName != "internal" {
+		t.Errorf("Expected the timer to start when overlaps are allowed (%d): %v", status, started)
+	}
+
+	timerEnd := timerStart.Add(time.Hour)
+	status = doRequest(t, router, "alice", "POST", "/timer/stop", &api.TimerStop{End: &timerEnd}, nil)
+	if status != 422 {
+		t.Errorf("Expected 422 for a stop that makes the timer overlap another entry, got %d", status)
+	}
+	var stopped api.TimeEntry
+	status = doRequest(t, router, "alice", "POST", "/timer/stop?allow_overlap=true", &api.TimerStop{End: &timerEnd}, &stopped)
+	if status != 200 || stopped.ID != started.Started.ID {
+		t.Errorf("Expected the timer to stop when overlaps are allowed (%d): %v", status, stopped)
+	}
+}
+
+func TestTimeEntryValidation(t *testing.T) {
+	router := newTestRouter()
+
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "internal"}, nil)
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+
+	var validation api.ValidationError
+	status := doRequest(t, router, "alice", "POST", "/projects/acme/entries",
+		&api.TimeEntry{Type: "holiday", Start: &end, End: &start, Breaks: -time.Minute}, &validation)
+	rules := make(map[string]string)
+	for _, fieldError := range validation.Errors {
+		rules[fieldError.Field] = fieldError.Rule
+	}
+	if status != 422 || len(validation.Errors) != 3 || rules["type"] != "unknown_type" || rules["end"] != "end_before_start" || rules["breaks"] != "negative" {
+		t.Errorf("Expected every violated rule to be listed (%d): %v", status, validation.Errors)
+	}
+
+	status = doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &start, End: &end, Breaks: 9 * time.Hour}, nil)
+	if status != 422 {
+		t.Errorf("Expected 422 for breaks longer than the entry, got %d", status)
+	}
+
+	var entry api.TimeEntry
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &start, End: &end}, &entry)
+	overlapStart := start.Add(4 * time.Hour)
+	validation = api.ValidationError{}
+	status = doRequest(t, router, "alice", "POST", "/projects/internal/entries", &api.TimeEntry{Type: "work", Start: &overlapStart}, &validation)
+	if status != 422 || len(validation.Errors) != 1 || validation.Errors[0].Rule != "overlap" || !strings.Contains(validation.Errors[0].Message, entry.ID) {
+		t.Errorf("Expected 422 for overlapping entry (%d): %v", status, validation.Errors)
+	}
+
+	status = doRequest(t, router, "alice", "POST", "/projects/internal/entries", &api.TimeEntry{Type: "work", Start: &end}, nil)
+	if status != 200 {
+		t.Errorf("Expected an entry that starts when another ends to be accepted, got %d", status)
+	}
+	status = doRequest(t, router, "alice", "POST", "/projects/internal/entries", &api.TimeEntry{Type: "vacation", Start: &overlapStart, End: &overlapStart}, nil)
+	if status != 200 {
+		t.Errorf("Expected days off not to be checked for overlaps, got %d", status)
+	}
+	status = doRequest(t, router, "alice", "POST", "/projects/internal/entries?allow_overlap=true", &api.TimeEntry{Type: "work", Start: &overlapStart, End: &end}, nil)
+	if status != 200 {
+		t.Errorf("Expected overlap to be allowed with allow_overlap, got %d", status)
+	}
+
+	// An entry does not overlap itself when it is updated
+	entry.Comment = "planning"
+	status = doRequest(t, router, "alice", "PUT", "/projects/acme/entries/"+url.QueryEscape(entry.ID)+"?allow_overlap=false", &entry, nil)
+	if status != 422 {
+		t.Errorf("Expected 422 for an update that overlaps the entries added with allow_overlap, got %d", status)
+	}
+}
+
+func TestEntryTypes(t *testing.T) {
+	router := newTestRouter()
+
+	var types []*api.EntryType
+	doRequest(t, router, "alice", "GET", "/entry-types", nil, &types)
+	if len(types) != 4 || types[0].Name != "work" || !types[0].TimeBased || !types[0].CountsAsWork || types[0].FulfillsSchedule || types[3].Name != "vacation" || !types[3].FulfillsSchedule {
+		t.Errorf("Expected the default types, got %v", types)
+	}
+
+	var validation api.ValidationError
+	status := doRequest(t, router, "alice", "PUT", "/entry-types/On%20Call", &api.EntryType{Color: "red"}, &validation)
+	if status != 422 || len(validation.Errors) != 2 {
+		t.Errorf("Expected 422 for an invalid name and color (%d): %v", status, validation.Errors)
+	}
+	status = doRequest(t, router, "alice", "PUT", "/entry-types/on-call", &api.EntryType{TimeBased: true, Color: "#9c27b0"}, nil)
+	if status != 200 {
+		t.Errorf("Expected the type to be added, got %d", status)
+	}
+	doRequest(t, router, "alice", "PUT", "/entry-types/training", &api.EntryType{TimeBased: true, CountsAsWork: true}, nil)
+
+	types = nil
+	doRequest(t, router, "alice", "GET", "/entry-types", nil, &types)
+	if len(types) != 6 || types[4].Name != "on-call" || types[5].Name != "training" {
+		t.Errorf("Expected the new types after the defaults, got %v", types)
+	}
+	types = nil
+	doRequest(t, router, "bob", "GET", "/entry-types", nil, &types)
+	if len(types) != 4 {
+		t.Errorf("Expected entry types to be per user, got %v", types)
+	}
+
+	// Training counts as work, so it may not overlap work; on-call does not
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme", Billable: true}, nil)
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &start, End: &end}, nil)
+	status = doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "training", Start: &start, End: &end}, nil)
+	if status != 422 {
+		t.Errorf("Expected 422 for training that overlaps work, got %d", status)
+	}
+	trainingStart := end
+	trainingEnd := end.Add(2 * time.Hour)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "training", Start: &trainingStart, End: &trainingEnd}, nil)
+	onCallEnd := start.Add(24 * time.Hour)
+	status = doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "on-call", Start: &start, End: &onCallEnd}, nil)
+	if status != 200 {
+		t.Errorf("Expected on-call time to be accepted alongside work, got %d", status)
+	}
+
+	var summary api.Summary
+	doRequest(t, router, "alice", "GET", "/reports/summary?group_by=type", nil, &summary)
+	if summary.Total.Worked != 10*time.Hour || summary.Total.Hours["on-call"] != 24*time.Hour || len(summary.Total.Days) != 0 {
+		t.Errorf("Expected work and training as worked time and on-call as hours, got %+v", summary.Total)
+	}
+
+	status = doRequest(t, router, "alice", "DELETE", "/entry-types/on-call", nil, nil)
+	if status != 409 {
+		t.Errorf("Expected 409 when deleting a type that has entries, got %d", status)
+	}
+	status = doRequest(t, router, "alice", "DELETE", "/entry-types/sick", nil, nil)
+	if status != 409 {
+		t.Errorf("Expected 409 when deleting a default type, got %d", status)
+	}
+	status = doRequest(t, router, "alice", "DELETE", "/entry-types/parental-leave", nil, nil)
+	if status != 404 {
+		t.Errorf("Expected 404 when deleting an unknown type, got %d", status)
+	}
+
+	// Changes to a default type are reverted by deleting it
+	doRequest(t, router, "alice", "PUT", "/entry-types/vacation", &api.EntryType{Color: "#000000"}, nil)
+	status = doRequest(t, router, "alice", "DELETE", "/entry-types/vacation", nil, nil)
+	types = nil
+	doRequest(t, router, "alice", "GET", "/entry-types", nil, &types)
+	if status != 204 || types[3].Name != "vacation" || types[3].Color != "#2196f3" {
+		t.Errorf("Expected the default vacation type to be restored (%d): %v", status, types[3])
+	}
+}
+
+func TestTags(t *testing.T) {
+	router := newTestRouter()
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme", Billable: true}, nil)
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	var created api.TimeEntry
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries",
+		&api.TimeEntry{Type: "work", Start: &start, End: &end, Comment: "Sprint #Planning", Tags: []string{"#meeting", "planning"}}, &created)
+	if strings.Join(created.Tags, ",") != "meeting,planning" {
+		t.Errorf("Expected the hashtags and tags to be merged, got %v", created.Tags)
+	}
+	secondStart := end
+	secondEnd := secondStart.Add(3 * time.Hour)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries",
+		&api.TimeEntry{Type: "work", Start: &secondStart, End: &secondEnd, Comment: "fix #bug-123"}, nil)
+	thirdStart := secondEnd
+	thirdEnd := thirdStart.Add(time.Hour)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &thirdStart, End: &thirdEnd}, nil)
+
+	var entries []*api.TimeEntry
+	doRequest(t, router, "alice", "GET", "/entries?tag=meeting,planning", nil, &entries)
+	if len(entries) != 1 || entries[0].ID != created.ID {
+		t.Errorf("Expected the entry with both tags, got %v", entries)
+	}
+	entries = nil
+	doRequest(t, router, "alice", "GET", "/entries?tag=meeting&tag=bug-123&tag_match=any", nil, &entries)
+	if len(entries) != 2 {
+		t.Errorf("Expected the entries with either tag, got %v", entries)
+	}
+	status := doRequest(t, router, "alice", "GET", "/entries?tag=meeting&tag_match=some", nil, nil)
+	if status != 400 {
+		t.Errorf("Expected 400 for an unknown tag_match, got %d", status)
+	}
+
+	var summary api.Summary
+	doRequest(t, router, "alice", "GET", "/reports/summary?group_by=tag", nil, &summary)
+	worked := make(map[string]time.Duration)
+	for _, group := range summary.Groups {
+		worked[group.Key] = group.Worked
+	}
+	if len(summary.Groups) != 4 || worked["meeting"] != 2*time.Hour || worked["planning"] != 2*time.Hour ||
+		worked["bug-123"] != 3*time.Hour || worked[""] != time.Hour || summary.Total.Worked != 6*time.Hour {
+		t.Errorf("Expected a group per tag and each entry counted once in the total, got %v %+v", worked, summary.Total)
+	}
+
+	var validation api.ValidationError
+	status = doRequest(t, router, "alice", "POST", "/projects/acme/entries",
+		&api.TimeEntry{Type: "vacation", Start: &start, End: &start, Tags: []string{"half day"}}, &validation)
+	if status != 422 || len(validation.Errors) != 1 || validation.Errors[0].Field != "tags" {
+		t.Errorf("Expected 422 for an invalid tag (%d): %v", status, validation.Errors)
+	}
+}
+
+func TestClientsAndBilling(t *testing.T) {
+	router := newTestRouter()
+	doRequest(t, router, "alice", "PUT", "/entry-types/support", &api.EntryType{TimeBased: true, CountsAsWork: true}, nil)
+
+	var validation api.ValidationError
+	status := doRequest(t, router, "alice", "POST", "/clients", &api.Client{Name: "acme", Rates: []*api.Rate{
+		{Amount: 100, Currency: "eur"}, {Amount: -1, Currency: "EUR", From: "2019-02-30"}}}, &validation)
+	if status != 422 || len(validation.Errors) != 3 || validation.Errors[0].Field != "rates[0].currency" {
+		t.Errorf("Expected 422 for invalid rates (%d): %v", status, validation.Errors)
+	}
+
+	var client api.Client
+	status = doRequest(t, router, "alice", "POST", "/clients", &api.Client{Name: "acme", Rates: []*api.Rate{
+		{Amount: 100, Currency: "EUR"},
+		{Amount: 120, Currency: "EUR", From: "2019-02-01"},
+		{EntryType: "support", Amount: 150, Currency: "EUR"},
+	}}, &client)
+	if status != 201 || len(client.Rates) != 3 {
+		t.Errorf("Expected the client to be created (%d): %v", status, client)
+	}
+
+	status = doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "web", Client: "nobody"}, nil)
+	if status != 400 {
+		t.Errorf("Expected 400 for an unknown client, got %d", status)
+	}
+	var project api.Project
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "web", Billable: true, Client: "acme"}, &project)
+	if project.Client != "acme" {
+		t.Errorf("Expected the project to be billed to acme, got %v", project)
+	}
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "rush", Billable: true, Client: "acme",
+		Rates: []*api.Rate{{Amount: 200, Currency: "USD"}}}, nil)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "other", Billable: true}, nil)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "internal", Client: "acme"}, nil)
+
+	for _, e := range []struct {
+		project   string
+		entryType string
+		start     time.Time
+		hours     time.Duration
+	}{
+		{"web", "work", time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC), 2},
+		{"web", "work", time.Date(2019, 2, 4, 8, 0, 0, 0, time.UTC), 3},
+		{"web", "support", time.Date(2019, 1, 8, 8, 0, 0, 0, time.UTC), 1},
+		{"rush", "work", time.Date(2019, 1, 9, 8, 0, 0, 0, time.UTC), 1},
+		{"other", "work", time.Date(2019, 1, 10, 8, 0, 0, 0, time.UTC), 1},
+		{"internal", "work", time.Date(2019, 1, 11, 8, 0, 0, 0, time.UTC), 1},
+	} {
+		end := e.start.Add(e.hours * time.Hour)
+		doRequest(t, router, "alice", "POST", "/projects/"+e.project+"/entries", &api.TimeEntry{Type: e.entryType, Start: &e.start, End: &end}, nil)
+	}
+
+	var billing api.Billing
+	doRequest(t, router, "alice", "GET", "/reports/billing", nil, &billing)
+	var lines []string
+	for _, line := range billing.Lines {
+		lines = append(lines, fmt.Sprintf("%s/%s/%s %v x %.0f %s = %.2f", line.Client, line.Project, line.EntryType, line.Hours, line.Rate, line.Currency, line.Amount))
+	}
+	expected := []string{
+		"acme/rush/work 1h0m0s x 200 USD = 200.00",
+		"acme/web/support 1h0m0s x 150 EUR = 150.00",
+		"acme/web/work 2h0m0s x 100 EUR = 200.00",
+		"acme/web/work 3h0m0s x 120 EUR = 360.00",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected billing lines:\n%s", strings.Join(lines, "\n"))
+	}
+	if billing.Totals["EUR"] != 710 || billing.Totals["USD"] != 200 || billing.Unpriced != time.Hour {
+		t.Errorf("Unexpected billing totals: %v, unpriced %v", billing.Totals, billing.Unpriced)
+	}
+
+	billing = api.Billing{}
+	doRequest(t, router, "alice", "GET", "/reports/billing?from=2019-02-01T00:00:00Z", nil, &billing)
+	if len(billing.Lines) != 1 || billing.Lines[0].Amount != 360 {
+		t.Errorf("Expected only the time after the rate change, got %v", billing.Lines)
+	}
+	billing = api.Billing{}
+	doRequest(t, router, "alice", "GET", "/reports/billing?client=acme", nil, &billing)
+	if len(billing.Lines) != 4 || billing.Unpriced != 0 {
+		t.Errorf("Expected only the time billed to acme, got %v", billing.Lines)
+	}
+	status = doRequest(t, router, "alice", "GET", "/reports/billing?client=nobody", nil, nil)
+	if status != 404 {
+		t.Errorf("Expected 404 for an unknown client, got %d", status)
+	}
+
+	status = doRequest(t, router, "alice", "DELETE", "/clients/acme", nil, nil)
+	if status != 409 {
+		t.Errorf("Expected 409 when deleting a client with projects, got %d", status)
+	}
+	var clients []*api.Client
+	doRequest(t, router, "bob", "GET", "/clients", nil, &clients)
+	if len(clients) != 0 {
+		t.Errorf("Expected clients to be per user, got %v", clients)
+	}
+}
+
+func TestInvoices(t *testing.T) {
+	router := newTestRouter()
+	doRequest(t, router, "alice", "POST", "/clients", &api.Client{Name: "acme", Rates: []*api.Rate{{Amount: 100, Currency: "EUR"}}}, nil)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "web", Description: "Web shop", Billable: true, Client: "acme"}, nil)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "app", Billable: true, Client: "acme"}, nil)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "internal"}, nil)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "unpriced", Billable: true}, nil)
+
+	var entries []*api.TimeEntry
+	for _, e := range []struct {
+		project string
+		comment string
+		start   time.Time
+		hours   time.Duration
+	}{
+		{"web", "design", time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC), 2},
+		{"web", "design", time.Date(2019, 1, 8, 8, 0, 0, 0, time.UTC), 1},
+		{"web", "checkout", time.Date(2019, 1, 9, 8, 0, 0, 0, time.UTC), 3},
+		{"app", "", time.Date(2019, 1, 10, 8, 0, 0, 0, time.UTC), 1},
+		{"web", "design", time.Date(2019, 2, 4, 8, 0, 0, 0, time.UTC), 1},
+		{"unpriced", "", time.Date(2019, 1, 10, 12, 0, 0, 0, time.UTC), 1},
+	} {
+		end := e.start.Add(e.hours * time.Hour)
+		var entry api.TimeEntry
+		doRequest(t, router, "alice", "POST", "/projects/"+e.project+"/entries", &api.TimeEntry{Type: "work", Start: &e.start, End: &end, Comment: e.comment}, &entry)
+		entries = append(entries, &entry)
+	}
+
+	from := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC)
+	var invoice api.Invoice
+	status := doRequest(t, router, "alice", "POST", "/invoices", &api.InvoiceRequest{Projects: []string{"web", "app"}, From: &from, To: &to}, &invoice)
+	if status != 201 || invoice.Number != 1 || invoice.Client != "acme" {
+		t.Fatalf("Expected invoice 1 for acme to be created (%d): %v", status, invoice)
+	}
+	var lines []string
+	for _, line := range invoice.Lines {
+		lines = append(lines, fmt.Sprintf("%s/%s %v = %.2f %s", line.Project, line.Description, line.Hours, line.Amount, line.Currency))
+	}
+	expected := []string{
+		"app/app 1h0m0s = 100.00 EUR",
+		"web/Web shop 6h0m0s = 600.00 EUR",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") || invoice.Totals["EUR"] != 700 {
+		t.Errorf("Unexpected invoice lines:\n%s\ntotals %v", strings.Join(lines, "\n"), invoice.Totals)
+	}
+
+	var validation api.ValidationError
+	status = doRequest(t, router, "alice", "POST", "/invoices", &api.InvoiceRequest{Projects: []string{"web"}, From: &from, To: &to}, &validation)
+	if status != 422 || len(validation.Errors) != 1 || validation.Errors[0].Rule != domain.RuleNothingToInvoice {
+		t.Errorf("Expected 422 when the time has been invoiced (%d): %v", status, validation.Errors)
+	}
+	validation = api.ValidationError{}
+	status = doRequest(t, router, "alice", "POST", "/invoices", &api.InvoiceRequest{Projects: []string{"internal", "unpriced", "nothing"}}, &validation)
+	var rules []string
+	for _, fieldError := range validation.Errors {
+		rules = append(rules, fieldError.Field+":"+fieldError.Rule)
+	}
+	expected = []string{"projects[0]:" + domain.RuleNotBillable, "projects[1]:" + domain.RuleNoRate, "projects[2]:" + domain.RuleUnknownProject}
+	if status != 422 || strings.Join(rules, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected 422 for projects that cannot be invoiced (%d): %v", status, rules)
+	}
+
+	invoice = api.Invoice{}
+	status = doRequest(t, router, "alice", "POST", "/invoices", &api.InvoiceRequest{Projects: []string{"web"}, GroupBy: "comment"}, &invoice)
+	if status != 201 || invoice.Number != 2 || len(invoice.Lines) != 1 || invoice.Lines[0].Description != "design" {
+		t.Errorf("Expected invoice 2 with the remaining time by comment (%d): %v", status, invoice.Lines)
+	}
+
+	var entry api.TimeEntry
+	doRequest(t, router, "alice", "GET", "/projects/web/entries/"+url.QueryEscape(entries[0].ID), nil, &entry)
+	if entry.InvoiceNumber != 1 {
+		t.Errorf("Expected the entry to be marked as invoiced, got %v", entry)
+	}
+	entry.Comment = "changed"
+	status = doRequest(t, router, "alice", "PUT", "/projects/web/entries/"+url.QueryEscape(entry.ID), &entry, nil)
+	if status != 409 {
+		t.Errorf("Expected 409 when changing an invoiced entry, got %d", status)
+	}
+	status = doRequest(t, router, "alice", "DELETE", "/projects/web/entries/"+url.QueryEscape(entry.ID), nil, nil)
+	if status != 409 {
+		t.Errorf("Expected 409 when deleting an invoiced entry, got %d", status)
+	}
+	status = doRequest(t, router, "alice", "DELETE", "/projects/web?cascade=true", nil, nil)
+	if status != 409 {
+		t.Errorf("Expected 409 when deleting a project with invoiced entries, got %d", status)
+	}
+	status = doRequest(t, router, "alice", "DELETE", "/projects/unpriced/entries/"+url.QueryEscape(entries[5].ID), nil, nil)
+	if status != 204 {
+		t.Errorf("Expected uninvoiced entries to be deletable, got %d", status)
+	}
+
+	var invoices []*api.Invoice
+	doRequest(t, router, "alice", "GET", "/invoices", nil, &invoices)
+	if len(invoices) != 2 || invoices[0].Number != 1 || invoices[1].Number != 2 {
+		t.Errorf("Expected two invoices in order, got %v", invoices)
+	}
+	invoice = api.Invoice{}
+	status = doRequest(t, router, "alice", "GET", "/invoices/1", nil, &invoice)
+	if status != 200 || invoice.Totals["EUR"] != 700 || len(invoice.Lines) != 2 {
+		t.Errorf("Expected invoice 1 to be unchanged (%d): %v", status, invoice)
+	}
+	if status := doRequest(t, router, "alice", "GET", "/invoices/3", nil, nil); status != 404 {
+		t.Errorf("Expected 404 for an unknown invoice, got %d", status)
+	}
+	if status := doRequest(t, router, "alice", "GET", "/invoices/first", nil, nil); status != 400 {
+		t.Errorf("Expected 400 for an invalid invoice number, got %d", status)
+	}
+	if status := doRequest(t, router, "bob", "GET", "/invoices/1", nil, nil); status != 404 {
+		t.Errorf("Expected invoices to be per user, got %d", status)
+	}
+}
+
+func TestTasks(t *testing.T) {
+	router := newTestRouter()
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+
+	var task api.Task
+	status := doRequest(t, router, "alice", "POST", "/projects/acme/tasks", &api.Task{Name: "design", Description: "Mockups"}, &task)
+	if status != 201 || task.Name != "design" || task.Description != "Mockups" {
+		t.Errorf("Expected the task to be created (%d): %v", status, task)
+	}
+	if status := doRequest(t, router, "alice", "POST", "/projects/acme/tasks", &api.Task{Name: "design"}, nil); status != 400 {
+		t.Errorf("Expected 400 for a duplicate task, got %d", status)
+	}
+	var validation api.ValidationError
+	status = doRequest(t, router, "alice", "POST", "/projects/acme/tasks", &api.Task{Name: "a/b"}, &validation)
+	if status != 422 || len(validation.Errors) != 1 || validation.Errors[0].Rule != domain.RuleFormat {
+		t.Errorf("Expected 422 for a task name with a slash (%d): %v", status, validation.Errors)
+	}
+	doRequest(t, router, "alice", "POST", "/projects/acme/tasks", &api.Task{Name: "build"}, nil)
+
+	var tasks []*api.Task
+	doRequest(t, router, "alice", "GET", "/projects/acme/tasks", nil, &tasks)
+	if len(tasks) != 2 || tasks[0].Name != "build" || tasks[1].Name != "design" {
+		t.Errorf("Expected the tasks ordered by name, got %v", tasks)
+	}
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	status = doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Task: "testing", Type: "work", Start: &start, End: &end}, nil)
+	if status != 400 {
+		t.Errorf("Expected 400 for an unknown task, got %d", status)
+	}
+	var entry api.TimeEntry
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Task: "design", Type: "work", Start: &start, End: &end}, &entry)
+	if entry.Task != "design" {
+		t.Errorf("Expected the entry to be on the design task, got %v", entry)
+	}
+	later := end.Add(time.Hour)
+	laterEnd := later.Add(time.Hour)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &later, End: &laterEnd}, nil)
+
+	// Entries keep referring to a renamed task
+	status = doRequest(t, router, "alice", "PUT", "/projects/acme/tasks/design", &api.Task{Name: "ux"}, &task)
+	if status != 200 || task.Name != "ux" {
+		t.Errorf("Expected the task to be renamed (%d): %v", status, task)
+	}
+	if status := doRequest(t, router, "alice", "PUT", "/projects/acme/tasks/ux", &api.Task{Name: "build"}, nil); status != 400 {
+		t.Errorf("Expected 400 when renaming to an existing task, got %d", status)
+	}
+	var found api.TimeEntry
+	doRequest(t, router, "alice", "GET", "/projects/acme/entries/"+url.QueryEscape(entry.ID), nil, &found)
+	if found.Task != "ux" {
+		t.Errorf("Expected the entry to refer to the renamed task, got %v", found)
+	}
+	var entries []*api.TimeEntry
+	doRequest(t, router, "alice", "GET", "/projects/acme/entries?task=ux", nil, &entries)
+	if len(entries) != 1 || entries[0].Task != "ux" {
+		t.Errorf("Expected the entry of the renamed task, got %v", entries)
+	}
+
+	var summary api.Summary
+	doRequest(t, router, "alice", "GET", "/reports/summary?group_by=task", nil, &summary)
+	if len(summary.Groups) != 2 || summary.Groups[0].Key != "acme" || summary.Groups[0].Worked != time.Hour ||
+		summary.Groups[1].Key != "acme/ux" || summary.Groups[1].Worked != 2*time.Hour {
+		t.Errorf("Unexpected summary by task: %+v", summary.Groups)
+	}
+	summary = api.Summary{}
+	doRequest(t, router, "alice", "GET", "/reports/summary?project=acme&task=ux", nil, &summary)
+	if summary.Total == nil || summary.Total.Worked != 2*time.Hour {
+		t.Errorf("Expected only the time on the task, got %+v", summary.Total)
+	}
+	if status := doRequest(t, router, "alice", "GET", "/reports/summary?task=ux", nil, nil); status != 400 {
+		t.Errorf("Expected 400 for a task without a project, got %d", status)
+	}
+
+	if status := doRequest(t, router, "alice", "DELETE", "/projects/acme/tasks/ux", nil, nil); status != 409 {
+		t.Errorf("Expected 409 when deleting a task with entries, got %d", status)
+	}
+	if status := doRequest(t, router, "alice", "DELETE", "/projects/acme/tasks/build", nil, nil); status != 204 {
+		t.Errorf("Expected the unused task to be deleted, got %d", status)
+	}
+	if status := doRequest(t, router, "alice", "GET", "/projects/acme/tasks/build", nil, nil); status != 404 {
+		t.Errorf("Expected 404 for a deleted task, got %d", status)
+	}
+	if status := doRequest(t, router, "bob", "GET", "/projects/acme/tasks/ux", nil, nil); status != 404 {
+		t.Errorf("Expected tasks to be per user, got %d", status)
+	}
+}
+
+// recordingNotifier keeps the budget notifications it is given
+type recordingNotifier struct {
+	notifications []*api.BudgetNotification
+}
+
+func (n *recordingNotifier) budgetReached(notification *api.BudgetNotification) {
+	n.notifications = append(n.notifications, notification)
+}
+
+func TestBudgets(t *testing.T) {
+	notifier := &recordingNotifier{}
+	router := newTestRouterWithNotifier(notifier)
+
+	var validation api.ValidationError
+	status := doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme", Budget: &api.Budget{Period: "year"}}, &validation)
+	if status != 422 || len(validation.Errors) != 2 || validation.Errors[0].Field != "budget.hours" || validation.Errors[1].Field != "budget.period" {
+		t.Errorf("Expected 422 for an invalid budget (%d): %v", status, validation.Errors)
+	}
+
+	var project api.Project
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme", Billable: true,
+		Rates:  []*api.Rate{{Amount: 100, Currency: "EUR"}},
+		Budget: &api.Budget{Hours: 10 * time.Hour, Amount: 500, Currency: "EUR", Period: "month"}}, &project)
+	if project.Budget == nil || project.Budget.Hours != 10*time.Hour || project.Budget.Period != "month" {
+		t.Errorf("Expected the project to have a budget, got %v", project)
+	}
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "internal"}, nil)
+	if status := doRequest(t, router, "alice", "GET", "/projects/internal/budget", nil, nil); status != 404 {
+		t.Errorf("Expected 404 for a project without a budget, got %d", status)
+	}
+
+	addEntry := func(day int, hours time.Duration) *api.TimeEntry {
+		start := time.Date(2019, 1, day, 8, 0, 0, 0, time.UTC)
+		end := start.Add(hours * time.Hour)
+		var entry api.TimeEntry
+		doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &start, End: &end}, &entry)
+		return &entry
+	}
+	addEntry(7, 3)
+	if len(notifier.notifications) != 0 {
+		t.Errorf("Expected no notifications below the thresholds, got %v", notifier.notifications)
+	}
+	// The time zone budget periods are computed in must be valid, or nothing is stored
+	invalidStart := time.Date(2019, 1, 7, 12, 0, 0, 0, time.UTC)
+	invalidEnd := invalidStart.Add(time.Hour)
+	if status := doRequest(t, router, "alice", "POST", "/projects/acme/entries?tz=Mars/Olympus", &api.TimeEntry{Type: "work", Start: &invalidStart, End: &invalidEnd}, nil); status != 400 {
+		t.Errorf("Expected 400 for an unknown time zone, got %d", status)
+	}
+	if status := doRequest(t, router, "alice", "POST", "/projects/acme/entries?tz=Local", &api.TimeEntry{Type: "work", Start: &invalidStart, End: &invalidEnd}, nil); status != 400 {
+		t.Errorf("Expected 400 for the server's local time zone, got %d", status)
+	}
+	// 4 hours amount to 400 EUR, which is 80% of the money
+	second := addEntry(8, 1)
+	if len(notifier.notifications) != 1 || notifier.notifications[0].Threshold != 80 || notifier.notifications[0].Status.Project != "acme" {
+		t.Errorf("Expected a notification for the 80%% threshold, got %v", notifier.notifications)
+	}
+	start := time.Date(2019, 1, 8, 8, 0, 0, 0, time.UTC)
+	end := start.Add(90 * time.Minute)
+	doRequest(t, router, "alice", "PUT", "/projects/acme/entries/"+url.QueryEscape(second.ID), &api.TimeEntry{Type: "work", Start: &start, End: &end}, nil)
+	if len(notifier.notifications) != 1 {
+		t.Errorf("Expected no new notification for a threshold that was already reached, got %v", notifier.notifications)
+	}
+	addEntry(9, 1)
+	if len(notifier.notifications) != 2 || notifier.notifications[1].Threshold != 100 {
+		t.Errorf("Expected a notification for the 100%% threshold, got %v", notifier.notifications)
+	}
+	// A new month starts a new period
+	addEntry(31+4, 1)
+	if len(notifier.notifications) != 2 {
+		t.Errorf("Expected no notification in a new period, got %v", notifier.notifications)
+	}
+
+	var budget api.BudgetStatus
+	status = doRequest(t, router, "alice", "GET", "/projects/acme/budget?at=2019-01-15T00:00:00Z", nil, &budget)
+	if status != 200 || budget.Hours != 5*time.Hour+30*time.Minute || budget.Amount != 550 || budget.RemainingAmount != -50 ||
+		budget.RemainingHours != 4*time.Hour+30*time.Minute || budget.Used != 110 || len(budget.Reached) != 2 {
+		t.Errorf("Unexpected budget status (%d): %+v", status, budget)
+	}
+	if budget.From == nil || !budget.From.Equal(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the period to start on the first of the month, got %v", budget.From)
+	}
+	budget = api.BudgetStatus{}
+	doRequest(t, router, "alice", "GET", "/projects/acme/budget?at=2019-02-15T00:00:00Z", nil, &budget)
+	if budget.Hours != time.Hour || len(budget.Reached) != 0 {
+		t.Errorf("Unexpected budget status for February: %+v", budget)
+	}
+}
+
+func TestArchivedProjects(t *testing.T) {
+	router := newTestRouter()
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "old"}, nil)
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	doRequest(t, router, "alice", "POST", "/projects/old/entries", &api.TimeEntry{Type: "work", Start: &start, End: &end}, nil)
+
+	var project api.Project
+	status := doRequest(t, router, "alice", "POST", "/projects/old/archive", nil, &project)
+	if status != 200 || !project.Archived {
+		t.Errorf("Expected the project to be archived (%d): %v", status, project)
+	}
+
+	var projects []*api.Project
+	doRequest(t, router, "alice", "GET", "/projects", nil, &projects)
+	if len(projects) != 1 || projects[0].Name != "acme" {
+		t.Errorf("Expected only the active project to be listed, got %v", projects)
+	}
+	doRequest(t, router, "alice", "GET", "/projects?archived=true", nil, &projects)
+	if len(projects) != 1 || projects[0].Name != "old" {
+		t.Errorf("Expected only the archived project to be listed, got %v", projects)
+	}
+	doRequest(t, router, "alice", "GET", "/projects?archived=all", nil, &projects)
+	if len(projects) != 2 {
+		t.Errorf("Expected all projects to be listed, got %v", projects)
+	}
+	if status := doRequest(t, router, "alice", "GET", "/projects?archived=maybe", nil, nil); status != 400 {
+		t.Errorf("Expected 400 for an invalid archived parameter, got %d", status)
+	}
+
+	later := end.Add(time.Hour)
+	laterEnd := later.Add(time.Hour)
+	if status := doRequest(t, router, "alice", "POST", "/projects/old/entries", &api.TimeEntry{Type: "work", Start: &later, End: &laterEnd}, nil); status != 409 {
+		t.Errorf("Expected 409 when adding an entry to an archived project, got %d", status)
+	}
+	if status := doRequest(t, router, "alice", "POST", "/entries:batch", []*api.TimeEntry{{ProjectName: "old", Type: "work", Start: &later, End: &laterEnd}}, nil); status != 409 {
+		t.Errorf("Expected 409 when adding a batch to an archived project, got %d", status)
+	}
+	if status := doRequest(t, router, "alice", "POST", "/timer", &api.TimeEntry{ProjectName: "old"}, nil); status != 409 {
+		t.Errorf("Expected 409 when starting a timer for an archived project, got %d", status)
+	}
+
+	// Archiving keeps the entries in reports
+	var summary api.Summary
+	doRequest(t, router, "alice", "GET", "/reports/summary?group_by=project", nil, &summary)
+	if len(summary.Groups) != 1 || summary.Groups[0].Key != "old" || summary.Groups[0].Worked != 2*time.Hour {
+		t.Errorf("Expected the archived project in the report, got %v", summary.Groups)
+	}
+
+	// Updates leave the archived state alone
+	status = doRequest(t, router, "alice", "PUT", "/projects/old", &api.Project{Name: "old", Description: "Done"}, &project)
+	if status != 200 || !project.Archived {
+		t.Errorf("Expected the project to stay archived (%d): %v", status, project)
+	}
+
+	status = doRequest(t, router, "alice", "POST", "/projects/old/unarchive", nil, &project)
+	if status != 200 || project.Archived {
+		t.Errorf("Expected the project to be active again (%d): %v", status, project)
+	}
+	if status := doRequest(t, router, "alice", "POST", "/projects/old/entries", &api.TimeEntry{Type: "work", Start: &later, End: &laterEnd}, nil); status != 200 {
+		t.Errorf("Expected entries to be added to an unarchived project, got %d", status)
+	}
+}
+
+func TestProjectRename(t *testing.T) {
+	router := newTestRouter()
+	var project api.Project
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, &project)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "other"}, nil)
+	if project.ID == "" {
+		t.Errorf("Expected the project to have an ID: %v", project)
+	}
+	id := project.ID
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &start, End: &end}, nil)
+
+	if status := doRequest(t, router, "alice", "PUT", "/projects/acme", &api.Project{Name: "other"}, nil); status != 400 {
+		t.Errorf("Expected 400 when renaming to an existing project, got %d", status)
+	}
+	status := doRequest(t, router, "alice", "PUT", "/projects/acme", &api.Project{Name: "acme-corp"}, &project)
+	if status != 200 || project.Name != "acme-corp" || project.ID != id || len(project.Aliases) != 1 || project.Aliases[0] != "acme" {
+		t.Errorf("Expected the project to be renamed with an alias (%d): %v", status, project)
+	}
+
+	// The former name still finds the project, with a hint about the new name
+	req := httptest.NewRequest("GET", "/projects/acme/entries", nil)
+	req.Header.Set(testSubjectHeader, "alice")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != 200 || !strings.Contains(rec.Header().Get("Warning"), "renamed to acme-corp") {
+		t.Errorf("Expected the entries by the former name with a warning (%d): %v", rec.Code, rec.Header())
+	}
+	var entries []*api.TimeEntry
+	json.Unmarshal(rec.Body.Bytes(), &entries)
+	if len(entries) != 1 || entries[0].ProjectName != "acme-corp" {
+		t.Errorf("Expected the entry under the new name, got %v", entries)
+	}
+
+	// So does the ID
+	status = doRequest(t, router, "alice", "GET", "/projects/"+url.QueryEscape(id), nil, &project)
+	if status != 200 || project.Name != "acme-corp" {
+		t.Errorf("Expected the project by ID (%d): %v", status, project)
+	}
+	later := end.Add(time.Hour)
+	laterEnd := later.Add(time.Hour)
+	if status := doRequest(t, router, "alice", "POST", "/entries:batch", []*api.TimeEntry{{ProjectName: "acme", Type: "work", Start: &later, End: &laterEnd}}, nil); status != 201 {
+		t.Errorf("Expected a batch by the former name to be added, got %d", status)
+	}
+
+	// A rename that is not saved leaves the aliases of other projects alone
+	doRequest(t, router, "alice", "PUT", "/projects/other", &api.Project{Name: "other-corp"}, nil)
+	if status := doRequest(t, router, "alice", "PUT", "/projects/acme-corp", &api.Project{Name: "other", Budget: &api.Budget{Hours: time.Hour, Period: "sometimes"}}, nil); status != 422 {
+		t.Errorf("Expected 422 for a rename with an invalid budget, got %d", status)
+	}
+	var other api.Project
+	doRequest(t, router, "alice", "GET", "/projects/other-corp", nil, &other)
+	if len(other.Aliases) != 1 || other.Aliases[0] != "other" {
+		t.Errorf("Expected the alias to be kept after a failed rename, got %v", other)
+	}
+
+	// A new project can take the former name, which then no longer finds the renamed one
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+	var renamed api.Project
+	doRequest(t, router, "alice", "GET", "/projects/acme-corp", nil, &renamed)
+	if renamed.Name != "acme-corp" || len(renamed.Aliases) != 0 {
+		t.Errorf("Expected the alias to be released, got %v", renamed)
+	}
+	doRequest(t, router, "alice", "GET", "/projects/acme/entries", nil, &entries)
+	if len(entries) != 0 {
+		t.Errorf("Expected no entries in the new project, got %v", entries)
+	}
+}
+
+func TestBalance(t *testing.T) {
+	router := newTestRouter()
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+
+	if status := doRequest(t, router, "alice", "GET", "/balance?from=2019-01-07&to=2019-01-11", nil, nil); status != 404 {
+		t.Errorf("Expected 404 without a schedule, got %d", status)
+	}
+	if status := doRequest(t, router, "alice", "PUT", "/schedules/2019-01-07", &api.Schedule{Hours: []time.Duration{8 * time.Hour}}, nil); status != 422 {
+		t.Errorf("Expected 422 for a schedule without all weekdays, got %d", status)
+	}
+	day := 8 * time.Hour
+	var schedule api.Schedule
+	status := doRequest(t, router, "alice", "PUT", "/schedules/2019-01-07", &api.Schedule{Hours: []time.Duration{day, day, day, day, day, 0, 0}}, &schedule)
+	if status != 200 || schedule.From != "2019-01-07" {
+		t.Errorf("Expected the schedule to be saved (%d): %v", status, schedule)
+	}
+
+	// Monday one hour over, Tuesday on vacation, Wednesday one hour short
+	work := func(date int, hours time.Duration) {
+		start := time.Date(2019, 1, date, 8, 0, 0, 0, time.UTC)
+		end := start.Add(hours)
+		doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &start, End: &end}, nil)
+	}
+	work(7, 9*time.Hour)
+	tuesday := time.Date(2019, 1, 8, 8, 0, 0, 0, time.UTC)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "vacation", Start: &tuesday, End: &tuesday}, nil)
+	work(9, 7*time.Hour)
+	work(10, 8*time.Hour)
+	work(11, 8*time.Hour)
+
+	var adjustment api.BalanceAdjustment
+	status = doRequest(t, router, "alice", "POST", "/balance/adjustments", &api.BalanceAdjustment{Date: "2019-01-10", Amount: 2 * time.Hour, Comment: "carried over"}, &adjustment)
+	if status != 201 || adjustment.ID == "" {
+		t.Errorf("Expected the adjustment to be added (%d): %v", status, adjustment)
+	}
+	if status := doRequest(t, router, "alice", "POST", "/balance/adjustments", &api.BalanceAdjustment{Date: "2019-01-10"}, nil); status != 422 {
+		t.Errorf("Expected 422 for an adjustment without an amount, got %d", status)
+	}
+
+	var balance api.Balance
+	status = doRequest(t, router, "alice", "GET", "/balance?from=2019-01-09&to=2019-01-11", nil, &balance)
+	if status != 200 || balance.Opening != time.Hour || len(balance.Periods) != 3 {
+		t.Fatalf("Expected an opening balance of 1h and three days (%d): %v", status, balance)
+	}
+	wednesday := balance.Periods[0]
+	if wednesday.Key != "2019-01-09" || wednesday.Expected != day || wednesday.Balance != -time.Hour || wednesday.Running != 0 {
+		t.Errorf("Unexpected balance for Wednesday: %v", wednesday)
+	}
+	if balance.Periods[1].Adjustments != 2*time.Hour || balance.Periods[1].Running != 2*time.Hour {
+		t.Errorf("Expected the adjustment on Thursday: %v", balance.Periods[1])
+	}
+	if balance.Total.Worked != 23*time.Hour || balance.Total.Running != 2*time.Hour {
+		t.Errorf("Unexpected total: %v", balance.Total)
+	}
+
+	var week api.Balance
+	doRequest(t, router, "alice", "GET", "/balance?from=2019-01-07&to=2019-01-13&group_by=week", nil, &week)
+	if len(week.Periods) != 1 || week.Opening != 0 || week.Total.Expected != 40*time.Hour || week.Total.Absence != day || week.Total.Running != 2*time.Hour {
+		t.Errorf("Unexpected weekly balance: %v %v", week.Periods, week.Total)
+	}
+	// No time is expected on holidays, and custom absence types can fulfill the schedule
+	doRequest(t, router, "alice", "PUT", "/holidays/2019-01-14", &api.Holiday{Name: "Founder's day"}, nil)
+	var parentalLeave api.EntryType
+	doRequest(t, router, "alice", "PUT", "/entry-types/parental-leave", &api.EntryType{FulfillsSchedule: true}, &parentalLeave)
+	if !parentalLeave.FulfillsSchedule {
+		t.Errorf("Expected parental leave to fulfill the schedule: %v", parentalLeave)
+	}
+	leave := time.Date(2019, 1, 15, 8, 0, 0, 0, time.UTC)
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "parental-leave", Start: &leave, End: &leave}, nil)
+	work(16, 8*time.Hour)
+	work(17, 8*time.Hour)
+	work(18, 8*time.Hour)
+	var next api.Balance
+	doRequest(t, router, "alice", "GET", "/balance?from=2019-01-14&to=2019-01-20&group_by=week", nil, &next)
+	if next.Opening != 2*time.Hour || next.Total.Expected != 32*time.Hour || next.Total.Absence != day || next.Total.Running != 2*time.Hour {
+		t.Errorf("Unexpected balance for a week with a holiday and parental leave: %v %v", next.Periods, next.Total)
+	}
+	if status := doRequest(t, router, "alice", "GET", "/balance?group_by=year", nil, nil); status != 400 {
+		t.Errorf("Expected 400 for an invalid grouping, got %d", status)
+	}
+	if status := doRequest(t, router, "alice", "GET", "/balance?from=2019-01-11&to=2019-01-07", nil, nil); status != 400 {
+		t.Errorf("Expected 400 for an inverted range, got %d", status)
+	}
+
+	if status := doRequest(t, router, "alice", "DELETE", "/balance/adjustments/unknown", nil, nil); status != 404 {
+		t.Errorf("Expected 404 for an unknown adjustment, got %d", status)
+	}
+	if status := doRequest(t, router, "alice", "DELETE", "/balance/adjustments/"+url.QueryEscape(adjustment.ID), nil, nil); status != 204 {
+		t.Errorf("Expected the adjustment to be deleted, got %d", status)
+	}
+	if status := doRequest(t, router, "alice", "DELETE", "/schedules/2019-01-07", nil, nil); status != 204 {
+		t.Errorf("Expected the schedule to be deleted, got %d", status)
+	}
+	var schedules []*api.Schedule
+	doRequest(t, router, "alice", "GET", "/schedules", nil, &schedules)
+	if len(schedules) != 0 {
+		t.Errorf("Expected no schedules, got %v", schedules)
+	}
+}
+
+func TestAllowances(t *testing.T) {
+	router := newTestRouter()
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+
+	if status := doRequest(t, router, "alice", "PUT", "/allowances/work/2019", &api.Allowance{Days: 3}, nil); status != 422 {
+		t.Errorf("Expected 422 for an allowance of a time-based type, got %d", status)
+	}
+	if status := doRequest(t, router, "alice", "PUT", "/allowances/vacation/next", &api.Allowance{Days: 3}, nil); status != 400 {
+		t.Errorf("Expected 400 for an invalid year, got %d", status)
+	}
+	var allowance api.Allowance
+	status := doRequest(t, router, "alice", "PUT", "/allowances/vacation/2019", &api.Allowance{Days: 3, CarryOver: 2}, &allowance)
+	if status != 200 || allowance.Type != "vacation" || allowance.From != 2019 || allowance.Days != 3 {
+		t.Errorf("Expected the allowance to be saved (%d): %v", status, allowance)
+	}
+	doRequest(t, router, "alice", "PUT", "/allowances/sick-child/2019", &api.Allowance{Days: 10, CarryOver: 2}, nil)
+	if status := doRequest(t, router, "alice", "PUT", "/holidays/2019-01-09", &api.Holiday{Name: "Founder's day"}, nil); status != 200 {
+		t.Errorf("Expected the holiday to be saved, got %d", status)
+	}
+
+	addVacation := func(from int, to int) *httptest.ResponseRecorder {
+		start := time.Date(2019, 1, from, 8, 0, 0, 0, time.UTC)
+		end := time.Date(2019, 1, to, 8, 0, 0, 0, time.UTC)
+		body, _ := json.Marshal(&api.TimeEntry{Type: "vacation", Start: &start, End: &end})
+		req := httptest.NewRequest("POST", "/projects/acme/entries", bytes.NewReader(body))
+		req.Header.Set(testSubjectHeader, "alice")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := addVacation(7, 7); rec.Code != 200 || rec.Header().Get("Warning") != "" {
+		t.Errorf("Expected a vacation day within the allowance (%d): %v", rec.Code, rec.Header())
+	}
+	// Tuesday to Sunday uses three days, as Wednesday is a holiday
+	rec := addVacation(8, 13)
+	if rec.Code != 200 || !strings.Contains(rec.Header().Get("Warning"), "vacation allowance for 2019 is exceeded by 1 days") {
+		t.Errorf("Expected the entry to be added with a warning (%d): %v", rec.Code, rec.Header())
+	}
+	// Allowance years are taken in the tz parameter, which must be valid
+	batchStart := time.Date(2019, 1, 14, 8, 0, 0, 0, time.UTC)
+	batchEnd := batchStart.AddDate(0, 0, 1)
+	if status := doRequest(t, router, "alice", "POST", "/entries:batch?tz=Mars/Olympus", []*api.TimeEntry{{ProjectName: "acme", Type: "vacation", Start: &batchStart, End: &batchEnd}}, nil); status != 400 {
+		t.Errorf("Expected 400 for an unknown time zone, got %d", status)
+	}
+
+	var statuses []*api.AllowanceStatus
+	doRequest(t, router, "alice", "GET", "/allowances/remaining?year=2019", nil, &statuses)
+	if len(statuses) != 2 || statuses[0].Type != "sick-child" || statuses[1].Used != 4 || statuses[1].Remaining != -1 {
+		t.Errorf("Unexpected allowances for 2019: %v", statuses)
+	}
+	// Unused days are carried over up to the limit, overdrawn ones are not
+	var next []*api.AllowanceStatus
+	doRequest(t, router, "alice", "GET", "/allowances/remaining?year=2020", nil, &next)
+	if len(next) != 2 || next[0].CarriedOver != 2 || next[0].Remaining != 12 || next[1].CarriedOver != 0 || next[1].Remaining != 3 {
+		t.Errorf("Unexpected allowances for 2020: %v", next)
+	}
+	var before []*api.AllowanceStatus
+	doRequest(t, router, "alice", "GET", "/allowances/remaining?year=2018", nil, &before)
+	if len(before) != 0 {
+		t.Errorf("Expected no allowances before 2019, got %v", before)
+	}
+
+	if status := doRequest(t, router, "alice", "DELETE", "/holidays/2019-01-10", nil, nil); status != 404 {
+		t.Errorf("Expected 404 for an unknown holiday, got %d", status)
+	}
+	if status := doRequest(t, router, "alice", "DELETE", "/holidays/2019-01-09", nil, nil); status != 204 {
+		t.Errorf("Expected the holiday to be deleted, got %d", status)
+	}
+	doRequest(t, router, "alice", "GET", "/allowances/remaining?year=2019", nil, &statuses)
+	if len(statuses) != 2 || statuses[1].Used != 5 {
+		t.Errorf("Expected the former holiday to count as used, got %v", statuses)
+	}
+	if status := doRequest(t, router, "alice", "DELETE", "/allowances/vacation/2019", nil, nil); status != 204 {
+		t.Errorf("Expected the allowance to be deleted, got %d", status)
+	}
+	var allowances []*api.Allowance
+	doRequest(t, router, "alice", "GET", "/allowances", nil, &allowances)
+	if len(allowances) != 1 || allowances[0].Type != "sick-child" {
+		t.Errorf("Expected only the sick-child allowance to be left, got %v", allowances)
+	}
+}
+
+func TestMoveTimeEntry(t *testing.T) {
+	router := newTestRouter()
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "internal"}, nil)
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	var entry api.TimeEntry
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &start, End: &end}, &entry)
+
+	// Updating an entry in another project moves it there, without overlapping itself
+	var moved api.TimeEntry
+	status := doRequest(t, router, "alice", "PUT", "/projects/internal/entries/"+url.QueryEscape(entry.ID), &api.TimeEntry{Type: "work", Start: &start, End: &end, Comment: "moved"}, &moved)
+	if status != 200 || moved.ID != entry.ID || moved.ProjectName != "internal" {
+		t.Errorf("Expected the entry to be moved (%d): %v", status, moved)
+	}
+	var entries []*api.TimeEntry
+	doRequest(t, router, "alice", "GET", "/projects/acme/entries", nil, &entries)
+	if len(entries) != 0 {
+		t.Errorf("Expected no entries left in the old project, got %v", entries)
+	}
+	doRequest(t, router, "alice", "GET", "/projects/internal/entries", nil, &entries)
+	if len(entries) != 1 || entries[0].Comment != "moved" {
+		t.Errorf("Expected the entry in the new project, got %v", entries)
+	}
+}
+
+func TestGetUserTimeEntry(t *testing.T) {
+	router := newTestRouter()
+	doRequest(t, router, "alice", "POST", "/projects", &api.Project{Name: "acme"}, nil)
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	var entry api.TimeEntry
+	doRequest(t, router, "alice", "POST", "/projects/acme/entries", &api.TimeEntry{Type: "work", Start: &start, End: &end, Comment: "design"}, &entry)
+
+	// Entries are found by ID without naming the project
+	var found api.TimeEntry
+	status := doRequest(t, router, "alice", "GET", "/entries/"+url.QueryEscape(entry.ID), nil, &found)
+	if status != 200 || found.ID != entry.ID || found.ProjectName != "acme" || found.Comment != "design" {
+		t.Errorf("Expected the entry (%d): %v", status, found)
+	}
+
+	// Other users' entries are not found
+	status = doRequest(t, router, "bob", "GET", "/entries/"+url.QueryEscape(entry.ID), nil, nil)
+	if status != 404 {
+		t.Errorf("Expected 404 for another user's entry, got %d", status)
+	}
+}
@@ -0,0 +1,213 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/kennep/timelapse/domain"
+)
+
+func (s *apiServer) listSchedules(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	schedules, err := user.GetSchedules()
+	if err != nil {
+		internalError(rw, r, err, "Could not get schedules")
+		return
+	}
+
+	jsonResponse(rw, r, 200, mapSchedulesToApi(schedules))
+}
+
+func (s *apiServer) saveSchedule(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	var apiSchedule api.Schedule
+	err := jsonRequest(rw, r, &apiSchedule)
+	if err != nil {
+		return
+	}
+	schedule := mapApiToSchedule(&apiSchedule)
+	schedule.From = chi.URLParam(r, "from")
+
+	if err := user.SaveSchedule(schedule); err != nil {
+		entryError(rw, r, err, "Error while saving schedule")
+		return
+	}
+
+	jsonResponse(rw, r, 200, mapScheduleToApi(schedule))
+}
+
+func (s *apiServer) deleteSchedule(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	from := chi.URLParam(r, "from")
+	schedules, err := user.GetSchedules()
+	if err != nil {
+		internalError(rw, r, err, "Could not get schedules")
+		return
+	}
+	found := false
+	for _, schedule := range schedules {
+		found = found || schedule.From == from
+	}
+	if !found {
+		notFoundError(rw, r, fmt.Sprintf("Schedule not found: %s", from))
+		return
+	}
+
+	if err := user.DeleteSchedule(from); err != nil {
+		internalError(rw, r, err, "Error while deleting schedule")
+		return
+	}
+	rw.WriteHeader(204)
+}
+
+func (s *apiServer) listBalanceAdjustments(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	adjustments, err := user.GetBalanceAdjustments()
+	if err != nil {
+		internalError(rw, r, err, "Could not get balance adjustments")
+		return
+	}
+
+	jsonResponse(rw, r, 200, mapBalanceAdjustmentsToApi(adjustments))
+}
+
+func (s *apiServer) addBalanceAdjustment(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	var apiAdjustment api.BalanceAdjustment
+	err := jsonRequest(rw, r, &apiAdjustment)
+	if err != nil {
+		return
+	}
+
+	adjustment, err := user.AddBalanceAdjustment(mapApiToBalanceAdjustment(&apiAdjustment))
+	if err != nil {
+		entryError(rw, r, err, "Error while adding balance adjustment")
+		return
+	}
+
+	jsonResponse(rw, r, 201, mapBalanceAdjustmentToApi(adjustment))
+}
+
+func (s *apiServer) deleteBalanceAdjustment(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	adjustmentID, err := url.PathUnescape(chi.URLParam(r, "adjustmentID"))
+	if err != nil {
+		validationError(rw, r, err.Error())
+		return
+	}
+	adjustments, err := user.GetBalanceAdjustments()
+	if err != nil {
+		internalError(rw, r, err, "Could not get balance adjustments")
+		return
+	}
+	found := false
+	for _, adjustment := range adjustments {
+		found = found || adjustment.ID == adjustmentID
+	}
+	if !found {
+		notFoundError(rw, r, fmt.Sprintf("Balance adjustment not found: %s", adjustmentID))
+		return
+	}
+
+	if err := user.DeleteBalanceAdjustment(adjustmentID); err != nil {
+		internalError(rw, r, err, "Error while deleting balance adjustment")
+		return
+	}
+	rw.WriteHeader(204)
+}
+
+// getBalance computes the flex balance for the days in the from and to query parameters,
+// formatted as YYYY-MM-DD, in the time zone of the tz parameter. The range defaults to
+// the current month up to and including today, and the periods to days.
+func (s *apiServer) getBalance(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	loc := getLocationFromURL(rw, r, domain.EntryQuery{})
+	if loc == nil {
+		return
+	}
+	params := r.URL.Query()
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	for _, param := range []struct {
+		name string
+		dest *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := params.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.ParseInLocation(domain.ScheduleDateFormat, value, loc)
+		if err != nil {
+			validationError(rw, r, fmt.Sprintf("invalid date in %s parameter: %s", param.name, value))
+			return
+		}
+		*param.dest = t
+	}
+	if to.Before(from) {
+		validationError(rw, r, "to must not be before from")
+		return
+	}
+	groupBy := params.Get("group_by")
+	if groupBy == "" {
+		groupBy = domain.GroupByDay
+	}
+	if !isGroupByOption(groupBy) {
+		validationError(rw, r, fmt.Sprintf("invalid group_by parameter: %s", groupBy))
+		return
+	}
+
+	balance, err := user.Balance(from, to, groupBy, loc)
+	if err != nil {
+		internalError(rw, r, err, "Could not compute the balance")
+		return
+	}
+	if balance == nil {
+		notFoundError(rw, r, "No work schedule has been set")
+		return
+	}
+	jsonResponse(rw, r, 200, mapBalanceToApi(balance))
+}
+
+// isGroupByOption tells whether groupBy is one of domain.BalanceGroupByOptions
+func isGroupByOption(groupBy string) bool {
+	for _, option := range domain.BalanceGroupByOptions {
+		if option == groupBy {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package endpoints
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/kennep/timelapse/domain"
+	log "github.com/sirupsen/logrus"
+)
+
+// budgetNotifier is told when recorded time makes a project's budget reach one of its
+// thresholds
+type budgetNotifier interface {
+	budgetReached(notification *api.BudgetNotification)
+}
+
+// logNotifier logs budget notifications. It is used when no webhook is configured.
+type logNotifier struct{}
+
+func (logNotifier) budgetReached(notification *api.BudgetNotification) {
+	log.WithFields(log.Fields{
+		"userid":    notification.UserID,
+		"project":   notification.Status.Project,
+		"threshold": notification.Threshold,
+	}).Warnf("Budget threshold reached: %s", notification.Status)
+}
+
+// webhookNotifier posts budget notifications as JSON to a URL. Notifications are sent in
+// the background, so that a slow webhook does not hold up requests; failures are logged.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n *webhookNotifier) budgetReached(notification *api.BudgetNotification) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		log.Errorf("Could not encode budget notification: %s", err)
+		return
+	}
+	go func() {
+		resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.WithFields(log.Fields{"url": n.url}).Errorf("Could not send budget notification: %s", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.WithFields(log.Fields{"url": n.url}).Errorf("Budget webhook returned %s", resp.Status)
+		}
+	}()
+}
+
+// newBudgetNotifier returns a notifier that posts to the given webhook URL, or one that
+// logs notifications if the URL is empty
+func newBudgetNotifier(webhookURL string) budgetNotifier {
+	if webhookURL == "" {
+		return logNotifier{}
+	}
+	return &webhookNotifier{url: webhookURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// checkBudget notifies the thresholds of the project budget that storing the entry made
+// the budget reach, given the previous version of the entry, or nil for new entries.
+// Dates are taken in loc. The entry is already stored, so problems are logged rather than
+// failing the request.
+func (s *apiServer) checkBudget(r *http.Request, loc *time.Location, user *domain.User, entry *domain.TimeEntry, previous *domain.TimeEntry) {
+	if entry == nil {
+		return
+	}
+
+	status, crossed, err := user.CheckBudget(entry, previous, loc)
+	if err != nil {
+		fields := RequestFields(r)
+		fields["error"] = err
+		log.WithFields(fields).Errorf("Could not check the budget of project %s", entry.Project.Name)
+		return
+	}
+	for _, threshold := range crossed {
+		s.notifier.budgetReached(&api.BudgetNotification{
+			UserID:    user.ID,
+			Threshold: threshold,
+			Status:    mapBudgetStatusToApi(entry.Project, status),
+		})
+	}
+}
+
+// checkBudgets checks the budgets for new entries, see checkBudget
+func (s *apiServer) checkBudgets(r *http.Request, loc *time.Location, user *domain.User, entries ...*domain.TimeEntry) {
+	for _, entry := range entries {
+		s.checkBudget(r, loc, user, entry, nil)
+	}
+}
+
+// getBudgetStatus shows how much of a project's budget is used in the period that
+// contains the time in the at query parameter, or now
+func (s *apiServer) getBudgetStatus(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	project := s.getProjectFromURL(rw, r, user)
+	if project == nil {
+		return
+	}
+	if project.Budget == nil {
+		notFoundError(rw, r, fmt.Sprintf("Project %s has no budget", project.Name))
+		return
+	}
+
+	at := time.Now()
+	if value := r.URL.Query().Get("at"); value != "" {
+		var err error
+		at, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			validationError(rw, r, fmt.Sprintf("invalid time in at parameter: %s", value))
+			return
+		}
+	}
+	loc := getLocationFromURL(rw, r, domain.EntryQuery{From: &at})
+	if loc == nil {
+		return
+	}
+
+	status, err := project.BudgetStatus(at, loc)
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not get the budget status of project %s", project.Name))
+		return
+	}
+	jsonResponse(rw, r, 200, mapBudgetStatusToApi(project, status))
+}
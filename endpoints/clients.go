@@ -0,0 +1,190 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/kennep/timelapse/domain"
+)
+
+// getClientNames returns the names of the user's clients by ID. If the clients cannot be
+// loaded, an error response is emitted and false is returned.
+func getClientNames(rw http.ResponseWriter, r *http.Request, user *domain.User) (map[string]string, bool) {
+	clients, err := user.GetClients()
+	if err != nil {
+		internalError(rw, r, err, "Could not get clients")
+		return nil, false
+	}
+	names := make(map[string]string)
+	for _, client := range clients {
+		names[client.ID] = client.Name
+	}
+	return names, true
+}
+
+// getClientID returns the ID of the named client, or an empty string if no name is given.
+// If there is no such client, an error response is emitted and false is returned.
+func getClientID(rw http.ResponseWriter, r *http.Request, user *domain.User, clientName string) (string, bool) {
+	if clientName == "" {
+		return "", true
+	}
+	client, err := user.GetClient(clientName)
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not get client by name %s", clientName))
+		return "", false
+	}
+	if client == nil {
+		validationError(rw, r, fmt.Sprintf("Client not found: %s", clientName))
+		return "", false
+	}
+	return client.ID, true
+}
+
+// getClientFromURL looks up the client named in the URL. If the client cannot be
+// found, an error response is emitted and nil is returned.
+func (s *apiServer) getClientFromURL(rw http.ResponseWriter, r *http.Request, user *domain.User) *domain.Client {
+	clientName, err := url.QueryUnescape(chi.URLParam(r, "clientName"))
+	if err != nil {
+		validationError(rw, r, err.Error())
+		return nil
+	}
+	if clientName == "" {
+		validationError(rw, r, "client name in URL cannot be blank")
+		return nil
+	}
+
+	client, err := user.GetClient(clientName)
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not get client by name %s", clientName))
+		return nil
+	}
+	if client == nil {
+		notFoundError(rw, r, fmt.Sprintf("Client not found: %s", clientName))
+		return nil
+	}
+	return client
+}
+
+func (s *apiServer) addClient(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	var apiClient api.Client
+	err := jsonRequest(rw, r, &apiClient)
+	if err != nil {
+		return
+	}
+	var client domain.Client
+	mapApiToClientDest(&apiClient, &client)
+
+	existing, err := user.GetClient(client.Name)
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not get client by name %s", client.Name))
+		return
+	}
+	if existing != nil {
+		validationError(rw, r, fmt.Sprintf("A client with this name already exists: %s", client.Name))
+		return
+	}
+
+	clientResult, err := user.AddClient(&client)
+	if err != nil {
+		entryError(rw, r, err, fmt.Sprintf("Could not create client %s", client.Name))
+		return
+	}
+	jsonResponse(rw, r, 201, mapClientToApi(clientResult))
+}
+
+func (s *apiServer) listClients(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	clients, err := user.GetClients()
+	if err != nil {
+		internalError(rw, r, err, "Could not list clients")
+		return
+	}
+	jsonResponse(rw, r, 200, mapClientsToApi(clients))
+}
+
+func (s *apiServer) getClient(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	client := s.getClientFromURL(rw, r, user)
+	if client == nil {
+		return
+	}
+	jsonResponse(rw, r, 200, mapClientToApi(client))
+}
+
+func (s *apiServer) updateClient(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	client := s.getClientFromURL(rw, r, user)
+	if client == nil {
+		return
+	}
+	clientName := client.Name
+
+	var apiClient api.Client
+	err := jsonRequest(rw, r, &apiClient)
+	if err != nil {
+		return
+	}
+	if apiClient.Name != clientName {
+		existing, err := user.GetClient(apiClient.Name)
+		if err != nil {
+			internalError(rw, r, err, fmt.Sprintf("Could not get client by name %s", apiClient.Name))
+			return
+		}
+		if existing != nil {
+			validationError(rw, r, fmt.Sprintf("A client with this name already exists: %s", apiClient.Name))
+			return
+		}
+	}
+
+	mapApiToClientDest(&apiClient, client)
+	if err := client.Save(); err != nil {
+		entryError(rw, r, err, fmt.Sprintf("Could not update client %s", clientName))
+		return
+	}
+	jsonResponse(rw, r, 200, mapClientToApi(client))
+}
+
+func (s *apiServer) deleteClient(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	client := s.getClientFromURL(rw, r, user)
+	if client == nil {
+		return
+	}
+
+	err := client.Delete()
+	if err == domain.ErrClientHasProjects {
+		conflictError(rw, r, fmt.Sprintf("Client %s has projects - move them to another client first", client.Name))
+		return
+	}
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not delete client %s", client.Name))
+		return
+	}
+
+	rw.WriteHeader(204)
+}
@@ -0,0 +1,88 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/kennep/timelapse/domain"
+)
+
+// getEntryTypes returns the user's entry types. If they cannot be loaded, an error response
+// is emitted and false is returned.
+func getEntryTypes(rw http.ResponseWriter, r *http.Request, user *domain.User) (domain.EntryTypeSet, bool) {
+	types, err := user.EntryTypeSet()
+	if err != nil {
+		internalError(rw, r, err, "Could not get entry types")
+		return nil, false
+	}
+	return types, true
+}
+
+func (s *apiServer) listEntryTypes(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	entryTypes, err := user.GetEntryTypes()
+	if err != nil {
+		internalError(rw, r, err, "Could not get entry types")
+		return
+	}
+
+	jsonResponse(rw, r, 200, mapEntryTypesToApi(entryTypes))
+}
+
+func (s *apiServer) saveEntryType(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	var apiEntryType api.EntryType
+	err := jsonRequest(rw, r, &apiEntryType)
+	if err != nil {
+		return
+	}
+	entryType := mapApiToEntryType(&apiEntryType)
+	entryType.Name = chi.URLParam(r, "typeName")
+
+	if err := user.SaveEntryType(entryType); err != nil {
+		entryError(rw, r, err, "Error while saving entry type")
+		return
+	}
+
+	jsonResponse(rw, r, 200, mapEntryTypeToApi(entryType))
+}
+
+func (s *apiServer) deleteEntryType(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	typeName := chi.URLParam(r, "typeName")
+	types, ok := getEntryTypes(rw, r, user)
+	if !ok {
+		return
+	}
+	if types.Get(typeName) == nil {
+		notFoundError(rw, r, fmt.Sprintf("Entry type not found: %s", typeName))
+		return
+	}
+
+	err := user.DeleteEntryType(typeName)
+	switch err {
+	case nil:
+		rw.WriteHeader(204)
+	case domain.ErrDefaultEntryType:
+		conflictError(rw, r, fmt.Sprintf("%s is a default entry type and cannot be deleted", typeName))
+	case domain.ErrEntryTypeInUse:
+		conflictError(rw, r, fmt.Sprintf("Entry type %s has time entries - change their type first", typeName))
+	default:
+		internalError(rw, r, err, "Error while deleting entry type")
+	}
+}
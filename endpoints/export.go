@@ -0,0 +1,293 @@
+package endpoints
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/kennep/timelapse/domain"
+)
+
+const feedSuffix = ".ics"
+
+// exportContext holds what the columns of an export need besides the entry
+type exportContext struct {
+	loc       *time.Location
+	types     domain.EntryTypeSet
+	taskNames map[string]string
+}
+
+// exportColumn renders one column of the csv export for a time entry
+type exportColumn func(e *domain.TimeEntry, ctx *exportContext) string
+
+func exportTime(t *time.Time, loc *time.Location) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(loc).Format(time.RFC3339)
+}
+
+func exportHours(d time.Duration) string {
+	return strconv.FormatFloat(d.Hours(), 'f', 2, 64)
+}
+
+var exportColumns = map[string]exportColumn{
+	"id": func(e *domain.TimeEntry, ctx *exportContext) string {
+		return e.ID
+	},
+	"project": func(e *domain.TimeEntry, ctx *exportContext) string {
+		if e.Project == nil {
+			return ""
+		}
+		return e.Project.Name
+	},
+	"task": func(e *domain.TimeEntry, ctx *exportContext) string {
+		return ctx.taskNames[e.TaskID]
+	},
+	"billable": func(e *domain.TimeEntry, ctx *exportContext) string {
+		if e.Project == nil {
+			return ""
+		}
+		return strconv.FormatBool(e.Project.Billable)
+	},
+	"type": func(e *domain.TimeEntry, ctx *exportContext) string {
+		return e.Type
+	},
+	"date": func(e *domain.TimeEntry, ctx *exportContext) string {
+		if e.Start == nil {
+			return ""
+		}
+		return e.Start.In(ctx.loc).Format("2006-01-02")
+	},
+	"start": func(e *domain.TimeEntry, ctx *exportContext) string {
+		return exportTime(e.Start, ctx.loc)
+	},
+	"end": func(e *domain.TimeEntry, ctx *exportContext) string {
+		return exportTime(e.End, ctx.loc)
+	},
+	"breaks": func(e *domain.TimeEntry, ctx *exportContext) string {
+		return exportHours(e.Breaks)
+	},
+	"hours": func(e *domain.TimeEntry, ctx *exportContext) string {
+		if !ctx.types.IsTimeBased(e.Type) {
+			return ""
+		}
+		return exportHours(e.Duration())
+	},
+	"days": func(e *domain.TimeEntry, ctx *exportContext) string {
+		if ctx.types.IsTimeBased(e.Type) {
+			return ""
+		}
+		return strconv.Itoa(e.Days())
+	},
+	"comment": func(e *domain.TimeEntry, ctx *exportContext) string {
+		return e.Comment
+	},
+	"tags": func(e *domain.TimeEntry, ctx *exportContext) string {
+		return strings.Join(e.Tags, ",")
+	},
+}
+
+var defaultExportColumns = []string{"date", "project", "type", "start", "end", "breaks", "hours", "days", "comment"}
+
+// getExportColumnsFromURL returns the csv columns from the comma separated columns query
+// parameter. If a column is unknown, an error response is emitted and nil is returned.
+func getExportColumnsFromURL(rw http.ResponseWriter, r *http.Request) []string {
+	param := r.URL.Query().Get("columns")
+	if param == "" {
+		return defaultExportColumns
+	}
+
+	columns := strings.Split(param, ",")
+	for idx, column := range columns {
+		columns[idx] = strings.TrimSpace(column)
+		if exportColumns[columns[idx]] == nil {
+			validationError(rw, r, fmt.Sprintf("unknown column: %s", column))
+			return nil
+		}
+	}
+	return columns
+}
+
+func formatCSV(entries []*domain.TimeEntry, columns []string, ctx *exportContext) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write(columns); err != nil {
+		return nil, err
+	}
+	row := make([]string, len(columns))
+	for _, entry := range entries {
+		for idx, column := range columns {
+			row[idx] = exportColumns[column](entry, ctx)
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fileResponse(rw http.ResponseWriter, contentType string, fileName string, body []byte) {
+	rw.Header().Set("Content-Type", contentType)
+	if fileName != "" {
+		rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	}
+	rw.WriteHeader(200)
+	rw.Write(body)
+}
+
+// getExportEntries returns the entries to export, using the same filters as when listing
+// entries, along with the time zone to present them in and the user's entry types. If the
+// query is invalid, an error response is emitted and false is returned.
+func (s *apiServer) getExportEntries(rw http.ResponseWriter, r *http.Request, user *domain.User) ([]*domain.TimeEntry, *exportContext, bool) {
+	q, ok := getEntryQueryFromURL(rw, r)
+	if !ok {
+		return nil, nil, false
+	}
+	// Exports always cover the whole range
+	q.Limit = 0
+	q.After = nil
+
+	loc := getLocationFromURL(rw, r, q)
+	if loc == nil {
+		return nil, nil, false
+	}
+
+	types, ok := getEntryTypes(rw, r, user)
+	if !ok {
+		return nil, nil, false
+	}
+
+	taskNames, ok := getTaskNames(rw, r, user)
+	if !ok {
+		return nil, nil, false
+	}
+
+	entries, ok := s.getReportEntries(rw, r, user, q)
+	return entries, &exportContext{loc: loc, types: types, taskNames: taskNames}, ok
+}
+
+func (s *apiServer) writeCalendar(rw http.ResponseWriter, r *http.Request, user *domain.User, fileName string) {
+	entries, ctx, ok := s.getExportEntries(rw, r, user)
+	if !ok {
+		return
+	}
+
+	fileResponse(rw, "text/calendar; charset=utf-8", fileName, formatICalendar(entries, ctx))
+}
+
+func (s *apiServer) exportTimeEntries(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "ics":
+		s.writeCalendar(rw, r, user, "timelapse.ics")
+		return
+	case "", "csv":
+	default:
+		validationError(rw, r, fmt.Sprintf("unknown export format: %s", format))
+		return
+	}
+
+	columns := getExportColumnsFromURL(rw, r)
+	if columns == nil {
+		return
+	}
+
+	entries, ctx, ok := s.getExportEntries(rw, r, user)
+	if !ok {
+		return
+	}
+
+	body, err := formatCSV(entries, columns, ctx)
+	if err != nil {
+		internalError(rw, r, err, "Could not write csv export")
+		return
+	}
+	fileResponse(rw, "text/csv; charset=utf-8", "timelapse.csv", body)
+}
+
+func feedPath(user *domain.User) string {
+	return "/feeds/" + user.FeedToken + feedSuffix
+}
+
+func (s *apiServer) getFeed(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	if user.FeedToken == "" {
+		notFoundError(rw, r, "Calendar feed is not enabled")
+		return
+	}
+
+	jsonResponse(rw, r, 200, &api.Feed{Path: feedPath(user)})
+}
+
+func (s *apiServer) resetFeed(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	if err := user.ResetFeedToken(); err != nil {
+		internalError(rw, r, err, "Could not reset calendar feed token")
+		return
+	}
+
+	jsonResponse(rw, r, 200, &api.Feed{Path: feedPath(user)})
+}
+
+func (s *apiServer) revokeFeed(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	if err := user.RevokeFeedToken(); err != nil {
+		internalError(rw, r, err, "Could not revoke calendar feed token")
+		return
+	}
+
+	rw.WriteHeader(204)
+}
+
+// getCalendarFeed serves the read-only calendar feed. It is reachable without
+// authentication - the token in the URL identifies the user.
+func (s *apiServer) getCalendarFeed(rw http.ResponseWriter, r *http.Request) {
+	feedFile := chi.URLParam(r, "feedFile")
+	if !strings.HasSuffix(feedFile, feedSuffix) {
+		notFoundError(rw, r, "Calendar feed not found")
+		return
+	}
+
+	user, err := s.users.GetUserByFeedToken(strings.TrimSuffix(feedFile, feedSuffix))
+	if err != nil {
+		internalError(rw, r, err, "Could not look up calendar feed token")
+		return
+	}
+	if user == nil {
+		notFoundError(rw, r, "Calendar feed not found")
+		return
+	}
+
+	s.writeCalendar(rw, r, user, "")
+}
@@ -0,0 +1,114 @@
+package endpoints
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kennep/timelapse/domain"
+)
+
+// Content lines longer than this many octets must be folded (RFC 5545, section 3.1)
+const icalLineLength = 75
+
+const (
+	icalDateTimeFormat = "20060102T150405Z"
+	icalDateFormat     = "20060102"
+)
+
+var icalTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+type icalWriter struct {
+	buf bytes.Buffer
+}
+
+// line writes a content line, folding it if it is too long
+func (w *icalWriter) line(name string, value string) {
+	contentLine := name + ":" + value
+	limit := icalLineLength
+	for len(contentLine) > limit {
+		cut := limit
+		for !utf8.RuneStart(contentLine[cut]) {
+			cut--
+		}
+		w.buf.WriteString(contentLine[:cut])
+		w.buf.WriteString("\r\n ")
+		contentLine = contentLine[cut:]
+		// The leading space of a continuation line counts towards its length
+		limit = icalLineLength - 1
+	}
+	w.buf.WriteString(contentLine)
+	w.buf.WriteString("\r\n")
+}
+
+func (w *icalWriter) text(name string, value string) {
+	w.line(name, icalTextEscaper.Replace(value))
+}
+
+// entrySummary is the event title: the project name and comment for work, and the
+// entry type for other entries
+func entrySummary(entry *domain.TimeEntry, types domain.EntryTypeSet) string {
+	projectName := ""
+	if entry.Project != nil {
+		projectName = entry.Project.Name
+	}
+	if !types.IsWork(entry.Type) {
+		return fmt.Sprintf("%s (%s)", entry.Type, projectName)
+	}
+	if entry.Comment != "" {
+		return fmt.Sprintf("%s: %s", projectName, entry.Comment)
+	}
+	return projectName
+}
+
+func (w *icalWriter) event(entry *domain.TimeEntry, ctx *exportContext, stamp time.Time) {
+	w.line("BEGIN", "VEVENT")
+	w.text("UID", entry.ID+"@timelapse")
+	w.line("DTSTAMP", stamp.UTC().Format(icalDateTimeFormat))
+	if ctx.types.IsTimeBased(entry.Type) {
+		w.line("DTSTART", entry.Start.UTC().Format(icalDateTimeFormat))
+		if entry.End != nil {
+			w.line("DTEND", entry.End.UTC().Format(icalDateTimeFormat))
+		}
+	} else {
+		// Whole-day entries are all-day events. The end date is exclusive.
+		end := entry.Start
+		if entry.End != nil {
+			end = entry.End
+		}
+		w.line("DTSTART;VALUE=DATE", entry.Start.In(ctx.loc).Format(icalDateFormat))
+		w.line("DTEND;VALUE=DATE", end.In(ctx.loc).AddDate(0, 0, 1).Format(icalDateFormat))
+		w.line("TRANSP", "TRANSPARENT")
+	}
+	w.text("SUMMARY", entrySummary(entry, ctx.types))
+	if entry.Comment != "" {
+		w.text("DESCRIPTION", entry.Comment)
+	}
+	w.text("CATEGORIES", entry.Type)
+	w.line("END", "VEVENT")
+}
+
+// formatICalendar renders time entries as an RFC 5545 calendar with one event per entry.
+// Entries without a start time are left out.
+func formatICalendar(entries []*domain.TimeEntry, ctx *exportContext) []byte {
+	var w icalWriter
+	stamp := time.Now()
+
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", "-//timelapse//timelapse//EN")
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("METHOD", "PUBLISH")
+	w.text("X-WR-CALNAME", "Timelapse")
+	for _, entry := range entries {
+		if entry.Start == nil {
+			continue
+		}
+		w.event(entry, ctx, stamp)
+	}
+	w.line("END", "VCALENDAR")
+
+	return w.buf.Bytes()
+}
@@ -0,0 +1,89 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/kennep/timelapse/domain"
+)
+
+func (s *apiServer) createInvoice(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	var apiRequest api.InvoiceRequest
+	err := jsonRequest(rw, r, &apiRequest)
+	if err != nil {
+		return
+	}
+
+	loc := getLocationFromURL(rw, r, domain.EntryQuery{From: apiRequest.From})
+	if loc == nil {
+		return
+	}
+	req := domain.InvoiceRequest{
+		Projects: apiRequest.Projects,
+		From:     apiRequest.From,
+		To:       apiRequest.To,
+		GroupBy:  apiRequest.GroupBy,
+		Location: loc,
+	}
+	if req.GroupBy == "" {
+		req.GroupBy = domain.InvoiceByProject
+	}
+
+	invoice, err := user.CreateInvoice(req)
+	if err == domain.ErrEntryInvoiced {
+		conflictError(rw, r, "Some of the time entries were invoiced while the invoice was created - try again")
+		return
+	}
+	if err != nil {
+		entryError(rw, r, err, "Could not create invoice")
+		return
+	}
+	jsonResponse(rw, r, 201, mapInvoiceToApi(invoice))
+}
+
+func (s *apiServer) listInvoices(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	invoices, err := user.GetInvoices()
+	if err != nil {
+		internalError(rw, r, err, "Could not list invoices")
+		return
+	}
+	jsonResponse(rw, r, 200, mapInvoicesToApi(invoices))
+}
+
+func (s *apiServer) getInvoice(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	number, err := strconv.Atoi(chi.URLParam(r, "invoiceNumber"))
+	if err != nil || number <= 0 {
+		validationError(rw, r, fmt.Sprintf("invalid invoice number: %s", chi.URLParam(r, "invoiceNumber")))
+		return
+	}
+
+	invoice, err := user.GetInvoice(number)
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not get invoice %d", number))
+		return
+	}
+	if invoice == nil {
+		notFoundError(rw, r, fmt.Sprintf("Invoice not found: %d", number))
+		return
+	}
+	jsonResponse(rw, r, 200, mapInvoiceToApi(invoice))
+}
@@ -18,12 +18,20 @@ func mapUserToApi(user *domain.User) *api.User {
 	return &result
 }
 
-func mapProjectToApi(project *domain.Project) *api.Project {
+// mapProjectToApi maps a project, looking up the name of its client in clientNames,
+// which holds client names by ID
+func mapProjectToApi(project *domain.Project, clientNames map[string]string) *api.Project {
 	var result api.Project
 
+	result.ID = project.ID
 	result.Name = project.Name
+	result.Aliases = project.Aliases
 	result.Description = project.Description
 	result.Billable = project.Billable
+	result.Client = clientNames[project.ClientID]
+	result.Rates = mapRatesToApi(project.Rates)
+	result.Budget = mapBudgetToApi(project.Budget)
+	result.Archived = project.Archived
 
 	return &result
 }
@@ -40,27 +48,86 @@ func mapApiToProjectDest(project *api.Project, result *domain.Project) {
 	result.Name = project.Name
 	result.Description = project.Description
 	result.Billable = project.Billable
+	result.Rates = mapApiToRates(project.Rates)
+	result.Budget = mapApiToBudget(project.Budget)
 }
 
-func mapProjectsToApi(projects []*domain.Project) []*api.Project {
+func mapBudgetToApi(budget *domain.Budget) *api.Budget {
+	if budget == nil {
+		return nil
+	}
+	return &api.Budget{
+		Hours:      budget.Hours,
+		Amount:     budget.Amount,
+		Currency:   budget.Currency,
+		Period:     budget.Period,
+		Thresholds: budget.Thresholds,
+	}
+}
+
+// mapApiToBudget maps a budget, which covers all time unless another period is given
+func mapApiToBudget(budget *api.Budget) *domain.Budget {
+	if budget == nil {
+		return nil
+	}
+	result := domain.Budget{
+		Hours:      budget.Hours,
+		Amount:     budget.Amount,
+		Currency:   budget.Currency,
+		Period:     budget.Period,
+		Thresholds: budget.Thresholds,
+	}
+	if result.Period == "" {
+		result.Period = domain.BudgetTotal
+	}
+	return &result
+}
+
+func mapBudgetStatusToApi(project *domain.Project, status *domain.BudgetStatus) *api.BudgetStatus {
+	result := api.BudgetStatus{
+		Project:  project.Name,
+		Budget:   mapBudgetToApi(status.Budget),
+		From:     status.From,
+		To:       status.To,
+		Hours:    status.Hours,
+		Amount:   status.Amount,
+		Unpriced: status.Unpriced,
+		Used:     status.Used,
+		Reached:  status.Reached,
+	}
+	if status.Budget.Hours > 0 {
+		result.RemainingHours = status.Budget.Hours - status.Hours
+	}
+	if status.Budget.Amount > 0 {
+		result.RemainingAmount = status.Budget.Amount - status.Amount
+	}
+	return &result
+}
+
+func mapProjectsToApi(projects []*domain.Project, clientNames map[string]string) []*api.Project {
 	var result []*api.Project
 	for _, project := range projects {
-		apiProject := mapProjectToApi(project)
+		apiProject := mapProjectToApi(project, clientNames)
 		result = append(result, apiProject)
 	}
 	return result
 }
 
-func mapTimeEntryToApi(entry *domain.TimeEntry) *api.TimeEntry {
+// mapTimeEntryToApi maps a time entry, looking up the name of its task in taskNames,
+// which holds task names by ID
+func mapTimeEntryToApi(entry *domain.TimeEntry, taskNames map[string]string) *api.TimeEntry {
 	var result api.TimeEntry
 
 	result.ID = entry.ID
 	result.ProjectName = entry.Project.Name
+	result.Task = taskNames[entry.TaskID]
 	result.Type = entry.Type
 	result.Start = entry.Start
 	result.End = entry.End
 	result.Breaks = entry.Breaks
 	result.Comment = entry.Comment
+	result.Tags = entry.Tags
+	result.InvoiceNumber = entry.InvoiceNumber
 
 	return &result
 }
@@ -73,15 +140,332 @@ func mapApiToTimeEntry(entry *api.TimeEntry) *domain.TimeEntry {
 	result.End = entry.End
 	result.Breaks = entry.Breaks
 	result.Comment = entry.Comment
+	result.Tags = domain.EntryTags(entry.Tags, entry.Comment)
 
 	return &result
 }
 
-func mapTimeEntriesToApi(entries []*domain.TimeEntry) []*api.TimeEntry {
+func mapTimeEntriesToApi(entries []*domain.TimeEntry, taskNames map[string]string) []*api.TimeEntry {
 	var result []*api.TimeEntry
 	for _, entry := range entries {
-		apiEntry := mapTimeEntryToApi(entry)
+		apiEntry := mapTimeEntryToApi(entry, taskNames)
 		result = append(result, apiEntry)
 	}
 	return result
 }
+
+func mapSummaryGroupToApi(group *domain.SummaryGroup) *api.SummaryGroup {
+	var result api.SummaryGroup
+
+	result.Key = group.Key
+	result.Worked = group.Worked
+	result.Billable = group.Billable
+	result.NonBillable = group.NonBillable
+	result.Hours = group.Hours
+	result.Days = group.Days
+
+	return &result
+}
+
+func mapSummaryToApi(summary *domain.Summary, q domain.EntryQuery) *api.Summary {
+	var result api.Summary
+
+	result.GroupBy = summary.GroupBy
+	result.From = q.From
+	result.To = q.To
+	result.Groups = []*api.SummaryGroup{}
+	for _, group := range summary.Groups {
+		result.Groups = append(result.Groups, mapSummaryGroupToApi(group))
+	}
+	result.Total = mapSummaryGroupToApi(summary.Total)
+
+	return &result
+}
+
+func mapEntryTypeToApi(entryType *domain.EntryType) *api.EntryType {
+	var result api.EntryType
+
+	result.Name = entryType.Name
+	result.TimeBased = entryType.TimeBased
+	result.CountsAsWork = entryType.CountsAsWork
+	result.FulfillsSchedule = entryType.FulfillsSchedule
+	result.Color = entryType.Color
+
+	return &result
+}
+
+func mapApiToEntryType(entryType *api.EntryType) *domain.EntryType {
+	var result domain.EntryType
+
+	result.Name = entryType.Name
+	result.TimeBased = entryType.TimeBased
+	result.CountsAsWork = entryType.CountsAsWork
+	result.FulfillsSchedule = entryType.FulfillsSchedule
+	result.Color = entryType.Color
+
+	return &result
+}
+
+func mapEntryTypesToApi(entryTypes []*domain.EntryType) []*api.EntryType {
+	var result []*api.EntryType
+	for _, entryType := range entryTypes {
+		result = append(result, mapEntryTypeToApi(entryType))
+	}
+	return result
+}
+
+func mapRatesToApi(rates []domain.Rate) []*api.Rate {
+	var result []*api.Rate
+	for _, rate := range rates {
+		result = append(result, &api.Rate{
+			EntryType: rate.EntryType,
+			Amount:    rate.Amount,
+			Currency:  rate.Currency,
+			From:      rate.From,
+		})
+	}
+	return result
+}
+
+func mapApiToRates(rates []*api.Rate) []domain.Rate {
+	var result []domain.Rate
+	for _, rate := range rates {
+		result = append(result, domain.Rate{
+			EntryType: rate.EntryType,
+			Amount:    rate.Amount,
+			Currency:  rate.Currency,
+			From:      rate.From,
+		})
+	}
+	return result
+}
+
+func mapClientToApi(client *domain.Client) *api.Client {
+	var result api.Client
+
+	result.Name = client.Name
+	result.Description = client.Description
+	result.Rates = mapRatesToApi(client.Rates)
+
+	return &result
+}
+
+func mapApiToClientDest(client *api.Client, result *domain.Client) {
+	result.Name = client.Name
+	result.Description = client.Description
+	result.Rates = mapApiToRates(client.Rates)
+}
+
+func mapTaskToApi(task *domain.Task) *api.Task {
+	return &api.Task{Name: task.Name, Description: task.Description}
+}
+
+func mapApiToTaskDest(task *api.Task, result *domain.Task) {
+	result.Name = task.Name
+	result.Description = task.Description
+}
+
+func mapTasksToApi(tasks []*domain.Task) []*api.Task {
+	var result []*api.Task
+	for _, task := range tasks {
+		result = append(result, mapTaskToApi(task))
+	}
+	return result
+}
+
+func mapClientsToApi(clients []*domain.Client) []*api.Client {
+	var result []*api.Client
+	for _, client := range clients {
+		result = append(result, mapClientToApi(client))
+	}
+	return result
+}
+
+func mapBillingToApi(billing *domain.Billing, q domain.EntryQuery) *api.Billing {
+	var result api.Billing
+
+	result.From = q.From
+	result.To = q.To
+	result.Lines = []*api.BillingLine{}
+	for _, line := range billing.Lines {
+		result.Lines = append(result.Lines, &api.BillingLine{
+			Client:    line.Client,
+			Project:   line.Project,
+			EntryType: line.EntryType,
+			Rate:      line.Rate,
+			Currency:  line.Currency,
+			Hours:     line.Hours,
+			Amount:    line.Amount,
+		})
+	}
+	result.Totals = billing.Totals
+	result.Unpriced = billing.Unpriced
+
+	return &result
+}
+
+func mapInvoiceToApi(invoice *domain.Invoice) *api.Invoice {
+	var result api.Invoice
+
+	result.Number = invoice.Number
+	result.Issued = invoice.Issued
+	result.Client = invoice.Client
+	result.From = invoice.From
+	result.To = invoice.To
+	result.Lines = []*api.InvoiceLine{}
+	for _, line := range invoice.Lines {
+		apiLine := api.InvoiceLine(line)
+		result.Lines = append(result.Lines, &apiLine)
+	}
+	result.Totals = invoice.Totals()
+
+	return &result
+}
+
+func mapInvoicesToApi(invoices []*domain.Invoice) []*api.Invoice {
+	var result []*api.Invoice
+	for _, invoice := range invoices {
+		result = append(result, mapInvoiceToApi(invoice))
+	}
+	return result
+}
+
+func mapScheduleToApi(schedule *domain.Schedule) *api.Schedule {
+	return &api.Schedule{From: schedule.From, Hours: schedule.Hours}
+}
+
+func mapApiToSchedule(schedule *api.Schedule) *domain.Schedule {
+	return &domain.Schedule{From: schedule.From, Hours: schedule.Hours}
+}
+
+func mapSchedulesToApi(schedules []*domain.Schedule) []*api.Schedule {
+	result := make([]*api.Schedule, len(schedules))
+	for i, s := range schedules {
+		result[i] = mapScheduleToApi(s)
+	}
+	return result
+}
+
+func mapBalanceAdjustmentToApi(adjustment *domain.BalanceAdjustment) *api.BalanceAdjustment {
+	return &api.BalanceAdjustment{
+		ID:      adjustment.ID,
+		Date:    adjustment.Date,
+		Amount:  adjustment.Amount,
+		Comment: adjustment.Comment,
+	}
+}
+
+func mapApiToBalanceAdjustment(adjustment *api.BalanceAdjustment) *domain.BalanceAdjustment {
+	return &domain.BalanceAdjustment{
+		Date:    adjustment.Date,
+		Amount:  adjustment.Amount,
+		Comment: adjustment.Comment,
+	}
+}
+
+func mapBalanceAdjustmentsToApi(adjustments []*domain.BalanceAdjustment) []*api.BalanceAdjustment {
+	result := make([]*api.BalanceAdjustment, len(adjustments))
+	for i, a := range adjustments {
+		result[i] = mapBalanceAdjustmentToApi(a)
+	}
+	return result
+}
+
+func mapBalancePeriodToApi(period *domain.BalancePeriod) *api.BalancePeriod {
+	return &api.BalancePeriod{
+		Key:         period.Key,
+		Expected:    period.Expected,
+		Worked:      period.Worked,
+		Absence:     period.Absence,
+		Adjustments: period.Adjustments,
+		Balance:     period.Balance,
+		Running:     period.Running,
+	}
+}
+
+func mapBalanceToApi(balance *domain.Balance) *api.Balance {
+	result := &api.Balance{
+		GroupBy: balance.GroupBy,
+		From:    balance.From.Format(domain.ScheduleDateFormat),
+		To:      balance.To.Format(domain.ScheduleDateFormat),
+		Opening: balance.Opening,
+		Periods: make([]*api.BalancePeriod, len(balance.Periods)),
+		Total:   mapBalancePeriodToApi(balance.Total),
+	}
+	for i, p := range balance.Periods {
+		result.Periods[i] = mapBalancePeriodToApi(p)
+	}
+	return result
+}
+
+func mapAllowanceToApi(allowance *domain.Allowance) *api.Allowance {
+	return &api.Allowance{
+		Type:      allowance.Type,
+		From:      allowance.From,
+		Days:      allowance.Days,
+		CarryOver: allowance.CarryOver,
+	}
+}
+
+func mapApiToAllowance(allowance *api.Allowance) *domain.Allowance {
+	return &domain.Allowance{
+		Type:      allowance.Type,
+		From:      allowance.From,
+		Days:      allowance.Days,
+		CarryOver: allowance.CarryOver,
+	}
+}
+
+func mapAllowancesToApi(allowances []*domain.Allowance) []*api.Allowance {
+	result := make([]*api.Allowance, len(allowances))
+	for i, a := range allowances {
+		result[i] = mapAllowanceToApi(a)
+	}
+	return result
+}
+
+func mapAllowanceStatusToApi(status *domain.AllowanceStatus) *api.AllowanceStatus {
+	return &api.AllowanceStatus{
+		Type:        status.Type,
+		Year:        status.Year,
+		Allowance:   status.Allowance,
+		CarriedOver: status.CarriedOver,
+		Used:        status.Used,
+		Remaining:   status.Remaining,
+	}
+}
+
+func mapAllowanceStatusesToApi(statuses []*domain.AllowanceStatus) []*api.AllowanceStatus {
+	result := make([]*api.AllowanceStatus, len(statuses))
+	for i, s := range statuses {
+		result[i] = mapAllowanceStatusToApi(s)
+	}
+	return result
+}
+
+func mapHolidayToApi(holiday *domain.Holiday) *api.Holiday {
+	return &api.Holiday{Date: holiday.Date, Name: holiday.Name}
+}
+
+func mapHolidaysToApi(holidays []*domain.Holiday) []*api.Holiday {
+	result := make([]*api.Holiday, len(holidays))
+	for i, h := range holidays {
+		result[i] = mapHolidayToApi(h)
+	}
+	return result
+}
+
+func mapValidationErrorToApi(err *domain.ValidationError) *api.ValidationError {
+	var result api.ValidationError
+
+	result.Message = "Validation failed"
+	for _, fieldError := range err.Errors {
+		result.Errors = append(result.Errors, &api.FieldError{
+			Field:   fieldError.Field,
+			Rule:    fieldError.Rule,
+			Message: fieldError.Message,
+		})
+	}
+
+	return &result
+}
@@ -0,0 +1,125 @@
+package endpoints
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kennep/timelapse/domain"
+)
+
+// maxPageSize is the largest page a client may ask for
+const maxPageSize = 1000
+
+// defaultPageSize is the page size of list endpoints when no limit is given, so that large
+// collections are returned a page at a time
+const defaultPageSize = 100
+
+// entryCursor is the serialized form of a domain.EntryCursor. Clients treat it as opaque.
+type entryCursor struct {
+	Start time.Time `json:"s"`
+	ID    string    `json:"i"`
+}
+
+func encodeEntryCursor(c *domain.EntryCursor) string {
+	data, _ := json.Marshal(entryCursor{c.Start, c.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeEntryCursor(s string) (*domain.EntryCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c entryCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &domain.EntryCursor{Start: c.Start, ID: c.ID}, nil
+}
+
+func encodeProjectCursor(projectName string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(projectName))
+}
+
+func decodeProjectCursor(s string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// getLimitFromURL reads the limit query parameter. 0 is returned if no limit was given.
+// If the limit is invalid, an error response is emitted and false is returned.
+// List endpoints use pageLimit to apply the default page size.
+func getLimitFromURL(rw http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return 0, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		validationError(rw, r, fmt.Sprintf("limit must be a number between 1 and %d", maxPageSize))
+		return 0, false
+	}
+	return limit, true
+}
+
+// pageLimit returns the size of a page of a list endpoint: the limit that was asked for, or
+// the default page size
+func pageLimit(limit int) int {
+	if limit == 0 {
+		return defaultPageSize
+	}
+	return limit
+}
+
+// getProjectQueryFromURL builds a project query from the limit, cursor and archived query
+// parameters, with the default page size if no limit is given. Only active projects are
+// listed unless archived is true, which lists the archived ones, or all. If a parameter is
+// invalid, an error response is emitted and false is returned.
+func getProjectQueryFromURL(rw http.ResponseWriter, r *http.Request) (domain.ProjectQuery, bool) {
+	var q domain.ProjectQuery
+	var ok bool
+
+	switch archived := r.URL.Query().Get("archived"); archived {
+	case "", "false":
+		q.Archived = new(bool)
+	case "true":
+		q.Archived = new(bool)
+		*q.Archived = true
+	case "all":
+	default:
+		validationError(rw, r, fmt.Sprintf("archived must be true, false or all, got %s", archived))
+		return q, false
+	}
+
+	q.Limit, ok = getLimitFromURL(rw, r)
+	if !ok {
+		return q, false
+	}
+	q.Limit = pageLimit(q.Limit)
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		after, err := decodeProjectCursor(cursor)
+		if err != nil {
+			validationError(rw, r, "invalid cursor")
+			return q, false
+		}
+		q.After = after
+	}
+
+	return q, true
+}
+
+// setNextLink adds a Link header pointing to the next page, which starts after the given cursor.
+// The next page is requested with the same parameters as the current one.
+func setNextLink(rw http.ResponseWriter, r *http.Request, cursor string) {
+	params := r.URL.Query()
+	params.Set("cursor", cursor)
+	rw.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", r.URL.Path, params.Encode()))
+}
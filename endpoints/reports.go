@@ -0,0 +1,194 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kennep/timelapse/domain"
+)
+
+// loadLocation returns the time zone with an IANA name, like Europe/Oslo, or a fixed UTC
+// offset, like +01:00. Local is rejected, as it is the zone of the server, not the user.
+func loadLocation(tz string) (*time.Location, error) {
+	if tz == "Local" {
+		return nil, fmt.Errorf("unknown time zone %s", tz)
+	}
+	loc, err := time.LoadLocation(tz)
+	if err == nil {
+		return loc, nil
+	}
+	offset, offsetErr := time.Parse("-07:00", tz)
+	if offsetErr != nil {
+		return nil, err
+	}
+	_, seconds := offset.Zone()
+	return time.FixedZone(tz, seconds), nil
+}
+
+// getLocationFromURL returns the time zone used for grouping by date. It is taken from the
+// tz query parameter if given, as a zone name or a UTC offset, otherwise from the offset of
+// the from parameter, falling back to UTC. If the time zone is invalid, an error response
+// is emitted and nil is returned.
+func getLocationFromURL(rw http.ResponseWriter, r *http.Request, q domain.EntryQuery) *time.Location {
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		loc, err := loadLocation(tz)
+		if err != nil {
+			validationError(rw, r, fmt.Sprintf("unknown time zone: %s", tz))
+			return nil
+		}
+		return loc
+	}
+	if q.From != nil {
+		return q.From.Location()
+	}
+	return time.UTC
+}
+
+// getReportEntries returns the entries a report is based on: those of the project in the
+// project query parameter if given, optionally limited to its task in the task parameter,
+// otherwise all the user's entries. If the entries cannot be read, an error response is
+// emitted and false is returned.
+func (s *apiServer) getReportEntries(rw http.ResponseWriter, r *http.Request, user *domain.User, q domain.EntryQuery) ([]*domain.TimeEntry, bool) {
+	projectName := r.URL.Query().Get("project")
+	if projectName == "" && r.URL.Query().Get("task") != "" {
+		validationError(rw, r, "task parameter requires a project parameter")
+		return nil, false
+	}
+	if projectName == "" {
+		entries, err := user.GetEntries(q)
+		if err != nil {
+			internalError(rw, r, err, "Could not get time entries")
+			return nil, false
+		}
+		return entries, true
+	}
+
+	project, err := findProject(rw, user, projectName)
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not get project by name %s", projectName))
+		return nil, false
+	}
+	if project == nil {
+		notFoundError(rw, r, fmt.Sprintf("Project not found: %s", projectName))
+		return nil, false
+	}
+	if !getTaskFilterFromURL(rw, r, project, &q) {
+		return nil, false
+	}
+	entries, err := project.GetEntries(q)
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not get time entries for project %s", projectName))
+		return nil, false
+	}
+	return entries, true
+}
+
+func (s *apiServer) getSummaryReport(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	q, ok := getEntryQueryFromURL(rw, r)
+	if !ok {
+		return
+	}
+	// Reports always cover the whole range
+	q.Limit = 0
+	q.After = nil
+
+	loc := getLocationFromURL(rw, r, q)
+	if loc == nil {
+		return
+	}
+
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = domain.GroupByProject
+	}
+
+	types, ok := getEntryTypes(rw, r, user)
+	if !ok {
+		return
+	}
+
+	entries, ok := s.getReportEntries(rw, r, user, q)
+	if !ok {
+		return
+	}
+
+	taskNames, ok := getTaskNames(rw, r, user)
+	if !ok {
+		return
+	}
+
+	summary, err := domain.Summarize(entries, groupBy, loc, types, taskNames)
+	if err != nil {
+		validationError(rw, r, err.Error())
+		return
+	}
+
+	jsonResponse(rw, r, 200, mapSummaryToApi(summary, q))
+}
+
+// getBillingReport prices the billable time in the range given by the from and to query
+// parameters, optionally limited to one project or one client
+func (s *apiServer) getBillingReport(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	q, ok := getEntryQueryFromURL(rw, r)
+	if !ok {
+		return
+	}
+	q.Limit = 0
+	q.After = nil
+
+	loc := getLocationFromURL(rw, r, q)
+	if loc == nil {
+		return
+	}
+
+	types, ok := getEntryTypes(rw, r, user)
+	if !ok {
+		return
+	}
+
+	clients, err := user.GetClients()
+	if err != nil {
+		internalError(rw, r, err, "Could not get clients")
+		return
+	}
+	var clientID string
+	if clientName := r.URL.Query().Get("client"); clientName != "" {
+		for _, client := range clients {
+			if client.Name == clientName {
+				clientID = client.ID
+			}
+		}
+		if clientID == "" {
+			notFoundError(rw, r, fmt.Sprintf("Client not found: %s", clientName))
+			return
+		}
+	}
+
+	entries, ok := s.getReportEntries(rw, r, user, q)
+	if !ok {
+		return
+	}
+	if clientID != "" {
+		var clientEntries []*domain.TimeEntry
+		for _, e := range entries {
+			if e.Project != nil && e.Project.ClientID == clientID {
+				clientEntries = append(clientEntries, e)
+			}
+		}
+		entries = clientEntries
+	}
+
+	billing := domain.Bill(entries, clients, loc, types)
+	jsonResponse(rw, r, 200, mapBillingToApi(billing, q))
+}
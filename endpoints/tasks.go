@@ -0,0 +1,225 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/kennep/timelapse/domain"
+)
+
+// getTaskNames returns the names of the tasks of all the user's projects by ID. If the
+// tasks cannot be loaded, an error response is emitted and false is returned.
+func getTaskNames(rw http.ResponseWriter, r *http.Request, user *domain.User) (map[string]string, bool) {
+	tasks, err := user.GetTasks()
+	if err != nil {
+		internalError(rw, r, err, "Could not get tasks")
+		return nil, false
+	}
+	names := make(map[string]string)
+	for _, task := range tasks {
+		names[task.ID] = task.Name
+	}
+	return names, true
+}
+
+// getTaskID returns the ID of the named task in the project, or an empty string if no
+// name is given. If there is no such task, an error response is emitted and false is
+// returned.
+func getTaskID(rw http.ResponseWriter, r *http.Request, project *domain.Project, taskName string) (string, bool) {
+	if taskName == "" {
+		return "", true
+	}
+	task, err := project.GetTask(taskName)
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not get task by name %s", taskName))
+		return "", false
+	}
+	if task == nil {
+		validationError(rw, r, fmt.Sprintf("Task not found in project %s: %s", project.Name, taskName))
+		return "", false
+	}
+	return task.ID, true
+}
+
+// getTaskFromURL looks up the task named in the URL. If the task cannot be found, an
+// error response is emitted and nil is returned.
+func (s *apiServer) getTaskFromURL(rw http.ResponseWriter, r *http.Request, project *domain.Project) *domain.Task {
+	taskName, err := url.QueryUnescape(chi.URLParam(r, "taskName"))
+	if err != nil {
+		validationError(rw, r, err.Error())
+		return nil
+	}
+	if taskName == "" {
+		validationError(rw, r, "task name in URL cannot be blank")
+		return nil
+	}
+
+	task, err := project.GetTask(taskName)
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not get task by name %s", taskName))
+		return nil
+	}
+	if task == nil {
+		notFoundError(rw, r, fmt.Sprintf("Task not found: %s", taskName))
+		return nil
+	}
+	return task
+}
+
+func (s *apiServer) addTask(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	project := s.getProjectFromURL(rw, r, user)
+	if project == nil {
+		return
+	}
+
+	var apiTask api.Task
+	err := jsonRequest(rw, r, &apiTask)
+	if err != nil {
+		return
+	}
+	var task domain.Task
+	mapApiToTaskDest(&apiTask, &task)
+
+	existing, err := project.GetTask(task.Name)
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not get task by name %s", task.Name))
+		return
+	}
+	if existing != nil {
+		validationError(rw, r, fmt.Sprintf("A task with this name already exists: %s", task.Name))
+		return
+	}
+
+	taskResult, err := project.AddTask(&task)
+	if err != nil {
+		entryError(rw, r, err, fmt.Sprintf("Could not create task %s", task.Name))
+		return
+	}
+	jsonResponse(rw, r, 201, mapTaskToApi(taskResult))
+}
+
+func (s *apiServer) listTasks(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	project := s.getProjectFromURL(rw, r, user)
+	if project == nil {
+		return
+	}
+
+	tasks, err := project.GetTasks()
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not list tasks of project %s", project.Name))
+		return
+	}
+	jsonResponse(rw, r, 200, mapTasksToApi(tasks))
+}
+
+func (s *apiServer) getTask(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	project := s.getProjectFromURL(rw, r, user)
+	if project == nil {
+		return
+	}
+
+	task := s.getTaskFromURL(rw, r, project)
+	if task == nil {
+		return
+	}
+	jsonResponse(rw, r, 200, mapTaskToApi(task))
+}
+
+func (s *apiServer) updateTask(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	project := s.getProjectFromURL(rw, r, user)
+	if project == nil {
+		return
+	}
+
+	task := s.getTaskFromURL(rw, r, project)
+	if task == nil {
+		return
+	}
+	taskName := task.Name
+
+	var apiTask api.Task
+	err := jsonRequest(rw, r, &apiTask)
+	if err != nil {
+		return
+	}
+	if apiTask.Name != taskName {
+		existing, err := project.GetTask(apiTask.Name)
+		if err != nil {
+			internalError(rw, r, err, fmt.Sprintf("Could not get task by name %s", apiTask.Name))
+			return
+		}
+		if existing != nil {
+			validationError(rw, r, fmt.Sprintf("A task with this name already exists: %s", apiTask.Name))
+			return
+		}
+	}
+
+	mapApiToTaskDest(&apiTask, task)
+	if err := task.Save(); err != nil {
+		entryError(rw, r, err, fmt.Sprintf("Could not update task %s", taskName))
+		return
+	}
+	jsonResponse(rw, r, 200, mapTaskToApi(task))
+}
+
+func (s *apiServer) deleteTask(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	project := s.getProjectFromURL(rw, r, user)
+	if project == nil {
+		return
+	}
+
+	task := s.getTaskFromURL(rw, r, project)
+	if task == nil {
+		return
+	}
+
+	err := task.Delete()
+	if err == domain.ErrTaskHasEntries {
+		conflictError(rw, r, fmt.Sprintf("Task %s has time entries - move them to another task first", task.Name))
+		return
+	}
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not delete task %s", task.Name))
+		return
+	}
+
+	rw.WriteHeader(204)
+}
+
+// getTaskFilterFromURL limits the entry query to the project's task named in the task
+// query parameter, if it is given. If there is no such task, an error response is emitted
+// and false is returned.
+func getTaskFilterFromURL(rw http.ResponseWriter, r *http.Request, project *domain.Project, q *domain.EntryQuery) bool {
+	taskID, ok := getTaskID(rw, r, project, r.URL.Query().Get("task"))
+	q.TaskID = taskID
+	return ok
+}
@@ -0,0 +1,214 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kennep/timelapse/api"
+	"github.com/kennep/timelapse/domain"
+)
+
+func mapUserSettingsToApi(user *domain.User) *api.UserSettings {
+	policy := user.TimerPolicy
+	if policy == "" {
+		policy = domain.TimerPolicyForbid
+	}
+	return &api.UserSettings{TimerPolicy: policy}
+}
+
+func (s *apiServer) getSettings(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	jsonResponse(rw, r, 200, mapUserSettingsToApi(user))
+}
+
+func (s *apiServer) updateSettings(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	var settings api.UserSettings
+	err := jsonRequest(rw, r, &settings)
+	if err != nil {
+		return
+	}
+
+	if settings.TimerPolicy != "" && settings.TimerPolicy != user.TimerPolicy {
+		if err := user.SetTimerPolicy(settings.TimerPolicy); err != nil {
+			validationError(rw, r, err.Error())
+			return
+		}
+	}
+
+	jsonResponse(rw, r, 200, mapUserSettingsToApi(user))
+}
+
+func (s *apiServer) getTimer(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	timer, err := user.GetTimer()
+	if err != nil {
+		internalError(rw, r, err, "Could not get running timer")
+		return
+	}
+	if timer == nil {
+		notFoundError(rw, r, "No timer is running")
+		return
+	}
+
+	timeEntryResponse(rw, r, 200, user, timer)
+}
+
+// getStopRunningFromURL tells whether the stop_running query parameter is set, which stops
+// running timers when a new one starts regardless of the timer policy. If the parameter is
+// invalid, an error response is emitted and false is returned as the second value.
+func getStopRunningFromURL(rw http.ResponseWriter, r *http.Request) (bool, bool) {
+	param := r.URL.Query().Get("stop_running")
+	if param == "" {
+		return false, true
+	}
+	stopRunning, err := strconv.ParseBool(param)
+	if err != nil {
+		validationError(rw, r, fmt.Sprintf("invalid stop_running: %s", param))
+		return false, false
+	}
+	return stopRunning, true
+}
+
+func (s *apiServer) startTimer(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	var apiTimeEntry api.TimeEntry
+	err := jsonRequest(rw, r, &apiTimeEntry)
+	if err != nil {
+		return
+	}
+	timeEntry := mapApiToTimeEntry(&apiTimeEntry)
+	if timeEntry.Type == "" {
+		timeEntry.Type = "work"
+	}
+
+	project, err := findProject(rw, user, apiTimeEntry.ProjectName)
+	if err != nil {
+		internalError(rw, r, err, fmt.Sprintf("Could not get project by name %s", apiTimeEntry.ProjectName))
+		return
+	}
+	if project == nil {
+		validationError(rw, r, fmt.Sprintf("project not found: %s", apiTimeEntry.ProjectName))
+		return
+	}
+	var ok bool
+	if timeEntry.TaskID, ok = getTaskID(rw, r, project, apiTimeEntry.Task); !ok {
+		return
+	}
+	stopRunning, ok := getStopRunningFromURL(rw, r)
+	if !ok {
+		return
+	}
+	allowOverlap, ok := getAllowOverlapFromURL(rw, r)
+	if !ok {
+		return
+	}
+	loc := getLocationFromURL(rw, r, domain.EntryQuery{})
+	if loc == nil {
+		return
+	}
+
+	var started *domain.TimeEntry
+	var stopped []*domain.TimeEntry
+	if stopRunning {
+		started, stopped, err = project.SwitchTimer(timeEntry, allowOverlap)
+	} else {
+		started, stopped, err = project.StartTimer(timeEntry, allowOverlap)
+	}
+	if err == domain.ErrTimerRunning {
+		conflictError(rw, r, "A timer is already running - stop it first, or set the timer policy to auto-stop")
+		return
+	} else if err == domain.ErrProjectArchived {
+		conflictError(rw, r, fmt.Sprintf("Project %s is archived - unarchive it to start a timer", project.Name))
+		return
+	} else if err != nil {
+		entryError(rw, r, err, "Error while starting timer")
+		return
+	}
+
+	s.checkBudgets(r, loc, user, stopped...)
+	s.checkBudgets(r, loc, user, started)
+
+	taskNames, ok := getTaskNames(rw, r, user)
+	if !ok {
+		return
+	}
+	result := api.TimerStart{
+		Started: mapTimeEntryToApi(started, taskNames),
+		Stopped: mapTimeEntriesToApi(stopped, taskNames),
+	}
+	if result.Stopped == nil {
+		result.Stopped = []*api.TimeEntry{}
+	}
+	jsonResponse(rw, r, 201, &result)
+}
+
+func (s *apiServer) stopTimer(rw http.ResponseWriter, r *http.Request) {
+	user := s.getUser(rw, r)
+	if user == nil {
+		return
+	}
+
+	var timerStop api.TimerStop
+	err := jsonRequest(rw, r, &timerStop)
+	if err != nil {
+		return
+	}
+	allowOverlap, ok := getAllowOverlapFromURL(rw, r)
+	if !ok {
+		return
+	}
+	loc := getLocationFromURL(rw, r, domain.EntryQuery{})
+	if loc == nil {
+		return
+	}
+
+	var project *domain.Project
+	if timerStop.ProjectName != "" {
+		project, err = findProject(rw, user, timerStop.ProjectName)
+		if err != nil {
+			internalError(rw, r, err, fmt.Sprintf("Could not get project by name %s", timerStop.ProjectName))
+			return
+		}
+		if project == nil {
+			notFoundError(rw, r, fmt.Sprintf("Project not found: %s", timerStop.ProjectName))
+			return
+		}
+	}
+
+	end := time.Now()
+	if timerStop.End != nil {
+		end = *timerStop.End
+	}
+
+	stopped, err := user.StopTimer(project, end, timerStop.Breaks, allowOverlap)
+	if err != nil {
+		entryError(rw, r, err, "Error while stopping timer")
+		return
+	}
+	if stopped == nil {
+		notFoundError(rw, r, "No timer is running")
+		return
+	}
+	s.checkBudgets(r, loc, user, stopped)
+
+	timeEntryResponse(rw, r, 200, user, stopped)
+}
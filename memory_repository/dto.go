@@ -0,0 +1,134 @@
+package memory_repository
+
+import "time"
+
+type (
+	identity struct {
+		Issuer    string
+		SubjectID string
+		Email     string
+	}
+
+	user struct {
+		ID          string
+		Identities  []identity
+		FeedToken   string
+		TimerPolicy string
+	}
+
+	rate struct {
+		EntryType string
+		Amount    float64
+		Currency  string
+		From      string
+	}
+
+	budget struct {
+		Hours      time.Duration
+		Amount     float64
+		Currency   string
+		Period     string
+		Thresholds []int
+	}
+
+	client struct {
+		ID          string
+		UserID      string
+		Name        string
+		Description string
+		Rates       []rate
+	}
+
+	project struct {
+		ID          string
+		UserID      string
+		Name        string
+		Description string
+		Billable    bool
+		ClientID    string
+		Rates       []rate
+		Budget      *budget
+		Aliases     []string
+		Archived    bool
+	}
+
+	task struct {
+		ID          string
+		UserID      string
+		ProjectID   string
+		Name        string
+		Description string
+	}
+
+	entryType struct {
+		UserID           string
+		Name             string
+		TimeBased        bool
+		CountsAsWork     bool
+		FulfillsSchedule bool
+		Color            string
+	}
+
+	timeEntry struct {
+		ID        string
+		ProjectID string
+		TaskID    string
+		UserID    string
+		Type      string
+		Start     *time.Time
+		End       *time.Time
+		Breaks    time.Duration
+		Comment   string
+		Tags      []string
+		// InvoiceNumber is 0 for entries that have not been invoiced
+		InvoiceNumber int
+	}
+
+	invoiceLine struct {
+		Project     string
+		Description string
+		Hours       time.Duration
+		Rate        float64
+		Currency    string
+		Amount      float64
+	}
+
+	invoice struct {
+		ID     string
+		UserID string
+		Number int
+		Issued time.Time
+		Client string
+		From   *time.Time
+		To     *time.Time
+		Lines  []invoiceLine
+	}
+
+	schedule struct {
+		UserID string
+		From   string
+		Hours  []time.Duration
+	}
+
+	balanceAdjustment struct {
+		ID      string
+		UserID  string
+		Date    string
+		Amount  time.Duration
+		Comment string
+	}
+
+	allowance struct {
+		UserID    string
+		Type      string
+		From      int
+		Days      int
+		CarryOver int
+	}
+
+	holiday struct {
+		UserID string
+		Date   string
+		Name   string
+	}
+)
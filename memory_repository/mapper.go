@@ -0,0 +1,329 @@
+package memory_repository
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/kennep/timelapse/domain"
+)
+
+// newID returns a random ID that can be used in URL paths as it is
+func newID() string {
+	var b [12]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func copyTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	return append([]string{}, tags...)
+}
+
+func mapUserToDomain(in *user) *domain.User {
+	out := domain.User{
+		ID:          in.ID,
+		FeedToken:   in.FeedToken,
+		TimerPolicy: in.TimerPolicy,
+	}
+	for _, identity := range in.Identities {
+		out.Identities = append(out.Identities,
+			domain.Identity{
+				Issuer:    identity.Issuer,
+				SubjectID: identity.SubjectID,
+				Email:     identity.Email,
+			})
+	}
+	return &out
+}
+
+func mapRatesToDomain(in []rate) []domain.Rate {
+	var out []domain.Rate
+	for _, r := range in {
+		out = append(out, domain.Rate{EntryType: r.EntryType, Amount: r.Amount, Currency: r.Currency, From: r.From})
+	}
+	return out
+}
+
+func mapRatesFromDomain(in []domain.Rate) []rate {
+	var out []rate
+	for _, r := range in {
+		out = append(out, rate{EntryType: r.EntryType, Amount: r.Amount, Currency: r.Currency, From: r.From})
+	}
+	return out
+}
+
+func mapBudgetToDomain(in *budget) *domain.Budget {
+	if in == nil {
+		return nil
+	}
+	return &domain.Budget{
+		Hours:      in.Hours,
+		Amount:     in.Amount,
+		Currency:   in.Currency,
+		Period:     in.Period,
+		Thresholds: append([]int(nil), in.Thresholds...),
+	}
+}
+
+func mapBudgetFromDomain(in *domain.Budget) *budget {
+	if in == nil {
+		return nil
+	}
+	return &budget{
+		Hours:      in.Hours,
+		Amount:     in.Amount,
+		Currency:   in.Currency,
+		Period:     in.Period,
+		Thresholds: append([]int(nil), in.Thresholds...),
+	}
+}
+
+func mapClientToDomain(in *client, user *domain.User) *domain.Client {
+	return &domain.Client{
+		ID:          in.ID,
+		User:        user,
+		Name:        in.Name,
+		Description: in.Description,
+		Rates:       mapRatesToDomain(in.Rates),
+	}
+}
+
+func mapClientFromDomain(in *domain.Client) *client {
+	return &client{
+		ID:          in.ID,
+		UserID:      in.User.ID,
+		Name:        in.Name,
+		Description: in.Description,
+		Rates:       mapRatesFromDomain(in.Rates),
+	}
+}
+
+func mapProjectToDomain(in *project, user *domain.User) *domain.Project {
+	return &domain.Project{
+		ID:          in.ID,
+		User:        user,
+		Name:        in.Name,
+		Description: in.Description,
+		Billable:    in.Billable,
+		ClientID:    in.ClientID,
+		Rates:       mapRatesToDomain(in.Rates),
+		Budget:      mapBudgetToDomain(in.Budget),
+		Aliases:     append([]string(nil), in.Aliases...),
+		Archived:    in.Archived,
+	}
+}
+
+// withoutAliases returns the aliases that are not among the released names
+func withoutAliases(aliases []string, released []string) []string {
+	var result []string
+	for _, alias := range aliases {
+		keep := true
+		for _, name := range released {
+			keep = keep && alias != name
+		}
+		if keep {
+			result = append(result, alias)
+		}
+	}
+	return result
+}
+
+func mapProjectFromDomain(in *domain.Project) *project {
+	return &project{
+		ID:          in.ID,
+		UserID:      in.User.ID,
+		Name:        in.Name,
+		Description: in.Description,
+		Billable:    in.Billable,
+		ClientID:    in.ClientID,
+		Rates:       mapRatesFromDomain(in.Rates),
+		Budget:      mapBudgetFromDomain(in.Budget),
+		Aliases:     append([]string(nil), in.Aliases...),
+		Archived:    in.Archived,
+	}
+}
+
+func mapTaskToDomain(in *task, p *domain.Project) *domain.Task {
+	return &domain.Task{
+		ID:          in.ID,
+		Project:     p,
+		Name:        in.Name,
+		Description: in.Description,
+	}
+}
+
+func mapTaskFromDomain(in *domain.Task) *task {
+	return &task{
+		ID:          in.ID,
+		UserID:      in.Project.User.ID,
+		ProjectID:   in.Project.ID,
+		Name:        in.Name,
+		Description: in.Description,
+	}
+}
+
+func mapTimeEntryToDomain(in *timeEntry, p *domain.Project) *domain.TimeEntry {
+	return &domain.TimeEntry{
+		ID:            in.ID,
+		Project:       p,
+		TaskID:        in.TaskID,
+		Type:          in.Type,
+		Start:         copyTime(in.Start),
+		End:           copyTime(in.End),
+		Breaks:        in.Breaks,
+		Comment:       in.Comment,
+		Tags:          copyTags(in.Tags),
+		InvoiceNumber: in.InvoiceNumber,
+	}
+}
+
+func mapTimeEntryFromDomain(in *domain.TimeEntry) *timeEntry {
+	return &timeEntry{
+		ID:            in.ID,
+		ProjectID:     in.Project.ID,
+		TaskID:        in.TaskID,
+		UserID:        in.Project.User.ID,
+		Type:          in.Type,
+		Start:         copyTime(in.Start),
+		End:           copyTime(in.End),
+		Breaks:        in.Breaks,
+		Comment:       in.Comment,
+		Tags:          copyTags(in.Tags),
+		InvoiceNumber: in.InvoiceNumber,
+	}
+}
+
+func mapInvoiceToDomain(in *invoice, user *domain.User) *domain.Invoice {
+	out := domain.Invoice{
+		ID:     in.ID,
+		User:   user,
+		Number: in.Number,
+		Issued: in.Issued,
+		Client: in.Client,
+		From:   copyTime(in.From),
+		To:     copyTime(in.To),
+	}
+	for _, line := range in.Lines {
+		out.Lines = append(out.Lines, domain.InvoiceLine(line))
+	}
+	return &out
+}
+
+func mapInvoiceFromDomain(in *domain.Invoice) *invoice {
+	out := invoice{
+		ID:     in.ID,
+		UserID: in.User.ID,
+		Number: in.Number,
+		Issued: in.Issued,
+		Client: in.Client,
+		From:   copyTime(in.From),
+		To:     copyTime(in.To),
+	}
+	for _, line := range in.Lines {
+		out.Lines = append(out.Lines, invoiceLine(line))
+	}
+	return &out
+}
+
+func mapEntryTypeToDomain(in *entryType) *domain.EntryType {
+	return &domain.EntryType{
+		Name:             in.Name,
+		TimeBased:        in.TimeBased,
+		CountsAsWork:     in.CountsAsWork,
+		FulfillsSchedule: in.FulfillsSchedule,
+		Color:            in.Color,
+	}
+}
+
+func mapEntryTypeFromDomain(in *domain.EntryType, u *domain.User) *entryType {
+	return &entryType{
+		UserID:           u.ID,
+		Name:             in.Name,
+		TimeBased:        in.TimeBased,
+		CountsAsWork:     in.CountsAsWork,
+		FulfillsSchedule: in.FulfillsSchedule,
+		Color:            in.Color,
+	}
+}
+
+func mapScheduleToDomain(in *schedule) *domain.Schedule {
+	return &domain.Schedule{
+		From:  in.From,
+		Hours: append([]time.Duration(nil), in.Hours...),
+	}
+}
+
+func mapScheduleFromDomain(in *domain.Schedule, u *domain.User) *schedule {
+	return &schedule{
+		UserID: u.ID,
+		From:   in.From,
+		Hours:  append([]time.Duration(nil), in.Hours...),
+	}
+}
+
+func mapBalanceAdjustmentToDomain(in *balanceAdjustment) *domain.BalanceAdjustment {
+	return &domain.BalanceAdjustment{
+		ID:      in.ID,
+		Date:    in.Date,
+		Amount:  in.Amount,
+		Comment: in.Comment,
+	}
+}
+
+func mapBalanceAdjustmentFromDomain(in *domain.BalanceAdjustment, u *domain.User) *balanceAdjustment {
+	return &balanceAdjustment{
+		ID:      in.ID,
+		UserID:  u.ID,
+		Date:    in.Date,
+		Amount:  in.Amount,
+		Comment: in.Comment,
+	}
+}
+
+func mapAllowanceToDomain(in *allowance) *domain.Allowance {
+	return &domain.Allowance{
+		Type:      in.Type,
+		From:      in.From,
+		Days:      in.Days,
+		CarryOver: in.CarryOver,
+	}
+}
+
+func mapAllowanceFromDomain(in *domain.Allowance, u *domain.User) *allowance {
+	return &allowance{
+		UserID:    u.ID,
+		Type:      in.Type,
+		From:      in.From,
+		Days:      in.Days,
+		CarryOver: in.CarryOver,
+	}
+}
+
+func mapHolidayToDomain(in *holiday) *domain.Holiday {
+	return &domain.Holiday{
+		Date: in.Date,
+		Name: in.Name,
+	}
+}
+
+func mapHolidayFromDomain(in *domain.Holiday, u *domain.User) *holiday {
+	return &holiday{
+		UserID: u.ID,
+		Date:   in.Date,
+		Name:   in.Name,
+	}
+}
@@ -0,0 +1,878 @@
+package memory_repository
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kennep/timelapse/domain"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MemoryRepository keeps all data in process memory. Nothing is persisted, so
+// it is meant for local development and tests.
+type MemoryRepository struct {
+	mutex       sync.RWMutex
+	users       []*user
+	clients     []*client
+	projects    []*project
+	tasks       []*task
+	entries     []*timeEntry
+	entryTypes  []*entryType
+	invoices    []*invoice
+	schedules   []*schedule
+	adjustments []*balanceAdjustment
+	allowances  []*allowance
+	holidays    []*holiday
+}
+
+// NewMemoryRepository initializes an empty repository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{}
+}
+
+func (r *MemoryRepository) CreateUserFromContext(appCtx *domain.ApplicationContext) (*domain.User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, repoUser := range r.users {
+		for idx := range repoUser.Identities {
+			identity := &repoUser.Identities[idx]
+			if identity.SubjectID == appCtx.User.SubjectID && identity.Issuer == appCtx.User.Issuer {
+				if identity.Email != appCtx.User.Email {
+					log.Info("Email mismatch, updating")
+					identity.Email = appCtx.User.Email
+				}
+				return mapUserToDomain(repoUser), nil
+			}
+		}
+	}
+
+	log.Info("No user found, inserting")
+	repoUser := &user{
+		ID: newID(),
+		Identities: []identity{{
+			Issuer:    appCtx.User.Issuer,
+			SubjectID: appCtx.User.SubjectID,
+			Email:     appCtx.User.Email,
+		}},
+	}
+	r.users = append(r.users, repoUser)
+
+	return mapUserToDomain(repoUser), nil
+}
+
+func (r *MemoryRepository) SetFeedToken(u *domain.User, token string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, repoUser := range r.users {
+		if repoUser.ID == u.ID {
+			repoUser.FeedToken = token
+			return nil
+		}
+	}
+
+	return fmt.Errorf("User with id %s not found", u.ID)
+}
+
+func (r *MemoryRepository) SetTimerPolicy(u *domain.User, policy string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, repoUser := range r.users {
+		if repoUser.ID == u.ID {
+			repoUser.TimerPolicy = policy
+			return nil
+		}
+	}
+
+	return fmt.Errorf("User with id %s not found", u.ID)
+}
+
+func (r *MemoryRepository) GetEntryTypes(u *domain.User) ([]*domain.EntryType, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*domain.EntryType
+	for _, repoType := range r.entryTypes {
+		if repoType.UserID == u.ID {
+			result = append(result, mapEntryTypeToDomain(repoType))
+		}
+	}
+
+	return result, nil
+}
+
+func (r *MemoryRepository) SaveEntryType(u *domain.User, t domain.EntryType) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for idx, existing := range r.entryTypes {
+		if existing.UserID == u.ID && existing.Name == t.Name {
+			r.entryTypes[idx] = mapEntryTypeFromDomain(&t, u)
+			return nil
+		}
+	}
+	r.entryTypes = append(r.entryTypes, mapEntryTypeFromDomain(&t, u))
+
+	return nil
+}
+
+func (r *MemoryRepository) DeleteEntryType(u *domain.User, name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var entryTypes []*entryType
+	for _, existing := range r.entryTypes {
+		if existing.UserID != u.ID || existing.Name != name {
+			entryTypes = append(entryTypes, existing)
+		}
+	}
+	r.entryTypes = entryTypes
+
+	return nil
+}
+
+func (r *MemoryRepository) GetSchedules(u *domain.User) ([]*domain.Schedule, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*domain.Schedule
+	for _, repoSchedule := range r.schedules {
+		if repoSchedule.UserID == u.ID {
+			result = append(result, mapScheduleToDomain(repoSchedule))
+		}
+	}
+	domain.SortSchedules(result)
+
+	return result, nil
+}
+
+func (r *MemoryRepository) SaveSchedule(u *domain.User, s domain.Schedule) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for idx, existing := range r.schedules {
+		if existing.UserID == u.ID && existing.From == s.From {
+			r.schedules[idx] = mapScheduleFromDomain(&s, u)
+			return nil
+		}
+	}
+	r.schedules = append(r.schedules, mapScheduleFromDomain(&s, u))
+
+	return nil
+}
+
+func (r *MemoryRepository) DeleteSchedule(u *domain.User, from string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var schedules []*schedule
+	for _, existing := range r.schedules {
+		if existing.UserID != u.ID || existing.From != from {
+			schedules = append(schedules, existing)
+		}
+	}
+	r.schedules = schedules
+
+	return nil
+}
+
+func (r *MemoryRepository) AddBalanceAdjustment(u *domain.User, a domain.BalanceAdjustment) (*domain.BalanceAdjustment, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	repoAdjustment := mapBalanceAdjustmentFromDomain(&a, u)
+	repoAdjustment.ID = newID()
+	r.adjustments = append(r.adjustments, repoAdjustment)
+
+	return mapBalanceAdjustmentToDomain(repoAdjustment), nil
+}
+
+func (r *MemoryRepository) GetBalanceAdjustments(u *domain.User) ([]*domain.BalanceAdjustment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*domain.BalanceAdjustment
+	for _, repoAdjustment := range r.adjustments {
+		if repoAdjustment.UserID == u.ID {
+			result = append(result, mapBalanceAdjustmentToDomain(repoAdjustment))
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date < result[j].Date
+	})
+
+	return result, nil
+}
+
+func (r *MemoryRepository) DeleteBalanceAdjustment(u *domain.User, adjustmentID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var adjustments []*balanceAdjustment
+	for _, existing := range r.adjustments {
+		if existing.UserID != u.ID || existing.ID != adjustmentID {
+			adjustments = append(adjustments, existing)
+		}
+	}
+	r.adjustments = adjustments
+
+	return nil
+}
+
+func (r *MemoryRepository) GetAllowances(u *domain.User) ([]*domain.Allowance, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*domain.Allowance
+	for _, repoAllowance := range r.allowances {
+		if repoAllowance.UserID == u.ID {
+			result = append(result, mapAllowanceToDomain(repoAllowance))
+		}
+	}
+	domain.SortAllowances(result)
+
+	return result, nil
+}
+
+func (r *MemoryRepository) SaveAllowance(u *domain.User, a domain.Allowance) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for idx, existing := range r.allowances {
+		if existing.UserID == u.ID && existing.Type == a.Type && existing.From == a.From {
+			r.allowances[idx] = mapAllowanceFromDomain(&a, u)
+			return nil
+		}
+	}
+	r.allowances = append(r.allowances, mapAllowanceFromDomain(&a, u))
+
+	return nil
+}
+
+func (r *MemoryRepository) DeleteAllowance(u *domain.User, entryType string, from int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var allowances []*allowance
+	for _, existing := range r.allowances {
+		if existing.UserID != u.ID || existing.Type != entryType || existing.From != from {
+			allowances = append(allowances, existing)
+		}
+	}
+	r.allowances = allowances
+
+	return nil
+}
+
+func (r *MemoryRepository) GetHolidays(u *domain.User) ([]*domain.Holiday, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*domain.Holiday
+	for _, repoHoliday := range r.holidays {
+		if repoHoliday.UserID == u.ID {
+			result = append(result, mapHolidayToDomain(repoHoliday))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Date < result[j].Date
+	})
+
+	return result, nil
+}
+
+func (r *MemoryRepository) SaveHoliday(u *domain.User, h domain.Holiday) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for idx, existing := range r.holidays {
+		if existing.UserID == u.ID && existing.Date == h.Date {
+			r.holidays[idx] = mapHolidayFromDomain(&h, u)
+			return nil
+		}
+	}
+	r.holidays = append(r.holidays, mapHolidayFromDomain(&h, u))
+
+	return nil
+}
+
+func (r *MemoryRepository) DeleteHoliday(u *domain.User, date string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var holidays []*holiday
+	for _, existing := range r.holidays {
+		if existing.UserID != u.ID || existing.Date != date {
+			holidays = append(holidays, existing)
+		}
+	}
+	r.holidays = holidays
+
+	return nil
+}
+
+func (r *MemoryRepository) GetUserByFeedToken(token string) (*domain.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, repoUser := range r.users {
+		if repoUser.FeedToken == token {
+			return mapUserToDomain(repoUser), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *MemoryRepository) AddClient(c domain.Client) (*domain.Client, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	repoClient := mapClientFromDomain(&c)
+	repoClient.ID = newID()
+	r.clients = append(r.clients, repoClient)
+
+	return mapClientToDomain(repoClient, c.User), nil
+}
+
+func (r *MemoryRepository) UpdateClient(c domain.Client) (*domain.Client, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	repoClient := mapClientFromDomain(&c)
+	for idx, existing := range r.clients {
+		if existing.ID == c.ID && existing.UserID == repoClient.UserID {
+			r.clients[idx] = repoClient
+			return mapClientToDomain(repoClient, c.User), nil
+		}
+	}
+
+	return nil, fmt.Errorf("Client with id %s not found", c.ID)
+}
+
+func (r *MemoryRepository) DeleteClient(c *domain.Client) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	found := false
+	var clients []*client
+	for _, existing := range r.clients {
+		if existing.ID == c.ID && existing.UserID == c.User.ID {
+			found = true
+		} else {
+			clients = append(clients, existing)
+		}
+	}
+	if !found {
+		return fmt.Errorf("Client with id %s not found", c.ID)
+	}
+	r.clients = clients
+
+	return nil
+}
+
+func (r *MemoryRepository) GetClient(u *domain.User, clientName string) (*domain.Client, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, repoClient := range r.clients {
+		if repoClient.UserID == u.ID && repoClient.Name == clientName {
+			return mapClientToDomain(repoClient, u), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *MemoryRepository) GetClients(u *domain.User) ([]*domain.Client, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*domain.Client
+	for _, repoClient := range r.clients {
+		if repoClient.UserID == u.ID {
+			result = append(result, mapClientToDomain(repoClient, u))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+func (r *MemoryRepository) AddProject(p domain.Project) (*domain.Project, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	repoProject := mapProjectFromDomain(&p)
+	repoProject.ID = newID()
+	r.projects = append(r.projects, repoProject)
+
+	return mapProjectToDomain(repoProject, p.User), nil
+}
+
+func (r *MemoryRepository) UpdateProject(p domain.Project) (*domain.Project, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.updateProject(p)
+}
+
+func (r *MemoryRepository) RenameProject(p domain.Project, released []string) (*domain.Project, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	updated, err := r.updateProject(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range r.projects {
+		if other.UserID != p.User.ID || other.ID == p.ID {
+			continue
+		}
+		other.Aliases = withoutAliases(other.Aliases, released)
+	}
+	return updated, nil
+}
+
+func (r *MemoryRepository) updateProject(p domain.Project) (*domain.Project, error) {
+	repoProject := mapProjectFromDomain(&p)
+	for idx, existing := range r.projects {
+		if existing.ID == p.ID && existing.UserID == repoProject.UserID {
+			r.projects[idx] = repoProject
+			return mapProjectToDomain(repoProject, p.User), nil
+		}
+	}
+
+	return nil, fmt.Errorf("Project with id %s not found", p.ID)
+}
+
+func (r *MemoryRepository) DeleteProject(p *domain.Project) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	found := false
+	var projects []*project
+	for _, existing := range r.projects {
+		if existing.ID == p.ID && existing.UserID == p.User.ID {
+			found = true
+		} else {
+			projects = append(projects, existing)
+		}
+	}
+	if !found {
+		return fmt.Errorf("Project with id %s not found", p.ID)
+	}
+	for _, existing := range r.entries {
+		if existing.ProjectID == p.ID && existing.InvoiceNumber != 0 {
+			return domain.ErrEntryInvoiced
+		}
+	}
+	r.projects = projects
+
+	var tasks []*task
+	for _, existing := range r.tasks {
+		if existing.ProjectID != p.ID {
+			tasks = append(tasks, existing)
+		}
+	}
+	r.tasks = tasks
+
+	var entries []*timeEntry
+	for _, existing := range r.entries {
+		if existing.ProjectID != p.ID {
+			entries = append(entries, existing)
+		}
+	}
+	r.entries = entries
+
+	return nil
+}
+
+func (r *MemoryRepository) GetProject(u *domain.User, projectName string) (*domain.Project, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, repoProject := range r.projects {
+		if repoProject.UserID == u.ID && repoProject.Name == projectName {
+			return mapProjectToDomain(repoProject, u), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *MemoryRepository) getProjectById(u *domain.User, projectID string) *domain.Project {
+	for _, repoProject := range r.projects {
+		if repoProject.UserID == u.ID && repoProject.ID == projectID {
+			return mapProjectToDomain(repoProject, u)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) GetProjectByID(u *domain.User, projectID string) (*domain.Project, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.getProjectById(u, projectID), nil
+}
+
+func (r *MemoryRepository) GetProjects(u *domain.User, q domain.ProjectQuery) ([]*domain.Project, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*domain.Project
+	for _, repoProject := range r.projects {
+		if repoProject.UserID == u.ID {
+			result = append(result, mapProjectToDomain(repoProject, u))
+		}
+	}
+
+	return q.Apply(result), nil
+}
+
+func (r *MemoryRepository) AddTask(t domain.Task) (*domain.Task, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	repoTask := mapTaskFromDomain(&t)
+	repoTask.ID = newID()
+	r.tasks = append(r.tasks, repoTask)
+
+	return mapTaskToDomain(repoTask, t.Project), nil
+}
+
+func (r *MemoryRepository) UpdateTask(t domain.Task) (*domain.Task, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	repoTask := mapTaskFromDomain(&t)
+	for idx, existing := range r.tasks {
+		if existing.ID == t.ID && existing.ProjectID == repoTask.ProjectID {
+			r.tasks[idx] = repoTask
+			return mapTaskToDomain(repoTask, t.Project), nil
+		}
+	}
+
+	return nil, fmt.Errorf("Task with id %s not found", t.ID)
+}
+
+func (r *MemoryRepository) DeleteTask(t *domain.Task) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	found := false
+	var tasks []*task
+	for _, existing := range r.tasks {
+		if existing.ID == t.ID && existing.ProjectID == t.Project.ID {
+			found = true
+		} else {
+			tasks = append(tasks, existing)
+		}
+	}
+	if !found {
+		return fmt.Errorf("Task with id %s not found", t.ID)
+	}
+	r.tasks = tasks
+
+	return nil
+}
+
+func (r *MemoryRepository) GetTask(p *domain.Project, taskName string) (*domain.Task, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, repoTask := range r.tasks {
+		if repoTask.ProjectID == p.ID && repoTask.Name == taskName {
+			return mapTaskToDomain(repoTask, p), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *MemoryRepository) GetTasks(p *domain.Project) ([]*domain.Task, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*domain.Task
+	for _, repoTask := range r.tasks {
+		if repoTask.ProjectID == p.ID {
+			result = append(result, mapTaskToDomain(repoTask, p))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+func (r *MemoryRepository) GetUserTasks(u *domain.User) ([]*domain.Task, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*domain.Task
+	for _, repoTask := range r.tasks {
+		if repoTask.UserID == u.ID {
+			result = append(result, mapTaskToDomain(repoTask, r.getProjectById(u, repoTask.ProjectID)))
+		}
+	}
+
+	return result, nil
+}
+
+func (r *MemoryRepository) AddTimeEntry(p *domain.Project, e domain.TimeEntry) (*domain.TimeEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	e.Project = p
+
+	repoEntry := mapTimeEntryFromDomain(&e)
+	repoEntry.ID = newID()
+	r.entries = append(r.entries, repoEntry)
+
+	e.ID = repoEntry.ID
+	return &e, nil
+}
+
+func (r *MemoryRepository) AddTimeEntries(u *domain.User, entries []domain.TimeEntry) ([]*domain.TimeEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var result []*domain.TimeEntry
+	for _, e := range entries {
+		e := e
+		repoEntry := mapTimeEntryFromDomain(&e)
+		repoEntry.ID = newID()
+		r.entries = append(r.entries, repoEntry)
+
+		e.ID = repoEntry.ID
+		result = append(result, &e)
+	}
+
+	return result, nil
+}
+
+func (r *MemoryRepository) UpdateTimeEntry(p *domain.Project, e domain.TimeEntry) (*domain.TimeEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	repoEntry := mapTimeEntryFromDomain(&e)
+	for idx, existing := range r.entries {
+		if existing.ID == e.ID && existing.UserID == repoEntry.UserID {
+			if existing.InvoiceNumber != 0 {
+				return nil, domain.ErrEntryInvoiced
+			}
+			r.entries[idx] = repoEntry
+			return mapTimeEntryToDomain(repoEntry, p), nil
+		}
+	}
+
+	return nil, fmt.Errorf("Time entry with id %s not found", e.ID)
+}
+
+func (r *MemoryRepository) DeleteTimeEntry(p *domain.Project, entryID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for idx, existing := range r.entries {
+		if existing.ID == entryID && existing.ProjectID == p.ID {
+			if existing.InvoiceNumber != 0 {
+				return domain.ErrEntryInvoiced
+			}
+			r.entries = append(r.entries[:idx], r.entries[idx+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Time entry with id %s not found", entryID)
+}
+
+func (r *MemoryRepository) GetProjectTimeEntries(p *domain.Project, q domain.EntryQuery) ([]*domain.TimeEntry, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*domain.TimeEntry
+	for _, repoEntry := range r.entries {
+		if repoEntry.ProjectID == p.ID {
+			result = append(result, mapTimeEntryToDomain(repoEntry, p))
+		}
+	}
+
+	return q.Apply(result), nil
+}
+
+func (r *MemoryRepository) GetProjectTimeEntry(p *domain.Project, entryID string) (*domain.TimeEntry, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, repoEntry := range r.entries {
+		if repoEntry.ID == entryID && repoEntry.ProjectID == p.ID {
+			return mapTimeEntryToDomain(repoEntry, p), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *MemoryRepository) GetUserTimeEntries(u *domain.User, q domain.EntryQuery) ([]*domain.TimeEntry, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*domain.TimeEntry
+	for _, repoEntry := range r.entries {
+		if repoEntry.UserID == u.ID {
+			project := r.getProjectById(u, repoEntry.ProjectID)
+			result = append(result, mapTimeEntryToDomain(repoEntry, project))
+		}
+	}
+
+	return q.Apply(result), nil
+}
+
+func (r *MemoryRepository) GetTimeEntry(u *domain.User, entryID string) (*domain.TimeEntry, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, repoEntry := range r.entries {
+		if repoEntry.ID == entryID && repoEntry.UserID == u.ID {
+			return mapTimeEntryToDomain(repoEntry, r.getProjectById(u, repoEntry.ProjectID)), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *MemoryRepository) AddInvoice(inv domain.Invoice, entryIDs []string) (*domain.Invoice, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	invoiced := make(map[string]bool)
+	for _, id := range entryIDs {
+		invoiced[id] = true
+	}
+	var entries []*timeEntry
+	for _, repoEntry := range r.entries {
+		if repoEntry.UserID == inv.User.ID && invoiced[repoEntry.ID] {
+			if repoEntry.InvoiceNumber != 0 {
+				return nil, domain.ErrEntryInvoiced
+			}
+			entries = append(entries, repoEntry)
+		}
+	}
+	if len(entries) != len(invoiced) {
+		return nil, fmt.Errorf("Time entries not found")
+	}
+
+	inv.ID = newID()
+	inv.Number = 1
+	for _, existing := range r.invoices {
+		if existing.UserID == inv.User.ID && existing.Number >= inv.Number {
+			inv.Number = existing.Number + 1
+		}
+	}
+	r.invoices = append(r.invoices, mapInvoiceFromDomain(&inv))
+	for _, repoEntry := range entries {
+		repoEntry.InvoiceNumber = inv.Number
+	}
+
+	return &inv, nil
+}
+
+func (r *MemoryRepository) GetInvoice(u *domain.User, number int) (*domain.Invoice, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, repoInvoice := range r.invoices {
+		if repoInvoice.UserID == u.ID && repoInvoice.Number == number {
+			return mapInvoiceToDomain(repoInvoice, u), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (r *MemoryRepository) GetInvoices(u *domain.User) ([]*domain.Invoice, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var result []*domain.Invoice
+	for _, repoInvoice := range r.invoices {
+		if repoInvoice.UserID == u.ID {
+			result = append(result, mapInvoiceToDomain(repoInvoice, u))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Number < result[j].Number
+	})
+
+	return result, nil
+}
+
+func (r *MemoryRepository) StartTimer(p *domain.Project, e domain.TimeEntry, stopRunning bool) (*domain.TimeEntry, []*domain.TimeEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var running []*timeEntry
+	for _, repoEntry := range r.entries {
+		if repoEntry.UserID == p.User.ID && repoEntry.End == nil {
+			if !stopRunning || (repoEntry.Start != nil && e.Start.Before(*repoEntry.Start)) {
+				return nil, nil, domain.ErrTimerRunning
+			}
+			running = append(running, repoEntry)
+		}
+	}
+
+	var stopped []*domain.TimeEntry
+	for _, repoEntry := range running {
+		end := *e.Start
+		repoEntry.End = &end
+		stopped = append(stopped, mapTimeEntryToDomain(repoEntry, r.getProjectById(p.User, repoEntry.ProjectID)))
+	}
+
+	e.Project = p
+	repoEntry := mapTimeEntryFromDomain(&e)
+	repoEntry.ID = newID()
+	r.entries = append(r.entries, repoEntry)
+
+	e.ID = repoEntry.ID
+	return &e, stopped, nil
+}
+
+func (r *MemoryRepository) StopTimer(u *domain.User, p *domain.Project, end time.Time, breaks *time.Duration, validate func(*domain.TimeEntry) error) (*domain.TimeEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var running *timeEntry
+	for _, repoEntry := range r.entries {
+		if repoEntry.UserID == u.ID && repoEntry.End == nil && (p == nil || repoEntry.ProjectID == p.ID) {
+			if running == nil || (repoEntry.Start != nil && (running.Start == nil || !repoEntry.Start.Before(*running.Start))) {
+				running = repoEntry
+			}
+		}
+	}
+	if running == nil {
+		return nil, nil
+	}
+
+	stopped := mapTimeEntryToDomain(running, r.getProjectById(u, running.ProjectID))
+	stopped.End = &end
+	if breaks != nil {
+		stopped.Breaks = *breaks
+	}
+	if err := validate(stopped); err != nil {
+		return nil, err
+	}
+
+	running.End = stopped.End
+	running.Breaks = stopped.Breaks
+	return stopped, nil
+}
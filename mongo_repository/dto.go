@@ -14,26 +14,133 @@ type (
 	}
 
 	user struct {
-		ID         primitive.ObjectID `bson:"_id"`
-		Identities []identity         `bson:"identities"`
+		ID          primitive.ObjectID `bson:"_id"`
+		Identities  []identity         `bson:"identities"`
+		FeedToken   string             `bson:"feedtoken,omitempty"`
+		TimerPolicy string             `bson:"timerpolicy,omitempty"`
+		// TimerLock is set until the time a lock on the user's timers expires, see lockTimers
+		TimerLock *time.Time `bson:"timerlock,omitempty"`
+	}
+
+	rate struct {
+		EntryType string  `bson:"entrytype,omitempty"`
+		Amount    float64 `bson:"amount"`
+		Currency  string  `bson:"currency"`
+		From      string  `bson:"from,omitempty"`
+	}
+
+	budget struct {
+		Hours      time.Duration `bson:"hours"`
+		Amount     float64       `bson:"amount"`
+		Currency   string        `bson:"currency,omitempty"`
+		Period     string        `bson:"period"`
+		Thresholds []int         `bson:"thresholds,omitempty"`
+	}
+
+	client struct {
+		ID          primitive.ObjectID `bson:"_id"`
+		UserID      primitive.ObjectID `bson:"userid"`
+		Name        string             `bson:"name"`
+		Description string             `bson:"description"`
+		Rates       []rate             `bson:"rates,omitempty"`
 	}
 
 	project struct {
+		ID          primitive.ObjectID  `bson:"_id"`
+		UserID      primitive.ObjectID  `bson:"userid"`
+		Name        string              `bson:"name"`
+		Description string              `bson:"description"`
+		Billable    bool                `bson:"billable"`
+		ClientID    *primitive.ObjectID `bson:"clientid,omitempty"`
+		Rates       []rate              `bson:"rates,omitempty"`
+		Budget      *budget             `bson:"budget,omitempty"`
+		Aliases     []string            `bson:"aliases,omitempty"`
+		Archived    bool                `bson:"archived,omitempty"`
+	}
+
+	task struct {
 		ID          primitive.ObjectID `bson:"_id"`
 		UserID      primitive.ObjectID `bson:"userid"`
+		ProjectID   primitive.ObjectID `bson:"projectid"`
 		Name        string             `bson:"name"`
 		Description string             `bson:"description"`
-		Billable    bool               `bson:"billable"`
+	}
+
+	entryType struct {
+		ID           primitive.ObjectID `bson:"_id,omitempty"`
+		UserID       primitive.ObjectID `bson:"userid"`
+		Name         string             `bson:"name"`
+		TimeBased    bool               `bson:"timebased"`
+		CountsAsWork bool               `bson:"countsaswork"`
+		// FulfillsSchedule is missing in types stored before it was added, which then
+		// take it from the default type with the same name, if any
+		FulfillsSchedule *bool  `bson:"fulfillsschedule,omitempty"`
+		Color            string `bson:"color"`
 	}
 
 	timeEntry struct {
-		ID        primitive.ObjectID `bson:"_id"`
-		ProjectID primitive.ObjectID `bson:"projectid"`
+		ID        primitive.ObjectID  `bson:"_id"`
+		ProjectID primitive.ObjectID  `bson:"projectid"`
+		TaskID    *primitive.ObjectID `bson:"taskid,omitempty"`
+		UserID    primitive.ObjectID  `bson:"userid"`
+		Type      string              `bson:"type"`
+		Start     *time.Time          `bson:"start"`
+		End       *time.Time          `bson:"end"`
+		Breaks    time.Duration       `bson:"breaks"`
+		Comment   string              `bson:"comment"`
+		Tags      []string            `bson:"tags,omitempty"`
+		// InvoiceNumber is left out for entries that have not been invoiced
+		InvoiceNumber int `bson:"invoicenumber,omitempty"`
+	}
+
+	invoiceLine struct {
+		Project     string        `bson:"project"`
+		Description string        `bson:"description"`
+		Hours       time.Duration `bson:"hours"`
+		Rate        float64       `bson:"rate"`
+		Currency    string        `bson:"currency"`
+		Amount      float64       `bson:"amount"`
+	}
+
+	invoice struct {
+		ID     primitive.ObjectID `bson:"_id"`
+		UserID primitive.ObjectID `bson:"userid"`
+		Number int                `bson:"number"`
+		Issued time.Time          `bson:"issued"`
+		Client string             `bson:"client"`
+		From   *time.Time         `bson:"from"`
+		To     *time.Time         `bson:"to"`
+		Lines  []invoiceLine      `bson:"lines"`
+	}
+
+	schedule struct {
+		ID     primitive.ObjectID `bson:"_id,omitempty"`
+		UserID primitive.ObjectID `bson:"userid"`
+		From   string             `bson:"from"`
+		Hours  []time.Duration    `bson:"hours"`
+	}
+
+	balanceAdjustment struct {
+		ID      primitive.ObjectID `bson:"_id"`
+		UserID  primitive.ObjectID `bson:"userid"`
+		Date    string             `bson:"date"`
+		Amount  time.Duration      `bson:"amount"`
+		Comment string             `bson:"comment"`
+	}
+
+	allowance struct {
+		ID        primitive.ObjectID `bson:"_id,omitempty"`
 		UserID    primitive.ObjectID `bson:"userid"`
 		Type      string             `bson:"type"`
-		Start     *time.Time         `bson:"start"`
-		End       *time.Time         `bson:"end"`
-		Breaks    time.Duration      `bson:"breaks"`
-		Comment   string             `bson:"comment"`
+		From      int                `bson:"from"`
+		Days      int                `bson:"days"`
+		CarryOver int                `bson:"carryover"`
+	}
+
+	holiday struct {
+		ID     primitive.ObjectID `bson:"_id,omitempty"`
+		UserID primitive.ObjectID `bson:"userid"`
+		Date   string             `bson:"date"`
+		Name   string             `bson:"name"`
 	}
 )
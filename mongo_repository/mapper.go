@@ -6,7 +6,9 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/mongodb/mongo-go-driver/bson"
 	"github.com/mongodb/mongo-go-driver/bson/primitive"
+	"github.com/mongodb/mongo-go-driver/mongo/options"
 
 	"github.com/kennep/timelapse/domain"
 )
@@ -50,7 +52,9 @@ func newID() primitive.ObjectID {
 
 func mapUserToDomain(in *user) *domain.User {
 	out := domain.User{
-		ID: idToString(in.ID),
+		ID:          idToString(in.ID),
+		FeedToken:   in.FeedToken,
+		TimerPolicy: in.TimerPolicy,
 	}
 	for _, identity := range in.Identities {
 		out.Identities = append(out.Identities,
@@ -63,14 +67,78 @@ func mapUserToDomain(in *user) *domain.User {
 	return &out
 }
 
+func mapRatesToDomain(in []rate) []domain.Rate {
+	var out []domain.Rate
+	for _, r := range in {
+		out = append(out, domain.Rate{EntryType: r.EntryType, Amount: r.Amount, Currency: r.Currency, From: r.From})
+	}
+	return out
+}
+
+func mapRatesFromDomain(in []domain.Rate) []rate {
+	var out []rate
+	for _, r := range in {
+		out = append(out, rate{EntryType: r.EntryType, Amount: r.Amount, Currency: r.Currency, From: r.From})
+	}
+	return out
+}
+
+func mapBudgetToDomain(in *budget) *domain.Budget {
+	if in == nil {
+		return nil
+	}
+	out := domain.Budget(*in)
+	return &out
+}
+
+func mapBudgetFromDomain(in *domain.Budget) *budget {
+	if in == nil {
+		return nil
+	}
+	out := budget(*in)
+	return &out
+}
+
+func mapClientToDomain(in *client, user *domain.User) *domain.Client {
+	return &domain.Client{
+		ID:          idToString(in.ID),
+		User:        user,
+		Name:        in.Name,
+		Description: in.Description,
+		Rates:       mapRatesToDomain(in.Rates),
+	}
+}
+
+func mapClientFromDomain(in *domain.Client) (*client, error) {
+	ids, err := stringsToIDs(in.ID, in.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		ID:          ids[0],
+		UserID:      ids[1],
+		Name:        in.Name,
+		Description: in.Description,
+		Rates:       mapRatesFromDomain(in.Rates),
+	}, nil
+}
+
 func mapProjectToDomain(in *project, user *domain.User) *domain.Project {
-	return &domain.Project{
+	out := domain.Project{
 		ID:          idToString(in.ID),
 		User:        user,
 		Name:        in.Name,
 		Description: in.Description,
 		Billable:    in.Billable,
+		Rates:       mapRatesToDomain(in.Rates),
+		Budget:      mapBudgetToDomain(in.Budget),
+		Aliases:     in.Aliases,
+		Archived:    in.Archived,
 	}
+	if in.ClientID != nil {
+		out.ClientID = idToString(*in.ClientID)
+	}
+	return &out
 }
 
 func mapProjectFromDomain(in *domain.Project) (*project, error) {
@@ -78,41 +146,320 @@ func mapProjectFromDomain(in *domain.Project) (*project, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &project{
+	out := project{
 		ID:          ids[0],
 		UserID:      ids[1],
 		Name:        in.Name,
 		Description: in.Description,
 		Billable:    in.Billable,
+		Rates:       mapRatesFromDomain(in.Rates),
+		Budget:      mapBudgetFromDomain(in.Budget),
+		Aliases:     in.Aliases,
+		Archived:    in.Archived,
+	}
+	if in.ClientID != "" {
+		clientid, err := stringToID(in.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		out.ClientID = &clientid
+	}
+	return &out, nil
+}
+
+func mapEntryTypeToDomain(in *entryType) *domain.EntryType {
+	fulfillsSchedule := false
+	if in.FulfillsSchedule != nil {
+		fulfillsSchedule = *in.FulfillsSchedule
+	} else if t := domain.DefaultEntryType(in.Name); t != nil {
+		fulfillsSchedule = t.FulfillsSchedule
+	}
+	return &domain.EntryType{
+		Name:             in.Name,
+		TimeBased:        in.TimeBased,
+		CountsAsWork:     in.CountsAsWork,
+		FulfillsSchedule: fulfillsSchedule,
+		Color:            in.Color,
+	}
+}
+
+func mapEntryTypeFromDomain(in *domain.EntryType, u *domain.User) (*entryType, error) {
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	fulfillsSchedule := in.FulfillsSchedule
+	return &entryType{
+		UserID:           userid,
+		Name:             in.Name,
+		TimeBased:        in.TimeBased,
+		CountsAsWork:     in.CountsAsWork,
+		FulfillsSchedule: &fulfillsSchedule,
+		Color:            in.Color,
 	}, nil
 }
 
-func mapTimeEntryToDomain(in *timeEntry, p *domain.Project) *domain.TimeEntry {
-	return &domain.TimeEntry{
+func mapScheduleToDomain(in *schedule) *domain.Schedule {
+	return &domain.Schedule{
+		From:  in.From,
+		Hours: in.Hours,
+	}
+}
+
+func mapScheduleFromDomain(in *domain.Schedule, u *domain.User) (*schedule, error) {
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &schedule{
+		UserID: userid,
+		From:   in.From,
+		Hours:  in.Hours,
+	}, nil
+}
+
+func mapBalanceAdjustmentToDomain(in *balanceAdjustment) *domain.BalanceAdjustment {
+	return &domain.BalanceAdjustment{
 		ID:      idToString(in.ID),
-		Project: p,
-		Type:    in.Type,
-		Start:   in.Start,
-		End:     in.End,
-		Breaks:  in.Breaks,
+		Date:    in.Date,
+		Amount:  in.Amount,
 		Comment: in.Comment,
 	}
 }
 
+func mapBalanceAdjustmentFromDomain(in *domain.BalanceAdjustment, u *domain.User) (*balanceAdjustment, error) {
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &balanceAdjustment{
+		UserID:  userid,
+		Date:    in.Date,
+		Amount:  in.Amount,
+		Comment: in.Comment,
+	}, nil
+}
+
+func mapAllowanceToDomain(in *allowance) *domain.Allowance {
+	return &domain.Allowance{
+		Type:      in.Type,
+		From:      in.From,
+		Days:      in.Days,
+		CarryOver: in.CarryOver,
+	}
+}
+
+func mapAllowanceFromDomain(in *domain.Allowance, u *domain.User) (*allowance, error) {
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &allowance{
+		UserID:    userid,
+		Type:      in.Type,
+		From:      in.From,
+		Days:      in.Days,
+		CarryOver: in.CarryOver,
+	}, nil
+}
+
+func mapHolidayToDomain(in *holiday) *domain.Holiday {
+	return &domain.Holiday{
+		Date: in.Date,
+		Name: in.Name,
+	}
+}
+
+func mapHolidayFromDomain(in *domain.Holiday, u *domain.User) (*holiday, error) {
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &holiday{
+		UserID: userid,
+		Date:   in.Date,
+		Name:   in.Name,
+	}, nil
+}
+
+func mapTaskToDomain(in *task, p *domain.Project) *domain.Task {
+	return &domain.Task{
+		ID:          idToString(in.ID),
+		Project:     p,
+		Name:        in.Name,
+		Description: in.Description,
+	}
+}
+
+func mapTaskFromDomain(in *domain.Task) (*task, error) {
+	ids, err := stringsToIDs(in.ID, in.Project.User.ID, in.Project.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &task{
+		ID:          ids[0],
+		UserID:      ids[1],
+		ProjectID:   ids[2],
+		Name:        in.Name,
+		Description: in.Description,
+	}, nil
+}
+
+func mapTimeEntryToDomain(in *timeEntry, p *domain.Project) *domain.TimeEntry {
+	out := domain.TimeEntry{
+		ID:            idToString(in.ID),
+		Project:       p,
+		Type:          in.Type,
+		Start:         in.Start,
+		End:           in.End,
+		Breaks:        in.Breaks,
+		Comment:       in.Comment,
+		Tags:          in.Tags,
+		InvoiceNumber: in.InvoiceNumber,
+	}
+	if in.TaskID != nil {
+		out.TaskID = idToString(*in.TaskID)
+	}
+	return &out
+}
+
 func mapTimeEntryFromDomain(in *domain.TimeEntry) (*timeEntry, error) {
 	ids, err := stringsToIDs(in.ID, in.Project.ID, in.Project.User.ID)
 	if err != nil {
 		return nil, err
 	}
 	log.Infof("Time entry ID: %s", ids[0])
-	return &timeEntry{
-		ID:        ids[0],
-		ProjectID: ids[1],
-		UserID:    ids[2],
-		Type:      in.Type,
-		Start:     in.Start,
-		End:       in.End,
-		Breaks:    in.Breaks,
-		Comment:   in.Comment,
-	}, nil
+	out := timeEntry{
+		ID:            ids[0],
+		ProjectID:     ids[1],
+		UserID:        ids[2],
+		Type:          in.Type,
+		Start:         in.Start,
+		End:           in.End,
+		Breaks:        in.Breaks,
+		Comment:       in.Comment,
+		Tags:          in.Tags,
+		InvoiceNumber: in.InvoiceNumber,
+	}
+	if in.TaskID != "" {
+		taskid, err := stringToID(in.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		out.TaskID = &taskid
+	}
+	return &out, nil
+}
+
+func mapInvoiceToDomain(in *invoice, user *domain.User) *domain.Invoice {
+	out := domain.Invoice{
+		ID:     idToString(in.ID),
+		User:   user,
+		Number: in.Number,
+		Issued: in.Issued,
+		Client: in.Client,
+		From:   in.From,
+		To:     in.To,
+	}
+	for _, line := range in.Lines {
+		out.Lines = append(out.Lines, domain.InvoiceLine(line))
+	}
+	return &out
+}
+
+func mapInvoiceFromDomain(in *domain.Invoice) (*invoice, error) {
+	ids, err := stringsToIDs(in.ID, in.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	out := invoice{
+		ID:     ids[0],
+		UserID: ids[1],
+		Number: in.Number,
+		Issued: in.Issued,
+		Client: in.Client,
+		From:   in.From,
+		To:     in.To,
+	}
+	for _, line := range in.Lines {
+		out.Lines = append(out.Lines, invoiceLine(line))
+	}
+	return &out, nil
+}
+
+// entryFilter adds the conditions of an entry query to a time entry filter
+func entryFilter(filter bson.M, q domain.EntryQuery) (bson.M, error) {
+	start := bson.M{}
+	if q.From != nil {
+		start["$gte"] = *q.From
+	}
+	if q.To != nil {
+		start["$lt"] = *q.To
+	}
+	if len(start) > 0 {
+		filter["start"] = start
+	}
+	if q.Type != "" {
+		filter["type"] = q.Type
+	}
+	if q.TaskID != "" {
+		taskid, err := stringToID(q.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		filter["taskid"] = taskid
+	}
+	if q.OpenOnly {
+		filter["end"] = nil
+	}
+	if len(q.Tags) > 0 {
+		if q.AnyTag {
+			filter["tags"] = bson.M{"$in": q.Tags}
+		} else {
+			filter["tags"] = bson.M{"$all": q.Tags}
+		}
+	}
+	if q.After != nil {
+		afterID, err := stringToID(q.After.ID)
+		if err != nil {
+			return nil, err
+		}
+		filter["$or"] = bson.A{
+			bson.M{"start": bson.M{"$gt": q.After.Start}},
+			bson.M{"start": q.After.Start, "_id": bson.M{"$gt": afterID}},
+		}
+	}
+	return filter, nil
+}
+
+// entryFindOptions orders time entries by start time and ID and applies the query limit
+func entryFindOptions(q domain.EntryQuery) *options.FindOptions {
+	opts := options.Find().SetSort(bson.D{{Key: "start", Value: 1}, {Key: "_id", Value: 1}})
+	if q.Limit > 0 {
+		opts.SetLimit(int64(q.Limit))
+	}
+	return opts
+}
+
+// projectFilter adds the conditions of a project query to a project filter
+func projectFilter(filter bson.M, q domain.ProjectQuery) bson.M {
+	if q.After != "" {
+		filter["name"] = bson.M{"$gt": q.After}
+	}
+	if q.Archived != nil && *q.Archived {
+		filter["archived"] = true
+	} else if q.Archived != nil {
+		// Active projects are stored without the field
+		filter["archived"] = bson.M{"$ne": true}
+	}
+	return filter
+}
+
+// projectFindOptions orders projects by name and applies the query limit
+func projectFindOptions(q domain.ProjectQuery) *options.FindOptions {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	if q.Limit > 0 {
+		opts.SetLimit(int64(q.Limit))
+	}
+	return opts
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/mongodb/mongo-go-driver/mongo"
 	"github.com/mongodb/mongo-go-driver/mongo/options"
 	"github.com/mongodb/mongo-go-driver/mongo/readpref"
+	"github.com/mongodb/mongo-go-driver/x/bsonx"
 
 	"github.com/kennep/timelapse/domain"
 
@@ -65,9 +66,102 @@ func NewMongoRepository() (*MongoRepository, error) {
 	repository.connectionString = client.ConnectionString()
 	repository.database = client.Database(database)
 
+	err = repository.ensureIndexes()
+	if err != nil {
+		return nil, err
+	}
+
 	return &repository, nil
 }
 
+// ensureIndexes creates the indexes used by the time entry queries. Creating an
+// index that already exists is a no-op.
+func (r *MongoRepository) ensureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.database.Collection("timeentries").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bsonx.Doc{}.Append("userid", bsonx.Int32(1)).Append("start", bsonx.Int32(1))},
+		{Keys: bsonx.Doc{}.Append("projectid", bsonx.Int32(1)).Append("start", bsonx.Int32(1))},
+		{Keys: bsonx.Doc{}.Append("userid", bsonx.Int32(1)).Append("type", bsonx.Int32(1)).Append("start", bsonx.Int32(1))},
+		{Keys: bsonx.Doc{}.Append("userid", bsonx.Int32(1)).Append("end", bsonx.Int32(1))},
+		{Keys: bsonx.Doc{}.Append("userid", bsonx.Int32(1)).Append("tags", bsonx.Int32(1))},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.database.Collection("projects").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bsonx.Doc{}.Append("userid", bsonx.Int32(1)).Append("name", bsonx.Int32(1)),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.database.Collection("users").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bsonx.Doc{}.Append("feedtoken", bsonx.Int32(1)),
+		Options: bsonx.Doc{}.Append("unique", bsonx.Boolean(true)).Append("sparse", bsonx.Boolean(true)),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.database.Collection("entrytypes").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bsonx.Doc{}.Append("userid", bsonx.Int32(1)).Append("name", bsonx.Int32(1)),
+		Options: bsonx.Doc{}.Append("unique", bsonx.Boolean(true)),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.database.Collection("clients").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bsonx.Doc{}.Append("userid", bsonx.Int32(1)).Append("name", bsonx.Int32(1)),
+		Options: bsonx.Doc{}.Append("unique", bsonx.Boolean(true)),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.database.Collection("tasks").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bsonx.Doc{}.Append("projectid", bsonx.Int32(1)).Append("name", bsonx.Int32(1)),
+		Options: bsonx.Doc{}.Append("unique", bsonx.Boolean(true)),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.database.Collection("schedules").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bsonx.Doc{}.Append("userid", bsonx.Int32(1)).Append("from", bsonx.Int32(1)),
+		Options: bsonx.Doc{}.Append("unique", bsonx.Boolean(true)),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.database.Collection("allowances").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bsonx.Doc{}.Append("userid", bsonx.Int32(1)).Append("type", bsonx.Int32(1)).Append("from", bsonx.Int32(1)),
+		Options: bsonx.Doc{}.Append("unique", bsonx.Boolean(true)),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.database.Collection("holidays").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bsonx.Doc{}.Append("userid", bsonx.Int32(1)).Append("date", bsonx.Int32(1)),
+		Options: bsonx.Doc{}.Append("unique", bsonx.Boolean(true)),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The unique index keeps invoice numbers from being used twice
+	_, err = r.database.Collection("invoices").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bsonx.Doc{}.Append("userid", bsonx.Int32(1)).Append("number", bsonx.Int32(1)),
+		Options: bsonx.Doc{}.Append("unique", bsonx.Boolean(true)),
+	})
+	return err
+}
+
 func (r *MongoRepository) CreateUserFromContext(appCtx *domain.ApplicationContext) (*domain.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
@@ -125,125 +219,203 @@ func (r *MongoRepository) CreateUserFromContext(appCtx *domain.ApplicationContex
 
 }
 
-func (r *MongoRepository) AddProject(p domain.Project) (*domain.Project, error) {
+func (r *MongoRepository) SetFeedToken(u *domain.User, token string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	repoProject, err := mapProjectFromDomain(&p)
+	userid, err := stringToID(u.ID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	repoProject.ID = newID()
-	_, err = r.database.Collection("projects").InsertOne(ctx, repoProject)
+
+	update := bson.M{"$set": bson.M{"feedtoken": token}}
+	if token == "" {
+		update = bson.M{"$unset": bson.M{"feedtoken": ""}}
+	}
+	result, err := r.database.Collection("users").UpdateOne(ctx, bson.M{"_id": userid}, update)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if result.MatchedCount != 1 {
+		return fmt.Errorf("User with id %s not found", u.ID)
 	}
 
-	return mapProjectToDomain(repoProject, p.User), nil
+	return nil
 }
 
-func (r *MongoRepository) UpdateProject(p domain.Project) (*domain.Project, error) {
+func (r *MongoRepository) SetTimerPolicy(u *domain.User, policy string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	projectid, err := stringToID(p.ID)
-	if err != err {
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.database.Collection("users").UpdateOne(ctx, bson.M{"_id": userid}, bson.M{"$set": bson.M{"timerpolicy": policy}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount != 1 {
+		return fmt.Errorf("User with id %s not found", u.ID)
+	}
+
+	return nil
+}
+
+func (r *MongoRepository) GetEntryTypes(u *domain.User) ([]*domain.EntryType, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
+	if err != nil {
 		return nil, err
 	}
-	repoProject, err := mapProjectFromDomain(&p)
+
+	var result []*domain.EntryType
+
+	cursor, err := r.database.Collection("entrytypes").Find(ctx, bson.M{"userid": userid}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
 	if err != nil {
 		return nil, err
 	}
+	for cursor.Next(ctx) {
+		var repoType entryType
+		err = cursor.Decode(&repoType)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mapEntryTypeToDomain(&repoType))
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
 
-	filter := bson.M{"_id": projectid}
+	return result, nil
+}
 
-	result, err := r.database.Collection("projects").ReplaceOne(ctx, filter, repoProject)
+func (r *MongoRepository) SaveEntryType(u *domain.User, t domain.EntryType) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
 
+	repoType, err := mapEntryTypeFromDomain(&t, u)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if result.MatchedCount != 1 {
-		return nil, fmt.Errorf("Project with id %s not found", projectid)
+
+	filter := bson.M{"userid": repoType.UserID, "name": repoType.Name}
+	_, err = r.database.Collection("entrytypes").ReplaceOne(ctx, filter, repoType, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *MongoRepository) DeleteEntryType(u *domain.User, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return err
 	}
 
-	return mapProjectToDomain(repoProject, p.User), nil
+	_, err = r.database.Collection("entrytypes").DeleteOne(ctx, bson.M{"userid": userid, "name": name})
+	return err
 }
 
-func (r *MongoRepository) GetProject(u *domain.User, projectName string) (*domain.Project, error) {
+func (r *MongoRepository) GetSchedules(u *domain.User) ([]*domain.Schedule, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
 	userid, err := stringToID(u.ID)
-	if err != err {
+	if err != nil {
 		return nil, err
 	}
-	filter := bson.M{"userid": userid, "name": projectName}
 
-	result := r.database.Collection("projects").FindOne(ctx, filter)
-	if result.Err() != nil {
+	var result []*domain.Schedule
+
+	cursor, err := r.database.Collection("schedules").Find(ctx, bson.M{"userid": userid}, options.Find().SetSort(bson.D{{Key: "from", Value: 1}}))
+	if err != nil {
 		return nil, err
 	}
-
-	var repoProject project
-	err = result.Decode(&repoProject)
-	if err == mongo.ErrNoDocuments {
-		return nil, nil
-	} else if err != nil {
+	for cursor.Next(ctx) {
+		var repoSchedule schedule
+		err = cursor.Decode(&repoSchedule)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mapScheduleToDomain(&repoSchedule))
+	}
+	if err = cursor.Err(); err != nil {
 		return nil, err
 	}
 
-	return mapProjectToDomain(&repoProject, u), nil
+	return result, nil
 }
 
-func (r *MongoRepository) getProjectById(u *domain.User, projectID primitive.ObjectID) (*domain.Project, error) {
+func (r *MongoRepository) SaveSchedule(u *domain.User, s domain.Schedule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	repoSchedule, err := mapScheduleFromDomain(&s, u)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"userid": repoSchedule.UserID, "from": repoSchedule.From}
+	_, err = r.database.Collection("schedules").ReplaceOne(ctx, filter, repoSchedule, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *MongoRepository) DeleteSchedule(u *domain.User, from string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
 	userid, err := stringToID(u.ID)
-	if err != err {
-		return nil, err
+	if err != nil {
+		return err
 	}
-	filter := bson.M{"userid": userid, "_id": projectID}
 
-	result := r.database.Collection("projects").FindOne(ctx, filter)
-	if result.Err() != nil {
+	_, err = r.database.Collection("schedules").DeleteOne(ctx, bson.M{"userid": userid, "from": from})
+	return err
+}
+
+func (r *MongoRepository) AddBalanceAdjustment(u *domain.User, a domain.BalanceAdjustment) (*domain.BalanceAdjustment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	repoAdjustment, err := mapBalanceAdjustmentFromDomain(&a, u)
+	if err != nil {
 		return nil, err
 	}
-
-	var repoProject project
-	err = result.Decode(&repoProject)
-	if err == mongo.ErrNoDocuments {
-		return nil, nil
-	} else if err != nil {
+	repoAdjustment.ID = newID()
+	_, err = r.database.Collection("balanceadjustments").InsertOne(ctx, repoAdjustment)
+	if err != nil {
 		return nil, err
 	}
 
-	return mapProjectToDomain(&repoProject, u), nil
+	return mapBalanceAdjustmentToDomain(repoAdjustment), nil
 }
 
-func (r *MongoRepository) GetProjects(u *domain.User) ([]*domain.Project, error) {
+func (r *MongoRepository) GetBalanceAdjustments(u *domain.User) ([]*domain.BalanceAdjustment, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
 	userid, err := stringToID(u.ID)
-	if err != err {
+	if err != nil {
 		return nil, err
 	}
-	filter := bson.M{"userid": userid}
 
-	var result []*domain.Project
+	var result []*domain.BalanceAdjustment
 
-	cursor, err := r.database.Collection("projects").Find(ctx, filter)
+	cursor, err := r.database.Collection("balanceadjustments").Find(ctx, bson.M{"userid": userid}, options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "_id", Value: 1}}))
 	if err != nil {
 		return nil, err
 	}
 	for cursor.Next(ctx) {
-		var repoProject project
-		err = cursor.Decode(&repoProject)
+		var repoAdjustment balanceAdjustment
+		err = cursor.Decode(&repoAdjustment)
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, mapProjectToDomain(&repoProject, u))
+		result = append(result, mapBalanceAdjustmentToDomain(&repoAdjustment))
 	}
 	if err = cursor.Err(); err != nil {
 		return nil, err
@@ -252,72 +424,98 @@ func (r *MongoRepository) GetProjects(u *domain.User) ([]*domain.Project, error)
 	return result, nil
 }
 
-func (r *MongoRepository) AddTimeEntry(p *domain.Project, e domain.TimeEntry) (*domain.TimeEntry, error) {
+func (r *MongoRepository) DeleteBalanceAdjustment(u *domain.User, adjustmentID string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	e.Project = p
+	ids, err := stringsToIDs(adjustmentID, u.ID)
+	if err != nil {
+		return err
+	}
 
-	repoEntry, err := mapTimeEntryFromDomain(&e)
-	repoEntry.ID = newID()
+	_, err = r.database.Collection("balanceadjustments").DeleteOne(ctx, bson.M{"_id": ids[0], "userid": ids[1]})
+	return err
+}
+
+func (r *MongoRepository) GetAllowances(u *domain.User) ([]*domain.Allowance, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
 	if err != nil {
 		return nil, err
 	}
-	_, err = r.database.Collection("timeentries").InsertOne(ctx, repoEntry)
+
+	var result []*domain.Allowance
+
+	cursor, err := r.database.Collection("allowances").Find(ctx, bson.M{"userid": userid}, options.Find().SetSort(bson.D{{Key: "type", Value: 1}, {Key: "from", Value: 1}}))
 	if err != nil {
 		return nil, err
 	}
+	for cursor.Next(ctx) {
+		var repoAllowance allowance
+		err = cursor.Decode(&repoAllowance)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mapAllowanceToDomain(&repoAllowance))
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
 
-	e.ID = idToString(repoEntry.ID)
-	return &e, nil
+	return result, nil
 }
 
-func (r *MongoRepository) UpdateTimeEntry(p *domain.Project, e domain.TimeEntry) (*domain.TimeEntry, error) {
+func (r *MongoRepository) SaveAllowance(u *domain.User, a domain.Allowance) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	repoEntry, err := mapTimeEntryFromDomain(&e)
+	repoAllowance, err := mapAllowanceFromDomain(&a, u)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	filter := bson.M{"_id": repoEntry.ID}
+	filter := bson.M{"userid": repoAllowance.UserID, "type": repoAllowance.Type, "from": repoAllowance.From}
+	_, err = r.database.Collection("allowances").ReplaceOne(ctx, filter, repoAllowance, options.Replace().SetUpsert(true))
+	return err
+}
 
-	result, err := r.database.Collection("timeentries").ReplaceOne(ctx, filter, repoEntry)
+func (r *MongoRepository) DeleteAllowance(u *domain.User, entryType string, from int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
 
+	userid, err := stringToID(u.ID)
 	if err != nil {
-		return nil, err
-	}
-	if result.MatchedCount != 1 {
-		return nil, fmt.Errorf("Time entry with id %s not found", e.ID)
+		return err
 	}
 
-	return mapTimeEntryToDomain(repoEntry, p), nil
+	_, err = r.database.Collection("allowances").DeleteOne(ctx, bson.M{"userid": userid, "type": entryType, "from": from})
+	return err
 }
 
-func (r *MongoRepository) GetProjectTimeEntries(p *domain.Project) ([]*domain.TimeEntry, error) {
+func (r *MongoRepository) GetHolidays(u *domain.User) ([]*domain.Holiday, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	projectid, err := stringToID(p.ID)
-	if err != err {
+	userid, err := stringToID(u.ID)
+	if err != nil {
 		return nil, err
 	}
-	filter := bson.M{"projectid": projectid}
 
-	var result []*domain.TimeEntry
+	var result []*domain.Holiday
 
-	cursor, err := r.database.Collection("timeentries").Find(ctx, filter)
+	cursor, err := r.database.Collection("holidays").Find(ctx, bson.M{"userid": userid}, options.Find().SetSort(bson.D{{Key: "date", Value: 1}}))
 	if err != nil {
 		return nil, err
 	}
 	for cursor.Next(ctx) {
-		var repoEntry timeEntry
-		err = cursor.Decode(&repoEntry)
+		var repoHoliday holiday
+		err = cursor.Decode(&repoHoliday)
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, mapTimeEntryToDomain(&repoEntry, p))
+		result = append(result, mapHolidayToDomain(&repoHoliday))
 	}
 	if err = cursor.Err(); err != nil {
 		return nil, err
@@ -326,67 +524,844 @@ func (r *MongoRepository) GetProjectTimeEntries(p *domain.Project) ([]*domain.Ti
 	return result, nil
 }
 
-func (r *MongoRepository) GetProjectTimeEntry(p *domain.Project, entryID string) (*domain.TimeEntry, error) {
+func (r *MongoRepository) SaveHoliday(u *domain.User, h domain.Holiday) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	projectid, err := stringToID(p.ID)
-	if err != err {
-		return nil, err
-	}
-	entryid, err := stringToID(entryID)
-	if err != err {
-		return nil, err
+	repoHoliday, err := mapHolidayFromDomain(&h, u)
+	if err != nil {
+		return err
 	}
 
-	filter := bson.M{
-		"_id":       entryid,
-		"projectid": projectid,
+	filter := bson.M{"userid": repoHoliday.UserID, "date": repoHoliday.Date}
+	_, err = r.database.Collection("holidays").ReplaceOne(ctx, filter, repoHoliday, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *MongoRepository) DeleteHoliday(u *domain.User, date string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return err
 	}
 
-	result := r.database.Collection("timeentries").FindOne(ctx, filter)
+	_, err = r.database.Collection("holidays").DeleteOne(ctx, bson.M{"userid": userid, "date": date})
+	return err
+}
+
+func (r *MongoRepository) GetUserByFeedToken(token string) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	result := r.database.Collection("users").FindOne(ctx, bson.M{"feedtoken": token})
 	if result.Err() != nil {
-		return nil, err
+		return nil, result.Err()
 	}
-	var repoEntry timeEntry
-	err = result.Decode(&repoEntry)
+
+	var repoUser user
+	err := result.Decode(&repoUser)
 	if err == mongo.ErrNoDocuments {
 		return nil, nil
 	} else if err != nil {
 		return nil, err
 	}
 
-	return mapTimeEntryToDomain(&repoEntry, p), nil
+	return mapUserToDomain(&repoUser), nil
 }
 
-func (r *MongoRepository) GetUserTimeEntries(u *domain.User) ([]*domain.TimeEntry, error) {
+func (r *MongoRepository) AddClient(c domain.Client) (*domain.Client, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	userid, err := stringToID(u.ID)
-	if err != err {
+	repoClient, err := mapClientFromDomain(&c)
+	if err != nil {
+		return nil, err
+	}
+	repoClient.ID = newID()
+	_, err = r.database.Collection("clients").InsertOne(ctx, repoClient)
+	if err != nil {
 		return nil, err
 	}
-	filter := bson.M{"userid": userid}
 
-	var result []*domain.TimeEntry
+	return mapClientToDomain(repoClient, c.User), nil
+}
 
-	cursor, err := r.database.Collection("timeentries").Find(ctx, filter)
+func (r *MongoRepository) UpdateClient(c domain.Client) (*domain.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	repoClient, err := mapClientFromDomain(&c)
 	if err != nil {
 		return nil, err
 	}
-	for cursor.Next(ctx) {
-		var repoEntry timeEntry
-		err = cursor.Decode(&repoEntry)
-		if err != nil {
-			return nil, err
-		}
 
-		project, err := r.getProjectById(u, repoEntry.ProjectID)
-		if err != nil {
-			return nil, err
-		}
-		result = append(result, mapTimeEntryToDomain(&repoEntry, project))
+	filter := bson.M{"_id": repoClient.ID, "userid": repoClient.UserID}
+	result, err := r.database.Collection("clients").ReplaceOne(ctx, filter, repoClient)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount != 1 {
+		return nil, fmt.Errorf("Client with id %s not found", c.ID)
+	}
+
+	return mapClientToDomain(repoClient, c.User), nil
+}
+
+func (r *MongoRepository) DeleteClient(c *domain.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ids, err := stringsToIDs(c.ID, c.User.ID)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.database.Collection("clients").DeleteOne(ctx, bson.M{"_id": ids[0], "userid": ids[1]})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount != 1 {
+		return fmt.Errorf("Client with id %s not found", c.ID)
+	}
+
+	return nil
+}
+
+func (r *MongoRepository) GetClient(u *domain.User, clientName string) (*domain.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var repoClient client
+	err = r.database.Collection("clients").FindOne(ctx, bson.M{"userid": userid, "name": clientName}).Decode(&repoClient)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return mapClientToDomain(&repoClient, u), nil
+}
+
+func (r *MongoRepository) GetClients(u *domain.User) ([]*domain.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.database.Collection("clients").Find(ctx, bson.M{"userid": userid}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []*domain.Client
+	for cursor.Next(ctx) {
+		var repoClient client
+		if err := cursor.Decode(&repoClient); err != nil {
+			return nil, err
+		}
+		result = append(result, mapClientToDomain(&repoClient, u))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *MongoRepository) AddProject(p domain.Project) (*domain.Project, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	repoProject, err := mapProjectFromDomain(&p)
+	if err != nil {
+		return nil, err
+	}
+	repoProject.ID = newID()
+	_, err = r.database.Collection("projects").InsertOne(ctx, repoProject)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapProjectToDomain(repoProject, p.User), nil
+}
+
+func (r *MongoRepository) UpdateProject(p domain.Project) (*domain.Project, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	projectid, err := stringToID(p.ID)
+	if err != err {
+		return nil, err
+	}
+	repoProject, err := mapProjectFromDomain(&p)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"_id": projectid}
+
+	result, err := r.database.Collection("projects").ReplaceOne(ctx, filter, repoProject)
+
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount != 1 {
+		return nil, fmt.Errorf("Project with id %s not found", projectid)
+	}
+
+	return mapProjectToDomain(repoProject, p.User), nil
+}
+
+// RenameProject updates the project, then removes the released names from the aliases of
+// the user's other projects. There are no transactions here, so if the second step fails,
+// the other projects keep the aliases until the project is renamed again.
+func (r *MongoRepository) RenameProject(p domain.Project, released []string) (*domain.Project, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	updated, err := r.UpdateProject(p)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := stringsToIDs(p.ID, p.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"userid": ids[1], "_id": bson.M{"$ne": ids[0]}, "aliases": bson.M{"$in": released}}
+	_, err = r.database.Collection("projects").UpdateMany(ctx, filter, bson.M{"$pull": bson.M{"aliases": bson.M{"$in": released}}})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (r *MongoRepository) DeleteProject(p *domain.Project) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ids, err := stringsToIDs(p.ID, p.User.ID)
+	if err != nil {
+		return err
+	}
+
+	entries := r.database.Collection("timeentries")
+	_, err = entries.DeleteMany(ctx, bson.M{"projectid": ids[0], "invoicenumber": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	// Entries that were invoiced since the caller checked must not be lost
+	invoiced, err := entries.CountDocuments(ctx, bson.M{"projectid": ids[0]})
+	if err != nil {
+		return err
+	}
+	if invoiced > 0 {
+		return domain.ErrEntryInvoiced
+	}
+	_, err = r.database.Collection("tasks").DeleteMany(ctx, bson.M{"projectid": ids[0]})
+	if err != nil {
+		return err
+	}
+
+	result, err := r.database.Collection("projects").DeleteOne(ctx, bson.M{"_id": ids[0], "userid": ids[1]})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount != 1 {
+		return fmt.Errorf("Project with id %s not found", p.ID)
+	}
+
+	return nil
+}
+
+func (r *MongoRepository) GetProject(u *domain.User, projectName string) (*domain.Project, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
+	if err != err {
+		return nil, err
+	}
+	filter := bson.M{"userid": userid, "name": projectName}
+
+	result := r.database.Collection("projects").FindOne(ctx, filter)
+	if result.Err() != nil {
+		return nil, err
+	}
+
+	var repoProject project
+	err = result.Decode(&repoProject)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return mapProjectToDomain(&repoProject, u), nil
+}
+
+// GetProjectByID returns the project with the given ID. IDs that are not valid object IDs
+// find no project.
+func (r *MongoRepository) GetProjectByID(u *domain.User, projectID string) (*domain.Project, error) {
+	id, err := stringToID(projectID)
+	if err != nil {
+		return nil, nil
+	}
+	return r.getProjectById(u, id)
+}
+
+func (r *MongoRepository) getProjectById(u *domain.User, projectID primitive.ObjectID) (*domain.Project, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
+	if err != err {
+		return nil, err
+	}
+	filter := bson.M{"userid": userid, "_id": projectID}
+
+	result := r.database.Collection("projects").FindOne(ctx, filter)
+	if result.Err() != nil {
+		return nil, err
+	}
+
+	var repoProject project
+	err = result.Decode(&repoProject)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return mapProjectToDomain(&repoProject, u), nil
+}
+
+func (r *MongoRepository) GetProjects(u *domain.User, q domain.ProjectQuery) ([]*domain.Project, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
+	if err != err {
+		return nil, err
+	}
+	filter := projectFilter(bson.M{"userid": userid}, q)
+
+	var result []*domain.Project
+
+	cursor, err := r.database.Collection("projects").Find(ctx, filter, projectFindOptions(q))
+	if err != nil {
+		return nil, err
+	}
+	for cursor.Next(ctx) {
+		var repoProject project
+		err = cursor.Decode(&repoProject)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mapProjectToDomain(&repoProject, u))
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *MongoRepository) AddTask(t domain.Task) (*domain.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	repoTask, err := mapTaskFromDomain(&t)
+	if err != nil {
+		return nil, err
+	}
+	repoTask.ID = newID()
+	_, err = r.database.Collection("tasks").InsertOne(ctx, repoTask)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapTaskToDomain(repoTask, t.Project), nil
+}
+
+func (r *MongoRepository) UpdateTask(t domain.Task) (*domain.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	repoTask, err := mapTaskFromDomain(&t)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"_id": repoTask.ID, "projectid": repoTask.ProjectID}
+	result, err := r.database.Collection("tasks").ReplaceOne(ctx, filter, repoTask)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount != 1 {
+		return nil, fmt.Errorf("Task with id %s not found", t.ID)
+	}
+
+	return mapTaskToDomain(repoTask, t.Project), nil
+}
+
+func (r *MongoRepository) DeleteTask(t *domain.Task) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ids, err := stringsToIDs(t.ID, t.Project.ID)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.database.Collection("tasks").DeleteOne(ctx, bson.M{"_id": ids[0], "projectid": ids[1]})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount != 1 {
+		return fmt.Errorf("Task with id %s not found", t.ID)
+	}
+
+	return nil
+}
+
+func (r *MongoRepository) GetTask(p *domain.Project, taskName string) (*domain.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	projectid, err := stringToID(p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var repoTask task
+	err = r.database.Collection("tasks").FindOne(ctx, bson.M{"projectid": projectid, "name": taskName}).Decode(&repoTask)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return mapTaskToDomain(&repoTask, p), nil
+}
+
+func (r *MongoRepository) GetTasks(p *domain.Project) ([]*domain.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	projectid, err := stringToID(p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.database.Collection("tasks").Find(ctx, bson.M{"projectid": projectid}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []*domain.Task
+	for cursor.Next(ctx) {
+		var repoTask task
+		if err := cursor.Decode(&repoTask); err != nil {
+			return nil, err
+		}
+		result = append(result, mapTaskToDomain(&repoTask, p))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *MongoRepository) GetUserTasks(u *domain.User) ([]*domain.Task, error) {
+	projects, err := r.GetProjects(u, domain.ProjectQuery{})
+	if err != nil {
+		return nil, err
+	}
+	projectsByID := make(map[string]*domain.Project)
+	for _, project := range projects {
+		projectsByID[project.ID] = project
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.database.Collection("tasks").Find(ctx, bson.M{"userid": userid})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []*domain.Task
+	for cursor.Next(ctx) {
+		var repoTask task
+		if err := cursor.Decode(&repoTask); err != nil {
+			return nil, err
+		}
+		result = append(result, mapTaskToDomain(&repoTask, projectsByID[idToString(repoTask.ProjectID)]))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *MongoRepository) AddTimeEntry(p *domain.Project, e domain.TimeEntry) (*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	e.Project = p
+
+	repoEntry, err := mapTimeEntryFromDomain(&e)
+	repoEntry.ID = newID()
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.database.Collection("timeentries").InsertOne(ctx, repoEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	e.ID = idToString(repoEntry.ID)
+	return &e, nil
+}
+
+// AddTimeEntries inserts the entries in order. MongoDB has no transactions here, so if an
+// insert fails the entries before it are kept.
+func (r *MongoRepository) AddTimeEntries(u *domain.User, entries []domain.TimeEntry) ([]*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var documents []interface{}
+	var result []*domain.TimeEntry
+	for _, e := range entries {
+		e := e
+		repoEntry, err := mapTimeEntryFromDomain(&e)
+		if err != nil {
+			return nil, err
+		}
+		repoEntry.ID = newID()
+		documents = append(documents, repoEntry)
+
+		e.ID = idToString(repoEntry.ID)
+		result = append(result, &e)
+	}
+	if len(documents) == 0 {
+		return result, nil
+	}
+
+	_, err := r.database.Collection("timeentries").InsertMany(ctx, documents)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *MongoRepository) UpdateTimeEntry(p *domain.Project, e domain.TimeEntry) (*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	repoEntry, err := mapTimeEntryFromDomain(&e)
+	if err != nil {
+		return nil, err
+	}
+
+	// Entries that have been invoiced in the meantime are not matched, so that the
+	// replacement cannot drop their invoice number
+	filter := bson.M{"_id": repoEntry.ID, "invoicenumber": bson.M{"$exists": false}}
+
+	result, err := r.database.Collection("timeentries").ReplaceOne(ctx, filter, repoEntry)
+
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount != 1 {
+		return nil, r.entryNotChanged(ctx, repoEntry.ID, e.ID)
+	}
+
+	return mapTimeEntryToDomain(repoEntry, p), nil
+}
+
+func (r *MongoRepository) DeleteTimeEntry(p *domain.Project, entryID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ids, err := stringsToIDs(entryID, p.ID)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{
+		"_id":           ids[0],
+		"projectid":     ids[1],
+		"invoicenumber": bson.M{"$exists": false},
+	}
+
+	result, err := r.database.Collection("timeentries").DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount != 1 {
+		return r.entryNotChanged(ctx, ids[0], entryID)
+	}
+
+	return nil
+}
+
+// entryNotChanged explains why an update or delete of an entry matched no document:
+// either the entry has been invoiced in the meantime, or it does not exist
+func (r *MongoRepository) entryNotChanged(ctx context.Context, id primitive.ObjectID, entryID string) error {
+	count, err := r.database.Collection("timeentries").CountDocuments(ctx, bson.M{"_id": id, "invoicenumber": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return domain.ErrEntryInvoiced
+	}
+	return fmt.Errorf("Time entry with id %s not found", entryID)
+}
+
+func (r *MongoRepository) GetProjectTimeEntries(p *domain.Project, q domain.EntryQuery) ([]*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	projectid, err := stringToID(p.ID)
+	if err != err {
+		return nil, err
+	}
+	filter, err := entryFilter(bson.M{"projectid": projectid}, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*domain.TimeEntry
+
+	cursor, err := r.database.Collection("timeentries").Find(ctx, filter, entryFindOptions(q))
+	if err != nil {
+		return nil, err
+	}
+	for cursor.Next(ctx) {
+		var repoEntry timeEntry
+		err = cursor.Decode(&repoEntry)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mapTimeEntryToDomain(&repoEntry, p))
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *MongoRepository) GetTimeEntry(u *domain.User, entryID string) (*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ids, err := stringsToIDs(entryID, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var repoEntry timeEntry
+	err = r.database.Collection("timeentries").FindOne(ctx, bson.M{"_id": ids[0], "userid": ids[1]}).Decode(&repoEntry)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	project, err := r.getProjectById(u, repoEntry.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	return mapTimeEntryToDomain(&repoEntry, project), nil
+}
+
+// AddInvoice claims the next invoice number by inserting the invoice, and then marks the
+// entries. Without transactions, the invoice is removed again if some of the entries
+// turn out to be invoiced already.
+func (r *MongoRepository) AddInvoice(inv domain.Invoice, entryIDs []string) (*domain.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(inv.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	entryids, err := stringsToIDs(entryIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	var last invoice
+	err = r.database.Collection("invoices").FindOne(ctx, bson.M{"userid": userid},
+		options.FindOne().SetSort(bson.D{{Key: "number", Value: -1}})).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	inv.ID = idToString(newID())
+	inv.Number = last.Number + 1
+
+	repoInvoice, err := mapInvoiceFromDomain(&inv)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.database.Collection("invoices").InsertOne(ctx, repoInvoice); err != nil {
+		return nil, err
+	}
+
+	entries := r.database.Collection("timeentries")
+	result, err := entries.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": entryids}, "userid": userid, "invoicenumber": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"invoicenumber": inv.Number}})
+	if err == nil && result.ModifiedCount == int64(len(entryids)) {
+		return &inv, nil
+	}
+
+	entries.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": entryids}, "invoicenumber": inv.Number},
+		bson.M{"$unset": bson.M{"invoicenumber": ""}})
+	r.database.Collection("invoices").DeleteOne(ctx, bson.M{"_id": repoInvoice.ID})
+	if err != nil {
+		return nil, err
+	}
+	return nil, domain.ErrEntryInvoiced
+}
+
+func (r *MongoRepository) GetInvoice(u *domain.User, number int) (*domain.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var repoInvoice invoice
+	err = r.database.Collection("invoices").FindOne(ctx, bson.M{"userid": userid, "number": number}).Decode(&repoInvoice)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return mapInvoiceToDomain(&repoInvoice, u), nil
+}
+
+func (r *MongoRepository) GetInvoices(u *domain.User) ([]*domain.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.database.Collection("invoices").Find(ctx, bson.M{"userid": userid}, options.Find().SetSort(bson.D{{Key: "number", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []*domain.Invoice
+	for cursor.Next(ctx) {
+		var repoInvoice invoice
+		if err := cursor.Decode(&repoInvoice); err != nil {
+			return nil, err
+		}
+		result = append(result, mapInvoiceToDomain(&repoInvoice, u))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *MongoRepository) GetProjectTimeEntry(p *domain.Project, entryID string) (*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	projectid, err := stringToID(p.ID)
+	if err != err {
+		return nil, err
+	}
+	entryid, err := stringToID(entryID)
+	if err != err {
+		return nil, err
+	}
+
+	filter := bson.M{
+		"_id":       entryid,
+		"projectid": projectid,
+	}
+
+	result := r.database.Collection("timeentries").FindOne(ctx, filter)
+	if result.Err() != nil {
+		return nil, err
+	}
+	var repoEntry timeEntry
+	err = result.Decode(&repoEntry)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return mapTimeEntryToDomain(&repoEntry, p), nil
+}
+
+func (r *MongoRepository) GetUserTimeEntries(u *domain.User, q domain.EntryQuery) ([]*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
+	if err != err {
+		return nil, err
+	}
+	filter, err := entryFilter(bson.M{"userid": userid}, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*domain.TimeEntry
+
+	cursor, err := r.database.Collection("timeentries").Find(ctx, filter, entryFindOptions(q))
+	if err != nil {
+		return nil, err
+	}
+	for cursor.Next(ctx) {
+		var repoEntry timeEntry
+		err = cursor.Decode(&repoEntry)
+		if err != nil {
+			return nil, err
+		}
+
+		project, err := r.getProjectById(u, repoEntry.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, mapTimeEntryToDomain(&repoEntry, project))
 	}
 	if err = cursor.Err(); err != nil {
 		return nil, err
@@ -394,3 +1369,179 @@ func (r *MongoRepository) GetUserTimeEntries(u *domain.User) ([]*domain.TimeEntr
 
 	return result, nil
 }
+
+// openEntries returns the user's time entries that have no end time, latest first
+func (r *MongoRepository) openEntries(ctx context.Context, filter bson.M) ([]*timeEntry, error) {
+	filter["end"] = nil
+	opts := options.Find().SetSort(bson.D{{Key: "start", Value: -1}, {Key: "_id", Value: -1}})
+	cursor, err := r.database.Collection("timeentries").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var result []*timeEntry
+	for cursor.Next(ctx) {
+		var repoEntry timeEntry
+		if err := cursor.Decode(&repoEntry); err != nil {
+			return nil, err
+		}
+		result = append(result, &repoEntry)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// timerLockTimeout is how long a lock on a user's timers lasts if it is never released
+const timerLockTimeout = 10 * time.Second
+
+// lockTimers serializes changes to the user's timers, like lockUser does in the SQL
+// repository. Without transactions the lock is a lease in the user document, taken with a
+// conditional update, which expires if its holder never releases it. The returned function
+// releases the lock.
+func (r *MongoRepository) lockTimers(ctx context.Context, userid primitive.ObjectID) (func(), error) {
+	users := r.database.Collection("users")
+	for {
+		now := time.Now().UTC()
+		until := now.Add(timerLockTimeout)
+		free := bson.A{bson.M{"timerlock": bson.M{"$exists": false}}, bson.M{"timerlock": bson.M{"$lt": now}}}
+		result, err := users.UpdateOne(ctx, bson.M{"_id": userid, "$or": free}, bson.M{"$set": bson.M{"timerlock": until}})
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount == 1 {
+			return func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				defer cancel()
+				users.UpdateOne(ctx, bson.M{"_id": userid, "timerlock": until}, bson.M{"$unset": bson.M{"timerlock": ""}})
+			}, nil
+		}
+
+		count, err := users.CountDocuments(ctx, bson.M{"_id": userid})
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("User with id %s not found", idToString(userid))
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// StartTimer stops the running timers and inserts the new one while holding the lock on the
+// user's timers. If the new timer cannot be inserted, the stopped timers are started again.
+func (r *MongoRepository) StartTimer(p *domain.Project, e domain.TimeEntry, stopRunning bool) (*domain.TimeEntry, []*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(p.User.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unlock, err := r.lockTimers(ctx, userid)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	running, err := r.openEntries(ctx, bson.M{"userid": userid})
+	if err != nil {
+		return nil, nil, err
+	}
+	var stopped []*domain.TimeEntry
+	for _, repoEntry := range running {
+		if !stopRunning || (repoEntry.Start != nil && e.Start.Before(*repoEntry.Start)) {
+			return nil, nil, domain.ErrTimerRunning
+		}
+		project, err := r.getProjectById(p.User, repoEntry.ProjectID)
+		if err != nil {
+			return nil, nil, err
+		}
+		end := *e.Start
+		repoEntry.End = &end
+		stopped = append(stopped, mapTimeEntryToDomain(repoEntry, project))
+	}
+
+	ids := make([]primitive.ObjectID, len(running))
+	for idx, repoEntry := range running {
+		ids[idx] = repoEntry.ID
+	}
+	entries := r.database.Collection("timeentries")
+	if len(ids) > 0 {
+		_, err := entries.UpdateMany(ctx,
+			bson.M{"_id": bson.M{"$in": ids}, "end": nil},
+			bson.M{"$set": bson.M{"end": *e.Start}})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	started, err := r.AddTimeEntry(p, e)
+	if err != nil {
+		if len(ids) > 0 {
+			entries.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}, "end": *e.Start}, bson.M{"$set": bson.M{"end": nil}})
+		}
+		return nil, nil, err
+	}
+
+	return started, stopped, nil
+}
+
+func (r *MongoRepository) StopTimer(u *domain.User, p *domain.Project, end time.Time, breaks *time.Duration, validate func(*domain.TimeEntry) error) (*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	userid, err := stringToID(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"userid": userid}
+	if p != nil {
+		projectid, err := stringToID(p.ID)
+		if err != nil {
+			return nil, err
+		}
+		filter["projectid"] = projectid
+	}
+
+	running, err := r.openEntries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(running) == 0 {
+		return nil, nil
+	}
+	repoEntry := running[0]
+
+	project := p
+	if project == nil {
+		if project, err = r.getProjectById(u, repoEntry.ProjectID); err != nil {
+			return nil, err
+		}
+	}
+
+	set := bson.M{"end": end}
+	if breaks != nil {
+		set["breaks"] = *breaks
+		repoEntry.Breaks = *breaks
+	}
+	repoEntry.End = &end
+	stopped := mapTimeEntryToDomain(repoEntry, project)
+	if err := validate(stopped); err != nil {
+		return nil, err
+	}
+	// Only close the entry if it was not stopped in the meantime
+	result, err := r.database.Collection("timeentries").UpdateOne(ctx, bson.M{"_id": repoEntry.ID, "end": nil}, bson.M{"$set": set})
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount != 1 {
+		return nil, nil
+	}
+	return stopped, nil
+}
@@ -0,0 +1,67 @@
+package sql_repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dialect captures the differences between the supported SQL databases
+type dialect struct {
+	name string
+	// numberedPlaceholders is set if the database wants $1, $2... instead of ?
+	numberedPlaceholders bool
+	// types replaces the type tokens used in the migration scripts
+	types *strings.Replacer
+}
+
+var dialects = map[string]*dialect{
+	"sqlite3": {
+		name:                 "sqlite3",
+		numberedPlaceholders: false,
+		types: strings.NewReplacer(
+			"{timestamp}", "TIMESTAMP",
+			"{boolean}", "BOOLEAN",
+		),
+	},
+	"postgres": {
+		name:                 "postgres",
+		numberedPlaceholders: true,
+		types: strings.NewReplacer(
+			"{timestamp}", "TIMESTAMP WITH TIME ZONE",
+			"{boolean}", "BOOLEAN",
+		),
+	},
+}
+
+func dialectFor(driverName string) (*dialect, error) {
+	d, ok := dialects[driverName]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported SQL driver: %s", driverName)
+	}
+	return d, nil
+}
+
+// rebind rewrites a query written with ? placeholders to the placeholder style of the dialect
+func (d *dialect) rebind(query string) string {
+	if !d.numberedPlaceholders {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// ddl expands the type tokens in a migration statement
+func (d *dialect) ddl(statement string) string {
+	return d.types.Replace(statement)
+}
@@ -0,0 +1,420 @@
+package sql_repository
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kennep/timelapse/domain"
+)
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+const projectColumns = "id, user_id, name, description, billable, client_id, rates, budget, archived, aliases"
+
+const clientColumns = "id, user_id, name, description, rates"
+
+const entryTypeColumns = "name, time_based, counts_as_work, fulfills_schedule, color"
+
+const timeEntryColumns = "id, project_id, user_id, type, start_time, end_time, breaks, comment, tags, invoice_number, task_id"
+
+const taskColumns = "id, user_id, project_id, name, description"
+
+const scheduleColumns = "from_date, hours"
+
+const balanceAdjustmentColumns = "id, day, amount, comment"
+
+const allowanceColumns = "entry_type, from_year, days, carry_over"
+
+const holidayColumns = "day, name"
+
+const invoiceColumns = "id, user_id, number, issued, client, period_from, period_to, lines"
+
+// newID returns a random ID that can be used in URL paths as it is
+func newID() string {
+	var b [12]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:])
+}
+
+// utcTime normalizes times before they are stored, so that they compare correctly
+// in databases that keep timestamps as text.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// rate is how rates are stored in the rates columns
+type rate struct {
+	EntryType string  `json:"entry_type,omitempty"`
+	Amount    float64 `json:"amount"`
+	Currency  string  `json:"currency"`
+	From      string  `json:"from,omitempty"`
+}
+
+// encodeRates stores rates as a JSON array, or as an empty string if there are none. Rates
+// are only ever read together with their client or project, so they do not need a table.
+func encodeRates(rates []domain.Rate) (string, error) {
+	if len(rates) == 0 {
+		return "", nil
+	}
+	var stored []rate
+	for _, r := range rates {
+		stored = append(stored, rate{EntryType: r.EntryType, Amount: r.Amount, Currency: r.Currency, From: r.From})
+	}
+	encoded, err := json.Marshal(stored)
+	return string(encoded), err
+}
+
+// budget is how budgets are stored in the budget column of projects
+type budget struct {
+	Hours      time.Duration `json:"hours"`
+	Amount     float64       `json:"amount"`
+	Currency   string        `json:"currency,omitempty"`
+	Period     string        `json:"period"`
+	Thresholds []int         `json:"thresholds,omitempty"`
+}
+
+// encodeBudget stores a budget as a JSON object, or as an empty string if there is none
+func encodeBudget(b *domain.Budget) (string, error) {
+	if b == nil {
+		return "", nil
+	}
+	encoded, err := json.Marshal(budget(*b))
+	return string(encoded), err
+}
+
+func decodeBudget(encoded string) (*domain.Budget, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	var stored budget
+	if err := json.Unmarshal([]byte(encoded), &stored); err != nil {
+		return nil, err
+	}
+	result := domain.Budget(stored)
+	return &result, nil
+}
+
+// encodeAliases stores the former names of a project as a JSON array, or as an empty
+// string if there are none
+func encodeAliases(aliases []string) (string, error) {
+	if len(aliases) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(aliases)
+	return string(encoded), err
+}
+
+func decodeAliases(encoded string) ([]string, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	var result []string
+	err := json.Unmarshal([]byte(encoded), &result)
+	return result, err
+}
+
+// withoutAliases returns the aliases that are not among the released names
+func withoutAliases(aliases []string, released []string) []string {
+	var result []string
+	for _, alias := range aliases {
+		keep := true
+		for _, name := range released {
+			keep = keep && alias != name
+		}
+		if keep {
+			result = append(result, alias)
+		}
+	}
+	return result
+}
+
+func decodeRates(rates string) ([]domain.Rate, error) {
+	if rates == "" {
+		return nil, nil
+	}
+	var stored []rate
+	if err := json.Unmarshal([]byte(rates), &stored); err != nil {
+		return nil, err
+	}
+	var result []domain.Rate
+	for _, r := range stored {
+		result = append(result, domain.Rate{EntryType: r.EntryType, Amount: r.Amount, Currency: r.Currency, From: r.From})
+	}
+	return result, nil
+}
+
+func scanProject(row scanner, user *domain.User) (*domain.Project, error) {
+	var project domain.Project
+	var userID, rates, budget, aliases string
+	err := row.Scan(&project.ID, &userID, &project.Name, &project.Description, &project.Billable, &project.ClientID, &rates, &budget, &project.Archived, &aliases)
+	if err != nil {
+		return nil, err
+	}
+	if project.Aliases, err = decodeAliases(aliases); err != nil {
+		return nil, err
+	}
+	if project.Rates, err = decodeRates(rates); err != nil {
+		return nil, err
+	}
+	if project.Budget, err = decodeBudget(budget); err != nil {
+		return nil, err
+	}
+	project.User = user
+	return &project, nil
+}
+
+func projectValues(p *domain.Project) ([]interface{}, error) {
+	rates, err := encodeRates(p.Rates)
+	if err != nil {
+		return nil, err
+	}
+	budget, err := encodeBudget(p.Budget)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := encodeAliases(p.Aliases)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{p.ID, p.User.ID, p.Name, p.Description, p.Billable, p.ClientID, rates, budget, p.Archived, aliases}, nil
+}
+
+func scanClient(row scanner, user *domain.User) (*domain.Client, error) {
+	var client domain.Client
+	var userID, rates string
+	err := row.Scan(&client.ID, &userID, &client.Name, &client.Description, &rates)
+	if err != nil {
+		return nil, err
+	}
+	if client.Rates, err = decodeRates(rates); err != nil {
+		return nil, err
+	}
+	client.User = user
+	return &client, nil
+}
+
+func clientValues(c *domain.Client) ([]interface{}, error) {
+	rates, err := encodeRates(c.Rates)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{c.ID, c.User.ID, c.Name, c.Description, rates}, nil
+}
+
+func scanEntryType(row scanner) (*domain.EntryType, error) {
+	var entryType domain.EntryType
+	err := row.Scan(&entryType.Name, &entryType.TimeBased, &entryType.CountsAsWork, &entryType.FulfillsSchedule, &entryType.Color)
+	if err != nil {
+		return nil, err
+	}
+	return &entryType, nil
+}
+
+// scanSchedule reads a schedule, whose hours are stored as a JSON array of durations
+func scanSchedule(row scanner) (*domain.Schedule, error) {
+	var schedule domain.Schedule
+	var hours string
+	err := row.Scan(&schedule.From, &hours)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(hours), &schedule.Hours); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func scanBalanceAdjustment(row scanner) (*domain.BalanceAdjustment, error) {
+	var adjustment domain.BalanceAdjustment
+	var amount int64
+	err := row.Scan(&adjustment.ID, &adjustment.Date, &amount, &adjustment.Comment)
+	if err != nil {
+		return nil, err
+	}
+	adjustment.Amount = time.Duration(amount)
+	return &adjustment, nil
+}
+
+func scanAllowance(row scanner) (*domain.Allowance, error) {
+	var allowance domain.Allowance
+	err := row.Scan(&allowance.Type, &allowance.From, &allowance.Days, &allowance.CarryOver)
+	if err != nil {
+		return nil, err
+	}
+	return &allowance, nil
+}
+
+func scanHoliday(row scanner) (*domain.Holiday, error) {
+	var holiday domain.Holiday
+	err := row.Scan(&holiday.Date, &holiday.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &holiday, nil
+}
+
+func scanTask(row scanner, project *domain.Project) (*domain.Task, string, error) {
+	var task domain.Task
+	var userID, projectID string
+	err := row.Scan(&task.ID, &userID, &projectID, &task.Name, &task.Description)
+	if err != nil {
+		return nil, "", err
+	}
+	task.Project = project
+	return &task, projectID, nil
+}
+
+func taskValues(t *domain.Task) []interface{} {
+	return []interface{}{t.ID, t.Project.User.ID, t.Project.ID, t.Name, t.Description}
+}
+
+// scanTimeEntry scans a time entry row. The project ID is returned so that callers
+// can resolve the project when it is not known in advance.
+func scanTimeEntry(row scanner, project *domain.Project) (*domain.TimeEntry, string, error) {
+	var entry domain.TimeEntry
+	var projectID, userID string
+	var breaks int64
+	var tags string
+	err := row.Scan(&entry.ID, &projectID, &userID, &entry.Type, &entry.Start, &entry.End, &breaks, &entry.Comment, &tags, &entry.InvoiceNumber, &entry.TaskID)
+	if err != nil {
+		return nil, "", err
+	}
+	entry.Breaks = time.Duration(breaks)
+	entry.Tags = decodeTags(tags)
+	entry.Project = project
+	return &entry, projectID, nil
+}
+
+func timeEntryValues(e *domain.TimeEntry) []interface{} {
+	return []interface{}{e.ID, e.Project.ID, e.Project.User.ID, e.Type, utcTime(e.Start), utcTime(e.End), int64(e.Breaks), e.Comment, encodeTags(e.Tags), e.InvoiceNumber, e.TaskID}
+}
+
+// invoiceLine is how invoice lines are stored in the lines column. Invoices never change,
+// so their lines are stored along with them.
+type invoiceLine struct {
+	Project     string        `json:"project"`
+	Description string        `json:"description"`
+	Hours       time.Duration `json:"hours"`
+	Rate        float64       `json:"rate"`
+	Currency    string        `json:"currency"`
+	Amount      float64       `json:"amount"`
+}
+
+func scanInvoice(row scanner, user *domain.User) (*domain.Invoice, error) {
+	var invoice domain.Invoice
+	var userID, lines string
+	err := row.Scan(&invoice.ID, &userID, &invoice.Number, &invoice.Issued, &invoice.Client, &invoice.From, &invoice.To, &lines)
+	if err != nil {
+		return nil, err
+	}
+	var stored []invoiceLine
+	if err := json.Unmarshal([]byte(lines), &stored); err != nil {
+		return nil, err
+	}
+	for _, line := range stored {
+		invoice.Lines = append(invoice.Lines, domain.InvoiceLine(line))
+	}
+	invoice.User = user
+	return &invoice, nil
+}
+
+func invoiceValues(inv *domain.Invoice) ([]interface{}, error) {
+	stored := []invoiceLine{}
+	for _, line := range inv.Lines {
+		stored = append(stored, invoiceLine(line))
+	}
+	lines, err := json.Marshal(stored)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{inv.ID, inv.User.ID, inv.Number, inv.Issued.UTC(), inv.Client, utcTime(inv.From), utcTime(inv.To), string(lines)}, nil
+}
+
+// encodeTags stores tags as a comma separated list with a comma at each end, like ",a,b,".
+// No tags are stored as an empty string. Queries use the time_entry_tags table instead.
+func encodeTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "," + strings.Join(tags, ",") + ","
+}
+
+func decodeTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	return strings.Split(strings.Trim(tags, ","), ",")
+}
+
+// tagCondition matches the user's entries that have any of the tags, using the
+// time_entry_tags index
+func tagCondition(userID string, tags []string) (string, []interface{}) {
+	args := []interface{}{userID}
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tags)), ", ")
+	return "id IN (SELECT entry_id FROM time_entry_tags WHERE user_id = ? AND tag IN (" + placeholders + "))", args
+}
+
+// entryConditions adds the WHERE conditions and arguments matching an entry query of the user
+func entryConditions(conditions []string, args []interface{}, userID string, q domain.EntryQuery) ([]string, []interface{}) {
+	if q.From != nil {
+		conditions = append(conditions, "start_time >= ?")
+		args = append(args, utcTime(q.From))
+	}
+	if q.To != nil {
+		conditions = append(conditions, "start_time < ?")
+		args = append(args, utcTime(q.To))
+	}
+	if q.Type != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, q.Type)
+	}
+	if q.TaskID != "" {
+		conditions = append(conditions, "task_id = ?")
+		args = append(args, q.TaskID)
+	}
+	if q.OpenOnly {
+		conditions = append(conditions, "end_time IS NULL")
+	}
+	if len(q.Tags) > 0 && q.AnyTag {
+		condition, tagArgs := tagCondition(userID, q.Tags)
+		conditions = append(conditions, condition)
+		args = append(args, tagArgs...)
+	} else {
+		for _, tag := range q.Tags {
+			condition, tagArgs := tagCondition(userID, []string{tag})
+			conditions = append(conditions, condition)
+			args = append(args, tagArgs...)
+		}
+	}
+	if q.After != nil {
+		conditions = append(conditions, "(start_time > ? OR (start_time = ? AND id > ?))")
+		start := utcTime(&q.After.Start)
+		args = append(args, start, start, q.After.ID)
+	}
+	return conditions, args
+}
+
+// limitClause returns the LIMIT clause for a query, if any
+func limitClause(limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" LIMIT %d", limit)
+}
@@ -0,0 +1,333 @@
+package sql_repository
+
+import (
+	"database/sql"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type migration struct {
+	version     int
+	description string
+	statements  []string
+	// apply, if set, runs after the statements, for changes that cannot be written in SQL
+	// that works in every supported database
+	apply func(r *SqlRepository, tx *sql.Tx) error
+}
+
+// migrations holds the schema history. Migrations are applied in order and must never be
+// changed once released - add a new migration instead.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "users, identities, projects and time entries",
+		statements: []string{
+			`CREATE TABLE users (
+				id TEXT PRIMARY KEY
+			)`,
+			`CREATE TABLE identities (
+				user_id TEXT NOT NULL REFERENCES users(id),
+				issuer TEXT NOT NULL,
+				subject_id TEXT NOT NULL,
+				email TEXT NOT NULL,
+				PRIMARY KEY (issuer, subject_id)
+			)`,
+			`CREATE INDEX identities_user_id ON identities(user_id)`,
+			`CREATE TABLE projects (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL REFERENCES users(id),
+				name TEXT NOT NULL,
+				description TEXT NOT NULL,
+				billable {boolean} NOT NULL,
+				UNIQUE (user_id, name)
+			)`,
+			`CREATE TABLE time_entries (
+				id TEXT PRIMARY KEY,
+				project_id TEXT NOT NULL REFERENCES projects(id),
+				user_id TEXT NOT NULL REFERENCES users(id),
+				type TEXT NOT NULL,
+				start_time {timestamp},
+				end_time {timestamp},
+				breaks BIGINT NOT NULL,
+				comment TEXT NOT NULL
+			)`,
+			`CREATE INDEX time_entries_user_start ON time_entries(user_id, start_time)`,
+			`CREATE INDEX time_entries_project_start ON time_entries(project_id, start_time)`,
+		},
+	},
+	{
+		version:     2,
+		description: "calendar feed tokens",
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN feed_token TEXT`,
+			`CREATE UNIQUE INDEX users_feed_token ON users(feed_token)`,
+		},
+	},
+	{
+		version:     3,
+		description: "timer policy",
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN timer_policy TEXT NOT NULL DEFAULT ''`,
+			`CREATE INDEX time_entries_user_end ON time_entries(user_id, end_time)`,
+		},
+	},
+	{
+		version:     4,
+		description: "entry types",
+		statements: []string{
+			`CREATE TABLE entry_types (
+				user_id TEXT NOT NULL REFERENCES users(id),
+				name TEXT NOT NULL,
+				time_based {boolean} NOT NULL,
+				counts_as_work {boolean} NOT NULL,
+				color TEXT NOT NULL,
+				PRIMARY KEY (user_id, name)
+			)`,
+		},
+	},
+	{
+		version:     5,
+		description: "time entry tags",
+		statements: []string{
+			`ALTER TABLE time_entries ADD COLUMN tags TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version:     6,
+		description: "clients and rates",
+		statements: []string{
+			`CREATE TABLE clients (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL REFERENCES users(id),
+				name TEXT NOT NULL,
+				description TEXT NOT NULL,
+				rates TEXT NOT NULL,
+				UNIQUE (user_id, name)
+			)`,
+			`ALTER TABLE projects ADD COLUMN client_id TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE projects ADD COLUMN rates TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version:     7,
+		description: "invoices",
+		statements: []string{
+			`CREATE TABLE invoices (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL REFERENCES users(id),
+				number INTEGER NOT NULL,
+				issued {timestamp} NOT NULL,
+				client TEXT NOT NULL,
+				period_from {timestamp},
+				period_to {timestamp},
+				lines TEXT NOT NULL,
+				UNIQUE (user_id, number)
+			)`,
+			`ALTER TABLE time_entries ADD COLUMN invoice_number INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version:     8,
+		description: "tasks",
+		statements: []string{
+			`CREATE TABLE tasks (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL REFERENCES users(id),
+				project_id TEXT NOT NULL REFERENCES projects(id),
+				name TEXT NOT NULL,
+				description TEXT NOT NULL,
+				UNIQUE (project_id, name)
+			)`,
+			`CREATE INDEX tasks_user_id ON tasks(user_id)`,
+			`ALTER TABLE time_entries ADD COLUMN task_id TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version:     9,
+		description: "project budgets",
+		statements: []string{
+			`ALTER TABLE projects ADD COLUMN budget TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version:     10,
+		description: "archived projects",
+		statements: []string{
+			`ALTER TABLE projects ADD COLUMN archived {boolean} NOT NULL DEFAULT FALSE`,
+		},
+	},
+	{
+		version:     11,
+		description: "project aliases",
+		statements: []string{
+			`ALTER TABLE projects ADD COLUMN aliases TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		version:     12,
+		description: "work schedules and balance adjustments",
+		statements: []string{
+			`CREATE TABLE schedules (
+				user_id TEXT NOT NULL REFERENCES users(id),
+				from_date TEXT NOT NULL,
+				hours TEXT NOT NULL,
+				PRIMARY KEY (user_id, from_date)
+			)`,
+			`CREATE TABLE balance_adjustments (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL REFERENCES users(id),
+				day TEXT NOT NULL,
+				amount BIGINT NOT NULL,
+				comment TEXT NOT NULL
+			)`,
+			`CREATE INDEX balance_adjustments_user_day ON balance_adjustments(user_id, day)`,
+		},
+	},
+	{
+		version:     13,
+		description: "allowances and holidays",
+		statements: []string{
+			`CREATE TABLE allowances (
+				user_id TEXT NOT NULL REFERENCES users(id),
+				entry_type TEXT NOT NULL,
+				from_year INTEGER NOT NULL,
+				days INTEGER NOT NULL,
+				carry_over INTEGER NOT NULL,
+				PRIMARY KEY (user_id, entry_type, from_year)
+			)`,
+			`CREATE TABLE holidays (
+				user_id TEXT NOT NULL REFERENCES users(id),
+				day TEXT NOT NULL,
+				name TEXT NOT NULL,
+				PRIMARY KEY (user_id, day)
+			)`,
+		},
+	},
+	{
+		version:     14,
+		description: "time entry tag index",
+		statements: []string{
+			`CREATE TABLE time_entry_tags (
+				entry_id TEXT NOT NULL REFERENCES time_entries(id),
+				user_id TEXT NOT NULL REFERENCES users(id),
+				tag TEXT NOT NULL,
+				PRIMARY KEY (entry_id, tag)
+			)`,
+			`CREATE INDEX time_entry_tags_user_tag ON time_entry_tags(user_id, tag)`,
+		},
+		apply: indexExistingTags,
+	},
+	{
+		version:     15,
+		description: "entry types that fulfill the work schedule",
+		statements: []string{
+			`ALTER TABLE entry_types ADD COLUMN fulfills_schedule {boolean} NOT NULL DEFAULT FALSE`,
+			// Changed default absence types keep counting towards the schedule
+			`UPDATE entry_types SET fulfills_schedule = TRUE WHERE name IN ('sick', 'sick-child', 'vacation')`,
+		},
+	},
+}
+
+// indexExistingTags fills time_entry_tags from the tags column of the existing entries
+func indexExistingTags(r *SqlRepository, tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, user_id, tags FROM time_entries WHERE tags <> ''")
+	if err != nil {
+		return err
+	}
+	type entryTags struct {
+		entryID, userID string
+		tags            []string
+	}
+	var entries []entryTags
+	for rows.Next() {
+		var e entryTags
+		var tags string
+		if err := rows.Scan(&e.entryID, &e.userID, &tags); err != nil {
+			rows.Close()
+			return err
+		}
+		e.tags = decodeTags(tags)
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		seen := make(map[string]bool)
+		for _, tag := range e.tags {
+			if seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			_, err := tx.Exec(r.dialect.rebind("INSERT INTO time_entry_tags (entry_id, user_id, tag) VALUES (?, ?, ?)"), e.entryID, e.userID, tag)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *SqlRepository) currentSchemaVersion() (int, error) {
+	_, err := r.db.Exec(r.dialect.ddl(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at {timestamp} NOT NULL
+	)`))
+	if err != nil {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	err = r.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// migrate brings the database schema up to date. Each migration runs in its own transaction.
+func (r *SqlRepository) migrate() error {
+	current, err := r.currentSchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		log.Infof("Applying database migration %d: %s", m.version, m.description)
+
+		tx, err := r.db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, statement := range m.statements {
+			if _, err := tx.Exec(r.dialect.ddl(statement)); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if m.apply != nil {
+			if err := m.apply(r, tx); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		_, err = tx.Exec(r.dialect.rebind("INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)"),
+			m.version, m.description, time.Now().UTC())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,1402 @@
+package sql_repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kennep/timelapse/domain"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SqlRepository stores data in a relational database through database/sql.
+// The database driver must be registered by the caller.
+type SqlRepository struct {
+	db      *sql.DB
+	dialect *dialect
+}
+
+// NewSqlRepository connects to the database and applies any pending schema migrations
+func NewSqlRepository(driverName string, dataSourceName string) (*SqlRepository, error) {
+	d, err := dialectFor(driverName)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	repository := &SqlRepository{
+		db:      db,
+		dialect: d,
+	}
+	if err = repository.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return repository, nil
+}
+
+// Close releases the database connection
+func (r *SqlRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *SqlRepository) getUser(ctx context.Context, tx *sql.Tx, userID string) (*domain.User, error) {
+	var feedToken sql.NullString
+	var timerPolicy string
+	err := tx.QueryRowContext(ctx, r.dialect.rebind("SELECT feed_token, timer_policy FROM users WHERE id = ?"), userID).Scan(&feedToken, &timerPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, r.dialect.rebind("SELECT issuer, subject_id, email FROM identities WHERE user_id = ? ORDER BY issuer, subject_id"), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	user := domain.User{ID: userID, FeedToken: feedToken.String, TimerPolicy: timerPolicy}
+	for rows.Next() {
+		var identity domain.Identity
+		if err := rows.Scan(&identity.Issuer, &identity.SubjectID, &identity.Email); err != nil {
+			return nil, err
+		}
+		user.Identities = append(user.Identities, identity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *SqlRepository) CreateUserFromContext(appCtx *domain.ApplicationContext) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var userID, email string
+	err = tx.QueryRowContext(ctx, r.dialect.rebind("SELECT user_id, email FROM identities WHERE issuer = ? AND subject_id = ?"),
+		appCtx.User.Issuer, appCtx.User.SubjectID).Scan(&userID, &email)
+	if err == sql.ErrNoRows {
+		log.Info("No user found, inserting")
+		userID = newID()
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind("INSERT INTO users (id) VALUES (?)"), userID); err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, r.dialect.rebind("INSERT INTO identities (user_id, issuer, subject_id, email) VALUES (?, ?, ?, ?)"),
+			userID, appCtx.User.Issuer, appCtx.User.SubjectID, appCtx.User.Email)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else if email != appCtx.User.Email {
+		log.Info("Email mismatch, updating")
+		_, err = tx.ExecContext(ctx, r.dialect.rebind("UPDATE identities SET email = ? WHERE issuer = ? AND subject_id = ?"),
+			appCtx.User.Email, appCtx.User.Issuer, appCtx.User.SubjectID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := r.getUser(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *SqlRepository) SetFeedToken(u *domain.User, token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	feedToken := sql.NullString{String: token, Valid: token != ""}
+	result, err := r.db.ExecContext(ctx, r.dialect.rebind("UPDATE users SET feed_token = ? WHERE id = ?"), feedToken, u.ID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return fmt.Errorf("User with id %s not found", u.ID)
+	}
+
+	return nil
+}
+
+func (r *SqlRepository) SetTimerPolicy(u *domain.User, policy string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, r.dialect.rebind("UPDATE users SET timer_policy = ? WHERE id = ?"), policy, u.ID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return fmt.Errorf("User with id %s not found", u.ID)
+	}
+
+	return nil
+}
+
+func (r *SqlRepository) GetEntryTypes(u *domain.User) ([]*domain.EntryType, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind("SELECT "+entryTypeColumns+" FROM entry_types WHERE user_id = ? ORDER BY name"), u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.EntryType
+	for rows.Next() {
+		entryType, err := scanEntryType(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entryType)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqlRepository) SaveEntryType(u *domain.User, t domain.EntryType) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM entry_types WHERE user_id = ? AND name = ?"), u.ID, t.Name)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("INSERT INTO entry_types (user_id, "+entryTypeColumns+") VALUES (?, ?, ?, ?, ?, ?)"),
+		u.ID, t.Name, t.TimeBased, t.CountsAsWork, t.FulfillsSchedule, t.Color)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SqlRepository) DeleteEntryType(u *domain.User, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, r.dialect.rebind("DELETE FROM entry_types WHERE user_id = ? AND name = ?"), u.ID, name)
+	return err
+}
+
+func (r *SqlRepository) GetSchedules(u *domain.User) ([]*domain.Schedule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind("SELECT "+scheduleColumns+" FROM schedules WHERE user_id = ? ORDER BY from_date"), u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.Schedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, schedule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqlRepository) SaveSchedule(u *domain.User, s domain.Schedule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	hours, err := json.Marshal(s.Hours)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM schedules WHERE user_id = ? AND from_date = ?"), u.ID, s.From)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("INSERT INTO schedules (user_id, "+scheduleColumns+") VALUES (?, ?, ?)"),
+		u.ID, s.From, string(hours))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SqlRepository) DeleteSchedule(u *domain.User, from string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, r.dialect.rebind("DELETE FROM schedules WHERE user_id = ? AND from_date = ?"), u.ID, from)
+	return err
+}
+
+func (r *SqlRepository) AddBalanceAdjustment(u *domain.User, a domain.BalanceAdjustment) (*domain.BalanceAdjustment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	a.ID = newID()
+	_, err := r.db.ExecContext(ctx, r.dialect.rebind("INSERT INTO balance_adjustments (user_id, "+balanceAdjustmentColumns+") VALUES (?, ?, ?, ?, ?)"),
+		u.ID, a.ID, a.Date, int64(a.Amount), a.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+func (r *SqlRepository) GetBalanceAdjustments(u *domain.User) ([]*domain.BalanceAdjustment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind("SELECT "+balanceAdjustmentColumns+" FROM balance_adjustments WHERE user_id = ? ORDER BY day, id"), u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.BalanceAdjustment
+	for rows.Next() {
+		adjustment, err := scanBalanceAdjustment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, adjustment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqlRepository) DeleteBalanceAdjustment(u *domain.User, adjustmentID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, r.dialect.rebind("DELETE FROM balance_adjustments WHERE id = ? AND user_id = ?"), adjustmentID, u.ID)
+	return err
+}
+
+func (r *SqlRepository) GetAllowances(u *domain.User) ([]*domain.Allowance, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind("SELECT "+allowanceColumns+" FROM allowances WHERE user_id = ? ORDER BY entry_type, from_year"), u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.Allowance
+	for rows.Next() {
+		allowance, err := scanAllowance(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, allowance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqlRepository) SaveAllowance(u *domain.User, a domain.Allowance) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM allowances WHERE user_id = ? AND entry_type = ? AND from_year = ?"), u.ID, a.Type, a.From)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("INSERT INTO allowances (user_id, "+allowanceColumns+") VALUES (?, ?, ?, ?, ?)"),
+		u.ID, a.Type, a.From, a.Days, a.CarryOver)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SqlRepository) DeleteAllowance(u *domain.User, entryType string, from int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, r.dialect.rebind("DELETE FROM allowances WHERE user_id = ? AND entry_type = ? AND from_year = ?"), u.ID, entryType, from)
+	return err
+}
+
+func (r *SqlRepository) GetHolidays(u *domain.User) ([]*domain.Holiday, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind("SELECT "+holidayColumns+" FROM holidays WHERE user_id = ? ORDER BY day"), u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.Holiday
+	for rows.Next() {
+		holiday, err := scanHoliday(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, holiday)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqlRepository) SaveHoliday(u *domain.User, h domain.Holiday) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM holidays WHERE user_id = ? AND day = ?"), u.ID, h.Date)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("INSERT INTO holidays (user_id, "+holidayColumns+") VALUES (?, ?, ?)"),
+		u.ID, h.Date, h.Name)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SqlRepository) DeleteHoliday(u *domain.User, date string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, r.dialect.rebind("DELETE FROM holidays WHERE user_id = ? AND day = ?"), u.ID, date)
+	return err
+}
+
+func (r *SqlRepository) GetUserByFeedToken(token string) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRowContext(ctx, r.dialect.rebind("SELECT id FROM users WHERE feed_token = ?"), token).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return r.getUser(ctx, tx, userID)
+}
+
+func (r *SqlRepository) AddClient(c domain.Client) (*domain.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	c.ID = newID()
+	values, err := clientValues(&c)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.db.ExecContext(ctx, r.dialect.rebind("INSERT INTO clients ("+clientColumns+") VALUES (?, ?, ?, ?, ?)"),
+		values...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (r *SqlRepository) UpdateClient(c domain.Client) (*domain.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	rates, err := encodeRates(c.Rates)
+	if err != nil {
+		return nil, err
+	}
+	result, err := r.db.ExecContext(ctx, r.dialect.rebind("UPDATE clients SET name = ?, description = ?, rates = ? WHERE id = ? AND user_id = ?"),
+		c.Name, c.Description, rates, c.ID, c.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected != 1 {
+		return nil, fmt.Errorf("Client with id %s not found", c.ID)
+	}
+
+	return &c, nil
+}
+
+func (r *SqlRepository) DeleteClient(c *domain.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, r.dialect.rebind("DELETE FROM clients WHERE id = ? AND user_id = ?"), c.ID, c.User.ID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return fmt.Errorf("Client with id %s not found", c.ID)
+	}
+
+	return nil
+}
+
+func (r *SqlRepository) GetClient(u *domain.User, clientName string) (*domain.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind("SELECT "+clientColumns+" FROM clients WHERE user_id = ? AND name = ?"),
+		u.ID, clientName)
+	client, err := scanClient(row, u)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (r *SqlRepository) GetClients(u *domain.User) ([]*domain.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind("SELECT "+clientColumns+" FROM clients WHERE user_id = ? ORDER BY name"), u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.Client
+	for rows.Next() {
+		client, err := scanClient(rows, u)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, client)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqlRepository) AddProject(p domain.Project) (*domain.Project, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	p.ID = newID()
+	values, err := projectValues(&p)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.db.ExecContext(ctx, r.dialect.rebind("INSERT INTO projects ("+projectColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		values...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (r *SqlRepository) UpdateProject(p domain.Project) (*domain.Project, error) {
+	return r.RenameProject(p, nil)
+}
+
+func (r *SqlRepository) RenameProject(p domain.Project, released []string) (*domain.Project, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := r.lockUser(ctx, tx, p.User.ID); err != nil {
+		return nil, err
+	}
+
+	rates, err := encodeRates(p.Rates)
+	if err != nil {
+		return nil, err
+	}
+	budget, err := encodeBudget(p.Budget)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := encodeAliases(p.Aliases)
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx, r.dialect.rebind("UPDATE projects SET name = ?, description = ?, billable = ?, client_id = ?, rates = ?, budget = ?, archived = ?, aliases = ? WHERE id = ? AND user_id = ?"),
+		p.Name, p.Description, p.Billable, p.ClientID, rates, budget, p.Archived, aliases, p.ID, p.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected != 1 {
+		return nil, fmt.Errorf("Project with id %s not found", p.ID)
+	}
+
+	if len(released) > 0 {
+		if err := r.releaseAliases(ctx, tx, &p, released); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// releaseAliases removes the released names from the aliases of the user's projects other
+// than p
+func (r *SqlRepository) releaseAliases(ctx context.Context, tx *sql.Tx, p *domain.Project, released []string) error {
+	rows, err := tx.QueryContext(ctx, r.dialect.rebind("SELECT id, aliases FROM projects WHERE user_id = ? AND id <> ? AND aliases <> ''"), p.User.ID, p.ID)
+	if err != nil {
+		return err
+	}
+	changed := make(map[string]string)
+	for rows.Next() {
+		var id, encoded string
+		if err := rows.Scan(&id, &encoded); err != nil {
+			rows.Close()
+			return err
+		}
+		aliases, err := decodeAliases(encoded)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		kept := withoutAliases(aliases, released)
+		if len(kept) == len(aliases) {
+			continue
+		}
+		if changed[id], err = encodeAliases(kept); err != nil {
+			rows.Close()
+			return err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, aliases := range changed {
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind("UPDATE projects SET aliases = ? WHERE id = ?"), aliases, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SqlRepository) DeleteProject(p *domain.Project) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM time_entry_tags WHERE entry_id IN (SELECT id FROM time_entries WHERE project_id = ? AND invoice_number = 0)"), p.ID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM time_entries WHERE project_id = ? AND invoice_number = 0"), p.ID)
+	if err != nil {
+		return err
+	}
+	// Entries that were invoiced since the caller checked must not be lost
+	var invoiced int
+	err = tx.QueryRowContext(ctx, r.dialect.rebind("SELECT COUNT(*) FROM time_entries WHERE project_id = ?"), p.ID).Scan(&invoiced)
+	if err != nil {
+		return err
+	}
+	if invoiced > 0 {
+		return domain.ErrEntryInvoiced
+	}
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM tasks WHERE project_id = ?"), p.ID)
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM projects WHERE id = ? AND user_id = ?"), p.ID, p.User.ID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return fmt.Errorf("Project with id %s not found", p.ID)
+	}
+
+	return tx.Commit()
+}
+
+func (r *SqlRepository) GetProject(u *domain.User, projectName string) (*domain.Project, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind("SELECT "+projectColumns+" FROM projects WHERE user_id = ? AND name = ?"),
+		u.ID, projectName)
+	project, err := scanProject(row, u)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return project, nil
+}
+
+func (r *SqlRepository) GetProjectByID(u *domain.User, projectID string) (*domain.Project, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind("SELECT "+projectColumns+" FROM projects WHERE user_id = ? AND id = ?"),
+		u.ID, projectID)
+	project, err := scanProject(row, u)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return project, nil
+}
+
+func (r *SqlRepository) GetProjects(u *domain.User, q domain.ProjectQuery) ([]*domain.Project, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	query := "SELECT " + projectColumns + " FROM projects WHERE user_id = ? AND name > ?"
+	args := []interface{}{u.ID, q.After}
+	if q.Archived != nil {
+		query += " AND archived = ?"
+		args = append(args, *q.Archived)
+	}
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind(query+" ORDER BY name"+limitClause(q.Limit)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.Project
+	for rows.Next() {
+		project, err := scanProject(rows, u)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, project)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqlRepository) AddTask(t domain.Task) (*domain.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	t.ID = newID()
+	_, err := r.db.ExecContext(ctx, r.dialect.rebind("INSERT INTO tasks ("+taskColumns+") VALUES (?, ?, ?, ?, ?)"),
+		taskValues(&t)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (r *SqlRepository) UpdateTask(t domain.Task) (*domain.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, r.dialect.rebind("UPDATE tasks SET name = ?, description = ? WHERE id = ? AND project_id = ?"),
+		t.Name, t.Description, t.ID, t.Project.ID)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected != 1 {
+		return nil, fmt.Errorf("Task with id %s not found", t.ID)
+	}
+
+	return &t, nil
+}
+
+func (r *SqlRepository) DeleteTask(t *domain.Task) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, r.dialect.rebind("DELETE FROM tasks WHERE id = ? AND project_id = ?"), t.ID, t.Project.ID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return fmt.Errorf("Task with id %s not found", t.ID)
+	}
+
+	return nil
+}
+
+func (r *SqlRepository) GetTask(p *domain.Project, taskName string) (*domain.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind("SELECT "+taskColumns+" FROM tasks WHERE project_id = ? AND name = ?"),
+		p.ID, taskName)
+	task, _, err := scanTask(row, p)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+func (r *SqlRepository) GetTasks(p *domain.Project) ([]*domain.Task, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind("SELECT "+taskColumns+" FROM tasks WHERE project_id = ? ORDER BY name"), p.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.Task
+	for rows.Next() {
+		task, _, err := scanTask(rows, p)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqlRepository) GetUserTasks(u *domain.User) ([]*domain.Task, error) {
+	projects, err := r.GetProjects(u, domain.ProjectQuery{})
+	if err != nil {
+		return nil, err
+	}
+	projectsByID := make(map[string]*domain.Project)
+	for _, project := range projects {
+		projectsByID[project.ID] = project
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind("SELECT "+taskColumns+" FROM tasks WHERE user_id = ?"), u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.Task
+	for rows.Next() {
+		task, projectID, err := scanTask(rows, nil)
+		if err != nil {
+			return nil, err
+		}
+		task.Project = projectsByID[projectID]
+		result = append(result, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqlRepository) AddTimeEntry(p *domain.Project, e domain.TimeEntry) (*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	e.Project = p
+	e.ID = newID()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := r.insertTimeEntry(ctx, tx, &e); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// insertTimeEntry stores a new entry and its tags
+func (r *SqlRepository) insertTimeEntry(ctx context.Context, tx *sql.Tx, e *domain.TimeEntry) error {
+	_, err := tx.ExecContext(ctx, r.dialect.rebind("INSERT INTO time_entries ("+timeEntryColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		timeEntryValues(e)...)
+	if err != nil {
+		return err
+	}
+	return r.insertTags(ctx, tx, e.ID, e.Project.User.ID, e.Tags)
+}
+
+// insertTags adds the tags of an entry to time_entry_tags, which queries by tag use
+func (r *SqlRepository) insertTags(ctx context.Context, tx *sql.Tx, entryID string, userID string, tags []string) error {
+	for _, tag := range tags {
+		_, err := tx.ExecContext(ctx, r.dialect.rebind("INSERT INTO time_entry_tags (entry_id, user_id, tag) VALUES (?, ?, ?)"), entryID, userID, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SqlRepository) AddTimeEntries(u *domain.User, entries []domain.TimeEntry) ([]*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, r.dialect.rebind("INSERT INTO time_entries ("+timeEntryColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var result []*domain.TimeEntry
+	for _, e := range entries {
+		e := e
+		e.ID = newID()
+		if _, err := stmt.ExecContext(ctx, timeEntryValues(&e)...); err != nil {
+			return nil, err
+		}
+		if err := r.insertTags(ctx, tx, e.ID, u.ID, e.Tags); err != nil {
+			return nil, err
+		}
+		result = append(result, &e)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *SqlRepository) UpdateTimeEntry(p *domain.Project, e domain.TimeEntry) (*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	e.Project = p
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, r.dialect.rebind("UPDATE time_entries SET project_id = ?, task_id = ?, type = ?, start_time = ?, end_time = ?, breaks = ?, comment = ?, tags = ? WHERE id = ? AND user_id = ? AND invoice_number = 0"),
+		p.ID, e.TaskID, e.Type, utcTime(e.Start), utcTime(e.End), int64(e.Breaks), e.Comment, encodeTags(e.Tags), e.ID, p.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected != 1 {
+		return nil, r.entryNotChanged(ctx, p.User, e.ID)
+	}
+	if _, err := tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM time_entry_tags WHERE entry_id = ?"), e.ID); err != nil {
+		return nil, err
+	}
+	if err := r.insertTags(ctx, tx, e.ID, p.User.ID, e.Tags); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *SqlRepository) DeleteTimeEntry(p *domain.Project, entryID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The tags go first because they refer to the entry. If the entry cannot be deleted,
+	// the transaction is rolled back and they are kept.
+	if _, err := tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM time_entry_tags WHERE entry_id = ?"), entryID); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM time_entries WHERE id = ? AND project_id = ? AND invoice_number = 0"), entryID, p.ID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return r.entryNotChanged(ctx, p.User, entryID)
+	}
+
+	return tx.Commit()
+}
+
+// entryNotChanged explains why an update or delete of an entry matched no row: either the
+// entry has been invoiced in the meantime, or it does not exist
+func (r *SqlRepository) entryNotChanged(ctx context.Context, u *domain.User, entryID string) error {
+	var invoiceNumber int
+	err := r.db.QueryRowContext(ctx, r.dialect.rebind("SELECT invoice_number FROM time_entries WHERE id = ? AND user_id = ?"), entryID, u.ID).Scan(&invoiceNumber)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && invoiceNumber != 0 {
+		return domain.ErrEntryInvoiced
+	}
+	return fmt.Errorf("Time entry with id %s not found", entryID)
+}
+
+func (r *SqlRepository) GetProjectTimeEntries(p *domain.Project, q domain.EntryQuery) ([]*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	conditions, args := entryConditions([]string{"project_id = ?"}, []interface{}{p.ID}, p.User.ID, q)
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind("SELECT "+timeEntryColumns+" FROM time_entries WHERE "+strings.Join(conditions, " AND ")+" ORDER BY start_time, id"+limitClause(q.Limit)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.TimeEntry
+	for rows.Next() {
+		entry, _, err := scanTimeEntry(rows, p)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqlRepository) GetProjectTimeEntry(p *domain.Project, entryID string) (*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind("SELECT "+timeEntryColumns+" FROM time_entries WHERE id = ? AND project_id = ?"),
+		entryID, p.ID)
+	entry, _, err := scanTimeEntry(row, p)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (r *SqlRepository) GetUserTimeEntries(u *domain.User, q domain.EntryQuery) ([]*domain.TimeEntry, error) {
+	projects, err := r.GetProjects(u, domain.ProjectQuery{})
+	if err != nil {
+		return nil, err
+	}
+	projectsByID := make(map[string]*domain.Project)
+	for _, project := range projects {
+		projectsByID[project.ID] = project
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	conditions, args := entryConditions([]string{"user_id = ?"}, []interface{}{u.ID}, u.ID, q)
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind("SELECT "+timeEntryColumns+" FROM time_entries WHERE "+strings.Join(conditions, " AND ")+" ORDER BY start_time, id"+limitClause(q.Limit)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.TimeEntry
+	for rows.Next() {
+		entry, projectID, err := scanTimeEntry(rows, nil)
+		if err != nil {
+			return nil, err
+		}
+		entry.Project = projectsByID[projectID]
+		result = append(result, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SqlRepository) GetTimeEntry(u *domain.User, entryID string) (*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind("SELECT "+timeEntryColumns+" FROM time_entries WHERE id = ? AND user_id = ?"),
+		entryID, u.ID)
+	entry, projectID, err := scanTimeEntry(row, nil)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	row = r.db.QueryRowContext(ctx, r.dialect.rebind("SELECT "+projectColumns+" FROM projects WHERE id = ?"), projectID)
+	if entry.Project, err = scanProject(row, u); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *SqlRepository) AddInvoice(inv domain.Invoice, entryIDs []string) (*domain.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := r.lockUser(ctx, tx, inv.User.ID); err != nil {
+		return nil, err
+	}
+
+	var last sql.NullInt64
+	err = tx.QueryRowContext(ctx, r.dialect.rebind("SELECT MAX(number) FROM invoices WHERE user_id = ?"), inv.User.ID).Scan(&last)
+	if err != nil {
+		return nil, err
+	}
+	inv.ID = newID()
+	inv.Number = int(last.Int64) + 1
+
+	values, err := invoiceValues(&inv)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("INSERT INTO invoices ("+invoiceColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"), values...)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, r.dialect.rebind("UPDATE time_entries SET invoice_number = ? WHERE id = ? AND user_id = ? AND invoice_number = 0"))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	for _, entryID := range entryIDs {
+		result, err := stmt.ExecContext(ctx, inv.Number, entryID, inv.User.ID)
+		if err != nil {
+			return nil, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if affected != 1 {
+			return nil, domain.ErrEntryInvoiced
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (r *SqlRepository) GetInvoice(u *domain.User, number int) (*domain.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	row := r.db.QueryRowContext(ctx, r.dialect.rebind("SELECT "+invoiceColumns+" FROM invoices WHERE user_id = ? AND number = ?"),
+		u.ID, number)
+	invoice, err := scanInvoice(row, u)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+func (r *SqlRepository) GetInvoices(u *domain.User) ([]*domain.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind("SELECT "+invoiceColumns+" FROM invoices WHERE user_id = ? ORDER BY number"), u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*domain.Invoice
+	for rows.Next() {
+		invoice, err := scanInvoice(rows, u)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, invoice)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// lockUser serializes changes to the user's timers and invoices. Updating the user row takes a write
+// lock on it until the transaction ends, in every supported database.
+func (r *SqlRepository) lockUser(ctx context.Context, tx *sql.Tx, userID string) error {
+	_, err := tx.ExecContext(ctx, r.dialect.rebind("UPDATE users SET id = id WHERE id = ?"), userID)
+	return err
+}
+
+func (r *SqlRepository) StartTimer(p *domain.Project, e domain.TimeEntry, stopRunning bool) (*domain.TimeEntry, []*domain.TimeEntry, error) {
+	projects, err := r.GetProjects(p.User, domain.ProjectQuery{})
+	if err != nil {
+		return nil, nil, err
+	}
+	projectsByID := make(map[string]*domain.Project)
+	for _, project := range projects {
+		projectsByID[project.ID] = project
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if err := r.lockUser(ctx, tx, p.User.ID); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, r.dialect.rebind("SELECT "+timeEntryColumns+" FROM time_entries WHERE user_id = ? AND end_time IS NULL"), p.User.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var stopped []*domain.TimeEntry
+	for rows.Next() {
+		entry, projectID, err := scanTimeEntry(rows, nil)
+		if err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		entry.Project = projectsByID[projectID]
+		stopped = append(stopped, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for _, entry := range stopped {
+		if !stopRunning || (entry.Start != nil && e.Start.Before(*entry.Start)) {
+			return nil, nil, domain.ErrTimerRunning
+		}
+		end := *e.Start
+		entry.End = &end
+		_, err := tx.ExecContext(ctx, r.dialect.rebind("UPDATE time_entries SET end_time = ? WHERE id = ?"), utcTime(entry.End), entry.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	e.Project = p
+	e.ID = newID()
+	if err := r.insertTimeEntry(ctx, tx, &e); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return &e, stopped, nil
+}
+
+func (r *SqlRepository) StopTimer(u *domain.User, p *domain.Project, end time.Time, breaks *time.Duration, validate func(*domain.TimeEntry) error) (*domain.TimeEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := r.lockUser(ctx, tx, u.ID); err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"user_id = ?", "end_time IS NULL"}
+	args := []interface{}{u.ID}
+	if p != nil {
+		conditions = append(conditions, "project_id = ?")
+		args = append(args, p.ID)
+	}
+	row := tx.QueryRowContext(ctx, r.dialect.rebind("SELECT "+timeEntryColumns+" FROM time_entries WHERE "+strings.Join(conditions, " AND ")+" ORDER BY start_time DESC, id DESC LIMIT 1"), args...)
+	entry, projectID, err := scanTimeEntry(row, p)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if entry.Project == nil {
+		row := tx.QueryRowContext(ctx, r.dialect.rebind("SELECT "+projectColumns+" FROM projects WHERE id = ?"), projectID)
+		if entry.Project, err = scanProject(row, u); err != nil {
+			return nil, err
+		}
+	}
+
+	entry.End = &end
+	if breaks != nil {
+		entry.Breaks = *breaks
+	}
+	if err := validate(entry); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, r.dialect.rebind("UPDATE time_entries SET end_time = ?, breaks = ? WHERE id = ?"),
+		utcTime(entry.End), int64(entry.Breaks), entry.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,931 @@
+package sql_repository
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kennep/timelapse/domain"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openTestRepository(t *testing.T) (*SqlRepository, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "timelapse-sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsn := "file:" + filepath.Join(dir, "timelapse.db") + "?_foreign_keys=on"
+
+	repository, err := NewSqlRepository("sqlite3", dsn)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	// Opening the database a second time must not re-apply migrations
+	second, err := NewSqlRepository("sqlite3", dsn)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	second.Close()
+
+	return repository, func() {
+		repository.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func contextFor(subjectID string, email string) *domain.ApplicationContext {
+	return &domain.ApplicationContext{
+		User: domain.UserContext{
+			SubjectID: subjectID,
+			Issuer:    "https://issuer.example.com",
+			Email:     email,
+		},
+	}
+}
+
+func TestUserLookup(t *testing.T) {
+	repository, cleanup := openTestRepository(t)
+	defer cleanup()
+
+	first, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.org"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != second.ID {
+		t.Errorf("Expected the same user for the same identity, got %s and %s", first.ID, second.ID)
+	}
+	if len(second.Identities) != 1 || second.Identities[0].Email != "alice@example.org" {
+		t.Errorf("Expected email to be updated, got %v", second.Identities)
+	}
+
+	other, err := repository.CreateUserFromContext(contextFor("bob", "bob@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ID == first.ID {
+		t.Errorf("Expected different users for different identities")
+	}
+}
+
+func TestProjects(t *testing.T) {
+	repository, cleanup := openTestRepository(t)
+	defer cleanup()
+
+	user, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	project, err := repository.AddProject(domain.Project{User: user, Name: "acme", Billable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.AddProject(domain.Project{User: user, Name: "acme"}); err == nil {
+		t.Errorf("Expected unique constraint on user and project name")
+	}
+
+	project.Description = "Acme Inc."
+	if _, err := repository.UpdateProject(*project); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := repository.GetProject(user, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.ID != project.ID || found.Description != "Acme Inc." || !found.Billable {
+		t.Errorf("Unexpected project: %v", found)
+	}
+
+	missing, err := repository.GetProject(user, "unknown")
+	if err != nil || missing != nil {
+		t.Errorf("Expected no project and no error, got %v, %v", missing, err)
+	}
+
+	found.Budget = &domain.Budget{Hours: 10 * time.Hour, Amount: 500, Currency: "EUR", Period: domain.BudgetMonthly, Thresholds: []int{50, 90}}
+	if _, err := repository.UpdateProject(*found); err != nil {
+		t.Fatal(err)
+	}
+	found, err = repository.GetProject(user, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Budget == nil || found.Budget.Hours != 10*time.Hour || found.Budget.Currency != "EUR" || found.Budget.Period != domain.BudgetMonthly || len(found.Budget.Thresholds) != 2 {
+		t.Errorf("Expected the budget to be stored, got %v", found.Budget)
+	}
+
+	found.Budget = nil
+	if _, err := repository.UpdateProject(*found); err != nil {
+		t.Fatal(err)
+	}
+	found, err = repository.GetProject(user, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Budget != nil {
+		t.Errorf("Expected the budget to be removed, got %v", found.Budget)
+	}
+
+	found.Archived = true
+	if _, err := repository.UpdateProject(*found); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.AddProject(domain.Project{User: user, Name: "beta"}); err != nil {
+		t.Fatal(err)
+	}
+	active := false
+	projects, err := repository.GetProjects(user, domain.ProjectQuery{Archived: &active})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 1 || projects[0].Name != "beta" {
+		t.Errorf("Expected only the active project, got %v", projects)
+	}
+	projects, err = repository.GetProjects(user, domain.ProjectQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 2 || !projects[0].Archived {
+		t.Errorf("Expected all projects, got %v", projects)
+	}
+
+	found.Aliases = []string{"acme-old", "acme-older"}
+	if _, err := repository.UpdateProject(*found); err != nil {
+		t.Fatal(err)
+	}
+	byID, err := repository.GetProjectByID(user, project.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byID == nil || byID.Name != "acme" || len(byID.Aliases) != 2 || byID.Aliases[1] != "acme-older" {
+		t.Errorf("Expected the project with its aliases by ID, got %v", byID)
+	}
+	if missing, err := repository.GetProjectByID(user, "unknown"); err != nil || missing != nil {
+		t.Errorf("Expected no project and no error for an unknown ID, got %v, %v", missing, err)
+	}
+
+	beta, err := repository.GetProject(user, "beta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	beta.Name = "beta-new"
+	beta.Aliases = []string{"beta"}
+	if _, err := repository.RenameProject(*beta, []string{"beta", "acme-old"}); err != nil {
+		t.Fatal(err)
+	}
+	released, err := repository.GetProjectByID(user, project.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(released.Aliases) != 1 || released.Aliases[0] != "acme-older" {
+		t.Errorf("Expected the released alias to be removed from the other project, got %v", released)
+	}
+	if beta, err = repository.GetProjectByID(user, beta.ID); err != nil || beta.Name != "beta-new" || len(beta.Aliases) != 1 {
+		t.Errorf("Expected the renamed project to keep its aliases, got %v, %v", beta, err)
+	}
+}
+
+func TestTimeEntries(t *testing.T) {
+	repository, cleanup := openTestRepository(t)
+	defer cleanup()
+
+	alice, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := repository.CreateUserFromContext(contextFor("bob", "bob@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := repository.AddProject(domain.Project{User: alice, Name: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	if err != nil {
+		oslo = time.FixedZone("CET", 3600)
+	}
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, oslo)
+	entry, err := repository.AddTimeEntry(project, domain.TimeEntry{Type: "work", Start: &start, Comment: "planning"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	end := start.Add(8 * time.Hour)
+	entry.End = &end
+	entry.Breaks = 30 * time.Minute
+	if _, err := repository.UpdateTimeEntry(project, *entry); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := repository.GetProjectTimeEntry(project, entry.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || !found.Start.Equal(start) || found.End == nil || !found.End.Equal(end) || found.Breaks != 30*time.Minute {
+		t.Errorf("Unexpected time entry: %v", found)
+	}
+
+	entries, err := repository.GetUserTimeEntries(alice, domain.EntryQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Project == nil || entries[0].Project.Name != "acme" {
+		t.Errorf("Unexpected user time entries: %v", entries)
+	}
+
+	later := start.Add(time.Hour)
+	for _, q := range []domain.EntryQuery{{Type: "vacation"}, {OpenOnly: true}, {From: &later}, {To: &start}} {
+		entries, err = repository.GetProjectTimeEntries(project, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("Expected no entries matching %v, got %v", q, entries)
+		}
+	}
+	entries, err = repository.GetProjectTimeEntries(project, domain.EntryQuery{From: &start, To: &later, Type: "work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected entry to match its own start time, got %v", entries)
+	}
+
+	vacation := start.Add(24 * time.Hour)
+	added, err := repository.AddTimeEntries(alice, []domain.TimeEntry{
+		{Project: project, Type: "vacation", Start: &vacation, End: &vacation},
+		{Project: project, Type: "sick", Start: &vacation, End: &vacation},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 2 || added[0].ID == "" || added[0].ID == added[1].ID {
+		t.Errorf("Unexpected added entries: %v", added)
+	}
+	entries, err = repository.GetProjectTimeEntries(project, domain.EntryQuery{From: &vacation})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 entries added in batch, got %v", entries)
+	}
+
+	entries, err = repository.GetUserTimeEntries(bob, domain.EntryQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected entries to be scoped to their user, got %v", entries)
+	}
+}
+
+func TestFeedToken(t *testing.T) {
+	repository, cleanup := openTestRepository(t)
+	defer cleanup()
+
+	user, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.SetFeedToken(user, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := repository.GetUserByFeedToken("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.ID != user.ID || found.FeedToken != "secret" || len(found.Identities) != 1 {
+		t.Errorf("Unexpected user for feed token: %v", found)
+	}
+
+	if err := repository.SetFeedToken(user, ""); err != nil {
+		t.Fatal(err)
+	}
+	found, err = repository.GetUserByFeedToken("secret")
+	if err != nil || found != nil {
+		t.Errorf("Expected no user for revoked token, got %v, %v", found, err)
+	}
+}
+
+func TestTimer(t *testing.T) {
+	repository, cleanup := openTestRepository(t)
+	defer cleanup()
+
+	user, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme, err := repository.AddProject(domain.Project{User: user, Name: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	internal, err := repository.AddProject(domain.Project{User: user, Name: "internal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	first, _, err := repository.StartTimer(acme, domain.TimeEntry{Type: "work", Start: &start}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	later := start.Add(2 * time.Hour)
+	if _, _, err := repository.StartTimer(internal, domain.TimeEntry{Type: "work", Start: &later}, false); err != domain.ErrTimerRunning {
+		t.Errorf("Expected ErrTimerRunning, got %v", err)
+	}
+	second, stopped, err := repository.StartTimer(internal, domain.TimeEntry{Type: "work", Start: &later}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stopped) != 1 || stopped[0].ID != first.ID || !stopped[0].End.Equal(later) || stopped[0].Project.Name != "acme" {
+		t.Errorf("Expected the first timer to be stopped, got %v", stopped)
+	}
+
+	if err := repository.SetTimerPolicy(user, domain.TimerPolicyAutoStop); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.TimerPolicy != domain.TimerPolicyAutoStop {
+		t.Errorf("Expected timer policy to be stored, got %q", reloaded.TimerPolicy)
+	}
+
+	end := later.Add(time.Hour)
+	accept := func(*domain.TimeEntry) error { return nil }
+	rejected := errors.New("rejected")
+	found, err := repository.StopTimer(user, nil, end, nil, func(*domain.TimeEntry) error { return rejected })
+	if err != rejected || found != nil {
+		t.Errorf("Expected the validation error, got %v, %v", found, err)
+	}
+	found, err = repository.StopTimer(user, acme, end, nil, accept)
+	if err != nil || found != nil {
+		t.Errorf("Expected no running timer for acme, got %v, %v", found, err)
+	}
+	breaks := 15 * time.Minute
+	found, err = repository.StopTimer(user, nil, end, &breaks, accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.ID != second.ID || !found.End.Equal(end) || found.Breaks != breaks || found.Project.Name != "internal" {
+		t.Errorf("Unexpected stopped timer: %v", found)
+	}
+	found, err = repository.StopTimer(user, nil, end, nil, accept)
+	if err != nil || found != nil {
+		t.Errorf("Expected no running timer, got %v, %v", found, err)
+	}
+}
+
+func TestEntryTypes(t *testing.T) {
+	repository, cleanup := openTestRepository(t)
+	defer cleanup()
+
+	user, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repository.SaveEntryType(user, domain.EntryType{Name: "on-call", TimeBased: true, Color: "#9c27b0"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.SaveEntryType(user, domain.EntryType{Name: "on-call", TimeBased: true, CountsAsWork: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.SaveEntryType(user, domain.EntryType{Name: "training", FulfillsSchedule: true}); err != nil {
+		t.Fatal(err)
+	}
+	types, err := repository.GetEntryTypes(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 2 || types[0].Name != "on-call" || !types[0].CountsAsWork || types[0].Color != "" || types[1].Name != "training" || !types[1].FulfillsSchedule {
+		t.Errorf("Expected the saved types in name order, got %v", types)
+	}
+
+	if err := repository.DeleteEntryType(user, "on-call"); err != nil {
+		t.Fatal(err)
+	}
+	types, err = repository.GetEntryTypes(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 1 || types[0].Name != "training" {
+		t.Errorf("Expected on-call to be deleted, got %v", types)
+	}
+}
+
+func TestTags(t *testing.T) {
+	repository, cleanup := openTestRepository(t)
+	defer cleanup()
+
+	user, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := repository.AddProject(domain.Project{User: user, Name: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	tagged, err := repository.AddTimeEntry(project, domain.TimeEntry{Type: "work", Start: &start, Tags: []string{"bug_1", "meeting"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.AddTimeEntry(project, domain.TimeEntry{Type: "work", Start: &start, Tags: []string{"bugx1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.AddTimeEntry(project, domain.TimeEntry{Type: "work", Start: &start}); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := repository.GetProjectTimeEntry(project, tagged.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || strings.Join(found.Tags, ",") != "bug_1,meeting" {
+		t.Errorf("Expected the tags to be stored, got %v", found)
+	}
+
+	for _, c := range []struct {
+		query    domain.EntryQuery
+		expected int
+	}{
+		{domain.EntryQuery{Tags: []string{"bug_1"}}, 1},
+		{domain.EntryQuery{Tags: []string{"meeting", "bugx1"}}, 0},
+		{domain.EntryQuery{Tags: []string{"meeting", "bugx1"}, AnyTag: true}, 2},
+		{domain.EntryQuery{Tags: []string{"meet"}}, 0},
+	} {
+		entries, err := repository.GetUserTimeEntries(user, c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != c.expected {
+			t.Errorf("Expected %d entries matching %v, got %v", c.expected, c.query, entries)
+		}
+	}
+
+	// Updates and deletes keep the tag index in step
+	tagged.Tags = []string{"review"}
+	if _, err := repository.UpdateTimeEntry(project, *tagged); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := repository.GetProjectTimeEntries(project, domain.EntryQuery{Tags: []string{"meeting"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected the old tags to be gone, got %v", entries)
+	}
+	entries, err = repository.GetProjectTimeEntries(project, domain.EntryQuery{Tags: []string{"review"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected the new tag to match, got %v", entries)
+	}
+	if err := repository.DeleteTimeEntry(project, tagged.ID); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = repository.GetProjectTimeEntries(project, domain.EntryQuery{Tags: []string{"review"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected the deleted entry not to match, got %v", entries)
+	}
+}
+
+func TestIndexExistingTags(t *testing.T) {
+	repository, cleanup := openTestRepository(t)
+	defer cleanup()
+
+	user, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := repository.AddProject(domain.Project{User: user, Name: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An entry tagged before the tag index existed
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	entry := domain.TimeEntry{ID: newID(), Project: project, Type: "work", Start: &start, Tags: []string{"meeting"}}
+	_, err = repository.db.Exec(repository.dialect.rebind("INSERT INTO time_entries ("+timeEntryColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"), timeEntryValues(&entry)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := repository.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := indexExistingTags(repository, tx); err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := repository.GetUserTimeEntries(user, domain.EntryQuery{Tags: []string{"meeting"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != entry.ID {
+		t.Errorf("Expected the existing entry to be found by its tag, got %v", entries)
+	}
+}
+
+func TestClients(t *testing.T) {
+	repository, cleanup := openTestRepository(t)
+	defer cleanup()
+
+	user, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := repository.AddClient(domain.Client{User: user, Name: "acme", Rates: []domain.Rate{{Amount: 100, Currency: "EUR"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Rates = append(client.Rates, domain.Rate{EntryType: "work", Amount: 120.5, Currency: "EUR", From: "2019-02-01"})
+	if _, err := repository.UpdateClient(*client); err != nil {
+		t.Fatal(err)
+	}
+	found, err := repository.GetClient(user, "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || len(found.Rates) != 2 || found.Rates[1] != client.Rates[1] {
+		t.Errorf("Expected the client with its rates, got %v", found)
+	}
+
+	project, err := repository.AddProject(domain.Project{User: user, Name: "web", ClientID: client.ID, Rates: []domain.Rate{{Amount: 90, Currency: "USD"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects, err := repository.GetProjects(user, domain.ProjectQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 1 || projects[0].ClientID != client.ID || len(projects[0].Rates) != 1 || projects[0].Rates[0].Currency != "USD" {
+		t.Errorf("Expected the project with its client and rates, got %v", projects)
+	}
+	project.ClientID = ""
+	project.Rates = nil
+	if _, err := repository.UpdateProject(*project); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repository.DeleteClient(client); err != nil {
+		t.Fatal(err)
+	}
+	clients, err := repository.GetClients(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 0 {
+		t.Errorf("Expected the client to be deleted, got %v", clients)
+	}
+}
+
+func TestInvoices(t *testing.T) {
+	repository, cleanup := openTestRepository(t)
+	defer cleanup()
+
+	user, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := repository.AddProject(domain.Project{User: user, Name: "web", Billable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for day := 7; day <= 9; day++ {
+		start := time.Date(2019, 1, day, 8, 0, 0, 0, time.UTC)
+		end := start.Add(2 * time.Hour)
+		entry, err := repository.AddTimeEntry(project, domain.TimeEntry{Project: project, Type: "work", Start: &start, End: &end})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, entry.ID)
+	}
+
+	from := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	lines := []domain.InvoiceLine{{Project: "web", Description: "Web shop", Hours: 4 * time.Hour, Rate: 100, Currency: "EUR", Amount: 400}}
+	invoice, err := repository.AddInvoice(domain.Invoice{User: user, Issued: from, Client: "acme", From: &from, Lines: lines}, ids[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Number != 1 {
+		t.Errorf("Expected the first invoice to be number 1, got %d", invoice.Number)
+	}
+
+	_, err = repository.AddInvoice(domain.Invoice{User: user, Issued: from, Lines: lines}, ids[1:])
+	if err != domain.ErrEntryInvoiced {
+		t.Errorf("Expected ErrEntryInvoiced when invoicing an entry twice, got %v", err)
+	}
+	entry, err := repository.GetTimeEntry(user, ids[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil || entry.InvoiceNumber != 0 || entry.Project.Name != "web" {
+		t.Errorf("Expected the failed invoice to leave the entry uninvoiced, got %v", entry)
+	}
+
+	second, err := repository.AddInvoice(domain.Invoice{User: user, Issued: from, Lines: lines}, ids[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Number != 2 {
+		t.Errorf("Expected the failed invoice not to use up a number, got %d", second.Number)
+	}
+	entry, err = repository.GetTimeEntry(user, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.InvoiceNumber != 1 {
+		t.Errorf("Expected the entry to be marked with invoice 1, got %d", entry.InvoiceNumber)
+	}
+
+	// The repository itself refuses to change invoiced entries, so that a change racing
+	// with an invoice cannot undo it
+	entry.Comment = "changed"
+	if _, err := repository.UpdateTimeEntry(project, *entry); err != domain.ErrEntryInvoiced {
+		t.Errorf("Expected ErrEntryInvoiced when updating an invoiced entry, got %v", err)
+	}
+	if err := repository.DeleteTimeEntry(project, ids[0]); err != domain.ErrEntryInvoiced {
+		t.Errorf("Expected ErrEntryInvoiced when deleting an invoiced entry, got %v", err)
+	}
+	if err := repository.DeleteTimeEntry(project, "unknown"); err == nil || err == domain.ErrEntryInvoiced {
+		t.Errorf("Expected a not found error when deleting an unknown entry, got %v", err)
+	}
+	entry, err = repository.GetTimeEntry(user, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil || entry.InvoiceNumber != 1 || entry.Comment == "changed" {
+		t.Errorf("Expected the invoiced entry to be unchanged, got %v", entry)
+	}
+	uninvoicedStart := time.Date(2019, 1, 10, 8, 0, 0, 0, time.UTC)
+	if _, err := repository.AddTimeEntry(project, domain.TimeEntry{Project: project, Type: "work", Start: &uninvoicedStart}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.DeleteProject(project); err != domain.ErrEntryInvoiced {
+		t.Errorf("Expected ErrEntryInvoiced when deleting a project with invoiced entries, got %v", err)
+	}
+	entries, err := repository.GetProjectTimeEntries(project, domain.EntryQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("Expected the failed delete to keep every entry, got %v", entries)
+	}
+
+	found, err := repository.GetInvoice(user, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.Client != "acme" || found.From == nil || !found.From.Equal(from) || found.To != nil ||
+		len(found.Lines) != 1 || found.Lines[0] != lines[0] {
+		t.Errorf("Expected invoice 1 as stored, got %v", found)
+	}
+	invoices, err := repository.GetInvoices(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) != 2 || invoices[0].Number != 1 || invoices[1].Number != 2 {
+		t.Errorf("Expected both invoices in order, got %v", invoices)
+	}
+}
+
+func TestTasks(t *testing.T) {
+	repository, cleanup := openTestRepository(t)
+	defer cleanup()
+
+	user, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := repository.AddProject(domain.Project{User: user, Name: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	design, err := repository.AddTask(domain.Task{Project: project, Name: "design"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.AddTask(domain.Task{Project: project, Name: "build", Description: "Building it"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.AddTask(domain.Task{Project: project, Name: "design"}); err == nil {
+		t.Errorf("Expected an error for a duplicate task name")
+	}
+
+	tasks, err := repository.GetTasks(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[0].Name != "build" || tasks[0].Description != "Building it" || tasks[1].ID != design.ID {
+		t.Errorf("Expected the tasks ordered by name, got %v", tasks)
+	}
+
+	design.Name = "ux"
+	if _, err := repository.UpdateTask(*design); err != nil {
+		t.Fatal(err)
+	}
+	found, err := repository.GetTask(project, "ux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.ID != design.ID || found.Project != project {
+		t.Errorf("Expected the renamed task, got %v", found)
+	}
+
+	start := time.Date(2019, 1, 7, 8, 0, 0, 0, time.UTC)
+	entry, err := repository.AddTimeEntry(project, domain.TimeEntry{Type: "work", Start: &start, TaskID: design.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.AddTimeEntry(project, domain.TimeEntry{Type: "work", Start: &start}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := repository.GetProjectTimeEntries(project, domain.EntryQuery{TaskID: design.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != entry.ID || entries[0].TaskID != design.ID {
+		t.Errorf("Expected only the entry of the task, got %v", entries)
+	}
+
+	userTasks, err := repository.GetUserTasks(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userTasks) != 2 || userTasks[0].Project == nil || userTasks[0].Project.Name != "acme" {
+		t.Errorf("Expected the user's tasks with their project, got %v", userTasks)
+	}
+
+	if err := repository.DeleteProject(project); err != nil {
+		t.Fatal(err)
+	}
+	userTasks, err = repository.GetUserTasks(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userTasks) != 0 {
+		t.Errorf("Expected the tasks to be deleted with the project, got %v", userTasks)
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	repository, cleanup := openTestRepository(t)
+	defer cleanup()
+
+	user, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := 8 * time.Hour
+	if err := repository.SaveSchedule(user, domain.Schedule{From: "2019-06-01", Hours: []time.Duration{day, day, day, day, 0, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.SaveSchedule(user, domain.Schedule{From: "2019-01-01", Hours: []time.Duration{0, 0, 0, 0, 0, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.SaveSchedule(user, domain.Schedule{From: "2019-01-01", Hours: []time.Duration{day, day, day, day, day, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	schedules, err := repository.GetSchedules(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 2 || schedules[0].From != "2019-01-01" || schedules[0].Hours[4] != day || schedules[1].Hours[4] != 0 {
+		t.Errorf("Expected the saved schedules in date order, got %v", schedules)
+	}
+	if err := repository.DeleteSchedule(user, "2019-06-01"); err != nil {
+		t.Fatal(err)
+	}
+	schedules, err = repository.GetSchedules(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 1 {
+		t.Errorf("Expected one schedule to be left, got %v", schedules)
+	}
+
+	added, err := repository.AddBalanceAdjustment(user, domain.BalanceAdjustment{Date: "2019-03-01", Amount: -2 * time.Hour, Comment: "paid out"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.AddBalanceAdjustment(user, domain.BalanceAdjustment{Date: "2019-01-01", Amount: 5 * time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	adjustments, err := repository.GetBalanceAdjustments(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adjustments) != 2 || adjustments[0].Date != "2019-01-01" || adjustments[1].ID != added.ID || adjustments[1].Amount != -2*time.Hour || adjustments[1].Comment != "paid out" {
+		t.Errorf("Expected the adjustments in date order, got %v", adjustments)
+	}
+	if err := repository.DeleteBalanceAdjustment(user, added.ID); err != nil {
+		t.Fatal(err)
+	}
+	adjustments, err = repository.GetBalanceAdjustments(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adjustments) != 1 {
+		t.Errorf("Expected one adjustment to be left, got %v", adjustments)
+	}
+}
+
+func TestAllowances(t *testing.T) {
+	repository, cleanup := openTestRepository(t)
+	defer cleanup()
+
+	user, err := repository.CreateUserFromContext(contextFor("alice", "alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range []domain.Allowance{
+		{Type: "vacation", From: 2020, Days: 25, CarryOver: 5},
+		{Type: "vacation", From: 2019, Days: 20},
+		{Type: "sick-child", From: 2019, Days: 10},
+		{Type: "vacation", From: 2019, Days: 22, CarryOver: 3},
+	} {
+		if err := repository.SaveAllowance(user, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	allowances, err := repository.GetAllowances(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allowances) != 3 || allowances[0].Type != "sick-child" || allowances[1].From != 2019 || allowances[1].Days != 22 || allowances[1].CarryOver != 3 || allowances[2].From != 2020 {
+		t.Errorf("Expected the allowances ordered by type and year, got %v", allowances)
+	}
+	if err := repository.DeleteAllowance(user, "vacation", 2020); err != nil {
+		t.Fatal(err)
+	}
+	allowances, err = repository.GetAllowances(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allowances) != 2 {
+		t.Errorf("Expected two allowances to be left, got %v", allowances)
+	}
+
+	if err := repository.SaveHoliday(user, domain.Holiday{Date: "2019-12-25", Name: "Christmas"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.SaveHoliday(user, domain.Holiday{Date: "2019-01-01", Name: "New Year"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.SaveHoliday(user, domain.Holiday{Date: "2019-12-25", Name: "Christmas Day"}); err != nil {
+		t.Fatal(err)
+	}
+	holidays, err := repository.GetHolidays(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holidays) != 2 || holidays[0].Date != "2019-01-01" || holidays[1].Name != "Christmas Day" {
+		t.Errorf("Expected the holidays in date order, got %v", holidays)
+	}
+	if err := repository.DeleteHoliday(user, "2019-01-01"); err != nil {
+		t.Fatal(err)
+	}
+	holidays, err = repository.GetHolidays(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holidays) != 1 {
+		t.Errorf("Expected one holiday to be left, got %v", holidays)
+	}
+}